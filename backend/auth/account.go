@@ -0,0 +1,325 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrPasswordIncorrect is returned when the current password given to
+	// confirm a change does not match
+	ErrPasswordIncorrect = errors.New("current password is incorrect")
+	// ErrPasswordAlreadySet is returned when setting a first password on an
+	// account that already has one
+	ErrPasswordAlreadySet = errors.New("password is already set")
+	// ErrEmailTaken is returned when the requested email belongs to another account
+	ErrEmailTaken = errors.New("email is already registered")
+	// ErrEmailChangeInvalid is returned when confirming an email change that
+	// was not requested, or was replaced by a later request
+	ErrEmailChangeInvalid = errors.New("email change is invalid")
+	// ErrGoogleNotLinked is returned when unlinking a Google account that is not linked
+	ErrGoogleNotLinked = errors.New("google account is not linked")
+	// ErrLastLoginMethod is returned when unlinking would leave the account
+	// with no way to log in
+	ErrLastLoginMethod = errors.New("cannot remove the only login method")
+	// ErrGoogleLinkDisabled is returned when Google login would link itself
+	// by email to an account whose user unlinked Google
+	ErrGoogleLinkDisabled = errors.New("google account was unlinked by the user")
+	// ErrGoogleAccountInUse is returned when linking a Google account that is
+	// already linked to another user
+	ErrGoogleAccountInUse = errors.New("google account is linked to another user")
+)
+
+// UpdateName updates a user's display name
+func (r *AuthRepository) UpdateName(ctx context.Context, userID uuid.UUID, name string) error {
+	query := `
+		UPDATE users
+		SET name = $1, updated_at = NOW()
+		WHERE id = $2
+	`
+
+	result, err := r.db.ExecContext(ctx, query, name, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update name: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
+// ChangePassword replaces a user's password after checking the current one.
+// All access and refresh tokens of the user are invalidated in the same
+// transaction, as with a reset.
+func (r *AuthRepository) ChangePassword(ctx context.Context, userID uuid.UUID, currentPassword, newPassword string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var passwordHash *string
+	err = tx.QueryRowContext(ctx, `
+		SELECT password_hash
+		FROM users
+		WHERE id = $1
+		FOR UPDATE
+	`, userID).Scan(&passwordHash)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if passwordHash == nil || bcrypt.CompareHashAndPassword([]byte(*passwordHash), []byte(currentPassword)) != nil {
+		return ErrPasswordIncorrect
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE users
+		SET password_hash = $1, tokens_valid_after = NOW(), updated_at = NOW()
+		WHERE id = $2
+	`, string(hash), userID); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE refresh_tokens
+		SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// SetPassword gives an account that only logs in with Google a password.
+// Existing sessions stay valid since no credential is replaced.
+func (r *AuthRepository) SetPassword(ctx context.Context, userID uuid.UUID, newPassword string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	query := `
+		UPDATE users
+		SET password_hash = $1, updated_at = NOW()
+		WHERE id = $2 AND password_hash IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, string(hash), userID)
+	if err != nil {
+		return fmt.Errorf("failed to set password: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrPasswordAlreadySet
+	}
+
+	return nil
+}
+
+// RequestEmailChange records newEmail as the user's pending email and calls
+// then in the same transaction, so the request is only stored if the email
+// verifying it is queued. A later request replaces an earlier one. then may
+// be nil.
+func (r *AuthRepository) RequestEmailChange(ctx context.Context, userID uuid.UUID, newEmail string, then func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var taken bool
+	if err := tx.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM users WHERE email = $1 AND id <> $2)
+	`, newEmail, userID).Scan(&taken); err != nil {
+		return fmt.Errorf("failed to check email: %w", err)
+	}
+	if taken {
+		return ErrEmailTaken
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE users
+		SET pending_email = $1, updated_at = NOW()
+		WHERE id = $2
+	`, newEmail, userID)
+	if err != nil {
+		return fmt.Errorf("failed to request email change: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	if then != nil {
+		if err := then(tx); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// ConfirmEmailChange makes newEmail the user's email if it is the pending
+// one. Opening the link proves ownership, so the account is verified too.
+func (r *AuthRepository) ConfirmEmailChange(ctx context.Context, userID uuid.UUID, newEmail string) error {
+	query := `
+		UPDATE users
+		SET email = pending_email, pending_email = NULL, is_verified = TRUE, updated_at = NOW()
+		WHERE id = $1 AND pending_email = $2
+	`
+
+	result, err := r.db.ExecContext(ctx, query, userID, newEmail)
+	if err != nil {
+		// Someone registered the address after the change was requested
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrEmailTaken
+		}
+		return fmt.Errorf("failed to change email: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrEmailChangeInvalid
+	}
+
+	return nil
+}
+
+// UnlinkGoogleID removes the Google account linked to a user. The user must
+// have a password to keep a way to log in. Google login stops linking itself
+// to the account by email until the user links it again with
+// LinkGoogleIDFromAccount.
+func (r *AuthRepository) UnlinkGoogleID(ctx context.Context, userID uuid.UUID) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var passwordHash, googleID *string
+	err = tx.QueryRowContext(ctx, `
+		SELECT password_hash, google_id
+		FROM users
+		WHERE id = $1
+		FOR UPDATE
+	`, userID).Scan(&passwordHash, &googleID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if googleID == nil {
+		return ErrGoogleNotLinked
+	}
+	if passwordHash == nil {
+		return ErrLastLoginMethod
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE users
+		SET google_id = NULL, google_link_disabled = TRUE, updated_at = NOW()
+		WHERE id = $1
+	`, userID); err != nil {
+		return fmt.Errorf("failed to unlink Google ID: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// LinkGoogleIDFromAccount links a Google account the user chose from their
+// account page, lifting an earlier opt-out left by UnlinkGoogleID
+func (r *AuthRepository) LinkGoogleIDFromAccount(ctx context.Context, userID uuid.UUID, googleID string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var inUse bool
+	err = tx.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM users WHERE google_id = $1 AND id <> $2)",
+		googleID, userID,
+	).Scan(&inUse)
+	if err != nil {
+		return fmt.Errorf("failed to check Google ID: %w", err)
+	}
+	if inUse {
+		return ErrGoogleAccountInUse
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE users
+		SET google_id = $1, google_link_disabled = FALSE, updated_at = NOW()
+		WHERE id = $2
+	`, googleID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to link Google ID: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
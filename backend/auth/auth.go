@@ -22,6 +22,7 @@ const (
 type User struct {
 	ID           uuid.UUID `json:"id"`
 	Email        string    `json:"email"`
+	PendingEmail *string   `json:"pending_email,omitempty"`
 	Name         *string   `json:"name,omitempty"`
 	PasswordHash *string   `json:"-"`
 	GoogleID     *string   `json:"-"`
@@ -52,6 +53,13 @@ func NewAuthRepository(db *sql.DB) *AuthRepository {
 
 // CreateUser creates a new user in the database
 func (r *AuthRepository) CreateUser(ctx context.Context, input CreateUserInput) (*User, error) {
+	return r.CreateUserWith(ctx, input, nil)
+}
+
+// CreateUserWith creates a user and calls then with it in the same
+// transaction, so work tied to the new account, such as queueing its
+// verification email, is committed with it or not at all. then may be nil.
+func (r *AuthRepository) CreateUserWith(ctx context.Context, input CreateUserInput, then func(tx *sql.Tx, user *User) error) (*User, error) {
 	var passwordHash *string
 
 	// Hash password if provided
@@ -86,7 +94,13 @@ func (r *AuthRepository) CreateUser(ctx context.Context, input CreateUserInput)
 	// Google OAuth users are verified by default
 	isVerified := input.GoogleID != nil
 
-	err := r.db.QueryRowContext(
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(
 		ctx,
 		query,
 		input.Email,
@@ -109,6 +123,16 @@ func (r *AuthRepository) CreateUser(ctx context.Context, input CreateUserInput)
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
+	if then != nil {
+		if err := then(tx, user); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return user, nil
 }
 
@@ -117,7 +141,7 @@ func (r *AuthRepository) GetUserByEmail(ctx context.Context, email string) (*Use
 	user := &User{}
 
 	query := `
-		SELECT id, email, name, password_hash, google_id, role, is_verified, created_at, updated_at
+		SELECT id, email, pending_email, name, password_hash, google_id, role, is_verified, created_at, updated_at
 		FROM users
 		WHERE email = $1
 	`
@@ -125,6 +149,7 @@ func (r *AuthRepository) GetUserByEmail(ctx context.Context, email string) (*Use
 	err := r.db.QueryRowContext(ctx, query, email).Scan(
 		&user.ID,
 		&user.Email,
+		&user.PendingEmail,
 		&user.Name,
 		&user.PasswordHash,
 		&user.GoogleID,
@@ -150,7 +175,7 @@ func (r *AuthRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*User,
 	user := &User{}
 
 	query := `
-		SELECT id, email, name, password_hash, google_id, role, is_verified, created_at, updated_at
+		SELECT id, email, pending_email, name, password_hash, google_id, role, is_verified, created_at, updated_at
 		FROM users
 		WHERE id = $1
 	`
@@ -158,6 +183,7 @@ func (r *AuthRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*User,
 	err := r.db.QueryRowContext(ctx, query, id).Scan(
 		&user.ID,
 		&user.Email,
+		&user.PendingEmail,
 		&user.Name,
 		&user.PasswordHash,
 		&user.GoogleID,
@@ -183,7 +209,7 @@ func (r *AuthRepository) GetUserByGoogleID(ctx context.Context, googleID string)
 	user := &User{}
 
 	query := `
-		SELECT id, email, name, password_hash, google_id, role, is_verified, created_at, updated_at
+		SELECT id, email, pending_email, name, password_hash, google_id, role, is_verified, created_at, updated_at
 		FROM users
 		WHERE google_id = $1
 	`
@@ -191,6 +217,7 @@ func (r *AuthRepository) GetUserByGoogleID(ctx context.Context, googleID string)
 	err := r.db.QueryRowContext(ctx, query, googleID).Scan(
 		&user.ID,
 		&user.Email,
+		&user.PendingEmail,
 		&user.Name,
 		&user.PasswordHash,
 		&user.GoogleID,
@@ -213,13 +240,25 @@ func (r *AuthRepository) GetUserByGoogleID(ctx context.Context, googleID string)
 
 // SetVerified marks a user as verified
 func (r *AuthRepository) SetVerified(ctx context.Context, userID uuid.UUID, verified bool) error {
+	return r.SetVerifiedWith(ctx, userID, verified, nil)
+}
+
+// SetVerifiedWith marks a user as verified and calls then in the same
+// transaction. then may be nil.
+func (r *AuthRepository) SetVerifiedWith(ctx context.Context, userID uuid.UUID, verified bool, then func(tx *sql.Tx) error) error {
 	query := `
 		UPDATE users
 		SET is_verified = $1, updated_at = NOW()
 		WHERE id = $2
 	`
 
-	result, err := r.db.ExecContext(ctx, query, verified, userID)
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, verified, userID)
 	if err != nil {
 		return fmt.Errorf("failed to update user verification status: %w", err)
 	}
@@ -233,6 +272,16 @@ func (r *AuthRepository) SetVerified(ctx context.Context, userID uuid.UUID, veri
 		return fmt.Errorf("user not found")
 	}
 
+	if then != nil {
+		if err := then(tx); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return nil
 }
 
@@ -255,7 +304,9 @@ func (r *AuthRepository) VerifyPassword(ctx context.Context, email, password str
 	return user, nil
 }
 
-// UpdatePassword updates a user's password
+// UpdatePassword updates a user's password and invalidates every access and
+// refresh token issued to the user. Use TokenRevoker.UpdatePassword, which
+// also drops the cached token cutoff.
 func (r *AuthRepository) UpdatePassword(ctx context.Context, userID uuid.UUID, newPassword string) error {
 	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
 	if err != nil {
@@ -264,33 +315,20 @@ func (r *AuthRepository) UpdatePassword(ctx context.Context, userID uuid.UUID, n
 
 	query := `
 		UPDATE users
-		SET password_hash = $1, updated_at = NOW()
+		SET password_hash = $1, tokens_valid_after = NOW(), updated_at = NOW()
 		WHERE id = $2
 	`
 
-	result, err := r.db.ExecContext(ctx, query, string(hash), userID)
-	if err != nil {
-		return fmt.Errorf("failed to update password: %w", err)
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
-	}
-
-	if rowsAffected == 0 {
-		return fmt.Errorf("user not found")
-	}
-
-	return nil
+	return r.updateAndRevoke(ctx, userID, query, string(hash), userID)
 }
 
-// LinkGoogleID links a Google ID to an existing user
+// LinkGoogleID links a Google account to the user with the same email.
+// Returns ErrGoogleLinkDisabled if the user unlinked Google before.
 func (r *AuthRepository) LinkGoogleID(ctx context.Context, userID uuid.UUID, googleID string) error {
 	query := `
 		UPDATE users
 		SET google_id = $1, updated_at = NOW()
-		WHERE id = $2
+		WHERE id = $2 AND NOT google_link_disabled
 	`
 
 	result, err := r.db.ExecContext(ctx, query, googleID, userID)
@@ -304,23 +342,52 @@ func (r *AuthRepository) LinkGoogleID(ctx context.Context, userID uuid.UUID, goo
 	}
 
 	if rowsAffected == 0 {
+		var disabled bool
+		err := r.db.QueryRowContext(ctx,
+			"SELECT google_link_disabled FROM users WHERE id = $1",
+			userID,
+		).Scan(&disabled)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("user not found")
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get user: %w", err)
+		}
+		if disabled {
+			return ErrGoogleLinkDisabled
+		}
 		return fmt.Errorf("user not found")
 	}
 
 	return nil
 }
 
-// UpdateRole updates a user's role
+// UpdateRole updates a user's role and invalidates every access and refresh
+// token issued to the user, so tokens carrying the old role stop working. Use
+// TokenRevoker.UpdateRole, which also drops the cached token cutoff.
 func (r *AuthRepository) UpdateRole(ctx context.Context, userID uuid.UUID, role UserRole) error {
 	query := `
 		UPDATE users
-		SET role = $1, updated_at = NOW()
+		SET role = $1, tokens_valid_after = NOW(), updated_at = NOW()
 		WHERE id = $2
 	`
 
-	result, err := r.db.ExecContext(ctx, query, string(role), userID)
+	return r.updateAndRevoke(ctx, userID, query, string(role), userID)
+}
+
+// updateAndRevoke runs a statement updating one user and revokes the user's
+// refresh tokens in the same transaction. Returns "user not found" if the
+// statement matched no row.
+func (r *AuthRepository) updateAndRevoke(ctx context.Context, userID uuid.UUID, query string, args ...interface{}) error {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to update role: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
@@ -332,5 +399,17 @@ func (r *AuthRepository) UpdateRole(ctx context.Context, userID uuid.UUID, role
 		return fmt.Errorf("user not found")
 	}
 
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE refresh_tokens
+		SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return nil
 }
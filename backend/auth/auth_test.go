@@ -3,10 +3,12 @@ package auth
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"os"
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
 	_ "github.com/lib/pq"
 )
 
@@ -250,3 +252,162 @@ func TestVerifyPassword(t *testing.T) {
 		t.Error("Expected error when verifying incorrect password")
 	}
 }
+
+func TestRotateRefreshTokenReuse(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewAuthRepository(db)
+	ctx := context.Background()
+
+	testEmail := "refresh@example.com"
+	testPassword := "password123"
+
+	defer cleanupTestUser(t, db, testEmail)
+
+	user, err := repo.CreateUser(ctx, CreateUserInput{
+		Email:    testEmail,
+		Password: &testPassword,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	expiresAt := time.Now().Add(time.Hour)
+	first := uuid.New()
+	if err := repo.CreateRefreshToken(ctx, first, user.ID, uuid.New(), expiresAt); err != nil {
+		t.Fatalf("Failed to create refresh token: %v", err)
+	}
+
+	// First use rotates
+	second := uuid.New()
+	if _, err := repo.RotateRefreshToken(ctx, first, second, expiresAt); err != nil {
+		t.Fatalf("Failed to rotate refresh token: %v", err)
+	}
+
+	// Replaying the first token revokes the family
+	if _, err := repo.RotateRefreshToken(ctx, first, uuid.New(), expiresAt); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("Expected ErrRefreshTokenReused, got %v", err)
+	}
+
+	// The legitimate successor is now revoked too
+	if _, err := repo.RotateRefreshToken(ctx, second, uuid.New(), expiresAt); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Errorf("Expected ErrRefreshTokenInvalid after family revocation, got %v", err)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewAuthRepository(db)
+	ctx := context.Background()
+
+	testEmail := "change-password@example.com"
+	testPassword := "oldpassword"
+	googleID := "google-change-password"
+
+	defer cleanupTestUser(t, db, testEmail)
+
+	// Google-only accounts can set a first password, but only once
+	user, err := repo.CreateUser(ctx, CreateUserInput{Email: testEmail, GoogleID: &googleID})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	if err := repo.UnlinkGoogleID(ctx, user.ID); !errors.Is(err, ErrLastLoginMethod) {
+		t.Errorf("Expected ErrLastLoginMethod, got %v", err)
+	}
+
+	if err := repo.SetPassword(ctx, user.ID, testPassword); err != nil {
+		t.Fatalf("Failed to set password: %v", err)
+	}
+	if err := repo.SetPassword(ctx, user.ID, "anotherpassword"); !errors.Is(err, ErrPasswordAlreadySet) {
+		t.Errorf("Expected ErrPasswordAlreadySet, got %v", err)
+	}
+
+	if err := repo.ChangePassword(ctx, user.ID, "wrongpassword", "newpassword"); !errors.Is(err, ErrPasswordIncorrect) {
+		t.Errorf("Expected ErrPasswordIncorrect, got %v", err)
+	}
+	if err := repo.ChangePassword(ctx, user.ID, testPassword, "newpassword"); err != nil {
+		t.Fatalf("Failed to change password: %v", err)
+	}
+	if _, err := repo.VerifyPassword(ctx, testEmail, "newpassword"); err != nil {
+		t.Errorf("New password was not accepted: %v", err)
+	}
+
+	cutoff, err := repo.GetTokensValidAfter(ctx, user.ID)
+	if err != nil || cutoff.IsZero() {
+		t.Errorf("Expected issued tokens to be invalidated, got %v, %v", cutoff, err)
+	}
+
+	if err := repo.UnlinkGoogleID(ctx, user.ID); err != nil {
+		t.Fatalf("Failed to unlink Google ID: %v", err)
+	}
+	if err := repo.UnlinkGoogleID(ctx, user.ID); !errors.Is(err, ErrGoogleNotLinked) {
+		t.Errorf("Expected ErrGoogleNotLinked, got %v", err)
+	}
+}
+
+func TestEmailChange(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewAuthRepository(db)
+	ctx := context.Background()
+
+	testEmail := "email-change@example.com"
+	otherEmail := "email-change-other@example.com"
+	newEmail := "email-change-new@example.com"
+	testPassword := "password123"
+
+	defer cleanupTestUser(t, db, testEmail)
+	defer cleanupTestUser(t, db, otherEmail)
+	defer cleanupTestUser(t, db, newEmail)
+
+	user, err := repo.CreateUser(ctx, CreateUserInput{Email: testEmail, Password: &testPassword})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	if _, err := repo.CreateUser(ctx, CreateUserInput{Email: otherEmail, Password: &testPassword}); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	if err := repo.RequestEmailChange(ctx, user.ID, otherEmail, nil); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("Expected ErrEmailTaken, got %v", err)
+	}
+
+	if err := repo.RequestEmailChange(ctx, user.ID, newEmail, nil); err != nil {
+		t.Fatalf("Failed to request email change: %v", err)
+	}
+
+	pending, err := repo.GetUserByID(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("Failed to get user: %v", err)
+	}
+	if pending.Email != testEmail || pending.PendingEmail == nil || *pending.PendingEmail != newEmail {
+		t.Errorf("Expected %s pending for %s, got %v for %s", newEmail, testEmail, pending.PendingEmail, pending.Email)
+	}
+
+	// Only the pending address can be confirmed
+	if err := repo.ConfirmEmailChange(ctx, user.ID, otherEmail); !errors.Is(err, ErrEmailChangeInvalid) {
+		t.Errorf("Expected ErrEmailChangeInvalid, got %v", err)
+	}
+
+	if err := repo.ConfirmEmailChange(ctx, user.ID, newEmail); err != nil {
+		t.Fatalf("Failed to confirm email change: %v", err)
+	}
+
+	changed, err := repo.GetUserByID(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("Failed to get user: %v", err)
+	}
+	if changed.Email != newEmail || changed.PendingEmail != nil || !changed.IsVerified {
+		t.Errorf("Expected verified %s, got %+v", newEmail, changed)
+	}
+
+	// A confirmation link works once
+	if err := repo.ConfirmEmailChange(ctx, user.ID, newEmail); !errors.Is(err, ErrEmailChangeInvalid) {
+		t.Errorf("Expected ErrEmailChangeInvalid, got %v", err)
+	}
+}
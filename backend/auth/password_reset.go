@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrResetTokenInvalid is returned for unknown, expired or already used reset tokens
+var ErrResetTokenInvalid = errors.New("password reset token is invalid")
+
+// CreatePasswordResetToken records a newly issued password reset token and
+// calls then in the same transaction, so the token is only stored if the
+// email carrying it is queued. then may be nil.
+func (r *AuthRepository) CreatePasswordResetToken(ctx context.Context, tokenID, userID uuid.UUID, expiresAt time.Time, then func(tx *sql.Tx) error) error {
+	query := `
+		INSERT INTO password_reset_tokens (id, user_id, expires_at)
+		VALUES ($1, $2, $3)
+	`
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, query, tokenID, userID, expiresAt); err != nil {
+		return fmt.Errorf("failed to create password reset token: %w", err)
+	}
+
+	if then != nil {
+		if err := then(tx); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// CountRecentPasswordResets returns how many reset tokens were issued to a
+// user since the given time
+func (r *AuthRepository) CountRecentPasswordResets(ctx context.Context, userID uuid.UUID, since time.Time) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM password_reset_tokens WHERE user_id = $1 AND created_at > $2",
+		userID, since,
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count password resets: %w", err)
+	}
+
+	return count, nil
+}
+
+// ResetPassword redeems a reset token and sets the new password. Every other
+// outstanding reset token and all access and refresh tokens of the user are
+// invalidated in the same transaction.
+func (r *AuthRepository) ResetPassword(ctx context.Context, tokenID, userID uuid.UUID, newPassword string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var tokenUserID uuid.UUID
+	var expiresAt time.Time
+	var usedAt *time.Time
+	err = tx.QueryRowContext(ctx, `
+		SELECT user_id, expires_at, used_at
+		FROM password_reset_tokens
+		WHERE id = $1
+		FOR UPDATE
+	`, tokenID).Scan(&tokenUserID, &expiresAt, &usedAt)
+	if err == sql.ErrNoRows {
+		return ErrResetTokenInvalid
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get password reset token: %w", err)
+	}
+
+	if tokenUserID != userID || usedAt != nil || time.Now().After(expiresAt) {
+		return ErrResetTokenInvalid
+	}
+
+	// Completing a reset also proves ownership of the email address
+	result, err := tx.ExecContext(ctx, `
+		UPDATE users
+		SET password_hash = $1, is_verified = TRUE, tokens_valid_after = NOW(), updated_at = NOW()
+		WHERE id = $2
+	`, string(hash), userID)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE password_reset_tokens
+		SET used_at = NOW()
+		WHERE user_id = $1 AND used_at IS NULL
+	`, userID); err != nil {
+		return fmt.Errorf("failed to invalidate reset tokens: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE refresh_tokens
+		SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExpiredPasswordResetTokens removes reset tokens past their expiry
+func (r *AuthRepository) DeleteExpiredPasswordResetTokens(ctx context.Context) (int64, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM password_reset_tokens WHERE expires_at < NOW() - INTERVAL '1 day'")
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired password reset tokens: %w", err)
+	}
+
+	return result.RowsAffected()
+}
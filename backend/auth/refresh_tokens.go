@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrRefreshTokenInvalid is returned for unknown, expired or revoked refresh tokens
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid")
+	// ErrRefreshTokenReused is returned when an already rotated refresh token
+	// is presented again; the whole token family is revoked when this happens
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+)
+
+// RefreshToken represents an issued refresh token
+type RefreshToken struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	FamilyID   uuid.UUID
+	ExpiresAt  time.Time
+	UsedAt     *time.Time
+	ReplacedBy *uuid.UUID
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+}
+
+// CreateRefreshToken records a newly issued refresh token
+func (r *AuthRepository) CreateRefreshToken(ctx context.Context, tokenID, userID, familyID uuid.UUID, expiresAt time.Time) error {
+	query := `
+		INSERT INTO refresh_tokens (id, user_id, family_id, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, tokenID, userID, familyID, expiresAt); err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	return nil
+}
+
+// RotateRefreshToken marks tokenID as used and records newTokenID in the same
+// family. If tokenID was already used its family is revoked and
+// ErrRefreshTokenReused is returned.
+func (r *AuthRepository) RotateRefreshToken(ctx context.Context, tokenID, newTokenID uuid.UUID, newExpiresAt time.Time) (*RefreshToken, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current := &RefreshToken{}
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, user_id, family_id, expires_at, used_at, replaced_by, revoked_at, created_at
+		FROM refresh_tokens
+		WHERE id = $1
+		FOR UPDATE
+	`, tokenID).Scan(
+		&current.ID,
+		&current.UserID,
+		&current.FamilyID,
+		&current.ExpiresAt,
+		&current.UsedAt,
+		&current.ReplacedBy,
+		&current.RevokedAt,
+		&current.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	if current.RevokedAt != nil || time.Now().After(current.ExpiresAt) {
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	if current.UsedAt != nil {
+		// A rotated token was replayed, so it may have been stolen. Revoke
+		// every token in the family, including the legitimate newest one.
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE refresh_tokens
+			SET revoked_at = NOW()
+			WHERE family_id = $1 AND revoked_at IS NULL
+		`, current.FamilyID); err != nil {
+			return nil, fmt.Errorf("failed to revoke token family: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return current, ErrRefreshTokenReused
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE refresh_tokens
+		SET used_at = NOW(), replaced_by = $1
+		WHERE id = $2
+	`, newTokenID, tokenID); err != nil {
+		return nil, fmt.Errorf("failed to mark refresh token used: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO refresh_tokens (id, user_id, family_id, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`, newTokenID, current.UserID, current.FamilyID, newExpiresAt); err != nil {
+		return nil, fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return current, nil
+}
+
+// RevokeUserRefreshTokens revokes every active refresh token of a user
+func (r *AuthRepository) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExpiredRefreshTokens removes refresh tokens past their expiry
+func (r *AuthRepository) DeleteExpiredRefreshTokens(ctx context.Context) (int64, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM refresh_tokens WHERE expires_at < NOW()")
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired refresh tokens: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+// RevokeRefreshTokenFamily revokes the family the given refresh token belongs to
+func (r *AuthRepository) RevokeRefreshTokenFamily(ctx context.Context, tokenID, userID uuid.UUID) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = NOW()
+		WHERE family_id = (SELECT family_id FROM refresh_tokens WHERE id = $1 AND user_id = $2)
+		  AND revoked_at IS NULL
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, tokenID, userID); err != nil {
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,281 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ramniya/ramniya-backend/cache"
+	"github.com/ramniya/ramniya-backend/jwt"
+	"go.uber.org/zap"
+)
+
+// cutoffCacheTTL bounds how long a cached tokens_valid_after value is trusted
+// when it was changed without going through the revoker
+const cutoffCacheTTL = time.Minute
+
+// TokenRevoker decides whether an access token has been revoked, either
+// individually (logout) or by a per-user cutoff (logout everywhere, password
+// or role change). Redis is used as a cache in front of Postgres.
+type TokenRevoker struct {
+	authRepo *AuthRepository
+	redis    *cache.RedisClient
+	logger   *zap.Logger
+}
+
+// NewTokenRevoker creates a new token revoker
+func NewTokenRevoker(authRepo *AuthRepository, redis *cache.RedisClient, logger *zap.Logger) *TokenRevoker {
+	return &TokenRevoker{
+		authRepo: authRepo,
+		redis:    redis,
+		logger:   logger,
+	}
+}
+
+// IsRevoked reports whether the access token described by claims was revoked
+func (t *TokenRevoker) IsRevoked(ctx context.Context, claims *jwt.Claims) (bool, error) {
+	userID, err := claims.GetUserID()
+	if err != nil {
+		return true, nil
+	}
+
+	if tokenID, err := claims.GetTokenID(); err == nil {
+		denied, err := t.isDenied(ctx, tokenID)
+		if err != nil {
+			return false, err
+		}
+		if denied {
+			return true, nil
+		}
+	}
+
+	cutoff, err := t.validAfter(ctx, userID)
+	if err != nil {
+		if err.Error() == "user not found" {
+			return true, nil // Tokens of deleted users are no longer valid
+		}
+		return false, err
+	}
+	if cutoff.IsZero() {
+		return false, nil
+	}
+
+	// iat has second precision, so a token issued in the same second as the
+	// cutoff is treated as revoked
+	if claims.IssuedAt == nil {
+		return true, nil
+	}
+	return !claims.IssuedAt.Time.After(cutoff.Truncate(time.Second)), nil
+}
+
+// RevokeToken denylists a single access token until it expires
+func (t *TokenRevoker) RevokeToken(ctx context.Context, claims *jwt.Claims) error {
+	tokenID, err := claims.GetTokenID()
+	if err != nil {
+		return err
+	}
+
+	userID, err := claims.GetUserID()
+	if err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(time.Hour)
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+
+	if err := t.authRepo.RevokeAccessToken(ctx, tokenID, userID, expiresAt); err != nil {
+		return err
+	}
+
+	if ttl := time.Until(expiresAt); ttl > 0 {
+		if err := t.redis.Set(ctx, deniedKey(tokenID), "1", ttl); err != nil {
+			t.logger.Warn("Failed to cache revoked token", zap.Error(err))
+		}
+	}
+
+	return nil
+}
+
+// RevokeUser invalidates every access and refresh token issued to a user
+func (t *TokenRevoker) RevokeUser(ctx context.Context, userID uuid.UUID) error {
+	if err := t.authRepo.BumpTokensValidAfter(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := t.authRepo.RevokeUserRefreshTokens(ctx, userID); err != nil {
+		return err
+	}
+
+	t.Forget(ctx, userID)
+
+	return nil
+}
+
+// UpdateRole changes a user's role and invalidates every token issued under
+// the old one
+func (t *TokenRevoker) UpdateRole(ctx context.Context, userID uuid.UUID, role UserRole) error {
+	if err := t.authRepo.UpdateRole(ctx, userID, role); err != nil {
+		return err
+	}
+
+	t.Forget(ctx, userID)
+
+	return nil
+}
+
+// UpdatePassword sets a user's password and invalidates every token issued
+// before the change
+func (t *TokenRevoker) UpdatePassword(ctx context.Context, userID uuid.UUID, newPassword string) error {
+	if err := t.authRepo.UpdatePassword(ctx, userID, newPassword); err != nil {
+		return err
+	}
+
+	t.Forget(ctx, userID)
+
+	return nil
+}
+
+// Forget drops the cached cutoff for a user so a change made directly in the
+// database (e.g. by ChangePassword) takes effect immediately
+func (t *TokenRevoker) Forget(ctx context.Context, userID uuid.UUID) {
+	if err := t.redis.Delete(ctx, cutoffKey(userID)); err != nil {
+		t.logger.Warn("Failed to clear cached token cutoff",
+			zap.String("user_id", userID.String()),
+			zap.Error(err),
+		)
+	}
+}
+
+func (t *TokenRevoker) isDenied(ctx context.Context, tokenID uuid.UUID) (bool, error) {
+	if t.redis.IsEnabled() {
+		exists, err := t.redis.Exists(ctx, deniedKey(tokenID))
+		if err == nil {
+			return exists, nil
+		}
+		// Fall through to Postgres on Redis errors
+	}
+
+	return t.authRepo.IsAccessTokenRevoked(ctx, tokenID)
+}
+
+func (t *TokenRevoker) validAfter(ctx context.Context, userID uuid.UUID) (time.Time, error) {
+	if t.redis.IsEnabled() {
+		if val, err := t.redis.Get(ctx, cutoffKey(userID)); err == nil {
+			if nanos, err := strconv.ParseInt(val, 10, 64); err == nil {
+				if nanos == 0 {
+					return time.Time{}, nil
+				}
+				return time.Unix(0, nanos), nil
+			}
+		}
+	}
+
+	cutoff, err := t.authRepo.GetTokensValidAfter(ctx, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var nanos int64
+	if !cutoff.IsZero() {
+		nanos = cutoff.UnixNano()
+	}
+	if err := t.redis.Set(ctx, cutoffKey(userID), strconv.FormatInt(nanos, 10), cutoffCacheTTL); err != nil {
+		t.logger.Warn("Failed to cache token cutoff", zap.Error(err))
+	}
+
+	return cutoff, nil
+}
+
+func deniedKey(tokenID uuid.UUID) string {
+	return "revoked_jti:" + tokenID.String()
+}
+
+func cutoffKey(userID uuid.UUID) string {
+	return "tokens_valid_after:" + userID.String()
+}
+
+// RevokeAccessToken records a revoked access token
+func (r *AuthRepository) RevokeAccessToken(ctx context.Context, tokenID, userID uuid.UUID, expiresAt time.Time) error {
+	query := `
+		INSERT INTO revoked_access_tokens (id, user_id, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO NOTHING
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, tokenID, userID, expiresAt); err != nil {
+		return fmt.Errorf("failed to revoke access token: %w", err)
+	}
+
+	return nil
+}
+
+// IsAccessTokenRevoked checks the access token denylist
+func (r *AuthRepository) IsAccessTokenRevoked(ctx context.Context, tokenID uuid.UUID) (bool, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM revoked_access_tokens WHERE id = $1)",
+		tokenID,
+	).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check revoked token: %w", err)
+	}
+
+	return exists, nil
+}
+
+// GetTokensValidAfter returns the user's token cutoff, or the zero time if unset
+func (r *AuthRepository) GetTokensValidAfter(ctx context.Context, userID uuid.UUID) (time.Time, error) {
+	var cutoff sql.NullTime
+	err := r.db.QueryRowContext(ctx,
+		"SELECT tokens_valid_after FROM users WHERE id = $1",
+		userID,
+	).Scan(&cutoff)
+	if err == sql.ErrNoRows {
+		return time.Time{}, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get token cutoff: %w", err)
+	}
+
+	if !cutoff.Valid {
+		return time.Time{}, nil
+	}
+	return cutoff.Time, nil
+}
+
+// BumpTokensValidAfter invalidates every access token issued to the user so far
+func (r *AuthRepository) BumpTokensValidAfter(ctx context.Context, userID uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE users SET tokens_valid_after = NOW() WHERE id = $1",
+		userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke tokens: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
+// DeleteExpiredRevokedTokens removes denylist entries for tokens that have expired anyway
+func (r *AuthRepository) DeleteExpiredRevokedTokens(ctx context.Context) (int64, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM revoked_access_tokens WHERE expires_at < NOW()")
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired revoked tokens: %w", err)
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,324 @@
+package cart
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// lineConflictTarget matches idx_cart_items_unique_line
+const lineConflictTarget = `(cart_id, product_id, (COALESCE(variant_id, '00000000-0000-0000-0000-000000000000'::uuid)))`
+
+// Cart represents a shopping cart owned by a user or a guest token
+type Cart struct {
+	ID        uuid.UUID  `json:"id"`
+	UserID    *uuid.UUID `json:"user_id,omitempty"`
+	Token     *string    `json:"-"`
+	Items     []CartItem `json:"items"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// CartItem represents a single line in a cart
+type CartItem struct {
+	ID             uuid.UUID  `json:"id"`
+	ProductID      uuid.UUID  `json:"product_id"`
+	VariantID      *uuid.UUID `json:"variant_id,omitempty"`
+	Quantity       int        `json:"quantity"`
+	UnitPriceCents int        `json:"unit_price_cents"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// CartRepository handles cart database operations
+type CartRepository struct {
+	db *sql.DB
+}
+
+// NewCartRepository creates a new cart repository
+func NewCartRepository(db *sql.DB) *CartRepository {
+	return &CartRepository{db: db}
+}
+
+// GetOrCreateUserCart returns the user's cart, creating it if needed
+func (r *CartRepository) GetOrCreateUserCart(ctx context.Context, userID uuid.UUID) (*Cart, error) {
+	cart, err := r.getCart(ctx, "user_id = $1", userID)
+	if err == nil {
+		return cart, nil
+	}
+	if err.Error() != "cart not found" {
+		return nil, err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO carts (user_id)
+		VALUES ($1)
+		ON CONFLICT (user_id) DO NOTHING
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cart: %w", err)
+	}
+
+	return r.getCart(ctx, "user_id = $1", userID)
+}
+
+// GetCartByToken returns a guest cart by its token
+func (r *CartRepository) GetCartByToken(ctx context.Context, token string) (*Cart, error) {
+	return r.getCart(ctx, "token = $1 AND user_id IS NULL", token)
+}
+
+// CreateGuestCart creates an empty cart identified by a new random token
+func (r *CartRepository) CreateGuestCart(ctx context.Context) (*Cart, error) {
+	token, err := generateCartToken()
+	if err != nil {
+		return nil, err
+	}
+
+	var cart Cart
+	err = r.db.QueryRowContext(ctx, `
+		INSERT INTO carts (token)
+		VALUES ($1)
+		RETURNING id, user_id, token, created_at, updated_at
+	`, token).Scan(&cart.ID, &cart.UserID, &cart.Token, &cart.CreatedAt, &cart.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create guest cart: %w", err)
+	}
+	cart.Items = []CartItem{}
+
+	return &cart, nil
+}
+
+// AddItem adds quantity of a product/variant to the cart, merging with an
+// existing line for the same product/variant
+func (r *CartRepository) AddItem(ctx context.Context, cartID, productID uuid.UUID, variantID *uuid.UUID, quantity, unitPriceCents int) (*CartItem, error) {
+	query := `
+		INSERT INTO cart_items (cart_id, product_id, variant_id, quantity, unit_price_cents)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT ` + lineConflictTarget + `
+		DO UPDATE SET quantity = cart_items.quantity + EXCLUDED.quantity,
+		              unit_price_cents = EXCLUDED.unit_price_cents
+		RETURNING id, product_id, variant_id, quantity, unit_price_cents, created_at, updated_at
+	`
+
+	var item CartItem
+	err := r.db.QueryRowContext(ctx, query, cartID, productID, variantID, quantity, unitPriceCents).Scan(
+		&item.ID, &item.ProductID, &item.VariantID, &item.Quantity, &item.UnitPriceCents, &item.CreatedAt, &item.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add cart item: %w", err)
+	}
+
+	r.touch(ctx, cartID)
+
+	return &item, nil
+}
+
+// GetItem retrieves a single cart line
+func (r *CartRepository) GetItem(ctx context.Context, cartID, itemID uuid.UUID) (*CartItem, error) {
+	var item CartItem
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, product_id, variant_id, quantity, unit_price_cents, created_at, updated_at
+		FROM cart_items
+		WHERE id = $1 AND cart_id = $2
+	`, itemID, cartID).Scan(
+		&item.ID, &item.ProductID, &item.VariantID, &item.Quantity, &item.UnitPriceCents, &item.CreatedAt, &item.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("cart item not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cart item: %w", err)
+	}
+
+	return &item, nil
+}
+
+// UpdateItemQuantity sets the quantity of a cart line
+func (r *CartRepository) UpdateItemQuantity(ctx context.Context, cartID, itemID uuid.UUID, quantity int) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE cart_items
+		SET quantity = $1
+		WHERE id = $2 AND cart_id = $3
+	`, quantity, itemID, cartID)
+	if err != nil {
+		return fmt.Errorf("failed to update cart item: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("cart item not found")
+	}
+
+	r.touch(ctx, cartID)
+
+	return nil
+}
+
+// RemoveItem deletes a cart line
+func (r *CartRepository) RemoveItem(ctx context.Context, cartID, itemID uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM cart_items WHERE id = $1 AND cart_id = $2", itemID, cartID)
+	if err != nil {
+		return fmt.Errorf("failed to remove cart item: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("cart item not found")
+	}
+
+	r.touch(ctx, cartID)
+
+	return nil
+}
+
+// Clear removes every line from the cart
+func (r *CartRepository) Clear(ctx context.Context, cartID uuid.UUID) error {
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM cart_items WHERE cart_id = $1", cartID); err != nil {
+		return fmt.Errorf("failed to clear cart: %w", err)
+	}
+
+	r.touch(ctx, cartID)
+
+	return nil
+}
+
+// MergeGuestCart moves the lines of a guest cart into the user's cart and
+// deletes the guest cart. Quantities for matching lines are summed.
+func (r *CartRepository) MergeGuestCart(ctx context.Context, token string, userID uuid.UUID) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var guestCartID uuid.UUID
+	err = tx.QueryRowContext(ctx,
+		"SELECT id FROM carts WHERE token = $1 AND user_id IS NULL FOR UPDATE",
+		token,
+	).Scan(&guestCartID)
+	if err == sql.ErrNoRows {
+		return nil // Nothing to merge
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get guest cart: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO carts (user_id)
+		VALUES ($1)
+		ON CONFLICT (user_id) DO NOTHING
+	`, userID); err != nil {
+		return fmt.Errorf("failed to create cart: %w", err)
+	}
+
+	var userCartID uuid.UUID
+	if err := tx.QueryRowContext(ctx,
+		"SELECT id FROM carts WHERE user_id = $1 FOR UPDATE",
+		userID,
+	).Scan(&userCartID); err != nil {
+		return fmt.Errorf("failed to get user cart: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO cart_items (cart_id, product_id, variant_id, quantity, unit_price_cents)
+		SELECT $1, product_id, variant_id, quantity, unit_price_cents
+		FROM cart_items
+		WHERE cart_id = $2
+		ON CONFLICT `+lineConflictTarget+`
+		DO UPDATE SET quantity = cart_items.quantity + EXCLUDED.quantity
+	`, userCartID, guestCartID)
+	if err != nil {
+		return fmt.Errorf("failed to merge cart items: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM carts WHERE id = $1", guestCartID); err != nil {
+		return fmt.Errorf("failed to delete guest cart: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE carts SET updated_at = NOW() WHERE id = $1", userCartID); err != nil {
+		return fmt.Errorf("failed to update cart: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteStaleGuestCarts removes guest carts untouched for longer than maxAge
+func (r *CartRepository) DeleteStaleGuestCarts(ctx context.Context, maxAge time.Duration) (int64, error) {
+	result, err := r.db.ExecContext(ctx,
+		"DELETE FROM carts WHERE user_id IS NULL AND updated_at < $1",
+		time.Now().Add(-maxAge),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete stale carts: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+func (r *CartRepository) getCart(ctx context.Context, where string, arg interface{}) (*Cart, error) {
+	var cart Cart
+	err := r.db.QueryRowContext(ctx,
+		"SELECT id, user_id, token, created_at, updated_at FROM carts WHERE "+where,
+		arg,
+	).Scan(&cart.ID, &cart.UserID, &cart.Token, &cart.CreatedAt, &cart.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("cart not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cart: %w", err)
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, product_id, variant_id, quantity, unit_price_cents, created_at, updated_at
+		FROM cart_items
+		WHERE cart_id = $1
+		ORDER BY created_at
+	`, cart.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cart items: %w", err)
+	}
+	defer rows.Close()
+
+	cart.Items = []CartItem{}
+	for rows.Next() {
+		var item CartItem
+		err := rows.Scan(&item.ID, &item.ProductID, &item.VariantID, &item.Quantity, &item.UnitPriceCents, &item.CreatedAt, &item.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan cart item: %w", err)
+		}
+		cart.Items = append(cart.Items, item)
+	}
+
+	return &cart, rows.Err()
+}
+
+// touch bumps the cart's updated_at so active guest carts are not cleaned up
+func (r *CartRepository) touch(ctx context.Context, cartID uuid.UUID) {
+	_, _ = r.db.ExecContext(ctx, "UPDATE carts SET updated_at = NOW() WHERE id = $1", cartID)
+}
+
+// generateCartToken generates an unguessable guest cart token
+func generateCartToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate cart token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
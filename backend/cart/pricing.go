@@ -0,0 +1,115 @@
+package cart
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ramniya/ramniya-backend/products"
+)
+
+// Line issues reported when a cart is re-validated
+const (
+	IssueUnavailable       = "unavailable"
+	IssueInsufficientStock = "insufficient_stock"
+)
+
+// PricedCart is a cart re-validated against the current catalog
+type PricedCart struct {
+	ID            uuid.UUID    `json:"id"`
+	Items         []PricedLine `json:"items"`
+	ItemCount     int          `json:"item_count"`
+	SubtotalCents int          `json:"subtotal_cents"`
+	Currency      string       `json:"currency"`
+	HasIssues     bool         `json:"has_issues"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+}
+
+// PricedLine is a cart line with its current price and availability
+type PricedLine struct {
+	ID                 uuid.UUID  `json:"id"`
+	ProductID          uuid.UUID  `json:"product_id"`
+	VariantID          *uuid.UUID `json:"variant_id,omitempty"`
+	Title              string     `json:"title"`
+	SKU                string     `json:"sku,omitempty"`
+	ImageURL           string     `json:"image_url,omitempty"`
+	Quantity           int        `json:"quantity"`
+	UnitPriceCents     int        `json:"unit_price_cents"`
+	LineTotalCents     int        `json:"line_total_cents"`
+	PriceChanged       bool       `json:"price_changed"`
+	PreviousPriceCents int        `json:"previous_price_cents,omitempty"`
+	AvailableStock     *int       `json:"available_stock,omitempty"`
+	Issue              string     `json:"issue,omitempty"`
+}
+
+// PriceCart resolves every cart line against the catalog. Lines whose product
+// or variant no longer exists are flagged unavailable and excluded from the
+// subtotal; lines exceeding current stock are flagged but still priced.
+func PriceCart(ctx context.Context, productRepo *products.ProductRepository, baseURL string, c *Cart) (*PricedCart, error) {
+	priced := &PricedCart{
+		ID:        c.ID,
+		Items:     make([]PricedLine, 0, len(c.Items)),
+		Currency:  "INR",
+		UpdatedAt: c.UpdatedAt,
+	}
+
+	for _, item := range c.Items {
+		line := PricedLine{
+			ID:        item.ID,
+			ProductID: item.ProductID,
+			VariantID: item.VariantID,
+			Quantity:  item.Quantity,
+		}
+
+		variantID := uuid.Nil
+		if item.VariantID != nil {
+			variantID = *item.VariantID
+		}
+
+		pi, err := productRepo.GetPricedItem(ctx, item.ProductID, variantID, baseURL)
+		if err != nil {
+			if !isCatalogError(err) {
+				return nil, err
+			}
+			line.Issue = IssueUnavailable
+			priced.HasIssues = true
+			priced.Items = append(priced.Items, line)
+			continue
+		}
+
+		line.Title = pi.Title
+		line.SKU = pi.SKU
+		line.ImageURL = pi.ImageURL
+		line.UnitPriceCents = pi.UnitPriceCents
+		line.LineTotalCents = pi.UnitPriceCents * item.Quantity
+
+		if item.UnitPriceCents > 0 && item.UnitPriceCents != pi.UnitPriceCents {
+			line.PriceChanged = true
+			line.PreviousPriceCents = item.UnitPriceCents
+		}
+
+		if pi.HasVariant {
+			stock := pi.Stock
+			line.AvailableStock = &stock
+			if item.Quantity > stock {
+				line.Issue = IssueInsufficientStock
+				priced.HasIssues = true
+			}
+		}
+
+		priced.ItemCount += item.Quantity
+		priced.SubtotalCents += line.LineTotalCents
+		priced.Items = append(priced.Items, line)
+	}
+
+	return priced, nil
+}
+
+// isCatalogError reports whether err means the product/variant cannot be bought
+func isCatalogError(err error) bool {
+	return errors.Is(err, products.ErrProductNotFound) ||
+		errors.Is(err, products.ErrVariantNotFound) ||
+		errors.Is(err, products.ErrVariantMismatch) ||
+		errors.Is(err, products.ErrVariantRequired)
+}
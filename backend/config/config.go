@@ -6,6 +6,7 @@ import (
 	"strconv"
 
 	"github.com/joho/godotenv"
+	"github.com/ramniya/ramniya-backend/tax"
 )
 
 // Config holds application configuration
@@ -16,25 +17,100 @@ type Config struct {
 	JWTSecret      string
 	JWTExpiryHours int
 
-	// SMTP Configuration
+	// Email transport: smtp, api or file (written to ./dev-emails)
+	EmailTransport string
+
+	// SMTP Configuration. SMTPFrom is the sender of every transport.
 	SMTPHost     string
 	SMTPPort     int
 	SMTPUsername string
 	SMTPPassword string
 	SMTPFrom     string
+	SMTPSecurity string // starttls, tls or none; empty picks tls on port 465
+
+	// HTTP email API taking SendGrid v3 mail/send requests
+	EmailAPIURL string
+	EmailAPIKey string
+
+	// Applied to every transport
+	EmailTimeoutSeconds  int
+	EmailReplyTo         string
+	EmailListUnsubscribe string
+
+	// Directory of email templates overriding the built-in ones
+	EmailTemplateDir string
+
+	// Outbox workers sending queued emails, and the attempts before an
+	// email is dead-lettered
+	EmailWorkers     int
+	EmailMaxAttempts int
+
+	// Inbox that receives a copy of new-order notifications; empty disables
+	OrderNotificationEmail string
 
 	// Google OAuth
 	GoogleClientID     string
 	GoogleClientSecret string
 	GoogleRedirectURL  string
 
+	// Payment gateway used for new orders: razorpay, stripe or fake
+	PaymentGateway string
+
 	// Razorpay Configuration
 	RazorpayKeyID     string
 	RazorpayKeySecret string
+	RazorpayAPIURL    string
+
+	// Stripe Configuration
+	StripeSecretKey      string
+	StripePublishableKey string
+	StripeWebhookSecret  string
+	StripeAPIURL         string
+
+	// Payment reconciliation
+	PaymentReconcileAfterMinutes int
+
+	// Cash on delivery
+	CODEnabled       bool
+	CODMaxOrderCents int
+	CODFeeCents      int
+	CODPincodes      string
+
+	// GST
+	GSTSellerState      string
+	GSTPricesIncludeTax bool
+	GSTDefaultRateBps   int
+	GSTDefaultHSN       string
+
+	// Shipping rates and serviceable pincodes (JSON); empty ships free
+	// everywhere
+	ShippingConfigFile string
+
+	// Shipping carrier for new shipments: shiprocket or fake
+	ShippingCarrier          string
+	ShiprocketEmail          string
+	ShiprocketPassword       string
+	ShiprocketPickupLocation string
+	ShiprocketWebhookToken   string
+	ShiprocketAPIURL         string
+
+	// Invoicing
+	SellerName       string
+	SellerAddress    string
+	SellerGSTIN      string
+	InvoiceSeries    string
+	CreditNoteSeries string
 
 	// Redis Configuration
 	RedisURL     string
 	RedisEnabled bool
+
+	// Inventory
+	StockReservationMinutes int
+
+	// Unpaid order expiry
+	OrderExpiryMinutes int
+	OrderExpiryStatus  string
 }
 
 // Load loads configuration from environment variables
@@ -55,21 +131,99 @@ func Load() (*Config, error) {
 		SMTPUsername: getEnv("SMTP_USERNAME", ""),
 		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
 		SMTPFrom:     getEnv("SMTP_FROM", "noreply@ramniyacreations.com"),
+		SMTPSecurity: getEnv("SMTP_SECURITY", ""),
+
+		// Email API
+		EmailAPIURL: getEnv("EMAIL_API_URL", ""),
+		EmailAPIKey: getEnv("EMAIL_API_KEY", ""),
+
+		// Email headers and timeouts
+		EmailTimeoutSeconds:  getEnvAsInt("EMAIL_TIMEOUT_SECONDS", 30),
+		EmailReplyTo:         getEnv("EMAIL_REPLY_TO", ""),
+		EmailListUnsubscribe: getEnv("EMAIL_LIST_UNSUBSCRIBE", ""),
+
+		// Email templates
+		EmailTemplateDir: getEnv("EMAIL_TEMPLATE_DIR", ""),
+
+		// Email outbox
+		EmailWorkers:     getEnvAsInt("EMAIL_WORKERS", 4),
+		EmailMaxAttempts: getEnvAsInt("EMAIL_MAX_ATTEMPTS", 8),
+
+		// Order notifications
+		OrderNotificationEmail: getEnv("ORDER_NOTIFICATION_EMAIL", ""),
 
 		// Google OAuth
 		GoogleClientID:     getEnv("GOOGLE_CLIENT_ID", ""),
 		GoogleClientSecret: getEnv("GOOGLE_CLIENT_SECRET", ""),
 		GoogleRedirectURL:  getEnv("GOOGLE_REDIRECT_URL", ""),
 
+		// Payments
+		PaymentGateway: getEnv("PAYMENT_GATEWAY", "razorpay"),
+
 		// Razorpay
 		RazorpayKeyID:     getEnv("RAZORPAY_KEY_ID", ""),
 		RazorpayKeySecret: getEnv("RAZORPAY_KEY_SECRET", ""),
+		RazorpayAPIURL:    getEnv("RAZORPAY_API_URL", ""),
+
+		// Stripe
+		StripeSecretKey:      getEnv("STRIPE_SECRET_KEY", ""),
+		StripePublishableKey: getEnv("STRIPE_PUBLISHABLE_KEY", ""),
+		StripeWebhookSecret:  getEnv("STRIPE_WEBHOOK_SECRET", ""),
+		StripeAPIURL:         getEnv("STRIPE_API_URL", ""),
+
+		// Payment reconciliation
+		PaymentReconcileAfterMinutes: getEnvAsInt("PAYMENT_RECONCILE_AFTER_MINUTES", 15),
+
+		// Cash on delivery
+		CODEnabled:       getEnvAsBool("COD_ENABLED", false),
+		CODMaxOrderCents: getEnvAsInt("COD_MAX_ORDER_CENTS", 0),
+		CODFeeCents:      getEnvAsInt("COD_FEE_CENTS", 0),
+		CODPincodes:      getEnv("COD_PINCODES", ""),
+
+		// GST
+		GSTSellerState:      getEnv("GST_SELLER_STATE", ""),
+		GSTPricesIncludeTax: getEnvAsBool("GST_PRICES_INCLUDE_TAX", true),
+		GSTDefaultRateBps:   getEnvAsInt("GST_DEFAULT_RATE_BPS", 500),
+		GSTDefaultHSN:       getEnv("GST_DEFAULT_HSN", ""),
+
+		// Shipping
+		ShippingConfigFile: getEnv("SHIPPING_CONFIG_FILE", ""),
+
+		// Shipping carrier
+		ShippingCarrier:          getEnv("SHIPPING_CARRIER", "shiprocket"),
+		ShiprocketEmail:          getEnv("SHIPROCKET_EMAIL", ""),
+		ShiprocketPassword:       getEnv("SHIPROCKET_PASSWORD", ""),
+		ShiprocketPickupLocation: getEnv("SHIPROCKET_PICKUP_LOCATION", "Primary"),
+		ShiprocketWebhookToken:   getEnv("SHIPROCKET_WEBHOOK_TOKEN", ""),
+		ShiprocketAPIURL:         getEnv("SHIPROCKET_API_URL", ""),
+
+		// Invoicing
+		SellerName:       getEnv("SELLER_NAME", "Ramniya Creations"),
+		SellerAddress:    getEnv("SELLER_ADDRESS", ""),
+		SellerGSTIN:      getEnv("SELLER_GSTIN", ""),
+		InvoiceSeries:    getEnv("INVOICE_SERIES", "RC"),
+		CreditNoteSeries: getEnv("CREDIT_NOTE_SERIES", "CN"),
 
 		// Redis
 		RedisURL:     getEnv("REDIS_URL", ""),
 		RedisEnabled: getEnv("REDIS_URL", "") != "",
+
+		// Inventory
+		StockReservationMinutes: getEnvAsInt("STOCK_RESERVATION_MINUTES", 30),
+
+		// Unpaid order expiry
+		OrderExpiryMinutes: getEnvAsInt("ORDER_EXPIRY_MINUTES", 120),
+		OrderExpiryStatus:  getEnv("ORDER_EXPIRY_STATUS", "cancelled"),
 	}
 
+	// Deployments configured before EMAIL_TRANSPORT existed send over SMTP
+	// when they have credentials
+	defaultEmailTransport := "file"
+	if config.SMTPUsername != "" && config.SMTPPassword != "" {
+		defaultEmailTransport = "smtp"
+	}
+	config.EmailTransport = getEnv("EMAIL_TRANSPORT", defaultEmailTransport)
+
 	// Validate required fields
 	if config.DatabaseURL == "" {
 		return nil, fmt.Errorf("DATABASE_URL is required")
@@ -79,17 +233,113 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("JWT_SECRET is required")
 	}
 
-	// Razorpay is required for checkout
-	if config.RazorpayKeyID == "" || config.RazorpayKeySecret == "" {
-		// Only warn in development, fail in production
+	if config.OrderExpiryStatus != "cancelled" && config.OrderExpiryStatus != "failed" {
+		return nil, fmt.Errorf("ORDER_EXPIRY_STATUS must be cancelled or failed")
+	}
+
+	if config.CODMaxOrderCents < 0 || config.CODFeeCents < 0 {
+		return nil, fmt.Errorf("COD_MAX_ORDER_CENTS and COD_FEE_CENTS must not be negative")
+	}
+
+	if !tax.ValidRate(config.GSTDefaultRateBps) {
+		return nil, fmt.Errorf("GST_DEFAULT_RATE_BPS must be a GST slab (0, 25, 300, 500, 1200, 1800 or 2800)")
+	}
+
+	// Without the seller's state every sale would be charged IGST
+	if config.GSTSellerState != "" && tax.StateCode(config.GSTSellerState) == "" {
+		return nil, fmt.Errorf("GST_SELLER_STATE is not a recognised Indian state")
+	}
+	if config.GSTSellerState == "" && config.IsProduction() {
+		return nil, fmt.Errorf("GST_SELLER_STATE is required")
+	}
+
+	// Invoice numbers such as RC/2026-27/00001 must fit GST's 16 characters
+	if !validSeries(config.InvoiceSeries) || !validSeries(config.CreditNoteSeries) || config.InvoiceSeries == config.CreditNoteSeries {
+		return nil, fmt.Errorf("INVOICE_SERIES and CREDIT_NOTE_SERIES must be different codes of 1-2 letters or digits")
+	}
+	if (config.SellerGSTIN == "" || config.SellerAddress == "") && config.IsProduction() {
+		return nil, fmt.Errorf("SELLER_GSTIN and SELLER_ADDRESS are required")
+	}
+
+	// The checkout gateway must be configured. The fake gateway signs
+	// webhooks with a secret in the source, so it is only enabled when
+	// chosen explicitly, and never in production.
+	switch config.PaymentGateway {
+	case "razorpay":
+		if !config.HasRazorpay() {
+			return nil, fmt.Errorf("RAZORPAY_KEY_ID and RAZORPAY_KEY_SECRET are required (or PAYMENT_GATEWAY=fake for development)")
+		}
+	case "stripe":
+		if !config.HasStripe() {
+			return nil, fmt.Errorf("STRIPE_SECRET_KEY, STRIPE_PUBLISHABLE_KEY and STRIPE_WEBHOOK_SECRET are required (or PAYMENT_GATEWAY=fake for development)")
+		}
+	case "fake":
 		if config.IsProduction() {
-			return nil, fmt.Errorf("RAZORPAY_KEY_ID and RAZORPAY_KEY_SECRET are required")
+			return nil, fmt.Errorf("PAYMENT_GATEWAY=fake is not allowed in production")
 		}
+	default:
+		return nil, fmt.Errorf("PAYMENT_GATEWAY must be razorpay, stripe or fake")
+	}
+
+	// Likewise the email transport
+	switch config.EmailTransport {
+	case "smtp":
+		if config.SMTPHost == "" {
+			return nil, fmt.Errorf("SMTP_HOST is required")
+		}
+		switch config.SMTPSecurity {
+		case "", "starttls", "tls", "none":
+		default:
+			return nil, fmt.Errorf("SMTP_SECURITY must be starttls, tls or none")
+		}
+	case "api":
+		if config.EmailAPIKey == "" {
+			return nil, fmt.Errorf("EMAIL_API_KEY is required")
+		}
+	case "file":
+		if config.IsProduction() {
+			return nil, fmt.Errorf("EMAIL_TRANSPORT=file is not allowed in production")
+		}
+	default:
+		return nil, fmt.Errorf("EMAIL_TRANSPORT must be smtp, api or file")
+	}
+	if config.EmailTimeoutSeconds <= 0 {
+		return nil, fmt.Errorf("EMAIL_TIMEOUT_SECONDS must be positive")
+	}
+
+	// Likewise the shipping carrier, whose fake accepts forged tracking
+	// webhooks
+	switch config.ShippingCarrier {
+	case "shiprocket":
+		if !config.HasShiprocket() {
+			return nil, fmt.Errorf("SHIPROCKET_EMAIL, SHIPROCKET_PASSWORD and SHIPROCKET_WEBHOOK_TOKEN are required (or SHIPPING_CARRIER=fake for development)")
+		}
+	case "fake":
+		if config.IsProduction() {
+			return nil, fmt.Errorf("SHIPPING_CARRIER=fake is not allowed in production")
+		}
+	default:
+		return nil, fmt.Errorf("SHIPPING_CARRIER must be shiprocket or fake")
 	}
 
 	return config, nil
 }
 
+// HasRazorpay returns true if Razorpay credentials are configured
+func (c *Config) HasRazorpay() bool {
+	return c.RazorpayKeyID != "" && c.RazorpayKeySecret != ""
+}
+
+// HasStripe returns true if Stripe credentials are configured
+func (c *Config) HasStripe() bool {
+	return c.StripeSecretKey != "" && c.StripePublishableKey != "" && c.StripeWebhookSecret != ""
+}
+
+// HasShiprocket returns true if Shiprocket credentials are configured
+func (c *Config) HasShiprocket() bool {
+	return c.ShiprocketEmail != "" && c.ShiprocketPassword != "" && c.ShiprocketWebhookToken != ""
+}
+
 // IsProduction returns true if running in production environment
 func (c *Config) IsProduction() bool {
 	return c.Environment == "production"
@@ -120,3 +370,30 @@ func getEnvAsInt(key string, defaultValue int) int {
 
 	return value
 }
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	value, err := strconv.ParseBool(valueStr)
+	if err != nil {
+		return defaultValue
+	}
+
+	return value
+}
+
+// validSeries reports whether s is a 1-2 character invoice series code
+func validSeries(s string) bool {
+	if len(s) < 1 || len(s) > 2 {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
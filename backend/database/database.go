@@ -79,3 +79,41 @@ func GetDefaultConfig(databaseURL string) Config {
 		ConnMaxIdleTime: 5 * time.Minute,
 	}
 }
+
+// Advisory lock keys for jobs that must run on a single replica at a time
+const (
+	LockKeyExpireOrders      int64 = 7_300_001
+	LockKeyIssueInvoices     int64 = 7_300_002
+	LockKeySendOrderEmails   int64 = 7_300_003
+	LockKeyReconcilePayments int64 = 7_300_004
+)
+
+// TryAdvisoryLock takes a session-level Postgres advisory lock on a dedicated
+// connection without waiting. When acquired is false another session holds
+// the lock. release must be called to unlock and return the connection.
+func TryAdvisoryLock(ctx context.Context, db *sql.DB, key int64) (release func(), acquired bool, err error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get connection: %w", err)
+	}
+
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, false, fmt.Errorf("failed to take advisory lock: %w", err)
+	}
+
+	if !acquired {
+		conn.Close()
+		return nil, false, nil
+	}
+
+	release = func() {
+		// Use a fresh context so the lock is released even after cancellation
+		unlockCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		conn.ExecContext(unlockCtx, "SELECT pg_advisory_unlock($1)", key)
+		conn.Close()
+	}
+
+	return release, true, nil
+}
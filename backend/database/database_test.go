@@ -127,3 +127,48 @@ func TestClose(t *testing.T) {
 		t.Errorf("Close failed: %v", err)
 	}
 }
+
+func TestTryAdvisoryLock(t *testing.T) {
+	// Skip if DATABASE_URL is not set
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL not set, skipping integration test")
+	}
+
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+
+	cfg := GetDefaultConfig(databaseURL)
+	err = Connect(cfg, logger)
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer Close()
+
+	ctx := context.Background()
+	const key int64 = 7_399_999
+
+	release, acquired, err := TryAdvisoryLock(ctx, DB, key)
+	if err != nil || !acquired {
+		t.Fatalf("Expected to acquire lock, got acquired=%v err=%v", acquired, err)
+	}
+
+	// A second session must not get the lock while it is held
+	_, acquired, err = TryAdvisoryLock(ctx, DB, key)
+	if err != nil {
+		t.Fatalf("TryAdvisoryLock failed: %v", err)
+	}
+	if acquired {
+		t.Fatal("Expected lock to be held by the first session")
+	}
+
+	release()
+
+	release, acquired, err = TryAdvisoryLock(ctx, DB, key)
+	if err != nil || !acquired {
+		t.Fatalf("Expected to re-acquire lock after release, got acquired=%v err=%v", acquired, err)
+	}
+	release()
+}
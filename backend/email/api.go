@@ -0,0 +1,149 @@
+package email
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// SendGridAPIURL is the default endpoint of the HTTP email API transport
+const SendGridAPIURL = "https://api.sendgrid.com/v3/mail/send"
+
+// APIConfig configures sending through an HTTP email API. Requests use the
+// SendGrid v3 mail/send format, which other providers and relays accept too.
+type APIConfig struct {
+	URL     string // defaults to SendGridAPIURL
+	Key     string // sent as a bearer token
+	From    string
+	Timeout time.Duration // defaults to 30 seconds
+	Headers Headers
+}
+
+// APIEmailSender implements EmailSender with an HTTP email API
+type APIEmailSender struct {
+	config     APIConfig
+	httpClient *http.Client
+	logger     *zap.Logger
+}
+
+// NewAPIEmailSender creates a new HTTP API email sender
+func NewAPIEmailSender(config APIConfig, logger *zap.Logger) *APIEmailSender {
+	if config.URL == "" {
+		config.URL = SendGridAPIURL
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 30 * time.Second
+	}
+
+	return &APIEmailSender{
+		config: config,
+		httpClient: &http.Client{
+			Timeout: config.Timeout,
+		},
+		logger: logger,
+	}
+}
+
+type apiAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type apiContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type apiPersonalization struct {
+	To []apiAddress `json:"to"`
+}
+
+type apiMessage struct {
+	Personalizations []apiPersonalization `json:"personalizations"`
+	From             apiAddress           `json:"from"`
+	ReplyTo          *apiAddress          `json:"reply_to,omitempty"`
+	Subject          string               `json:"subject"`
+	Content          []apiContent         `json:"content"`
+	Headers          map[string]string    `json:"headers,omitempty"`
+}
+
+// Send sends an email through the API
+func (a *APIEmailSender) Send(to string, message *Message) error {
+	body := apiMessage{
+		Personalizations: []apiPersonalization{{To: []apiAddress{{Email: to}}}},
+		From:             parseAPIAddress(a.config.From),
+		Subject:          message.Subject,
+		Content: []apiContent{
+			{Type: "text/plain", Value: message.Text},
+			{Type: "text/html", Value: message.HTML},
+		},
+	}
+	if a.config.Headers.ReplyTo != "" {
+		replyTo := parseAPIAddress(a.config.Headers.ReplyTo)
+		body.ReplyTo = &replyTo
+	}
+	if a.config.Headers.ListUnsubscribe != "" {
+		body.Headers = map[string]string{"List-Unsubscribe": a.config.Headers.listUnsubscribe()}
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, a.config.URL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+a.config.Key)
+
+	resp, err := a.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("email API error (status %d): %s", resp.StatusCode, apiErrorMessage(respBody))
+	}
+
+	a.logger.Info("Email sent successfully",
+		zap.String("to", to),
+		zap.String("subject", message.Subject),
+	)
+
+	return nil
+}
+
+func parseAPIAddress(value string) apiAddress {
+	if addr, err := mail.ParseAddress(value); err == nil {
+		return apiAddress{Email: addr.Address, Name: addr.Name}
+	}
+	return apiAddress{Email: value}
+}
+
+// apiErrorMessage extracts the messages of a SendGrid-style error response
+func apiErrorMessage(body []byte) string {
+	var apiErr struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if json.Unmarshal(body, &apiErr) == nil && len(apiErr.Errors) > 0 {
+		messages := make([]string, len(apiErr.Errors))
+		for i, e := range apiErr.Errors {
+			messages[i] = e.Message
+		}
+		return strings.Join(messages, "; ")
+	}
+	return strings.TrimSpace(string(body))
+}
@@ -0,0 +1,66 @@
+package email
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestAPIEmailSender(t *testing.T) {
+	var got apiMessage
+	var auth string
+	fail := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if fail {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":[{"message":"The from address does not match a verified Sender Identity"}]}`))
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	sender := NewAPIEmailSender(APIConfig{
+		URL:  server.URL,
+		Key:  "SG.test-key",
+		From: "Ramniya Creations <noreply@ramniyacreations.com>",
+		Headers: Headers{
+			ReplyTo:         "support@ramniyacreations.com",
+			ListUnsubscribe: "mailto:unsubscribe@ramniyacreations.com",
+		},
+	}, zap.NewNop())
+
+	renderer, _ := NewRenderer("")
+	msg, _ := renderer.Render(TemplateWelcome, AccountData{Name: "Asha"})
+	if err := sender.Send("asha@example.com", msg); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	if auth != "Bearer SG.test-key" {
+		t.Errorf("Authorization = %q", auth)
+	}
+	if len(got.Personalizations) != 1 || got.Personalizations[0].To[0].Email != "asha@example.com" {
+		t.Errorf("Personalizations = %+v", got.Personalizations)
+	}
+	if got.From != (apiAddress{Email: "noreply@ramniyacreations.com", Name: "Ramniya Creations"}) || got.ReplyTo == nil || got.ReplyTo.Email != "support@ramniyacreations.com" {
+		t.Errorf("From = %+v, Reply-To = %+v", got.From, got.ReplyTo)
+	}
+	if got.Subject != msg.Subject || len(got.Content) != 2 || got.Content[0].Value != msg.Text || got.Content[1].Value != msg.HTML {
+		t.Errorf("Unexpected content %+v", got)
+	}
+	if got.Headers["List-Unsubscribe"] != "<mailto:unsubscribe@ramniyacreations.com>" {
+		t.Errorf("Headers = %v", got.Headers)
+	}
+
+	fail = true
+	err := sender.Send("asha@example.com", msg)
+	if err == nil || !strings.Contains(err.Error(), "status 400") || !strings.Contains(err.Error(), "verified Sender Identity") {
+		t.Errorf("Expected the API error, got %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package email
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Headers are optional headers added to every email, whichever transport
+// sends it
+type Headers struct {
+	ReplyTo string
+	// ListUnsubscribe is a mailto: or https: URL mailbox providers offer as
+	// an unsubscribe button
+	ListUnsubscribe string
+}
+
+// MIME encodes the message as a multipart/alternative email with a
+// plain-text part and an HTML part. Clients show the last part they
+// support, so the HTML part comes last.
+func (m *Message) MIME(from, to string, headers Headers) ([]byte, error) {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=UTF-8", m.Text},
+		{"text/html; charset=UTF-8", m.HTML},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create message part: %w", err)
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, fmt.Errorf("failed to encode message part: %w", err)
+		}
+		if err := qp.Close(); err != nil {
+			return nil, fmt.Errorf("failed to encode message part: %w", err)
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close message: %w", err)
+	}
+
+	messageID, err := newMessageID(from)
+	if err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Message-ID: %s\r\n", messageID)
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	if headers.ReplyTo != "" {
+		fmt.Fprintf(&msg, "Reply-To: %s\r\n", headers.ReplyTo)
+	}
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", m.Subject))
+	if headers.ListUnsubscribe != "" {
+		fmt.Fprintf(&msg, "List-Unsubscribe: %s\r\n", headers.listUnsubscribe())
+	}
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%q\r\n", parts.Boundary())
+	msg.WriteString("\r\n")
+	msg.Write(body.Bytes())
+
+	return msg.Bytes(), nil
+}
+
+// listUnsubscribe returns the List-Unsubscribe header value, which wraps the
+// URL in angle brackets
+func (h Headers) listUnsubscribe() string {
+	if strings.HasPrefix(h.ListUnsubscribe, "<") {
+		return h.ListUnsubscribe
+	}
+	return "<" + h.ListUnsubscribe + ">"
+}
+
+// newMessageID returns a unique Message-ID in the domain of the sender
+func newMessageID(from string) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("failed to generate message ID: %w", err)
+	}
+
+	domain := "localhost"
+	if _, host, ok := strings.Cut(envelopeAddress(from), "@"); ok && host != "" {
+		domain = host
+	}
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(random), domain), nil
+}
+
+// envelopeAddress returns the bare address of a From value such as
+// "Ramniya Creations <noreply@ramniyacreations.com>"
+func envelopeAddress(from string) string {
+	if addr, err := mail.ParseAddress(from); err == nil {
+		return addr.Address
+	}
+	return from
+}
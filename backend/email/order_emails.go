@@ -0,0 +1,114 @@
+package email
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// OrderEmail holds the order details shown in order emails. Amounts are in
+// paise.
+type OrderEmail struct {
+	Reference       string // short order number shown to customers
+	URL             string // order page the email links to
+	CustomerName    string
+	CustomerEmail   string
+	Items           []OrderEmailItem
+	SubtotalCents   int
+	DiscountCents   int
+	CouponCode      string
+	TaxCents        int
+	TaxIncluded     bool // GST is part of the item prices rather than added
+	ShippingCents   int
+	CODFeeCents     int
+	TotalCents      int
+	CashOnDelivery  bool
+	ShippingAddress []string // one entry per address line
+	Courier         string
+	TrackingNumber  string
+	TrackingURL     string
+	RefundCents     int
+	RefundReason    string
+}
+
+// OrderEmailItem is an order line shown in order emails
+type OrderEmailItem struct {
+	Title      string
+	Quantity   int
+	PriceCents int // per unit
+}
+
+// TotalCents is the line total
+func (i OrderEmailItem) TotalCents() int {
+	return i.PriceCents * i.Quantity
+}
+
+// TotalRow is a row of an order's totals
+type TotalRow struct {
+	Label  string
+	Amount string
+	Grand  bool // the order total
+}
+
+// Totals returns the subtotal, discount, charges, GST and total rows shown
+// under the items
+func (o OrderEmail) Totals() []TotalRow {
+	rows := []TotalRow{{Label: "Subtotal", Amount: formatRupees(o.SubtotalCents)}}
+	if o.DiscountCents > 0 {
+		label := "Discount"
+		if o.CouponCode != "" {
+			label += " (" + o.CouponCode + ")"
+		}
+		rows = append(rows, TotalRow{Label: label, Amount: "-" + formatRupees(o.DiscountCents)})
+	}
+	if o.ShippingCents > 0 {
+		rows = append(rows, TotalRow{Label: "Shipping", Amount: formatRupees(o.ShippingCents)})
+	} else {
+		rows = append(rows, TotalRow{Label: "Shipping", Amount: "Free"})
+	}
+	if o.CODFeeCents > 0 {
+		rows = append(rows, TotalRow{Label: "Cash on delivery fee", Amount: formatRupees(o.CODFeeCents)})
+	}
+	if o.TaxCents > 0 {
+		label := "GST"
+		if o.TaxIncluded {
+			label = "GST (included)"
+		}
+		rows = append(rows, TotalRow{Label: label, Amount: formatRupees(o.TaxCents)})
+	}
+	return append(rows, TotalRow{Label: "Total", Amount: formatRupees(o.TotalCents), Grand: true})
+}
+
+// TrackingDetails describes the courier and tracking number, or "" if
+// unknown
+func (o OrderEmail) TrackingDetails() string {
+	switch {
+	case o.Courier != "" && o.TrackingNumber != "":
+		return fmt.Sprintf("%s, tracking number %s", o.Courier, o.TrackingNumber)
+	case o.TrackingNumber != "":
+		return "Tracking number " + o.TrackingNumber
+	}
+	return o.Courier
+}
+
+// formatRupees formats paise as rupees with Indian digit grouping, e.g.
+// ₹1,23,456.00
+func formatRupees(cents int) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	rupees := strconv.Itoa(cents / 100)
+	if len(rupees) > 3 {
+		head, tail := rupees[:len(rupees)-3], rupees[len(rupees)-3:]
+		var groups []string
+		for len(head) > 2 {
+			groups = append([]string{head[len(head)-2:]}, groups...)
+			head = head[:len(head)-2]
+		}
+		groups = append([]string{head}, groups...)
+		rupees = strings.Join(groups, ",") + "," + tail
+	}
+	return fmt.Sprintf("%s₹%s.%02d", sign, rupees, cents%100)
+}
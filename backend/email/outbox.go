@@ -0,0 +1,378 @@
+package email
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxStatus represents the delivery state of a queued email
+type OutboxStatus string
+
+const (
+	OutboxPending OutboxStatus = "pending"
+	OutboxSending OutboxStatus = "sending"
+	OutboxSent    OutboxStatus = "sent"
+	OutboxDead    OutboxStatus = "dead"
+)
+
+var (
+	// ErrOutboxEmailNotFound is returned for unknown outbox email IDs
+	ErrOutboxEmailNotFound = errors.New("outbox email not found")
+	// ErrOutboxEmailSending is returned when resending an email a worker is
+	// sending
+	ErrOutboxEmailSending = errors.New("outbox email is being sent")
+	// ErrOutboxLeaseLost is returned when recording the outcome of an email
+	// whose claim ran out and was taken over, or which an admin resent
+	ErrOutboxLeaseLost = errors.New("outbox email lease lost")
+)
+
+// Execer runs a statement. *sql.DB and *sql.Tx both satisfy it, so emails
+// can be queued in the transaction of the change they announce.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Outgoing is an email to queue
+type Outgoing struct {
+	To       string
+	Template string
+	Data     interface{} // AccountData or OrderData, depending on the template
+	// DedupeKey names the event the email is about, e.g. "welcome:<user id>".
+	// The recipient gets at most one email per key; empty allows repeats.
+	DedupeKey string
+}
+
+// OutboxEmail is a queued email
+type OutboxEmail struct {
+	ID            uuid.UUID       `json:"id"`
+	Template      string          `json:"template"`
+	Recipient     string          `json:"recipient"`
+	Data          json.RawMessage `json:"data"`
+	DedupeKey     *string         `json:"dedupe_key,omitempty"`
+	Status        OutboxStatus    `json:"status"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	LockedUntil   *time.Time      `json:"locked_until,omitempty"`
+	LastError     *string         `json:"last_error,omitempty"`
+	SentAt        *time.Time      `json:"sent_at,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
+
+// ListOutboxFilter represents filters for listing queued emails
+type ListOutboxFilter struct {
+	Status    *OutboxStatus
+	Recipient string
+	Template  string
+	Limit     int
+	Offset    int
+}
+
+// OutboxRepository stores queued emails
+type OutboxRepository struct {
+	db *sql.DB
+}
+
+// NewOutboxRepository creates a new outbox repository
+func NewOutboxRepository(db *sql.DB) *OutboxRepository {
+	return &OutboxRepository{db: db}
+}
+
+const outboxColumns = `id, template, recipient, data, dedupe_key, status, attempts, next_attempt_at,
+		       locked_until, last_error, sent_at, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanOutboxEmail(row rowScanner) (*OutboxEmail, error) {
+	var e OutboxEmail
+	var data []byte
+	err := row.Scan(
+		&e.ID, &e.Template, &e.Recipient, &data, &e.DedupeKey, &e.Status, &e.Attempts, &e.NextAttemptAt,
+		&e.LockedUntil, &e.LastError, &e.SentAt, &e.CreatedAt, &e.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	e.Data = data
+	return &e, nil
+}
+
+// Enqueue queues an email through exec, which is normally the transaction
+// making the change the email is about. It returns false if the recipient
+// already has an email with the same dedupe key.
+func (r *OutboxRepository) Enqueue(ctx context.Context, exec Execer, out Outgoing) (bool, error) {
+	if !isTemplate(out.Template) {
+		return false, fmt.Errorf("%w: %s", ErrUnknownTemplate, out.Template)
+	}
+
+	data, err := json.Marshal(out.Data)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal email data: %w", err)
+	}
+
+	var dedupeKey *string
+	if out.DedupeKey != "" {
+		dedupeKey = &out.DedupeKey
+	}
+
+	result, err := exec.ExecContext(ctx, `
+		INSERT INTO email_outbox (template, recipient, data, dedupe_key)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (recipient, dedupe_key) WHERE dedupe_key IS NOT NULL DO NOTHING
+	`, out.Template, out.To, data, dedupeKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to queue email: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// Claim marks up to limit due emails as sending for the lease and counts the
+// attempt. Emails whose lease ran out (the worker crashed mid-send) are
+// claimed again. Rows locked by another replica's claim are skipped, so
+// replicas never claim the same email.
+func (r *OutboxRepository) Claim(ctx context.Context, limit int, lease time.Duration) ([]OutboxEmail, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		UPDATE email_outbox
+		SET status = $1, attempts = attempts + 1, locked_until = $2
+		WHERE id IN (
+			SELECT id FROM email_outbox
+			WHERE (status = $3 AND next_attempt_at <= NOW())
+			   OR (status = $1 AND locked_until < NOW())
+			ORDER BY next_attempt_at, created_at
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+outboxColumns,
+		OutboxSending, time.Now().Add(lease), OutboxPending, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim emails: %w", err)
+	}
+	defer rows.Close()
+
+	emails := []OutboxEmail{}
+	for rows.Next() {
+		e, err := scanOutboxEmail(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan outbox email: %w", err)
+		}
+		emails = append(emails, *e)
+	}
+
+	return emails, rows.Err()
+}
+
+// MarkSent records that a claimed email was delivered. lockedUntil is the
+// lease from Claim; if the email has since been claimed again or resent,
+// nothing changes and ErrOutboxLeaseLost is returned.
+func (r *OutboxRepository) MarkSent(ctx context.Context, id uuid.UUID, lockedUntil time.Time) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE email_outbox
+		SET status = $1, sent_at = NOW(), locked_until = NULL, last_error = NULL
+		WHERE id = $2 AND status = $3 AND locked_until = $4
+	`, OutboxSent, id, OutboxSending, lockedUntil)
+	if err != nil {
+		return fmt.Errorf("failed to mark email sent: %w", err)
+	}
+	return checkLease(result)
+}
+
+// MarkFailed records a delivery error. The email is retried at nextAttempt,
+// or dead-lettered if nextAttempt is nil. Like MarkSent it only applies while
+// the worker still holds the lease from Claim.
+func (r *OutboxRepository) MarkFailed(ctx context.Context, id uuid.UUID, lockedUntil time.Time, errText string, nextAttempt *time.Time) error {
+	status := OutboxDead
+	if nextAttempt != nil {
+		status = OutboxPending
+	}
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE email_outbox
+		SET status = $1, last_error = $2, next_attempt_at = COALESCE($3, next_attempt_at), locked_until = NULL
+		WHERE id = $4 AND status = $5 AND locked_until = $6
+	`, status, errText, nextAttempt, id, OutboxSending, lockedUntil)
+	if err != nil {
+		return fmt.Errorf("failed to record email failure: %w", err)
+	}
+	return checkLease(result)
+}
+
+// checkLease returns ErrOutboxLeaseLost if a lease-guarded update matched no
+// row
+func checkLease(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrOutboxLeaseLost
+	}
+	return nil
+}
+
+// Get retrieves a queued email by ID
+func (r *OutboxRepository) Get(ctx context.Context, id uuid.UUID) (*OutboxEmail, error) {
+	e, err := scanOutboxEmail(r.db.QueryRowContext(ctx,
+		"SELECT "+outboxColumns+" FROM email_outbox WHERE id = $1",
+		id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrOutboxEmailNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get outbox email: %w", err)
+	}
+
+	return e, nil
+}
+
+// Resend queues a sent or dead email again with a fresh set of attempts.
+// Returns ErrOutboxEmailSending if a worker has it claimed.
+func (r *OutboxRepository) Resend(ctx context.Context, id uuid.UUID) (*OutboxEmail, error) {
+	e, err := scanOutboxEmail(r.db.QueryRowContext(ctx, `
+		UPDATE email_outbox
+		SET status = $1, attempts = 0, next_attempt_at = NOW(), locked_until = NULL, last_error = NULL, sent_at = NULL
+		WHERE id = $2 AND status <> $3
+		RETURNING `+outboxColumns,
+		OutboxPending, id, OutboxSending,
+	))
+	if err == nil {
+		return e, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to resend email: %w", err)
+	}
+
+	if _, err := r.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	return nil, ErrOutboxEmailSending
+}
+
+// List retrieves queued emails, newest first
+func (r *OutboxRepository) List(ctx context.Context, filter ListOutboxFilter) ([]OutboxEmail, int, error) {
+	where := " WHERE 1=1"
+	args := []interface{}{}
+	argCount := 1
+
+	if filter.Status != nil {
+		where += fmt.Sprintf(" AND status = $%d", argCount)
+		args = append(args, *filter.Status)
+		argCount++
+	}
+
+	if filter.Recipient != "" {
+		where += fmt.Sprintf(" AND recipient = $%d", argCount)
+		args = append(args, filter.Recipient)
+		argCount++
+	}
+
+	if filter.Template != "" {
+		where += fmt.Sprintf(" AND template = $%d", argCount)
+		args = append(args, filter.Template)
+		argCount++
+	}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM email_outbox"+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count outbox emails: %w", err)
+	}
+
+	limit := 20
+	if filter.Limit > 0 && filter.Limit <= 100 {
+		limit = filter.Limit
+	}
+
+	query := "SELECT " + outboxColumns + " FROM email_outbox" + where +
+		fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d OFFSET $%d", argCount, argCount+1)
+	args = append(args, limit, filter.Offset)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list outbox emails: %w", err)
+	}
+	defer rows.Close()
+
+	emails := []OutboxEmail{}
+	for rows.Next() {
+		e, err := scanOutboxEmail(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan outbox email: %w", err)
+		}
+		emails = append(emails, *e)
+	}
+
+	return emails, total, rows.Err()
+}
+
+// redactedURL replaces account email links in outbox data shown to admins
+const redactedURL = "[redacted]"
+
+// Redact hides the link in verification and password reset emails, which
+// carries a live token, so the email can be shown to admins. Data that cannot
+// be decoded is dropped.
+func (e *OutboxEmail) Redact() {
+	if e.Template != TemplateVerification && e.Template != TemplatePasswordReset {
+		return
+	}
+
+	var data AccountData
+	if err := json.Unmarshal(e.Data, &data); err != nil {
+		e.Data = nil
+		return
+	}
+	if data.URL != "" {
+		data.URL = redactedURL
+	}
+
+	redacted, err := json.Marshal(data)
+	if err != nil {
+		e.Data = nil
+		return
+	}
+	e.Data = redacted
+}
+
+// decodeData unmarshals queued template data into the type the template
+// expects
+func decodeData(template string, raw json.RawMessage) (interface{}, error) {
+	switch template {
+	case TemplateVerification, TemplatePasswordReset, TemplateWelcome:
+		var data AccountData
+		if err := json.Unmarshal(raw, &data); err != nil {
+			return nil, fmt.Errorf("failed to decode %s data: %w", template, err)
+		}
+		return data, nil
+	case TemplateOrderConfirmation, TemplatePaymentFailed, TemplateOrderShipped, TemplateOrderDelivered,
+		TemplateOrderCancelled, TemplateOrderRefunded, TemplateNewOrder:
+		var data OrderData
+		if err := json.Unmarshal(raw, &data); err != nil {
+			return nil, fmt.Errorf("failed to decode %s data: %w", template, err)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownTemplate, template)
+}
+
+func isTemplate(name string) bool {
+	for _, t := range Templates {
+		if t == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package email
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+)
+
+func setupTestDB(t *testing.T) *sql.DB {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL not set, skipping integration test")
+	}
+
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		t.Fatalf("Failed to ping database: %v", err)
+	}
+
+	return db
+}
+
+func TestOutboxRepository(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	ctx := context.Background()
+	repo := NewOutboxRepository(db)
+	recipient := "outbox-test-" + uuid.NewString() + "@example.com"
+	defer db.Exec("DELETE FROM email_outbox WHERE recipient = $1", recipient)
+
+	welcome := Outgoing{To: recipient, Template: TemplateWelcome, Data: AccountData{Name: "Asha"}, DedupeKey: "welcome:test"}
+	queued, err := repo.Enqueue(ctx, db, welcome)
+	if err != nil || !queued {
+		t.Fatalf("Enqueue = %v, %v", queued, err)
+	}
+	if queued, _ := repo.Enqueue(ctx, db, welcome); queued {
+		t.Error("Email with a repeated dedupe key was queued")
+	}
+
+	// Emails queued in a rolled back transaction are never sent
+	tx, _ := db.BeginTx(ctx, nil)
+	repo.Enqueue(ctx, tx, Outgoing{To: recipient, Template: TemplateVerification, Data: AccountData{Name: "Asha"}})
+	tx.Rollback()
+
+	if _, err := repo.Enqueue(ctx, db, Outgoing{To: recipient, Template: "invoice"}); !errors.Is(err, ErrUnknownTemplate) {
+		t.Errorf("Expected ErrUnknownTemplate, got %v", err)
+	}
+
+	emails, total, err := repo.List(ctx, ListOutboxFilter{Recipient: recipient})
+	if err != nil || total != 1 {
+		t.Fatalf("List = %d emails, %v; want 1", total, err)
+	}
+	id := emails[0].ID
+
+	// Claim may pick up other due emails; only ours is marked here
+	claimed, err := repo.Claim(ctx, 100, time.Minute)
+	if err != nil {
+		t.Fatalf("Claim failed: %v", err)
+	}
+	var lease time.Time
+	for _, e := range claimed {
+		if e.ID == id && e.Status == OutboxSending && e.Attempts == 1 && e.LockedUntil != nil {
+			lease = *e.LockedUntil
+		}
+	}
+	if lease.IsZero() {
+		t.Fatal("Queued email was not claimed")
+	}
+
+	if _, err := repo.Resend(ctx, id); !errors.Is(err, ErrOutboxEmailSending) {
+		t.Errorf("Resend while sending = %v, want ErrOutboxEmailSending", err)
+	}
+
+	// An outcome under someone else's lease is not recorded
+	if err := repo.MarkSent(ctx, id, lease.Add(time.Second)); !errors.Is(err, ErrOutboxLeaseLost) {
+		t.Errorf("MarkSent with a stale lease = %v, want ErrOutboxLeaseLost", err)
+	}
+
+	if err := repo.MarkFailed(ctx, id, lease, "550 mailbox unavailable", nil); err != nil {
+		t.Fatalf("MarkFailed failed: %v", err)
+	}
+	dead, _ := repo.Get(ctx, id)
+	if dead.Status != OutboxDead || dead.LastError == nil || *dead.LastError != "550 mailbox unavailable" {
+		t.Errorf("Email after failure = %+v, want dead", dead)
+	}
+
+	resent, err := repo.Resend(ctx, id)
+	if err != nil {
+		t.Fatalf("Resend failed: %v", err)
+	}
+	if resent.Status != OutboxPending || resent.Attempts != 0 || resent.LastError != nil {
+		t.Errorf("Resent email = %+v, want pending with no attempts", resent)
+	}
+
+	// The dead email is no longer leased, so a late outcome is dropped
+	if err := repo.MarkFailed(ctx, id, lease, "timeout", nil); !errors.Is(err, ErrOutboxLeaseLost) {
+		t.Errorf("MarkFailed after resend = %v, want ErrOutboxLeaseLost", err)
+	}
+
+	if _, err := repo.Get(ctx, uuid.New()); !errors.Is(err, ErrOutboxEmailNotFound) {
+		t.Errorf("Expected ErrOutboxEmailNotFound, got %v", err)
+	}
+}
+
+func TestOutboxEmailRedact(t *testing.T) {
+	reset := OutboxEmail{
+		Template: TemplatePasswordReset,
+		Data:     []byte(`{"Name":"Asha","URL":"https://ramniya.com/auth/reset-password?token=abc"}`),
+	}
+	reset.Redact()
+	if string(reset.Data) != `{"Name":"Asha","URL":"[redacted]"}` {
+		t.Errorf("Redacted data = %s", reset.Data)
+	}
+
+	// Order emails carry no tokens and are shown as queued
+	order := OutboxEmail{Template: TemplateOrderShipped, Data: []byte(`{"Name":"Ravi"}`)}
+	order.Redact()
+	if string(order.Data) != `{"Name":"Ravi"}` {
+		t.Errorf("Order data = %s, want unchanged", order.Data)
+	}
+
+	broken := OutboxEmail{Template: TemplateVerification, Data: []byte(`not json`)}
+	broken.Redact()
+	if broken.Data != nil {
+		t.Errorf("Undecodable data = %s, want dropped", broken.Data)
+	}
+}
@@ -0,0 +1,51 @@
+package email
+
+import "fmt"
+
+// sampleOrder is a cash on delivery order with a coupon, shipping and GST,
+// so the previews show every total row
+var sampleOrder = OrderEmail{
+	Reference:     "3F2A9C1B",
+	URL:           "https://ramniya.com/orders/3f2a9c1b-5d4e-4f60-8a7b-9c0d1e2f3a4b",
+	CustomerName:  "Asha Rao",
+	CustomerEmail: "asha@example.com",
+	Items: []OrderEmailItem{
+		{Title: "Kanjivaram Silk Saree", Quantity: 1, PriceCents: 1250000},
+		{Title: "Hand-painted Diya (Set of 4)", Quantity: 2, PriceCents: 45000},
+	},
+	SubtotalCents:   1340000,
+	DiscountCents:   134000,
+	CouponCode:      "DIWALI10",
+	TaxCents:        60300,
+	TaxIncluded:     true,
+	ShippingCents:   6900,
+	CODFeeCents:     4900,
+	TotalCents:      1217800,
+	CashOnDelivery:  true,
+	ShippingAddress: []string{"Asha Rao", "12 MG Road", "Chennai, Tamil Nadu 600001", "Phone: 9876543210"},
+	Courier:         "Delhivery Surface",
+	TrackingNumber:  "19041234567890",
+	TrackingURL:     "https://shiprocket.co/tracking/19041234567890",
+	RefundCents:     45000,
+	RefundReason:    "One diya arrived broken",
+}
+
+// SampleData returns example data for a template, for previews
+func SampleData(name string) (interface{}, error) {
+	switch name {
+	case TemplateVerification:
+		return AccountData{Name: "Asha Rao", URL: "https://ramniya.com/auth/verify?token=sample-token"}, nil
+	case TemplatePasswordReset:
+		return AccountData{Name: "Asha Rao", URL: "https://ramniya.com/auth/reset-password?token=sample-token"}, nil
+	case TemplateWelcome:
+		return AccountData{Name: "Asha Rao"}, nil
+	case TemplateNewOrder:
+		order := sampleOrder
+		order.URL = "https://ramniya.com/admin/orders"
+		return OrderData{Order: order}, nil
+	case TemplateOrderConfirmation, TemplatePaymentFailed, TemplateOrderShipped, TemplateOrderDelivered,
+		TemplateOrderCancelled, TemplateOrderRefunded:
+		return OrderData{Name: "Asha Rao", Order: sampleOrder}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownTemplate, name)
+}
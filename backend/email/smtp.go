@@ -0,0 +1,227 @@
+package email
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// SMTPSecurity is how the connection to the SMTP server is encrypted
+type SMTPSecurity string
+
+const (
+	// SecuritySTARTTLS upgrades a plain connection, usually on port 587. The
+	// server must offer STARTTLS.
+	SecuritySTARTTLS SMTPSecurity = "starttls"
+	// SecurityTLS connects over TLS from the start, usually on port 465
+	SecurityTLS SMTPSecurity = "tls"
+	// SecurityNone sends in the clear, e.g. to a local relay. net/smtp only
+	// sends credentials in the clear to localhost.
+	SecurityNone SMTPSecurity = "none"
+)
+
+// SMTPConfig holds SMTP configuration
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string // empty skips authentication
+	Password string
+	From     string
+	// Security defaults to SecurityTLS on port 465 and SecuritySTARTTLS
+	// otherwise
+	Security SMTPSecurity
+	// Timeout bounds connecting and each email sent; defaults to 30 seconds
+	Timeout time.Duration
+	// PoolSize is how many idle connections are kept for reuse; defaults to 1
+	PoolSize  int
+	TLSConfig *tls.Config // optional, e.g. to trust a private CA
+	Headers   Headers
+}
+
+// SMTPEmailSender implements EmailSender using SMTP. Connections are kept
+// open between emails, so bulk sends do not pay for a handshake each.
+type SMTPEmailSender struct {
+	config SMTPConfig
+	logger *zap.Logger
+	idle   chan *smtpConn
+}
+
+// smtpConn is a pooled SMTP session
+type smtpConn struct {
+	client *smtp.Client
+	conn   net.Conn
+}
+
+// NewSMTPEmailSender creates a new SMTP email sender
+func NewSMTPEmailSender(config SMTPConfig, logger *zap.Logger) *SMTPEmailSender {
+	if config.Security == "" {
+		config.Security = SecuritySTARTTLS
+		if config.Port == 465 {
+			config.Security = SecurityTLS
+		}
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 30 * time.Second
+	}
+	if config.PoolSize <= 0 {
+		config.PoolSize = 1
+	}
+
+	return &SMTPEmailSender{
+		config: config,
+		logger: logger,
+		idle:   make(chan *smtpConn, config.PoolSize),
+	}
+}
+
+// Send sends an email via SMTP
+func (s *SMTPEmailSender) Send(to string, message *Message) error {
+	msg, err := message.MIME(s.config.From, to, s.config.Headers)
+	if err != nil {
+		return err
+	}
+
+	c, err := s.get()
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	if err := c.send(envelopeAddress(s.config.From), to, msg); err != nil {
+		// The session is in an unknown state, so it is not reused
+		c.client.Close()
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	s.put(c)
+
+	s.logger.Info("Email sent successfully",
+		zap.String("to", to),
+		zap.String("subject", message.Subject),
+	)
+
+	return nil
+}
+
+// Close ends the idle connections
+func (s *SMTPEmailSender) Close() error {
+	for {
+		select {
+		case c := <-s.idle:
+			c.conn.SetDeadline(time.Now().Add(s.config.Timeout))
+			c.client.Quit()
+		default:
+			return nil
+		}
+	}
+}
+
+// get returns an idle connection that is still open, or a new one
+func (s *SMTPEmailSender) get() (*smtpConn, error) {
+	for {
+		select {
+		case c := <-s.idle:
+			c.conn.SetDeadline(time.Now().Add(s.config.Timeout))
+			// The server may have closed the connection while it was idle
+			if err := c.client.Reset(); err != nil {
+				c.client.Close()
+				continue
+			}
+			return c, nil
+		default:
+			return s.dial()
+		}
+	}
+}
+
+// put returns a connection to the pool, or closes it if the pool is full
+func (s *SMTPEmailSender) put(c *smtpConn) {
+	select {
+	case s.idle <- c:
+	default:
+		c.client.Quit()
+	}
+}
+
+// dial connects, secures the connection and authenticates
+func (s *SMTPEmailSender) dial() (*smtpConn, error) {
+	addr := net.JoinHostPort(s.config.Host, strconv.Itoa(s.config.Port))
+	dialer := &net.Dialer{Timeout: s.config.Timeout}
+
+	var conn net.Conn
+	var err error
+	if s.config.Security == SecurityTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, s.tlsConfig())
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(s.config.Timeout))
+
+	client, err := smtp.NewClient(conn, s.config.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err := s.secure(client); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &smtpConn{client: client, conn: conn}, nil
+}
+
+func (s *SMTPEmailSender) secure(client *smtp.Client) error {
+	if s.config.Security == SecuritySTARTTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return errors.New("server does not support STARTTLS")
+		}
+		if err := client.StartTLS(s.tlsConfig()); err != nil {
+			return err
+		}
+	}
+
+	if s.config.Username == "" {
+		return nil
+	}
+	if ok, _ := client.Extension("AUTH"); !ok {
+		return errors.New("server does not support AUTH")
+	}
+	return client.Auth(smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host))
+}
+
+func (s *SMTPEmailSender) tlsConfig() *tls.Config {
+	config := &tls.Config{}
+	if s.config.TLSConfig != nil {
+		config = s.config.TLSConfig.Clone()
+	}
+	if config.ServerName == "" {
+		config.ServerName = s.config.Host
+	}
+	return config
+}
+
+// send runs one mail transaction
+func (c *smtpConn) send(from, to string, msg []byte) error {
+	if err := c.client.Mail(from); err != nil {
+		return err
+	}
+	if err := c.client.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	return w.Close()
+}
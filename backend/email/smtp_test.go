@@ -0,0 +1,358 @@
+package email
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// smtpServer is a local SMTP stand-in that records the messages it accepts.
+// It offers AUTH PLAIN, and STARTTLS or implicit TLS with a self-signed
+// certificate depending on the security it is started with. Without TLS
+// net/smtp only sends credentials because the server is on localhost.
+type smtpServer struct {
+	host     string
+	port     int
+	security SMTPSecurity
+	tls      *tls.Config
+	roots    *x509.CertPool
+
+	mu       sync.Mutex
+	messages []smtpMessage
+	conns    []net.Conn
+	reject   int // upcoming transactions to turn away with a 451
+}
+
+type smtpMessage struct {
+	Auth string // decoded AUTH PLAIN credentials
+	TLS  bool
+	From string
+	To   []string
+	Data string
+}
+
+func newSMTPServer(t *testing.T, security SMTPSecurity) *smtpServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	addr := listener.Addr().(*net.TCPAddr)
+	s := &smtpServer{host: addr.IP.String(), port: addr.Port, security: security}
+	s.tls, s.roots = selfSignedTLS(t)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			if security == SecurityTLS {
+				conn = tls.Server(conn, s.tls)
+			}
+			s.mu.Lock()
+			s.conns = append(s.conns, conn)
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(s.dropConnections)
+
+	return s
+}
+
+// selfSignedTLS returns a server config with a certificate for 127.0.0.1,
+// and a pool trusting it
+func selfSignedTLS(t *testing.T) (*tls.Config, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "smtp stand-in"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}, roots
+}
+
+// config returns an SMTP config pointing at the stand-in
+func (s *smtpServer) config() SMTPConfig {
+	return SMTPConfig{
+		Host:      s.host,
+		Port:      s.port,
+		Username:  "mailer",
+		Password:  "secret",
+		From:      "Ramniya Creations <noreply@ramniyacreations.com>",
+		Security:  s.security,
+		TLSConfig: &tls.Config{RootCAs: s.roots},
+	}
+}
+
+// rejectNext makes the next n transactions fail with a temporary error
+func (s *smtpServer) rejectNext(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reject = n
+}
+
+func (s *smtpServer) received() []smtpMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]smtpMessage(nil), s.messages...)
+}
+
+func (s *smtpServer) connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+// dropConnections closes every open connection, as servers do with idle ones
+func (s *smtpServer) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	_, secure := conn.(*tls.Conn)
+
+	var msg smtpMessage
+	text.PrintfLine("220 localhost ESMTP stand-in")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			extensions := []string{"localhost", "8BITMIME"}
+			if s.security == SecuritySTARTTLS && !secure {
+				extensions = append(extensions, "STARTTLS")
+			}
+			extensions = append(extensions, "AUTH PLAIN")
+			for i, ext := range extensions {
+				sep := "-"
+				if i == len(extensions)-1 {
+					sep = " "
+				}
+				text.PrintfLine("250%s%s", sep, ext)
+			}
+		case "STARTTLS":
+			text.PrintfLine("220 Ready to start TLS")
+			tlsConn := tls.Server(conn, s.tls)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, secure = tlsConn, true
+			text = textproto.NewConn(conn)
+			msg = smtpMessage{}
+		case "AUTH":
+			_, encoded, _ := strings.Cut(arg, " ")
+			decoded, _ := base64.StdEncoding.DecodeString(encoded)
+			msg.Auth = string(decoded)
+			text.PrintfLine("235 Authenticated")
+		case "MAIL":
+			s.mu.Lock()
+			rejected := s.reject > 0
+			if rejected {
+				s.reject--
+			}
+			s.mu.Unlock()
+			if rejected {
+				text.PrintfLine("451 Try again later")
+				continue
+			}
+			msg.From = pathAddress(arg)
+			text.PrintfLine("250 OK")
+		case "RCPT":
+			msg.To = append(msg.To, pathAddress(arg))
+			text.PrintfLine("250 OK")
+		case "DATA":
+			text.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := io.ReadAll(text.DotReader())
+			if err != nil {
+				return
+			}
+			msg.Data, msg.TLS = string(data), secure
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			msg = smtpMessage{Auth: msg.Auth}
+			text.PrintfLine("250 Queued")
+		case "RSET":
+			msg = smtpMessage{Auth: msg.Auth}
+			text.PrintfLine("250 OK")
+		case "NOOP":
+			text.PrintfLine("250 OK")
+		case "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+// pathAddress returns the address in a MAIL or RCPT argument such as
+// "FROM:<a@example.com> BODY=8BITMIME"
+func pathAddress(arg string) string {
+	_, rest, _ := strings.Cut(arg, "<")
+	address, _, _ := strings.Cut(rest, ">")
+	return address
+}
+
+func TestSMTPEmailSender(t *testing.T) {
+	renderer, _ := NewRenderer("")
+	msg, _ := renderer.Render(TemplateWelcome, AccountData{Name: "Asha"})
+
+	for _, security := range []SMTPSecurity{SecurityNone, SecuritySTARTTLS, SecurityTLS} {
+		t.Run(string(security), func(t *testing.T) {
+			server := newSMTPServer(t, security)
+			sender := NewSMTPEmailSender(server.config(), zap.NewNop())
+			defer sender.Close()
+
+			if err := sender.Send("asha@example.com", msg); err != nil {
+				t.Fatalf("Send failed: %v", err)
+			}
+
+			received := server.received()
+			if len(received) != 1 {
+				t.Fatalf("Server received %d messages, want 1", len(received))
+			}
+			got := received[0]
+			if got.Auth != "\x00mailer\x00secret" || got.From != "noreply@ramniyacreations.com" || strings.Join(got.To, ",") != "asha@example.com" {
+				t.Errorf("Unexpected envelope %+v", got)
+			}
+			if got.TLS != (security != SecurityNone) {
+				t.Errorf("TLS = %v", got.TLS)
+			}
+			parsed, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(got.Data)))
+			if err != nil {
+				t.Fatalf("ReadMessage failed: %v", err)
+			}
+			if parsed.Header.Get("To") != "asha@example.com" || parsed.Header.Get("Message-ID") == "" {
+				t.Errorf("Unexpected headers %v", parsed.Header)
+			}
+		})
+	}
+}
+
+func TestSMTPEmailSenderPool(t *testing.T) {
+	server := newSMTPServer(t, SecuritySTARTTLS)
+	config := server.config()
+	config.PoolSize = 2
+	sender := NewSMTPEmailSender(config, zap.NewNop())
+	defer sender.Close()
+
+	renderer, _ := NewRenderer("")
+	msg, _ := renderer.Render(TemplateWelcome, AccountData{Name: "Asha"})
+
+	// Sequential emails share one connection
+	for i := 0; i < 3; i++ {
+		if err := sender.Send("asha@example.com", msg); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	if server.connections() != 1 {
+		t.Errorf("Opened %d connections, want 1", server.connections())
+	}
+
+	// A connection the server closed while idle is replaced
+	server.dropConnections()
+	if err := sender.Send("asha@example.com", msg); err != nil {
+		t.Fatalf("Send after the connection dropped failed: %v", err)
+	}
+
+	// A rejected transaction fails and its connection is not reused
+	server.rejectNext(1)
+	if err := sender.Send("asha@example.com", msg); err == nil || !strings.Contains(err.Error(), "451") {
+		t.Errorf("Expected a 451 error, got %v", err)
+	}
+	if err := sender.Send("asha@example.com", msg); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if len(server.received()) != 5 || server.connections() != 3 {
+		t.Errorf("Received %d messages over %d connections, want 5 over 3", len(server.received()), server.connections())
+	}
+}
+
+func TestSMTPEmailSenderErrors(t *testing.T) {
+	renderer, _ := NewRenderer("")
+	msg, _ := renderer.Render(TemplateWelcome, AccountData{Name: "Asha"})
+
+	// STARTTLS is required unless security is none
+	server := newSMTPServer(t, SecurityNone)
+	config := server.config()
+	config.Security = SecuritySTARTTLS
+	err := NewSMTPEmailSender(config, zap.NewNop()).Send("asha@example.com", msg)
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Errorf("Expected a STARTTLS error, got %v", err)
+	}
+
+	// A server that never answers times out
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	sender := NewSMTPEmailSender(SMTPConfig{Host: "127.0.0.1", Port: addr.Port, Security: SecurityNone, Timeout: 100 * time.Millisecond}, zap.NewNop())
+	start := time.Now()
+	if err := sender.Send("asha@example.com", msg); err == nil {
+		t.Error("Expected a timeout")
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("Send took %s, want it bounded by the timeout", time.Since(start))
+	}
+}
@@ -0,0 +1,179 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+//go:embed templates/*.html templates/*.txt
+var embeddedTemplates embed.FS
+
+// Email templates. Each has a NAME.html and a NAME.txt file; the text file
+// also defines the subject.
+const (
+	TemplateVerification      = "verification"
+	TemplatePasswordReset     = "password_reset"
+	TemplateWelcome           = "welcome"
+	TemplateOrderConfirmation = "order_confirmation"
+	TemplatePaymentFailed     = "payment_failed"
+	TemplateOrderShipped      = "order_shipped"
+	TemplateOrderDelivered    = "order_delivered"
+	TemplateOrderCancelled    = "order_cancelled"
+	TemplateOrderRefunded     = "order_refunded"
+	TemplateNewOrder          = "new_order"
+)
+
+// Templates lists every email template
+var Templates = []string{
+	TemplateVerification,
+	TemplatePasswordReset,
+	TemplateWelcome,
+	TemplateOrderConfirmation,
+	TemplatePaymentFailed,
+	TemplateOrderShipped,
+	TemplateOrderDelivered,
+	TemplateOrderCancelled,
+	TemplateOrderRefunded,
+	TemplateNewOrder,
+}
+
+// ErrUnknownTemplate is returned when rendering a template that does not exist
+var ErrUnknownTemplate = errors.New("unknown email template")
+
+// Message is a rendered email
+type Message struct {
+	Template string // name of the template it was rendered from
+	Subject  string
+	HTML     string
+	Text     string
+}
+
+// Link is a call-to-action button in the HTML templates
+type Link struct {
+	Label string
+	URL   string
+}
+
+// AccountData is the data of the verification, password reset and welcome
+// templates. URL is empty for the welcome email.
+type AccountData struct {
+	Name string
+	URL  string
+}
+
+// OrderData is the data of the order templates. Name is empty for the
+// new-order notification sent to the shop.
+type OrderData struct {
+	Name  string
+	Order OrderEmail
+}
+
+// Renderer renders email templates. Every email shares layout.html and
+// layout.txt, which wrap the "content" each template defines, and the
+// helpers in partials.html and partials.txt. Templates are embedded in the
+// binary; a file of the same name in the override directory replaces the
+// embedded one, so copy can be changed without a rebuild.
+type Renderer struct {
+	html map[string]*htmltemplate.Template
+	text map[string]*texttemplate.Template
+}
+
+// NewRenderer parses the email templates, preferring files in overrideDir
+// when it is not empty
+func NewRenderer(overrideDir string) (*Renderer, error) {
+	read := func(filename string) (string, error) {
+		if overrideDir != "" {
+			content, err := os.ReadFile(filepath.Join(overrideDir, filename))
+			if err == nil {
+				return string(content), nil
+			}
+			if !errors.Is(err, fs.ErrNotExist) {
+				return "", fmt.Errorf("failed to read template %s: %w", filename, err)
+			}
+		}
+		content, err := embeddedTemplates.ReadFile("templates/" + filename)
+		if err != nil {
+			return "", fmt.Errorf("failed to read template %s: %w", filename, err)
+		}
+		return string(content), nil
+	}
+
+	funcs := map[string]interface{}{
+		"year":   func() int { return time.Now().Year() },
+		"rupees": formatRupees,
+		"link":   func(label, url string) Link { return Link{Label: label, URL: url} },
+	}
+
+	r := &Renderer{
+		html: map[string]*htmltemplate.Template{},
+		text: map[string]*texttemplate.Template{},
+	}
+	for _, name := range Templates {
+		html := htmltemplate.New(name).Funcs(htmltemplate.FuncMap(funcs))
+		for _, filename := range []string{"layout.html", "partials.html", name + ".html"} {
+			content, err := read(filename)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := html.New(filename).Parse(content); err != nil {
+				return nil, fmt.Errorf("failed to parse template %s: %w", filename, err)
+			}
+		}
+
+		text := texttemplate.New(name).Funcs(texttemplate.FuncMap(funcs))
+		for _, filename := range []string{"layout.txt", "partials.txt", name + ".txt"} {
+			content, err := read(filename)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := text.New(filename).Parse(content); err != nil {
+				return nil, fmt.Errorf("failed to parse template %s: %w", filename, err)
+			}
+		}
+		if text.Lookup("subject") == nil {
+			return nil, fmt.Errorf("template %s.txt does not define a subject", name)
+		}
+
+		r.html[name] = html
+		r.text[name] = text
+	}
+
+	return r, nil
+}
+
+// Render renders a template's subject, HTML and text body
+func (r *Renderer) Render(name string, data interface{}) (*Message, error) {
+	html, ok := r.html[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTemplate, name)
+	}
+	text := r.text[name]
+
+	var subject, htmlBody, textBody bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, fmt.Errorf("failed to render %s subject: %w", name, err)
+	}
+	if err := html.ExecuteTemplate(&htmlBody, "layout", data); err != nil {
+		return nil, fmt.Errorf("failed to render %s HTML: %w", name, err)
+	}
+	if err := text.ExecuteTemplate(&textBody, "layout", data); err != nil {
+		return nil, fmt.Errorf("failed to render %s text: %w", name, err)
+	}
+
+	return &Message{
+		Template: name,
+		// A subject is a single header line
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		HTML:    htmlBody.String(),
+		Text:    textBody.String(),
+	}, nil
+}
@@ -0,0 +1,193 @@
+package email
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestRenderTemplates(t *testing.T) {
+	renderer, err := NewRenderer("")
+	if err != nil {
+		t.Fatalf("NewRenderer failed: %v", err)
+	}
+
+	year := strconv.Itoa(time.Now().Year())
+	for _, name := range Templates {
+		data, err := SampleData(name)
+		if err != nil {
+			t.Fatalf("SampleData(%s) failed: %v", name, err)
+		}
+		msg, err := renderer.Render(name, data)
+		if err != nil {
+			t.Errorf("Render(%s) failed: %v", name, err)
+			continue
+		}
+		if msg.Subject == "" || strings.Contains(msg.Subject, "\n") {
+			t.Errorf("%s: bad subject %q", name, msg.Subject)
+		}
+		if !strings.Contains(msg.HTML, "© "+year) || !strings.Contains(msg.Text, "© "+year) {
+			t.Errorf("%s: footer is missing the current year", name)
+		}
+	}
+
+	msg, _ := renderer.Render(TemplateOrderConfirmation, OrderData{Name: "Asha", Order: sampleOrder})
+	for _, want := range []string{"Discount (DIWALI10): -₹1,340.00", "Total: ₹12,178.00", "Chennai, Tamil Nadu 600001", "ready to pay on delivery"} {
+		if !strings.Contains(msg.Text, want) {
+			t.Errorf("Confirmation text is missing %q:\n%s", want, msg.Text)
+		}
+	}
+
+	if _, err := renderer.Render("invoice", nil); err == nil {
+		t.Error("Expected an error for an unknown template")
+	}
+}
+
+func TestRenderEscaping(t *testing.T) {
+	renderer, _ := NewRenderer("")
+
+	msg, err := renderer.Render(TemplateWelcome, AccountData{Name: `<b>Asha</b> & "Co"`})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if strings.Contains(msg.HTML, "<b>Asha</b>") || !strings.Contains(msg.HTML, "&lt;b&gt;Asha&lt;/b&gt; &amp; &#34;Co&#34;") {
+		t.Errorf("Name not escaped in HTML:\n%s", msg.HTML)
+	}
+	if !strings.Contains(msg.Text, `Welcome, <b>Asha</b> & "Co"!`) {
+		t.Errorf("Name escaped in text:\n%s", msg.Text)
+	}
+
+	msg, _ = renderer.Render(TemplateVerification, AccountData{Name: "Asha", URL: "javascript:alert(1)"})
+	if strings.Contains(msg.HTML, `href="javascript:`) {
+		t.Errorf("Unsafe URL in link:\n%s", msg.HTML)
+	}
+}
+
+func TestRendererOverride(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "welcome.txt"), []byte(`{{define "subject"}}Hello {{.Name}}{{end}}{{define "content"}}Custom welcome{{end}}`), 0644)
+
+	renderer, err := NewRenderer(dir)
+	if err != nil {
+		t.Fatalf("NewRenderer failed: %v", err)
+	}
+
+	msg, _ := renderer.Render(TemplateWelcome, AccountData{Name: "Asha"})
+	if msg.Subject != "Hello Asha" || !strings.HasPrefix(msg.Text, "Custom welcome") {
+		t.Errorf("Override not used: %q\n%s", msg.Subject, msg.Text)
+	}
+	// Files that are not overridden come from the binary
+	if !strings.Contains(msg.HTML, "Your email has been verified successfully!") {
+		t.Errorf("Embedded HTML not used:\n%s", msg.HTML)
+	}
+
+	os.WriteFile(filepath.Join(dir, "welcome.txt"), []byte(`{{define "content"}}No subject{{end}}`), 0644)
+	if _, err := NewRenderer(dir); err == nil || !strings.Contains(err.Error(), "subject") {
+		t.Errorf("Expected a missing subject error, got %v", err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "layout.html"), []byte(`{{define "layout"}}{{.Missing`), 0644)
+	if _, err := NewRenderer(dir); err == nil {
+		t.Error("Expected a parse error")
+	}
+}
+
+func TestMIME(t *testing.T) {
+	msg := &Message{
+		Subject: "Order Confirmed #3F2A9C1B - ₹12,178.00",
+		Text:    "Hi Asha,\n\nhttps://ramniya.com/auth/verify?token=abc=def",
+		HTML:    "<p>Hi Asha,</p>",
+	}
+
+	raw, err := msg.MIME("Ramniya Creations <noreply@ramniyacreations.com>", "asha@example.com", Headers{
+		ReplyTo:         "support@ramniyacreations.com",
+		ListUnsubscribe: "mailto:unsubscribe@ramniyacreations.com",
+	})
+	if err != nil {
+		t.Fatalf("MIME failed: %v", err)
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatalf("ReadMessage failed: %v", err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if subject != msg.Subject {
+		t.Errorf("Subject = %q, want %q", subject, msg.Subject)
+	}
+	if date, err := parsed.Header.Date(); err != nil || time.Since(date) > time.Minute {
+		t.Errorf("Date = %q, %v", parsed.Header.Get("Date"), err)
+	}
+	if id := parsed.Header.Get("Message-ID"); !strings.HasPrefix(id, "<") || !strings.HasSuffix(id, "@ramniyacreations.com>") {
+		t.Errorf("Message-ID = %q", id)
+	}
+	if again, _ := msg.MIME("noreply@ramniyacreations.com", "asha@example.com", Headers{}); strings.Contains(string(again), parsed.Header.Get("Message-ID")) {
+		t.Error("Message-ID was reused")
+	}
+	if got := parsed.Header.Get("Reply-To"); got != "support@ramniyacreations.com" {
+		t.Errorf("Reply-To = %q", got)
+	}
+	if got := parsed.Header.Get("List-Unsubscribe"); got != "<mailto:unsubscribe@ramniyacreations.com>" {
+		t.Errorf("List-Unsubscribe = %q", got)
+	}
+
+	mediaType, params, _ := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %s", mediaType)
+	}
+
+	reader := multipart.NewReader(parsed.Body, params["boundary"])
+	for _, want := range []struct{ contentType, body string }{
+		{"text/plain; charset=UTF-8", msg.Text},
+		{"text/html; charset=UTF-8", msg.HTML},
+	} {
+		part, err := reader.NextRawPart()
+		if err != nil {
+			t.Fatalf("NextRawPart failed: %v", err)
+		}
+		body, _ := io.ReadAll(quotedprintable.NewReader(part))
+		// Line breaks are sent as CRLF
+		body = []byte(strings.ReplaceAll(string(body), "\r\n", "\n"))
+		if part.Header.Get("Content-Type") != want.contentType || string(body) != want.body {
+			t.Errorf("Part %s = %q, want %s %q", part.Header.Get("Content-Type"), body, want.contentType, want.body)
+		}
+	}
+	if _, err := reader.NextPart(); err != io.EOF {
+		t.Errorf("Expected two parts, got more: %v", err)
+	}
+}
+
+func TestFileEmailSender(t *testing.T) {
+	dir := t.TempDir()
+	renderer, _ := NewRenderer("")
+	sender, err := NewFileEmailSender(dir, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewFileEmailSender failed: %v", err)
+	}
+
+	url := "http://localhost:3000/auth/verify?token=abc"
+	msg, _ := renderer.Render(TemplateVerification, AccountData{Name: "Asha", URL: url})
+	if err := sender.Send("asha@example.com", msg); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	txt, _ := filepath.Glob(filepath.Join(dir, "*-verification-asha@example.com.txt"))
+	html, _ := filepath.Glob(filepath.Join(dir, "*-verification-asha@example.com.html"))
+	if len(txt) != 1 || len(html) != 1 {
+		t.Fatalf("Expected a .txt and a .html file, got %v %v", txt, html)
+	}
+	content, _ := os.ReadFile(txt[0])
+	if !strings.Contains(string(content), "Subject: Verify Your Email - Ramniya Creations") || !strings.Contains(string(content), url) {
+		t.Errorf("Unexpected email file:\n%s", content)
+	}
+}
@@ -0,0 +1,209 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// OutboxStore is the subset of the outbox repository used by the worker
+type OutboxStore interface {
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]OutboxEmail, error)
+	MarkSent(ctx context.Context, id uuid.UUID, lockedUntil time.Time) error
+	MarkFailed(ctx context.Context, id uuid.UUID, lockedUntil time.Time, errText string, nextAttempt *time.Time) error
+}
+
+// WorkerConfig controls how queued emails are sent and retried
+type WorkerConfig struct {
+	Workers     int           // emails sent concurrently
+	BatchSize   int           // emails claimed at a time
+	MaxAttempts int           // attempts before an email is dead-lettered
+	Lease       time.Duration // how long a claimed email is reserved for its worker
+	RetryDelay  time.Duration // delay after the first failure, doubled after each further one
+	MaxDelay    time.Duration
+}
+
+// WorkerResult summarises a worker run
+type WorkerResult struct {
+	Sent         int
+	Failed       int // will be retried
+	DeadLettered int
+	LeaseLost    int // outcome not recorded; the email was claimed again or resent
+}
+
+// Worker sends emails from the outbox. Each run claims due emails and sends
+// them from a pool of goroutines; a failed email is retried with exponential
+// backoff until it runs out of attempts and is dead-lettered.
+type Worker struct {
+	store    OutboxStore
+	renderer *Renderer
+	sender   EmailSender
+	logger   *zap.Logger
+	config   WorkerConfig
+}
+
+// NewWorker creates a new outbox worker
+func NewWorker(store OutboxStore, renderer *Renderer, sender EmailSender, logger *zap.Logger, config WorkerConfig) *Worker {
+	if config.Workers <= 0 {
+		config.Workers = 4
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 50
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 8
+	}
+	if config.Lease <= 0 {
+		config.Lease = 5 * time.Minute
+	}
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = time.Minute
+	}
+	if config.MaxDelay <= 0 {
+		config.MaxDelay = 6 * time.Hour
+	}
+
+	return &Worker{
+		store:    store,
+		renderer: renderer,
+		sender:   sender,
+		logger:   logger,
+		config:   config,
+	}
+}
+
+// Run sends due emails until none are left or ctx is done
+func (w *Worker) Run(ctx context.Context) (*WorkerResult, error) {
+	result := &WorkerResult{}
+	for ctx.Err() == nil {
+		batch, err := w.store.Claim(ctx, w.config.BatchSize, w.config.Lease)
+		if err != nil {
+			return result, err
+		}
+		if err := w.sendBatch(ctx, batch, result); err != nil {
+			return result, err
+		}
+		if len(batch) < w.config.BatchSize {
+			break
+		}
+	}
+	return result, ctx.Err()
+}
+
+func (w *Worker) sendBatch(ctx context.Context, batch []OutboxEmail, result *WorkerResult) error {
+	queue := make(chan *OutboxEmail)
+	var mu sync.Mutex
+	var firstErr error
+	var wg sync.WaitGroup
+
+	for i := 0; i < w.config.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range queue {
+				outcome, err := w.send(ctx, e)
+
+				mu.Lock()
+				switch {
+				case err != nil:
+					if firstErr == nil {
+						firstErr = err
+					}
+				case outcome == OutboxSent:
+					result.Sent++
+				case outcome == OutboxDead:
+					result.DeadLettered++
+				case outcome == OutboxSending:
+					result.LeaseLost++
+				default:
+					result.Failed++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for i := range batch {
+		queue <- &batch[i]
+	}
+	close(queue)
+	wg.Wait()
+
+	return firstErr
+}
+
+// send delivers a claimed email and records the outcome. The returned error
+// is a failure to record it; delivery errors are recorded on the email. If
+// the lease ran out before the outcome was recorded, the email is left to
+// whoever holds it now and OutboxSending is returned.
+func (w *Worker) send(ctx context.Context, e *OutboxEmail) (OutboxStatus, error) {
+	sendErr := w.deliver(e)
+	if sendErr == nil {
+		if err := w.store.MarkSent(ctx, e.ID, *e.LockedUntil); err != nil {
+			return w.leaseLost(e, err)
+		}
+		return OutboxSent, nil
+	}
+
+	var nextAttempt *time.Time
+	if e.Attempts < w.config.MaxAttempts {
+		next := time.Now().Add(w.retryDelay(e.Attempts))
+		nextAttempt = &next
+	}
+
+	w.logger.Error("Failed to send email",
+		zap.String("id", e.ID.String()),
+		zap.String("template", e.Template),
+		zap.String("to", e.Recipient),
+		zap.Int("attempt", e.Attempts),
+		zap.Bool("will_retry", nextAttempt != nil),
+		zap.Error(sendErr),
+	)
+
+	if err := w.store.MarkFailed(ctx, e.ID, *e.LockedUntil, sendErr.Error(), nextAttempt); err != nil {
+		return w.leaseLost(e, err)
+	}
+	if nextAttempt == nil {
+		return OutboxDead, nil
+	}
+	return OutboxPending, nil
+}
+
+// leaseLost logs an outcome that could not be recorded because the lease
+// was lost, and passes any other error on
+func (w *Worker) leaseLost(e *OutboxEmail, err error) (OutboxStatus, error) {
+	if !errors.Is(err, ErrOutboxLeaseLost) {
+		return "", err
+	}
+	w.logger.Warn("Email lease lost before its outcome was recorded",
+		zap.String("id", e.ID.String()),
+		zap.String("template", e.Template),
+		zap.Int("attempt", e.Attempts),
+	)
+	return OutboxSending, nil
+}
+
+func (w *Worker) deliver(e *OutboxEmail) error {
+	data, err := decodeData(e.Template, e.Data)
+	if err != nil {
+		return err
+	}
+	msg, err := w.renderer.Render(e.Template, data)
+	if err != nil {
+		return err
+	}
+	return w.sender.Send(e.Recipient, msg)
+}
+
+// retryDelay backs off exponentially from RetryDelay, capped at MaxDelay
+func (w *Worker) retryDelay(attempt int) time.Duration {
+	delay := w.config.RetryDelay << (attempt - 1)
+	if delay <= 0 || delay > w.config.MaxDelay {
+		delay = w.config.MaxDelay
+	}
+	return delay
+}
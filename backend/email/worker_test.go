@@ -0,0 +1,242 @@
+package email
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// memoryOutbox is an in-memory OutboxStore
+type memoryOutbox struct {
+	mu     sync.Mutex
+	emails []*OutboxEmail
+}
+
+func (m *memoryOutbox) add(t *testing.T, to, template string, data interface{}) *OutboxEmail {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	e := &OutboxEmail{ID: uuid.New(), Template: template, Recipient: to, Data: raw, Status: OutboxPending, NextAttemptAt: time.Now()}
+	m.emails = append(m.emails, e)
+	return e
+}
+
+func (m *memoryOutbox) get(id uuid.UUID) OutboxEmail {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range m.emails {
+		if e.ID == id {
+			return *e
+		}
+	}
+	return OutboxEmail{}
+}
+
+// makeDue moves an email's retry to now
+func (m *memoryOutbox) makeDue(id uuid.UUID) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range m.emails {
+		if e.ID == id {
+			e.NextAttemptAt = time.Now()
+		}
+	}
+}
+
+func (m *memoryOutbox) Claim(ctx context.Context, limit int, lease time.Duration) ([]OutboxEmail, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var claimed []OutboxEmail
+	for _, e := range m.emails {
+		if len(claimed) == limit {
+			break
+		}
+		due := e.Status == OutboxPending && !e.NextAttemptAt.After(time.Now())
+		expired := e.Status == OutboxSending && e.LockedUntil.Before(time.Now())
+		if !due && !expired {
+			continue
+		}
+		lockedUntil := time.Now().Add(lease)
+		e.Status, e.LockedUntil = OutboxSending, &lockedUntil
+		e.Attempts++
+		claimed = append(claimed, *e)
+	}
+	return claimed, nil
+}
+
+// leased returns the email if it is still claimed under lockedUntil
+func (m *memoryOutbox) leased(id uuid.UUID, lockedUntil time.Time) *OutboxEmail {
+	for _, e := range m.emails {
+		if e.ID == id && e.Status == OutboxSending && e.LockedUntil != nil && e.LockedUntil.Equal(lockedUntil) {
+			return e
+		}
+	}
+	return nil
+}
+
+func (m *memoryOutbox) MarkSent(ctx context.Context, id uuid.UUID, lockedUntil time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := m.leased(id, lockedUntil)
+	if e == nil {
+		return ErrOutboxLeaseLost
+	}
+	now := time.Now()
+	e.Status, e.SentAt, e.LockedUntil, e.LastError = OutboxSent, &now, nil, nil
+	return nil
+}
+
+func (m *memoryOutbox) MarkFailed(ctx context.Context, id uuid.UUID, lockedUntil time.Time, errText string, nextAttempt *time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := m.leased(id, lockedUntil)
+	if e == nil {
+		return ErrOutboxLeaseLost
+	}
+	e.Status, e.LastError, e.LockedUntil = OutboxDead, &errText, nil
+	if nextAttempt != nil {
+		e.Status, e.NextAttemptAt = OutboxPending, *nextAttempt
+	}
+	return nil
+}
+
+func TestWorkerRun(t *testing.T) {
+	server := newSMTPServer(t, SecurityNone)
+	renderer, _ := NewRenderer("")
+	store := &memoryOutbox{}
+
+	welcome := store.add(t, "asha@example.com", TemplateWelcome, AccountData{Name: "Asha"})
+	confirmation := store.add(t, "ravi@example.com", TemplateOrderConfirmation, OrderData{Name: "Ravi", Order: sampleOrder})
+	reset := store.add(t, "meera@example.com", TemplatePasswordReset, AccountData{Name: "Meera", URL: "https://ramniya.com/auth/reset-password?token=abc"})
+
+	worker := NewWorker(store, renderer, NewSMTPEmailSender(server.config(), zap.NewNop()), zap.NewNop(), WorkerConfig{
+		Workers:     2,
+		BatchSize:   2, // two batches
+		MaxAttempts: 2,
+		RetryDelay:  time.Minute,
+	})
+
+	// One transaction is turned away; that email is retried later
+	server.rejectNext(1)
+	start := time.Now()
+	result, err := worker.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.Sent != 2 || result.Failed != 1 || result.DeadLettered != 0 {
+		t.Fatalf("Run = %+v, want 2 sent and 1 failed", *result)
+	}
+	if len(server.received()) != 2 {
+		t.Errorf("Server received %d messages, want 2", len(server.received()))
+	}
+
+	var failed OutboxEmail
+	for _, e := range []*OutboxEmail{welcome, confirmation, reset} {
+		if got := store.get(e.ID); got.Status == OutboxPending {
+			failed = got
+		} else if got.Status != OutboxSent || got.SentAt == nil {
+			t.Errorf("%s: status = %s, want sent", got.Template, got.Status)
+		}
+	}
+	if failed.Attempts != 1 || failed.LastError == nil || failed.NextAttemptAt.Before(start.Add(time.Minute)) {
+		t.Errorf("Failed email = %+v, want a retry in a minute", failed)
+	}
+
+	// Not due yet
+	if result, _ := worker.Run(context.Background()); result.Sent+result.Failed+result.DeadLettered != 0 {
+		t.Errorf("Run before the retry is due = %+v", *result)
+	}
+
+	// The second failure is the last attempt
+	store.makeDue(failed.ID)
+	server.rejectNext(1)
+	result, err = worker.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.DeadLettered != 1 {
+		t.Errorf("Run = %+v, want 1 dead-lettered", *result)
+	}
+	if got := store.get(failed.ID); got.Status != OutboxDead || got.Attempts != 2 {
+		t.Errorf("Email = %s after %d attempts, want dead after 2", got.Status, got.Attempts)
+	}
+
+	// Emails that cannot be rendered fail without reaching the server
+	broken := store.add(t, "asha@example.com", "invoice", AccountData{})
+	if _, err := worker.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if got := store.get(broken.ID); got.Status != OutboxPending || got.LastError == nil {
+		t.Errorf("Broken email = %+v", got)
+	}
+	if len(server.received()) != 2 {
+		t.Errorf("Server received %d messages, want 2", len(server.received()))
+	}
+}
+
+// takenOverOutbox hands out claims whose lease another worker has already
+// taken over
+type takenOverOutbox struct {
+	*memoryOutbox
+}
+
+func (o takenOverOutbox) Claim(ctx context.Context, limit int, lease time.Duration) ([]OutboxEmail, error) {
+	claimed, err := o.memoryOutbox.Claim(ctx, limit, lease)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, e := range o.emails {
+		if e.Status == OutboxSending {
+			lockedUntil := e.LockedUntil.Add(lease)
+			e.LockedUntil = &lockedUntil
+		}
+	}
+	return claimed, err
+}
+
+func TestWorkerLeaseLost(t *testing.T) {
+	server := newSMTPServer(t, SecurityNone)
+	renderer, _ := NewRenderer("")
+	store := takenOverOutbox{&memoryOutbox{}}
+	welcome := store.add(t, "asha@example.com", TemplateWelcome, AccountData{Name: "Asha"})
+
+	worker := NewWorker(store, renderer, NewSMTPEmailSender(server.config(), zap.NewNop()), zap.NewNop(), WorkerConfig{})
+	result, err := worker.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.LeaseLost != 1 || result.Sent != 0 {
+		t.Errorf("Run = %+v, want 1 lease lost", *result)
+	}
+
+	// The email stays with the worker that took it over
+	if got := store.get(welcome.ID); got.Status != OutboxSending || got.SentAt != nil {
+		t.Errorf("Email = %+v, want still sending", got)
+	}
+}
+
+func TestWorkerRetryDelay(t *testing.T) {
+	worker := NewWorker(&memoryOutbox{}, nil, nil, zap.NewNop(), WorkerConfig{RetryDelay: time.Minute, MaxDelay: time.Hour})
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{4, 8 * time.Minute},
+		{7, time.Hour},
+		{100, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := worker.retryDelay(tt.attempt); got != tt.want {
+			t.Errorf("retryDelay(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,399 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/ramniya/ramniya-backend/auth"
+	"github.com/ramniya/ramniya-backend/email"
+	"go.uber.org/zap"
+)
+
+// LoginMethods shows how a user can log in
+type LoginMethods struct {
+	Password bool `json:"password"`
+	Google   bool `json:"google"`
+}
+
+// ProfileResponse represents the logged-in user's profile
+type ProfileResponse struct {
+	ID           string       `json:"id"`
+	Email        string       `json:"email"`
+	PendingEmail string       `json:"pending_email,omitempty"`
+	Name         string       `json:"name,omitempty"`
+	Role         string       `json:"role"`
+	IsVerified   bool         `json:"is_verified"`
+	CreatedAt    string       `json:"created_at"`
+	LoginMethods LoginMethods `json:"login_methods"`
+}
+
+// UpdateProfileRequest represents a profile update
+type UpdateProfileRequest struct {
+	Name string `json:"name"`
+}
+
+// ChangePasswordRequest represents a password change. CurrentPassword is
+// not needed when the account has no password yet.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password,omitempty"`
+	NewPassword     string `json:"new_password"`
+}
+
+// ChangeEmailRequest represents an email change. Password is required when
+// the account has one.
+type ChangeEmailRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password,omitempty"`
+}
+
+// GetProfile handles GET /api/me
+func (h *AuthHandler) GetProfile(c echo.Context) error {
+	user, err := h.currentUser(c)
+	if user == nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, newProfileResponse(user))
+}
+
+// UpdateProfile handles PATCH /api/me
+func (h *AuthHandler) UpdateProfile(c echo.Context) error {
+	user, err := h.currentUser(c)
+	if user == nil {
+		return err
+	}
+
+	var req UpdateProfileRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request body",
+		})
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Name is required",
+		})
+	}
+
+	if len(name) > 100 {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Name must be at most 100 characters long",
+		})
+	}
+
+	if err := h.authRepo.UpdateName(c.Request().Context(), user.ID, name); err != nil {
+		h.logger.Error("Failed to update profile",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err),
+		)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to update profile",
+		})
+	}
+
+	user.Name = &name
+	return c.JSON(http.StatusOK, newProfileResponse(user))
+}
+
+// ChangePassword handles POST /api/me/password. Users with a password must
+// give the current one, and every session is ended afterwards. Users who
+// only log in with Google set a first password and stay logged in.
+func (h *AuthHandler) ChangePassword(c echo.Context) error {
+	user, err := h.currentUser(c)
+	if user == nil {
+		return err
+	}
+
+	var req ChangePasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request body",
+		})
+	}
+
+	if len(req.NewPassword) < 8 {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Password must be at least 8 characters long",
+		})
+	}
+
+	ctx := c.Request().Context()
+
+	if user.PasswordHash == nil {
+		if err := h.authRepo.SetPassword(ctx, user.ID, req.NewPassword); err != nil {
+			if errors.Is(err, auth.ErrPasswordAlreadySet) {
+				return c.JSON(http.StatusConflict, map[string]string{
+					"error": "A password has already been set",
+				})
+			}
+			h.logger.Error("Failed to set password",
+				zap.String("user_id", user.ID.String()),
+				zap.Error(err),
+			)
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "Failed to set password",
+			})
+		}
+
+		h.logger.Info("Password set",
+			zap.String("user_id", user.ID.String()),
+		)
+
+		return c.JSON(http.StatusOK, map[string]string{
+			"message": "Password set. You can now log in with your email and password.",
+		})
+	}
+
+	if req.CurrentPassword == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Current password is required",
+		})
+	}
+
+	if err := h.authRepo.ChangePassword(ctx, user.ID, req.CurrentPassword, req.NewPassword); err != nil {
+		if errors.Is(err, auth.ErrPasswordIncorrect) {
+			return c.JSON(http.StatusForbidden, map[string]string{
+				"error": "Current password is incorrect",
+			})
+		}
+		h.logger.Error("Failed to change password",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err),
+		)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to change password",
+		})
+	}
+
+	h.revoker.Forget(ctx, user.ID)
+
+	h.logger.Info("Password changed",
+		zap.String("user_id", user.ID.String()),
+	)
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Password changed. Please log in with your new password.",
+	})
+}
+
+// ChangeEmail handles POST /api/me/email. The new address only replaces the
+// current one once the verification link sent to it is opened.
+func (h *AuthHandler) ChangeEmail(c echo.Context) error {
+	user, err := h.currentUser(c)
+	if user == nil {
+		return err
+	}
+
+	var req ChangeEmailRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request body",
+		})
+	}
+
+	newEmail := strings.TrimSpace(req.Email)
+	if addr, err := mail.ParseAddress(newEmail); err != nil || addr.Address != newEmail {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "A valid email is required",
+		})
+	}
+
+	if strings.EqualFold(newEmail, user.Email) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "This is already your email",
+		})
+	}
+
+	if user.PasswordHash != nil {
+		if _, err := h.authRepo.VerifyPassword(c.Request().Context(), user.Email, req.Password); err != nil {
+			return c.JSON(http.StatusForbidden, map[string]string{
+				"error": "Password is incorrect",
+			})
+		}
+	}
+
+	verificationToken, err := h.tokenService.GenerateEmailVerificationToken(user.ID, newEmail)
+	if err != nil {
+		h.logger.Error("Failed to generate verification token",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err),
+		)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to change email",
+		})
+	}
+
+	userName := "User"
+	if user.Name != nil {
+		userName = *user.Name
+	}
+
+	ctx := c.Request().Context()
+	verificationURL := fmt.Sprintf("%s/auth/verify?token=%s", h.frontendURL, verificationToken)
+	err = h.authRepo.RequestEmailChange(ctx, user.ID, newEmail, func(tx *sql.Tx) error {
+		_, err := h.outbox.Enqueue(ctx, tx, email.Outgoing{
+			To:       newEmail,
+			Template: email.TemplateVerification,
+			Data:     email.AccountData{Name: userName, URL: verificationURL},
+		})
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, auth.ErrEmailTaken) {
+			return c.JSON(http.StatusConflict, map[string]string{
+				"error": "Email already registered",
+			})
+		}
+		h.logger.Error("Failed to request email change",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err),
+		)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to change email",
+		})
+	}
+
+	h.logger.Info("Email change requested",
+		zap.String("user_id", user.ID.String()),
+	)
+
+	return c.JSON(http.StatusAccepted, map[string]string{
+		"message":       "Please check your new email to confirm the change.",
+		"pending_email": newEmail,
+	})
+}
+
+// GetLoginMethods handles GET /api/me/logins
+func (h *AuthHandler) GetLoginMethods(c echo.Context) error {
+	user, err := h.currentUser(c)
+	if user == nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, loginMethods(user))
+}
+
+// UnlinkGoogle handles DELETE /api/me/logins/google. Logging in with Google
+// under the account's email is refused from then on; the user can link Google
+// again with LinkGoogle.
+func (h *AuthHandler) UnlinkGoogle(c echo.Context) error {
+	user, err := h.currentUser(c)
+	if user == nil {
+		return err
+	}
+
+	if err := h.authRepo.UnlinkGoogleID(c.Request().Context(), user.ID); err != nil {
+		if errors.Is(err, auth.ErrGoogleNotLinked) {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "No Google account is linked",
+			})
+		}
+		if errors.Is(err, auth.ErrLastLoginMethod) {
+			return c.JSON(http.StatusConflict, map[string]string{
+				"error": "Set a password before unlinking your Google account",
+			})
+		}
+		h.logger.Error("Failed to unlink Google account",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err),
+		)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to unlink Google account",
+		})
+	}
+
+	h.logger.Info("Google account unlinked",
+		zap.String("user_id", user.ID.String()),
+	)
+
+	user.GoogleID = nil
+	return c.JSON(http.StatusOK, loginMethods(user))
+}
+
+// LinkGoogle handles GET /api/me/logins/google/link. It returns the Google
+// authorization URL; the OAuth callback links the chosen Google account to
+// the logged-in user.
+func (h *AuthHandler) LinkGoogle(c echo.Context) error {
+	user, err := h.currentUser(c)
+	if user == nil {
+		return err
+	}
+
+	state := h.generateSecureState()
+	h.storeOAuthState(state, oauthState{linkUserID: user.ID})
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"auth_url": h.oauthService.GetAuthURL(state),
+		"state":    state,
+	})
+}
+
+// currentUser loads the authenticated user. When it returns nil the error
+// response has already been written and err is what the handler returns.
+func (h *AuthHandler) currentUser(c echo.Context) (*auth.User, error) {
+	userIDStr, ok := c.Get("user_id").(string)
+	if !ok {
+		return nil, c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "User not authenticated",
+		})
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return nil, c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid user ID",
+		})
+	}
+
+	user, err := h.authRepo.GetUserByID(c.Request().Context(), userID)
+	if err != nil {
+		if err.Error() == "user not found" {
+			return nil, c.JSON(http.StatusUnauthorized, map[string]string{
+				"error": "User not authenticated",
+			})
+		}
+		h.logger.Error("Failed to get user",
+			zap.String("user_id", userIDStr),
+			zap.Error(err),
+		)
+		return nil, c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to get user",
+		})
+	}
+
+	return user, nil
+}
+
+func newProfileResponse(user *auth.User) ProfileResponse {
+	resp := ProfileResponse{
+		ID:           user.ID.String(),
+		Email:        user.Email,
+		Role:         string(user.Role),
+		IsVerified:   user.IsVerified,
+		CreatedAt:    user.CreatedAt.Format(time.RFC3339),
+		LoginMethods: loginMethods(user),
+	}
+	if user.Name != nil {
+		resp.Name = *user.Name
+	}
+	if user.PendingEmail != nil {
+		resp.PendingEmail = *user.PendingEmail
+	}
+	return resp
+}
+
+func loginMethods(user *auth.User) LoginMethods {
+	return LoginMethods{
+		Password: user.PasswordHash != nil,
+		Google:   user.GoogleID != nil,
+	}
+}
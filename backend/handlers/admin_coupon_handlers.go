@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/ramniya/ramniya-backend/promotions"
+	"go.uber.org/zap"
+)
+
+// AdminCouponHandler handles admin coupon management
+type AdminCouponHandler struct {
+	couponRepo *promotions.CouponRepository
+	logger     *zap.Logger
+}
+
+// NewAdminCouponHandler creates a new admin coupon handler
+func NewAdminCouponHandler(couponRepo *promotions.CouponRepository, logger *zap.Logger) *AdminCouponHandler {
+	return &AdminCouponHandler{
+		couponRepo: couponRepo,
+		logger:     logger,
+	}
+}
+
+// CreateCoupon handles POST /api/admin/coupons
+func (h *AdminCouponHandler) CreateCoupon(c echo.Context) error {
+	var input promotions.CouponInput
+	if err := c.Bind(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := input.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	coupon, err := h.couponRepo.CreateCoupon(c.Request().Context(), input)
+	if err != nil {
+		if errors.Is(err, promotions.ErrCouponCodeTaken) {
+			return c.JSON(http.StatusConflict, map[string]string{
+				"error": "Coupon code already exists",
+			})
+		}
+		h.logger.Error("Failed to create coupon", zap.String("code", input.Code), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to create coupon",
+		})
+	}
+
+	adminID, _ := c.Get("user_id").(string)
+	h.logger.Info("Coupon created by admin",
+		zap.String("coupon_id", coupon.ID.String()),
+		zap.String("code", coupon.Code),
+		zap.String("admin_id", adminID),
+	)
+
+	return c.JSON(http.StatusCreated, coupon)
+}
+
+// ListCoupons handles GET /api/admin/coupons
+func (h *AdminCouponHandler) ListCoupons(c echo.Context) error {
+	filter := promotions.ListCouponsFilter{}
+
+	if activeStr := c.QueryParam("active"); activeStr != "" {
+		active, err := strconv.ParseBool(activeStr)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid active filter",
+			})
+		}
+		filter.Active = &active
+	}
+
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	filter.Limit = limit
+
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	if page < 1 {
+		page = 1
+	}
+	filter.Offset = (page - 1) * limit
+
+	coupons, total, err := h.couponRepo.ListCoupons(c.Request().Context(), filter)
+	if err != nil {
+		h.logger.Error("Failed to list coupons", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to list coupons",
+		})
+	}
+
+	totalPages := (total + limit - 1) / limit
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"coupons": coupons,
+		"pagination": map[string]interface{}{
+			"total":        total,
+			"page":         page,
+			"limit":        limit,
+			"total_pages":  totalPages,
+			"has_next":     page < totalPages,
+			"has_previous": page > 1,
+		},
+	})
+}
+
+// GetCoupon handles GET /api/admin/coupons/:id
+func (h *AdminCouponHandler) GetCoupon(c echo.Context) error {
+	couponID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid coupon ID",
+		})
+	}
+
+	coupon, err := h.couponRepo.GetCoupon(c.Request().Context(), couponID)
+	if err != nil {
+		if errors.Is(err, promotions.ErrCouponNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "Coupon not found",
+			})
+		}
+		h.logger.Error("Failed to get coupon", zap.String("coupon_id", couponID.String()), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to get coupon",
+		})
+	}
+
+	return c.JSON(http.StatusOK, coupon)
+}
+
+// UpdateCoupon handles PUT /api/admin/coupons/:id. The body replaces every
+// setting; send active=false to retire a coupon that has been redeemed.
+func (h *AdminCouponHandler) UpdateCoupon(c echo.Context) error {
+	couponID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid coupon ID",
+		})
+	}
+
+	var input promotions.CouponInput
+	if err := c.Bind(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := input.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	coupon, err := h.couponRepo.UpdateCoupon(c.Request().Context(), couponID, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, promotions.ErrCouponNotFound):
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "Coupon not found",
+			})
+		case errors.Is(err, promotions.ErrCouponCodeTaken):
+			return c.JSON(http.StatusConflict, map[string]string{
+				"error": "Coupon code already exists",
+			})
+		}
+		h.logger.Error("Failed to update coupon", zap.String("coupon_id", couponID.String()), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to update coupon",
+		})
+	}
+
+	adminID, _ := c.Get("user_id").(string)
+	h.logger.Info("Coupon updated by admin",
+		zap.String("coupon_id", coupon.ID.String()),
+		zap.String("code", coupon.Code),
+		zap.String("admin_id", adminID),
+	)
+
+	return c.JSON(http.StatusOK, coupon)
+}
+
+// DeleteCoupon handles DELETE /api/admin/coupons/:id
+func (h *AdminCouponHandler) DeleteCoupon(c echo.Context) error {
+	couponID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid coupon ID",
+		})
+	}
+
+	if err := h.couponRepo.DeleteCoupon(c.Request().Context(), couponID); err != nil {
+		switch {
+		case errors.Is(err, promotions.ErrCouponNotFound):
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "Coupon not found",
+			})
+		case errors.Is(err, promotions.ErrCouponRedeemed):
+			return c.JSON(http.StatusConflict, map[string]string{
+				"error": "Coupon has been redeemed; deactivate it instead",
+			})
+		}
+		h.logger.Error("Failed to delete coupon", zap.String("coupon_id", couponID.String()), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to delete coupon",
+		})
+	}
+
+	adminID, _ := c.Get("user_id").(string)
+	h.logger.Info("Coupon deleted by admin",
+		zap.String("coupon_id", couponID.String()),
+		zap.String("admin_id", adminID),
+	)
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Coupon deleted successfully",
+	})
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/ramniya/ramniya-backend/email"
+	"go.uber.org/zap"
+)
+
+// AdminEmailHandler handles admin email operations
+type AdminEmailHandler struct {
+	renderer *email.Renderer
+	outbox   *email.OutboxRepository
+	logger   *zap.Logger
+}
+
+// NewAdminEmailHandler creates a new admin email handler
+func NewAdminEmailHandler(renderer *email.Renderer, outbox *email.OutboxRepository, logger *zap.Logger) *AdminEmailHandler {
+	return &AdminEmailHandler{
+		renderer: renderer,
+		outbox:   outbox,
+		logger:   logger,
+	}
+}
+
+// ListEmails handles GET /api/admin/emails. It lists queued, sent and
+// dead-lettered emails, filtered by ?status=, ?recipient= and ?template=.
+// Verification and password reset links are redacted.
+func (h *AdminEmailHandler) ListEmails(c echo.Context) error {
+	filter := email.ListOutboxFilter{
+		Recipient: c.QueryParam("recipient"),
+		Template:  c.QueryParam("template"),
+	}
+
+	if statusStr := c.QueryParam("status"); statusStr != "" {
+		status := email.OutboxStatus(statusStr)
+		filter.Status = &status
+	}
+
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	filter.Limit = limit
+
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	if page < 1 {
+		page = 1
+	}
+	filter.Offset = (page - 1) * limit
+
+	emails, total, err := h.outbox.List(c.Request().Context(), filter)
+	if err != nil {
+		h.logger.Error("Failed to list emails", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to list emails",
+		})
+	}
+
+	for i := range emails {
+		emails[i].Redact()
+	}
+
+	totalPages := (total + limit - 1) / limit
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"emails": emails,
+		"pagination": map[string]interface{}{
+			"total":        total,
+			"page":         page,
+			"limit":        limit,
+			"total_pages":  totalPages,
+			"has_next":     page < totalPages,
+			"has_previous": page > 1,
+		},
+	})
+}
+
+// GetEmail handles GET /api/admin/emails/:id
+func (h *AdminEmailHandler) GetEmail(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid email ID",
+		})
+	}
+
+	queued, err := h.outbox.Get(c.Request().Context(), id)
+	if errors.Is(err, email.ErrOutboxEmailNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "Email not found",
+		})
+	}
+	if err != nil {
+		h.logger.Error("Failed to get email", zap.String("id", id.String()), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to get email",
+		})
+	}
+
+	queued.Redact()
+	return c.JSON(http.StatusOK, queued)
+}
+
+// ResendEmail handles POST /api/admin/emails/:id/resend. A sent or
+// dead-lettered email is queued again with a fresh set of attempts.
+func (h *AdminEmailHandler) ResendEmail(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid email ID",
+		})
+	}
+
+	queued, err := h.outbox.Resend(c.Request().Context(), id)
+	switch {
+	case errors.Is(err, email.ErrOutboxEmailNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "Email not found",
+		})
+	case errors.Is(err, email.ErrOutboxEmailSending):
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": "Email is being sent",
+		})
+	case err != nil:
+		h.logger.Error("Failed to resend email", zap.String("id", id.String()), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to resend email",
+		})
+	}
+
+	h.logger.Info("Email queued for resend",
+		zap.String("id", id.String()),
+		zap.String("template", queued.Template),
+	)
+
+	queued.Redact()
+	return c.JSON(http.StatusOK, queued)
+}
+
+// ListTemplates handles GET /api/admin/emails/templates
+func (h *AdminEmailHandler) ListTemplates(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"templates": email.Templates,
+	})
+}
+
+// PreviewTemplate handles GET /api/admin/emails/templates/:name/preview. It
+// renders the template with sample data as HTML, or as text or JSON with
+// ?format=text or ?format=json.
+func (h *AdminEmailHandler) PreviewTemplate(c echo.Context) error {
+	name := c.Param("name")
+
+	var msg *email.Message
+	data, err := email.SampleData(name)
+	if err == nil {
+		msg, err = h.renderer.Render(name, data)
+	}
+	if errors.Is(err, email.ErrUnknownTemplate) {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "Template not found",
+		})
+	}
+	if err != nil {
+		h.logger.Error("Failed to render email preview",
+			zap.String("template", name),
+			zap.Error(err),
+		)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to render template",
+		})
+	}
+
+	c.Response().Header().Set("X-Email-Subject", msg.Subject)
+	switch c.QueryParam("format") {
+	case "", "html":
+		return c.HTML(http.StatusOK, msg.HTML)
+	case "text":
+		return c.String(http.StatusOK, msg.Text)
+	case "json":
+		return c.JSON(http.StatusOK, map[string]string{
+			"subject": msg.Subject,
+			"html":    msg.HTML,
+			"text":    msg.Text,
+		})
+	}
+	return c.JSON(http.StatusBadRequest, map[string]string{
+		"error": "Format must be html, text or json",
+	})
+}
@@ -1,25 +1,32 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/ramniya/ramniya-backend/orders"
+	"github.com/ramniya/ramniya-backend/payments"
+	"github.com/ramniya/ramniya-backend/promotions"
 	"go.uber.org/zap"
 )
 
 // AdminOrderHandler handles admin order operations
 type AdminOrderHandler struct {
 	orderRepo *orders.OrderRepository
+	gateways  *payments.Registry
 	logger    *zap.Logger
 }
 
 // NewAdminOrderHandler creates a new admin order handler
-func NewAdminOrderHandler(orderRepo *orders.OrderRepository, logger *zap.Logger) *AdminOrderHandler {
+func NewAdminOrderHandler(orderRepo *orders.OrderRepository, gateways *payments.Registry, logger *zap.Logger) *AdminOrderHandler {
 	return &AdminOrderHandler{
 		orderRepo: orderRepo,
+		gateways:  gateways,
 		logger:    logger,
 	}
 }
@@ -34,6 +41,11 @@ func (h *AdminOrderHandler) ListAllOrders(c echo.Context) error {
 		filter.Status = &status
 	}
 
+	if fulfilmentStr := c.QueryParam("fulfilment_status"); fulfilmentStr != "" {
+		fulfilment := orders.FulfilmentStatus(fulfilmentStr)
+		filter.FulfilmentStatus = &fulfilment
+	}
+
 	if userIDStr := c.QueryParam("user_id"); userIDStr != "" {
 		userID, err := uuid.Parse(userIDStr)
 		if err == nil {
@@ -119,9 +131,12 @@ func (h *AdminOrderHandler) GetOrderAdmin(c echo.Context) error {
 // UpdateOrderStatusRequest represents admin order status update
 type UpdateOrderStatusRequest struct {
 	Status string `json:"status"`
+	Note   string `json:"note,omitempty"`
 }
 
-// UpdateOrderStatusAdmin handles PUT /api/admin/orders/:id/status
+// UpdateOrderStatusAdmin handles PUT /api/admin/orders/:id/status. Admins
+// may fail or cancel an order, approve a payment held for review, or confirm
+// a cash on delivery order; payments themselves come from the gateways.
 func (h *AdminOrderHandler) UpdateOrderStatusAdmin(c echo.Context) error {
 	orderIDStr := c.Param("id")
 	orderID, err := uuid.Parse(orderIDStr)
@@ -141,12 +156,18 @@ func (h *AdminOrderHandler) UpdateOrderStatusAdmin(c echo.Context) error {
 	// Validate status
 	status := orders.OrderStatus(req.Status)
 	validStatuses := map[orders.OrderStatus]bool{
-		orders.OrderStatusCreated:   true,
-		orders.OrderStatusPending:   true,
-		orders.OrderStatusPaid:      true,
 		orders.OrderStatusFailed:    true,
 		orders.OrderStatusCancelled: true,
-		orders.OrderStatusRefunded:  true,
+		// Approves a payment held for review
+		orders.OrderStatusPaid: true,
+		// Confirms a cash on delivery order
+		orders.OrderStatusCODPending: true,
+	}
+
+	if status == orders.OrderStatusRefunded || status == orders.OrderStatusPartiallyRefunded {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Use the refunds endpoint to refund an order",
+		})
 	}
 
 	if !validStatuses[status] {
@@ -156,8 +177,11 @@ func (h *AdminOrderHandler) UpdateOrderStatusAdmin(c echo.Context) error {
 	}
 
 	// Update order status
+	adminID, _ := c.Get("user_id").(string)
 	updateInput := orders.UpdateOrderStatusInput{
 		Status: status,
+		Actor:  orders.Actor{Type: orders.ActorAdmin, ID: adminID},
+		Note:   req.Note,
 	}
 
 	order, err := h.orderRepo.UpdateOrderStatus(c.Request().Context(), orderID, updateInput)
@@ -167,6 +191,29 @@ func (h *AdminOrderHandler) UpdateOrderStatusAdmin(c echo.Context) error {
 				"error": "Order not found",
 			})
 		}
+		var invalid *orders.InvalidTransitionError
+		if errors.As(err, &invalid) {
+			return c.JSON(http.StatusConflict, map[string]string{
+				"error": fmt.Sprintf("Cannot change order status from %s to %s", invalid.From, invalid.To),
+			})
+		}
+		if errors.Is(err, orders.ErrCODCollectionRequired) {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Use the COD collection endpoint to mark a cash on delivery order paid",
+			})
+		}
+		if errors.Is(err, orders.ErrNotCODOrder) {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Only cash on delivery orders can be moved to cod_pending",
+			})
+		}
+		var couponErr *promotions.CouponError
+		if errors.As(err, &couponErr) {
+			return c.JSON(http.StatusConflict, map[string]string{
+				"error":  "The order's coupon can no longer be used: " + couponErr.Message,
+				"reason": couponErr.Reason,
+			})
+		}
 		h.logger.Error("Failed to update order status",
 			zap.String("order_id", orderIDStr),
 			zap.Error(err),
@@ -185,6 +232,455 @@ func (h *AdminOrderHandler) UpdateOrderStatusAdmin(c echo.Context) error {
 	return c.JSON(http.StatusOK, order)
 }
 
+// UpdateFulfilmentRequest represents an admin fulfilment update
+type UpdateFulfilmentRequest struct {
+	Status         string  `json:"status"`
+	Carrier        *string `json:"carrier,omitempty"`
+	TrackingNumber *string `json:"tracking_number,omitempty"`
+	TrackingURL    *string `json:"tracking_url,omitempty"`
+	Note           string  `json:"note,omitempty"`
+}
+
+// UpdateFulfilmentAdmin handles PUT /api/admin/orders/:id/fulfilment
+func (h *AdminOrderHandler) UpdateFulfilmentAdmin(c echo.Context) error {
+	orderIDStr := c.Param("id")
+	orderID, err := uuid.Parse(orderIDStr)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid order ID",
+		})
+	}
+
+	var req UpdateFulfilmentRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request body",
+		})
+	}
+
+	status := orders.FulfilmentStatus(req.Status)
+	if !status.IsValid() {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid fulfilment status",
+		})
+	}
+
+	adminID, _ := c.Get("user_id").(string)
+	order, err := h.orderRepo.UpdateFulfilment(c.Request().Context(), orderID, orders.UpdateFulfilmentInput{
+		Status:         status,
+		Carrier:        req.Carrier,
+		TrackingNumber: req.TrackingNumber,
+		TrackingURL:    req.TrackingURL,
+		Actor:          orders.Actor{Type: orders.ActorAdmin, ID: adminID},
+		Note:           req.Note,
+	})
+	if err != nil {
+		var invalid *orders.InvalidFulfilmentTransitionError
+		switch {
+		case err.Error() == "order not found":
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "Order not found",
+			})
+		case errors.As(err, &invalid):
+			return c.JSON(http.StatusConflict, map[string]string{
+				"error": fmt.Sprintf("Cannot change fulfilment status from %s to %s", invalid.From, invalid.To),
+			})
+		case errors.Is(err, orders.ErrOrderNotFulfillable):
+			return c.JSON(http.StatusConflict, map[string]string{
+				"error": "Only paid orders can be fulfilled",
+			})
+		case errors.Is(err, orders.ErrTrackingRequired):
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "carrier and tracking_number are required to ship an order",
+			})
+		}
+		h.logger.Error("Failed to update fulfilment",
+			zap.String("order_id", orderIDStr),
+			zap.Error(err),
+		)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to update fulfilment",
+		})
+	}
+
+	h.logger.Info("Order fulfilment updated by admin",
+		zap.String("order_id", orderID.String()),
+		zap.String("fulfilment_status", req.Status),
+		zap.String("admin_id", adminID),
+	)
+
+	return c.JSON(http.StatusOK, order)
+}
+
+// CollectCODPaymentRequest represents confirmation of cash collected on delivery
+type CollectCODPaymentRequest struct {
+	Note string `json:"note,omitempty"`
+}
+
+// CollectCODPayment handles POST /api/admin/orders/:id/cod/collect
+func (h *AdminOrderHandler) CollectCODPayment(c echo.Context) error {
+	orderIDStr := c.Param("id")
+	orderID, err := uuid.Parse(orderIDStr)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid order ID",
+		})
+	}
+
+	var req CollectCODPaymentRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request body",
+		})
+	}
+
+	adminID, _ := c.Get("user_id").(string)
+	order, err := h.orderRepo.CollectCODPayment(c.Request().Context(), orderID,
+		orders.Actor{Type: orders.ActorAdmin, ID: adminID}, req.Note)
+	if err != nil {
+		var invalid *orders.InvalidTransitionError
+		switch {
+		case err.Error() == "order not found":
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "Order not found",
+			})
+		case errors.As(err, &invalid):
+			return c.JSON(http.StatusConflict, map[string]string{
+				"error": fmt.Sprintf("Order is %s, not awaiting cash on delivery", invalid.From),
+			})
+		case errors.Is(err, orders.ErrCODNotDelivered):
+			return c.JSON(http.StatusConflict, map[string]string{
+				"error": "Cash can only be collected once the order is delivered",
+			})
+		}
+		h.logger.Error("Failed to record COD collection",
+			zap.String("order_id", orderIDStr),
+			zap.Error(err),
+		)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to update order",
+		})
+	}
+
+	h.logger.Info("COD payment collected",
+		zap.String("order_id", orderID.String()),
+		zap.Int("amount_cents", order.AmountCents),
+		zap.String("admin_id", adminID),
+	)
+
+	return c.JSON(http.StatusOK, order)
+}
+
+// GetOrderHistory handles GET /api/admin/orders/:id/history
+func (h *AdminOrderHandler) GetOrderHistory(c echo.Context) error {
+	orderIDStr := c.Param("id")
+	orderID, err := uuid.Parse(orderIDStr)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid order ID",
+		})
+	}
+
+	if _, err := h.orderRepo.GetOrder(c.Request().Context(), orderID); err != nil {
+		if err.Error() == "order not found" {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "Order not found",
+			})
+		}
+		h.logger.Error("Failed to get order", zap.String("order_id", orderIDStr), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to get order history",
+		})
+	}
+
+	history, err := h.orderRepo.GetStatusHistory(c.Request().Context(), orderID)
+	if err != nil {
+		h.logger.Error("Failed to get order history",
+			zap.String("order_id", orderIDStr),
+			zap.Error(err),
+		)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to get order history",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"history": history,
+	})
+}
+
+// CreateRefundRequest represents an admin refund request
+type CreateRefundRequest struct {
+	AmountCents int    `json:"amount_cents"` // Omit to refund the remaining balance
+	Reason      string `json:"reason"`
+}
+
+// CreateRefund handles POST /api/admin/orders/:id/refunds
+func (h *AdminOrderHandler) CreateRefund(c echo.Context) error {
+	orderIDStr := c.Param("id")
+	orderID, err := uuid.Parse(orderIDStr)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid order ID",
+		})
+	}
+
+	var req CreateRefundRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.AmountCents < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Amount must be positive",
+		})
+	}
+
+	adminIDStr, _ := c.Get("user_id").(string)
+	var adminID *uuid.UUID
+	if id, err := uuid.Parse(adminIDStr); err == nil {
+		adminID = &id
+	}
+	actor := orders.Actor{Type: orders.ActorAdmin, ID: adminIDStr}
+
+	ctx := c.Request().Context()
+
+	order, err := h.orderRepo.GetOrder(ctx, orderID)
+	if err != nil {
+		if err.Error() == "order not found" {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "Order not found",
+			})
+		}
+		h.logger.Error("Failed to get order", zap.String("order_id", orderIDStr), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to create refund",
+		})
+	}
+
+	if order.PaymentGateway == orders.PaymentMethodCOD {
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": "Cash on delivery orders are refunded outside the payment gateway",
+		})
+	}
+
+	gateway, err := h.gateways.Get(order.PaymentGateway)
+	if err != nil {
+		h.logger.Error("Payment gateway for order unavailable",
+			zap.String("order_id", orderIDStr),
+			zap.String("gateway", order.PaymentGateway),
+		)
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{
+			"error": "Payment gateway unavailable",
+		})
+	}
+
+	refund, err := h.orderRepo.CreateRefund(ctx, orders.CreateRefundInput{
+		OrderID:     orderID,
+		AmountCents: req.AmountCents,
+		Reason:      req.Reason,
+		CreatedBy:   adminID,
+	})
+	if err != nil {
+		var tooLarge *orders.RefundTooLargeError
+		switch {
+		case err.Error() == "order not found":
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "Order not found",
+			})
+		case errors.Is(err, orders.ErrOrderNotRefundable):
+			return c.JSON(http.StatusConflict, map[string]string{
+				"error": "Only paid orders can be refunded",
+			})
+		case errors.As(err, &tooLarge):
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"error":           "Refund amount exceeds the refundable balance",
+				"remaining_cents": tooLarge.RemainingCents,
+			})
+		}
+		h.logger.Error("Failed to create refund",
+			zap.String("order_id", orderIDStr),
+			zap.Error(err),
+		)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to create refund",
+		})
+	}
+
+	return h.submitRefund(c, gateway, refund, actor)
+}
+
+// RetryRefund handles POST /api/admin/orders/:id/refunds/:refund_id/retry. A
+// refund whose submission had an unknown outcome is sent again; gateways
+// recognise the refund ID, so it is never made twice.
+func (h *AdminOrderHandler) RetryRefund(c echo.Context) error {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid order ID",
+		})
+	}
+
+	refundID, err := uuid.Parse(c.Param("refund_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid refund ID",
+		})
+	}
+
+	adminIDStr, _ := c.Get("user_id").(string)
+	actor := orders.Actor{Type: orders.ActorAdmin, ID: adminIDStr}
+
+	ctx := c.Request().Context()
+
+	refund, err := h.orderRepo.GetRefund(ctx, refundID)
+	if err != nil || refund.OrderID != orderID {
+		if err == nil || err.Error() == "refund not found" {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "Refund not found",
+			})
+		}
+		h.logger.Error("Failed to get refund", zap.String("refund_id", refundID.String()), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to retry refund",
+		})
+	}
+
+	if refund.Status != orders.RefundStatusPending || refund.RazorpayRefundID != nil {
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": "Only refunds not yet accepted by the payment gateway can be retried",
+		})
+	}
+
+	order, err := h.orderRepo.GetOrder(ctx, orderID)
+	if err != nil {
+		h.logger.Error("Failed to get order", zap.String("order_id", orderID.String()), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to retry refund",
+		})
+	}
+
+	gateway, err := h.gateways.Get(order.PaymentGateway)
+	if err != nil {
+		h.logger.Error("Payment gateway for order unavailable",
+			zap.String("order_id", orderID.String()),
+			zap.String("gateway", order.PaymentGateway),
+		)
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{
+			"error": "Payment gateway unavailable",
+		})
+	}
+
+	return h.submitRefund(c, gateway, refund, actor)
+}
+
+// submitRefund sends a pending refund to the payment gateway and records the
+// result. Only a definitive rejection fails the refund and releases its
+// amount; after timeouts and server errors the gateway may have made the
+// refund, so it stays pending for the webhook to settle or an admin retry.
+func (h *AdminOrderHandler) submitRefund(c echo.Context, gateway payments.Gateway, refund *orders.Refund, actor orders.Actor) error {
+	ctx := c.Request().Context()
+
+	notes := map[string]string{"order_id": refund.OrderID.String()}
+	if refund.Reason != nil && *refund.Reason != "" {
+		notes["reason"] = *refund.Reason
+	}
+
+	gatewayRefund, err := gateway.Refund(payments.RefundInput{
+		PaymentID: refund.RazorpayPaymentID,
+		Amount:    refund.AmountCents,
+		Receipt:   refund.ID.String(),
+		Notes:     notes,
+	})
+	if err != nil {
+		h.logger.Error("Failed to submit refund to payment gateway",
+			zap.String("order_id", refund.OrderID.String()),
+			zap.String("refund_id", refund.ID.String()),
+			zap.Error(err),
+		)
+
+		if !errors.Is(err, payments.ErrRefundRejected) {
+			return c.JSON(http.StatusAccepted, map[string]interface{}{
+				"message": "The payment gateway did not confirm the refund. It stays pending until the gateway reports back, or can be retried.",
+				"refund":  refund,
+			})
+		}
+
+		// Release the amount so a new refund can be created
+		if _, updateErr := h.orderRepo.UpdateRefundStatus(ctx, orders.RefundUpdate{
+			RefundID:      refund.ID,
+			Status:        orders.RefundStatusFailed,
+			FailureReason: err.Error(),
+			Actor:         actor,
+		}); updateErr != nil {
+			h.logger.Error("Failed to mark refund as failed",
+				zap.String("refund_id", refund.ID.String()),
+				zap.Error(updateErr),
+			)
+		}
+
+		return c.JSON(http.StatusBadGateway, map[string]string{
+			"error": "The payment gateway rejected the refund",
+		})
+	}
+
+	updated, err := h.orderRepo.UpdateRefundStatus(ctx, orders.RefundUpdate{
+		RefundID:         refund.ID,
+		RazorpayRefundID: gatewayRefund.ID,
+		Status:           gatewayRefund.Status,
+		Actor:            actor,
+	})
+	if err != nil {
+		// The refund exists at the gateway; the webhook will settle our record
+		h.logger.Error("Failed to record gateway refund",
+			zap.String("refund_id", refund.ID.String()),
+			zap.String("gateway_refund_id", gatewayRefund.ID),
+			zap.Error(err),
+		)
+		return c.JSON(http.StatusAccepted, map[string]string{
+			"message": "Refund submitted",
+		})
+	}
+	refund = updated
+
+	h.logger.Info("Refund submitted by admin",
+		zap.String("order_id", refund.OrderID.String()),
+		zap.String("refund_id", refund.ID.String()),
+		zap.Int("amount_cents", refund.AmountCents),
+		zap.String("admin_id", actor.ID),
+	)
+
+	return c.JSON(http.StatusCreated, refund)
+}
+
+// ListRefunds handles GET /api/admin/orders/:id/refunds
+func (h *AdminOrderHandler) ListRefunds(c echo.Context) error {
+	orderIDStr := c.Param("id")
+	orderID, err := uuid.Parse(orderIDStr)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid order ID",
+		})
+	}
+
+	refunds, err := h.orderRepo.ListRefunds(c.Request().Context(), orderID)
+	if err != nil {
+		h.logger.Error("Failed to list refunds",
+			zap.String("order_id", orderIDStr),
+			zap.Error(err),
+		)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to list refunds",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"refunds": refunds,
+	})
+}
+
 // GetOrderStats handles GET /api/admin/orders/stats
 func (h *AdminOrderHandler) GetOrderStats(c echo.Context) error {
 	ctx := c.Request().Context()
@@ -221,6 +717,20 @@ func (h *AdminOrderHandler) GetOrderStats(c echo.Context) error {
 		Limit:  1,
 	})
 
+	// Get orders whose payment needs review
+	reviewStatus := orders.OrderStatusPaymentReview
+	_, reviewCount, _ := h.orderRepo.ListOrders(ctx, orders.ListOrdersFilter{
+		Status: &reviewStatus,
+		Limit:  1,
+	})
+
+	// Get cash on delivery orders awaiting collection
+	codStatus := orders.OrderStatusCODPending
+	_, codCount, _ := h.orderRepo.ListOrders(ctx, orders.ListOrdersFilter{
+		Status: &codStatus,
+		Limit:  1,
+	})
+
 	// Calculate total revenue
 	totalRevenue := 0
 	for _, order := range paidOrders {
@@ -232,9 +742,69 @@ func (h *AdminOrderHandler) GetOrderStats(c echo.Context) error {
 		"paid_orders":    paidCount,
 		"pending_orders": pendingCount,
 		"failed_orders":  failedCount,
+		"payment_review": reviewCount,
+		"cod_pending":    codCount,
 		"total_revenue":  totalRevenue,
 		"currency":       "INR",
 	}
 
 	return c.JSON(http.StatusOK, stats)
 }
+
+// GetGSTReport handles GET /api/admin/reports/gst. from and to are dates
+// (YYYY-MM-DD, India time, both inclusive) and default to the current month.
+func (h *AdminOrderHandler) GetGSTReport(c echo.Context) error {
+	ist := time.FixedZone("IST", 5*60*60+30*60)
+	now := time.Now().In(ist)
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, ist)
+	to := from.AddDate(0, 1, -1)
+
+	if fromStr := c.QueryParam("from"); fromStr != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", fromStr, ist)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid from date, expected YYYY-MM-DD",
+			})
+		}
+		from = parsed
+	}
+	if toStr := c.QueryParam("to"); toStr != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", toStr, ist)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid to date, expected YYYY-MM-DD",
+			})
+		}
+		to = parsed
+	}
+	if to.Before(from) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "to must not be before from",
+		})
+	}
+
+	summary, err := h.orderRepo.GSTSummary(c.Request().Context(), from, to.AddDate(0, 0, 1))
+	if err != nil {
+		h.logger.Error("Failed to get GST report", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to get GST report",
+		})
+	}
+
+	totals := orders.GSTSummaryRow{}
+	for _, row := range summary {
+		totals.TaxableCents += row.TaxableCents
+		totals.CGSTCents += row.CGSTCents
+		totals.SGSTCents += row.SGSTCents
+		totals.IGSTCents += row.IGSTCents
+		totals.TaxCents += row.TaxCents
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"from":     from.Format("2006-01-02"),
+		"to":       to.Format("2006-01-02"),
+		"rows":     summary,
+		"totals":   totals,
+		"currency": "INR",
+	})
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/ramniya/ramniya-backend/auth"
+	"go.uber.org/zap"
+)
+
+// AdminUserHandler handles admin user operations
+type AdminUserHandler struct {
+	authRepo *auth.AuthRepository
+	revoker  *auth.TokenRevoker
+	logger   *zap.Logger
+}
+
+// NewAdminUserHandler creates a new admin user handler
+func NewAdminUserHandler(authRepo *auth.AuthRepository, revoker *auth.TokenRevoker, logger *zap.Logger) *AdminUserHandler {
+	return &AdminUserHandler{
+		authRepo: authRepo,
+		revoker:  revoker,
+		logger:   logger,
+	}
+}
+
+// ForceLogout handles POST /api/admin/users/:id/logout
+func (h *AdminUserHandler) ForceLogout(c echo.Context) error {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid user ID",
+		})
+	}
+
+	if err := h.revoker.RevokeUser(c.Request().Context(), userID); err != nil {
+		if err.Error() == "user not found" {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "User not found",
+			})
+		}
+
+		h.logger.Error("Failed to force logout user",
+			zap.String("user_id", userID.String()),
+			zap.Error(err),
+		)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to log out user",
+		})
+	}
+
+	adminID, _ := c.Get("user_id").(string)
+	h.logger.Info("User force logged out by admin",
+		zap.String("user_id", userID.String()),
+		zap.String("admin_id", adminID),
+	)
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "User has been logged out from all devices",
+	})
+}
+
+// UpdateUserRoleRequest represents the request body for changing a user's role
+type UpdateUserRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// UpdateRole handles PUT /api/admin/users/:id/role. The user's existing
+// sessions are revoked so the new role applies from their next login.
+func (h *AdminUserHandler) UpdateRole(c echo.Context) error {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid user ID",
+		})
+	}
+
+	var req UpdateUserRoleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request body",
+		})
+	}
+
+	role := auth.UserRole(req.Role)
+	if role != auth.RoleCustomer && role != auth.RoleAdmin {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid role",
+		})
+	}
+
+	adminID, _ := c.Get("user_id").(string)
+	if adminID == userID.String() {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "You cannot change your own role",
+		})
+	}
+
+	if err := h.revoker.UpdateRole(c.Request().Context(), userID, role); err != nil {
+		if err.Error() == "user not found" {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "User not found",
+			})
+		}
+
+		h.logger.Error("Failed to update user role",
+			zap.String("user_id", userID.String()),
+			zap.Error(err),
+		)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to update role",
+		})
+	}
+
+	h.logger.Info("User role changed by admin",
+		zap.String("user_id", userID.String()),
+		zap.String("role", string(role)),
+		zap.String("admin_id", adminID),
+	)
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Role updated; the user has been logged out from all devices",
+	})
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/ramniya/ramniya-backend/auth"
+	"github.com/ramniya/ramniya-backend/cache"
+	"github.com/ramniya/ramniya-backend/database"
+	"github.com/ramniya/ramniya-backend/jwt"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestAdminUpdateRole(t *testing.T) {
+	_, authRepo, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	testEmail := "test-admin-update-role@example.com"
+	testPassword := "testpass123"
+	defer cleanupTestUser(t, authRepo, testEmail)
+
+	ctx := context.Background()
+	user, err := authRepo.CreateUser(ctx, auth.CreateUserInput{Email: testEmail, Password: &testPassword})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	testLogger, _ := zap.NewDevelopment()
+	redisClient, _ := cache.NewRedisClient("", testLogger)
+	revoker := auth.NewTokenRevoker(authRepo, redisClient, testLogger)
+	handler := NewAdminUserHandler(authRepo, revoker, testLogger)
+
+	// A session issued before the role change
+	tokenService := jwt.NewTokenService("test-secret", 7*24*time.Hour, 30*24*time.Hour)
+	accessToken, _, err := tokenService.GenerateAccessToken(user.ID, user.Email)
+	assert.NoError(t, err)
+	claims, err := tokenService.VerifyToken(accessToken, jwt.PurposeAccess)
+	assert.NoError(t, err)
+	refreshID := uuid.New()
+	assert.NoError(t, authRepo.CreateRefreshToken(ctx, refreshID, user.ID, refreshID, time.Now().Add(time.Hour)))
+
+	revoked, err := revoker.IsRevoked(ctx, claims)
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	e := echo.New()
+	newContext := func(userID, body string) (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(http.MethodPut, "/api/admin/users/"+userID+"/role", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues(userID)
+		c.Set("user_id", uuid.New().String())
+		return c, rec
+	}
+
+	t.Run("Invalid Role", func(t *testing.T) {
+		c, rec := newContext(user.ID.String(), `{"role":"owner"}`)
+		if assert.NoError(t, handler.UpdateRole(c)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		}
+	})
+
+	t.Run("Unknown User", func(t *testing.T) {
+		c, rec := newContext(uuid.New().String(), `{"role":"admin"}`)
+		if assert.NoError(t, handler.UpdateRole(c)) {
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+		}
+	})
+
+	t.Run("Promote", func(t *testing.T) {
+		c, rec := newContext(user.ID.String(), `{"role":"admin"}`)
+		if assert.NoError(t, handler.UpdateRole(c)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+
+		changed, err := authRepo.GetUserByID(ctx, user.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, auth.RoleAdmin, changed.Role)
+
+		// Tokens carrying the old role no longer work
+		revoked, err := revoker.IsRevoked(ctx, claims)
+		assert.NoError(t, err)
+		assert.True(t, revoked)
+
+		var refreshRevoked bool
+		err = database.DB.QueryRow(
+			"SELECT revoked_at IS NOT NULL FROM refresh_tokens WHERE id = $1", refreshID,
+		).Scan(&refreshRevoked)
+		assert.NoError(t, err)
+		assert.True(t, refreshRevoked)
+	})
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/ramniya/ramniya-backend/orders"
+	"github.com/ramniya/ramniya-backend/payments"
+	"go.uber.org/zap"
+)
+
+// AdminWebhookHandler handles admin webhook event operations
+type AdminWebhookHandler struct {
+	orderRepo *orders.OrderRepository
+	webhooks  *payments.WebhookProcessor
+	logger    *zap.Logger
+}
+
+// NewAdminWebhookHandler creates a new admin webhook handler
+func NewAdminWebhookHandler(orderRepo *orders.OrderRepository, webhooks *payments.WebhookProcessor, logger *zap.Logger) *AdminWebhookHandler {
+	return &AdminWebhookHandler{
+		orderRepo: orderRepo,
+		webhooks:  webhooks,
+		logger:    logger,
+	}
+}
+
+// ListWebhookEvents handles GET /api/admin/webhooks
+func (h *AdminWebhookHandler) ListWebhookEvents(c echo.Context) error {
+	filter := orders.ListWebhookEventsFilter{
+		EventType: c.QueryParam("event_type"),
+	}
+
+	if statusStr := c.QueryParam("status"); statusStr != "" {
+		status := orders.WebhookEventStatus(statusStr)
+		filter.Status = &status
+	}
+
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	filter.Limit = limit
+
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	if page < 1 {
+		page = 1
+	}
+	filter.Offset = (page - 1) * limit
+
+	events, total, err := h.orderRepo.ListWebhookEvents(c.Request().Context(), filter)
+	if err != nil {
+		h.logger.Error("Failed to list webhook events", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to list webhook events",
+		})
+	}
+
+	totalPages := (total + limit - 1) / limit
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"events": events,
+		"pagination": map[string]interface{}{
+			"total":        total,
+			"page":         page,
+			"limit":        limit,
+			"total_pages":  totalPages,
+			"has_next":     page < totalPages,
+			"has_previous": page > 1,
+		},
+	})
+}
+
+// GetWebhookEvent handles GET /api/admin/webhooks/:id
+func (h *AdminWebhookHandler) GetWebhookEvent(c echo.Context) error {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid webhook event ID",
+		})
+	}
+
+	event, err := h.orderRepo.GetWebhookEvent(c.Request().Context(), eventID)
+	if err != nil {
+		if err.Error() == "webhook event not found" {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "Webhook event not found",
+			})
+		}
+		h.logger.Error("Failed to get webhook event", zap.String("id", eventID.String()), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to get webhook event",
+		})
+	}
+
+	return c.JSON(http.StatusOK, event)
+}
+
+// ReplayWebhookEvent handles POST /api/admin/webhooks/:id/replay. The stored
+// payload is processed again regardless of its current status.
+func (h *AdminWebhookHandler) ReplayWebhookEvent(c echo.Context) error {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid webhook event ID",
+		})
+	}
+
+	ctx := c.Request().Context()
+
+	event, err := h.orderRepo.GetWebhookEvent(ctx, eventID)
+	if err != nil {
+		if err.Error() == "webhook event not found" {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "Webhook event not found",
+			})
+		}
+		h.logger.Error("Failed to get webhook event", zap.String("id", eventID.String()), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to replay webhook event",
+		})
+	}
+
+	outcome, procErr := h.webhooks.Process(ctx, event, true)
+	if outcome == payments.OutcomeAlreadyHandled {
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": "Webhook event is being processed",
+		})
+	}
+
+	// Return the stored event so the admin sees the recorded outcome
+	updated, err := h.orderRepo.GetWebhookEvent(ctx, eventID)
+	if err != nil {
+		h.logger.Error("Failed to get webhook event", zap.String("id", eventID.String()), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to replay webhook event",
+		})
+	}
+
+	if procErr != nil {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]interface{}{
+			"error": procErr.Error(),
+			"event": updated,
+		})
+	}
+
+	h.logger.Info("Webhook event replayed",
+		zap.String("id", eventID.String()),
+		zap.String("event_id", event.EventID),
+		zap.String("outcome", outcome),
+	)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"outcome": outcome,
+		"event":   updated,
+	})
+}
@@ -1,16 +1,22 @@
 package handlers
 
 import (
+	"context"
 	"crypto/rand"
+	"database/sql"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/ramniya/ramniya-backend/auth"
+	"github.com/ramniya/ramniya-backend/cart"
 	"github.com/ramniya/ramniya-backend/email"
 	"github.com/ramniya/ramniya-backend/jwt"
 	"github.com/ramniya/ramniya-backend/oauth"
@@ -21,20 +27,31 @@ import (
 type AuthHandler struct {
 	authRepo     *auth.AuthRepository
 	tokenService *jwt.TokenService
-	emailSender  email.EmailSender
+	outbox       *email.OutboxRepository
 	oauthService *oauth.GoogleOAuthService
+	cartRepo     *cart.CartRepository
+	revoker      *auth.TokenRevoker
 	logger       *zap.Logger
 	baseURL      string
 	frontendURL  string
-	oauthStates  map[string]time.Time // Production: use Redis/database
+	oauthStates  map[string]oauthState // Production: use Redis/database
+}
+
+// oauthState is a pending OAuth login
+type oauthState struct {
+	expiry     time.Time
+	cartToken  string    // Guest cart to merge once the user is known
+	linkUserID uuid.UUID // Set when linking Google from the account page
 }
 
 // NewAuthHandler creates a new auth handler
 func NewAuthHandler(
 	authRepo *auth.AuthRepository,
 	tokenService *jwt.TokenService,
-	emailSender email.EmailSender,
+	outbox *email.OutboxRepository,
 	oauthService *oauth.GoogleOAuthService,
+	cartRepo *cart.CartRepository,
+	revoker *auth.TokenRevoker,
 	logger *zap.Logger,
 	baseURL string,
 	frontendURL string,
@@ -42,12 +59,14 @@ func NewAuthHandler(
 	return &AuthHandler{
 		authRepo:     authRepo,
 		tokenService: tokenService,
-		emailSender:  emailSender,
+		outbox:       outbox,
 		oauthService: oauthService,
+		cartRepo:     cartRepo,
+		revoker:      revoker,
 		logger:       logger,
 		baseURL:      baseURL,
 		frontendURL:  frontendURL,
-		oauthStates:  make(map[string]time.Time),
+		oauthStates:  make(map[string]oauthState),
 	}
 }
 
@@ -60,8 +79,9 @@ type RegisterRequest struct {
 
 // LoginRequest represents login request
 type LoginRequest struct {
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required"`
+	Email     string `json:"email" validate:"required,email"`
+	Password  string `json:"password" validate:"required"`
+	CartToken string `json:"cart_token,omitempty"`
 }
 
 // AuthResponse represents authentication response
@@ -105,11 +125,24 @@ func (h *AuthHandler) Register(c echo.Context) error {
 		})
 	}
 
-	// Create user
-	user, err := h.authRepo.CreateUser(c.Request().Context(), auth.CreateUserInput{
+	// Create user and queue the verification email with it
+	user, err := h.authRepo.CreateUserWith(c.Request().Context(), auth.CreateUserInput{
 		Email:    req.Email,
 		Name:     &req.Name,
 		Password: &req.Password,
+	}, func(tx *sql.Tx, user *auth.User) error {
+		verificationToken, err := h.tokenService.GenerateEmailVerificationToken(user.ID, user.Email)
+		if err != nil {
+			return fmt.Errorf("failed to generate verification token: %w", err)
+		}
+
+		verificationURL := fmt.Sprintf("%s/auth/verify?token=%s", h.frontendURL, verificationToken)
+		_, err = h.outbox.Enqueue(c.Request().Context(), tx, email.Outgoing{
+			To:       user.Email,
+			Template: email.TemplateVerification,
+			Data:     email.AccountData{Name: req.Name, URL: verificationURL},
+		})
+		return err
 	})
 
 	if err != nil {
@@ -128,26 +161,6 @@ func (h *AuthHandler) Register(c echo.Context) error {
 		})
 	}
 
-	// Generate email verification token
-	verificationToken, err := h.tokenService.GenerateEmailVerificationToken(user.ID, user.Email)
-	if err != nil {
-		h.logger.Error("Failed to generate verification token",
-			zap.String("user_id", user.ID.String()),
-			zap.Error(err),
-		)
-		// User is created but email won't be sent - still return success
-	} else {
-		// Send verification email
-		verificationURL := fmt.Sprintf("%s/auth/verify?token=%s", h.frontendURL, verificationToken)
-		if err := h.emailSender.SendVerificationEmail(user.Email, *user.Name, verificationURL); err != nil {
-			h.logger.Error("Failed to send verification email",
-				zap.String("user_id", user.ID.String()),
-				zap.Error(err),
-			)
-			// Continue anyway - user can request another verification email
-		}
-	}
-
 	h.logger.Info("User registered successfully",
 		zap.String("user_id", user.ID.String()),
 		zap.String("email", user.Email),
@@ -192,9 +205,16 @@ func (h *AuthHandler) VerifyEmail(c echo.Context) error {
 		})
 	}
 
-	// Set user as verified
-	if err := h.authRepo.SetVerified(c.Request().Context(), userID, true); err != nil {
-		h.logger.Error("Failed to verify user",
+	ctx := c.Request().Context()
+
+	user, err := h.authRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if err.Error() == "user not found" {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid token",
+			})
+		}
+		h.logger.Error("Failed to get user for verification",
 			zap.String("user_id", userID.String()),
 			zap.Error(err),
 		)
@@ -203,25 +223,33 @@ func (h *AuthHandler) VerifyEmail(c echo.Context) error {
 		})
 	}
 
-	// Get updated user
-	user, err := h.authRepo.GetUserByID(c.Request().Context(), userID)
+	// A token for another address confirms an email change
+	if !strings.EqualFold(claims.Email, user.Email) {
+		return h.confirmEmailChange(c, user, claims.Email)
+	}
+
+	// Set user as verified and queue the welcome email, once per account
+	userName := "User"
+	if user.Name != nil {
+		userName = *user.Name
+	}
+	err = h.authRepo.SetVerifiedWith(ctx, userID, true, func(tx *sql.Tx) error {
+		_, err := h.outbox.Enqueue(ctx, tx, email.Outgoing{
+			To:        user.Email,
+			Template:  email.TemplateWelcome,
+			Data:      email.AccountData{Name: userName},
+			DedupeKey: "welcome:" + userID.String(),
+		})
+		return err
+	})
 	if err != nil {
-		h.logger.Error("Failed to get user after verification",
+		h.logger.Error("Failed to verify user",
 			zap.String("user_id", userID.String()),
 			zap.Error(err),
 		)
-	} else {
-		// Send welcome email
-		userName := "User"
-		if user.Name != nil {
-			userName = *user.Name
-		}
-		if err := h.emailSender.SendWelcomeEmail(user.Email, userName); err != nil {
-			h.logger.Error("Failed to send welcome email",
-				zap.String("user_id", userID.String()),
-				zap.Error(err),
-			)
-		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to verify email",
+		})
 	}
 
 	h.logger.Info("Email verified successfully",
@@ -235,6 +263,40 @@ func (h *AuthHandler) VerifyEmail(c echo.Context) error {
 	})
 }
 
+// confirmEmailChange applies a pending email change whose verification link
+// was opened
+func (h *AuthHandler) confirmEmailChange(c echo.Context, user *auth.User, newEmail string) error {
+	if err := h.authRepo.ConfirmEmailChange(c.Request().Context(), user.ID, newEmail); err != nil {
+		if errors.Is(err, auth.ErrEmailChangeInvalid) {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid or expired verification token",
+			})
+		}
+		if errors.Is(err, auth.ErrEmailTaken) {
+			return c.JSON(http.StatusConflict, map[string]string{
+				"error": "Email already registered",
+			})
+		}
+		h.logger.Error("Failed to change email",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err),
+		)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to verify email",
+		})
+	}
+
+	h.logger.Info("Email changed successfully",
+		zap.String("user_id", user.ID.String()),
+	)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":  "Your email has been changed.",
+		"verified": true,
+		"email":    newEmail,
+	})
+}
+
 // Login handles user login
 func (h *AuthHandler) Login(c echo.Context) error {
 	var req LoginRequest
@@ -281,7 +343,7 @@ func (h *AuthHandler) Login(c echo.Context) error {
 		})
 	}
 
-	refreshToken, _, err := h.tokenService.GenerateRefreshToken(user.ID, user.Email)
+	refreshToken, err := h.issueRefreshToken(c.Request().Context(), user)
 	if err != nil {
 		h.logger.Error("Failed to generate refresh token",
 			zap.String("user_id", user.ID.String()),
@@ -296,6 +358,12 @@ func (h *AuthHandler) Login(c echo.Context) error {
 		zap.String("email", user.Email),
 	)
 
+	cartToken := req.CartToken
+	if cartToken == "" {
+		cartToken = c.Request().Header.Get(CartTokenHeader)
+	}
+	h.mergeGuestCart(c, cartToken, user.ID)
+
 	userName := ""
 	if user.Name != nil {
 		userName = *user.Name
@@ -320,6 +388,382 @@ func (h *AuthHandler) Login(c echo.Context) error {
 	})
 }
 
+// RefreshTokenRequest represents a token refresh request
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshToken handles POST /api/auth/refresh. Refresh tokens are single use:
+// each call returns a new refresh token and invalidates the one presented.
+func (h *AuthHandler) RefreshToken(c echo.Context) error {
+	var req RefreshTokenRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.RefreshToken == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Refresh token is required",
+		})
+	}
+
+	claims, err := h.tokenService.VerifyToken(req.RefreshToken, jwt.PurposeRefresh)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "Invalid or expired refresh token",
+		})
+	}
+
+	tokenID, err := claims.GetTokenID()
+	if err != nil {
+		// Tokens issued before rotation was introduced carry no jti
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "Invalid or expired refresh token",
+		})
+	}
+
+	userID, err := claims.GetUserID()
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "Invalid or expired refresh token",
+		})
+	}
+
+	ctx := c.Request().Context()
+
+	user, err := h.authRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "Invalid or expired refresh token",
+		})
+	}
+
+	newTokenID := uuid.New()
+	refreshToken, refreshExpiresAt, err := h.tokenService.GenerateRefreshToken(user.ID, user.Email, newTokenID)
+	if err != nil {
+		h.logger.Error("Failed to generate refresh token",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err),
+		)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to generate token",
+		})
+	}
+
+	rotated, err := h.authRepo.RotateRefreshToken(ctx, tokenID, newTokenID, refreshExpiresAt)
+	if err != nil {
+		if errors.Is(err, auth.ErrRefreshTokenReused) {
+			h.logger.Warn("Refresh token reuse detected, token family revoked",
+				zap.String("user_id", user.ID.String()),
+				zap.String("family_id", rotated.FamilyID.String()),
+			)
+			return c.JSON(http.StatusUnauthorized, map[string]string{
+				"error": "Refresh token has already been used. Please log in again.",
+			})
+		}
+		if errors.Is(err, auth.ErrRefreshTokenInvalid) {
+			return c.JSON(http.StatusUnauthorized, map[string]string{
+				"error": "Invalid or expired refresh token",
+			})
+		}
+		h.logger.Error("Failed to rotate refresh token",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err),
+		)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to refresh token",
+		})
+	}
+
+	accessToken, expiresAt, err := h.tokenService.GenerateAccessToken(user.ID, user.Email)
+	if err != nil {
+		h.logger.Error("Failed to generate access token",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err),
+		)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to generate token",
+		})
+	}
+
+	userName := ""
+	if user.Name != nil {
+		userName = *user.Name
+	}
+
+	return c.JSON(http.StatusOK, AuthResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(time.Until(expiresAt).Seconds()),
+		TokenType:    "Bearer",
+		User: &UserDetail{
+			ID:         user.ID.String(),
+			Email:      user.Email,
+			Name:       userName,
+			Role:       string(user.Role),
+			IsVerified: user.IsVerified,
+			CreatedAt:  user.CreatedAt.Format(time.RFC3339),
+		},
+	})
+}
+
+// LogoutRequest represents a logout request
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token,omitempty"`
+	AllDevices   bool   `json:"all_devices,omitempty"`
+}
+
+// Logout handles POST /api/auth/logout. The presented access token is revoked
+// along with the refresh token family it was issued with, if provided. With
+// all_devices every session of the user is ended.
+func (h *AuthHandler) Logout(c echo.Context) error {
+	claims, ok := c.Get("token_claims").(*jwt.Claims)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "User not authenticated",
+		})
+	}
+
+	userID, err := claims.GetUserID()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid user ID",
+		})
+	}
+
+	var req LogoutRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request body",
+		})
+	}
+
+	ctx := c.Request().Context()
+
+	if req.AllDevices {
+		if err := h.revoker.RevokeUser(ctx, userID); err != nil {
+			h.logger.Error("Failed to revoke user tokens",
+				zap.String("user_id", userID.String()),
+				zap.Error(err),
+			)
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "Failed to log out",
+			})
+		}
+
+		h.logger.Info("User logged out from all devices",
+			zap.String("user_id", userID.String()),
+		)
+
+		return c.JSON(http.StatusOK, map[string]string{
+			"message": "Logged out from all devices",
+		})
+	}
+
+	if err := h.revoker.RevokeToken(ctx, claims); err != nil {
+		h.logger.Error("Failed to revoke access token",
+			zap.String("user_id", userID.String()),
+			zap.Error(err),
+		)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to log out",
+		})
+	}
+
+	if req.RefreshToken != "" {
+		// An invalid refresh token is ignored; the access token is already revoked
+		if refreshClaims, err := h.tokenService.VerifyToken(req.RefreshToken, jwt.PurposeRefresh); err == nil {
+			if tokenID, err := refreshClaims.GetTokenID(); err == nil {
+				if err := h.authRepo.RevokeRefreshTokenFamily(ctx, tokenID, userID); err != nil {
+					h.logger.Error("Failed to revoke refresh token",
+						zap.String("user_id", userID.String()),
+						zap.Error(err),
+					)
+				}
+			}
+		}
+	}
+
+	h.logger.Info("User logged out",
+		zap.String("user_id", userID.String()),
+	)
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Logged out",
+	})
+}
+
+// maxPasswordResetsPerHour limits reset emails sent to a single account
+const maxPasswordResetsPerHour = 3
+
+// ForgotPasswordRequest represents a password reset request
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// ResetPasswordRequest represents setting a new password with a reset token
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// ForgotPassword handles POST /api/auth/forgot-password. The response is the
+// same whether or not the email belongs to an account.
+func (h *AuthHandler) ForgotPassword(c echo.Context) error {
+	var req ForgotPasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.Email == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Email is required",
+		})
+	}
+
+	// Issue the token in the background so response time does not reveal
+	// whether the account exists
+	go h.sendPasswordReset(strings.TrimSpace(req.Email))
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "If an account exists for this email, a password reset link has been sent.",
+	})
+}
+
+// sendPasswordReset issues a reset token and emails it, if the account exists
+// and has not hit the per-account limit
+func (h *AuthHandler) sendPasswordReset(address string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	user, err := h.authRepo.GetUserByEmail(ctx, address)
+	if err != nil {
+		if err.Error() != "user not found" {
+			h.logger.Error("Failed to look up user for password reset", zap.Error(err))
+		}
+		return
+	}
+
+	recent, err := h.authRepo.CountRecentPasswordResets(ctx, user.ID, time.Now().Add(-time.Hour))
+	if err != nil {
+		h.logger.Error("Failed to check password reset limit",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err),
+		)
+		return
+	}
+	if recent >= maxPasswordResetsPerHour {
+		h.logger.Warn("Password reset limit reached",
+			zap.String("user_id", user.ID.String()),
+		)
+		return
+	}
+
+	tokenID := uuid.New()
+	resetToken, expiresAt, err := h.tokenService.GeneratePasswordResetToken(user.ID, user.Email, tokenID)
+	if err != nil {
+		h.logger.Error("Failed to generate password reset token",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err),
+		)
+		return
+	}
+
+	userName := ""
+	if user.Name != nil {
+		userName = *user.Name
+	}
+
+	resetURL := fmt.Sprintf("%s/auth/reset-password?token=%s", h.frontendURL, resetToken)
+	err = h.authRepo.CreatePasswordResetToken(ctx, tokenID, user.ID, expiresAt, func(tx *sql.Tx) error {
+		_, err := h.outbox.Enqueue(ctx, tx, email.Outgoing{
+			To:       user.Email,
+			Template: email.TemplatePasswordReset,
+			Data:     email.AccountData{Name: userName, URL: resetURL},
+		})
+		return err
+	})
+	if err != nil {
+		h.logger.Error("Failed to store password reset token",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err),
+		)
+		return
+	}
+
+	h.logger.Info("Password reset email queued",
+		zap.String("user_id", user.ID.String()),
+	)
+}
+
+// ResetPassword handles POST /api/auth/reset-password
+func (h *AuthHandler) ResetPassword(c echo.Context) error {
+	var req ResetPasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.Token == "" || req.Password == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Token and password are required",
+		})
+	}
+
+	if len(req.Password) < 8 {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Password must be at least 8 characters long",
+		})
+	}
+
+	invalidToken := map[string]string{
+		"error": "Invalid or expired reset link",
+	}
+
+	claims, err := h.tokenService.VerifyToken(req.Token, jwt.PurposeResetPassword)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, invalidToken)
+	}
+
+	tokenID, err := claims.GetTokenID()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, invalidToken)
+	}
+
+	userID, err := claims.GetUserID()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, invalidToken)
+	}
+
+	if err := h.authRepo.ResetPassword(c.Request().Context(), tokenID, userID, req.Password); err != nil {
+		if errors.Is(err, auth.ErrResetTokenInvalid) || err.Error() == "user not found" {
+			return c.JSON(http.StatusBadRequest, invalidToken)
+		}
+		h.logger.Error("Failed to reset password",
+			zap.String("user_id", userID.String()),
+			zap.Error(err),
+		)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to reset password",
+		})
+	}
+
+	h.revoker.Forget(c.Request().Context(), userID)
+
+	h.logger.Info("Password reset successfully",
+		zap.String("user_id", userID.String()),
+	)
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Password has been reset. Please log in with your new password.",
+	})
+}
+
 // GoogleOAuthCallback handles Google OAuth callback
 func (h *AuthHandler) GoogleOAuthCallback(c echo.Context) error {
 	code := c.QueryParam("code")
@@ -337,7 +781,8 @@ func (h *AuthHandler) GoogleOAuthCallback(c echo.Context) error {
 	}
 
 	// Verify state parameter
-	if !h.verifyOAuthState(state) {
+	pending, validState := h.verifyOAuthState(state)
+	if !validState {
 		h.logger.Warn("Invalid OAuth state parameter",
 			zap.String("state", state),
 		)
@@ -360,57 +805,14 @@ func (h *AuthHandler) GoogleOAuthCallback(c echo.Context) error {
 			fmt.Sprintf("%s/login?error=email_not_verified", h.frontendURL))
 	}
 
-	// Try to find existing user by Google ID
-	user, err := h.authRepo.GetUserByGoogleID(c.Request().Context(), userInfo.ID)
-	if err != nil {
-		// User doesn't exist with this Google ID, try by email
-		user, err = h.authRepo.GetUserByEmail(c.Request().Context(), userInfo.Email)
-		if err != nil {
-			// User doesn't exist at all, create new user
-			user, err = h.authRepo.CreateUser(c.Request().Context(), auth.CreateUserInput{
-				Email:    userInfo.Email,
-				Name:     &userInfo.Name,
-				GoogleID: &userInfo.ID,
-			})
-			if err != nil {
-				h.logger.Error("Failed to create user from Google OAuth",
-					zap.String("email", userInfo.Email),
-					zap.Error(err),
-				)
-				return c.Redirect(http.StatusTemporaryRedirect,
-					fmt.Sprintf("%s/login?error=user_creation_failed", h.frontendURL))
-			}
-
-			h.logger.Info("New user created via Google OAuth",
-				zap.String("user_id", user.ID.String()),
-				zap.String("email", user.Email),
-			)
-		} else {
-			// User exists by email but not linked to Google, link it
-			if err := h.authRepo.LinkGoogleID(c.Request().Context(), user.ID, userInfo.ID); err != nil {
-				h.logger.Error("Failed to link Google ID",
-					zap.String("user_id", user.ID.String()),
-					zap.Error(err),
-				)
-				// Continue anyway
-			}
-
-			// Ensure user is verified since Google email is verified
-			if !user.IsVerified {
-				if err := h.authRepo.SetVerified(c.Request().Context(), user.ID, true); err != nil {
-					h.logger.Error("Failed to verify user",
-						zap.String("user_id", user.ID.String()),
-						zap.Error(err),
-					)
-				}
-				user.IsVerified = true
-			}
+	if pending.linkUserID != uuid.Nil {
+		return h.linkGoogleFromAccount(c, pending.linkUserID, userInfo)
+	}
 
-			h.logger.Info("Existing user logged in via Google OAuth",
-				zap.String("user_id", user.ID.String()),
-				zap.String("email", user.Email),
-			)
-		}
+	user, errCode := h.googleUser(c.Request().Context(), userInfo)
+	if user == nil {
+		return c.Redirect(http.StatusTemporaryRedirect,
+			fmt.Sprintf("%s/login?error=%s", h.frontendURL, errCode))
 	}
 
 	// Generate tokens
@@ -424,48 +826,144 @@ func (h *AuthHandler) GoogleOAuthCallback(c echo.Context) error {
 			fmt.Sprintf("%s/login?error=token_generation_failed", h.frontendURL))
 	}
 
-	// Generate refresh token (but we won't use it in redirect)
-	_, _, err = h.tokenService.GenerateRefreshToken(user.ID, user.Email)
+	refreshToken, err := h.issueRefreshToken(c.Request().Context(), user)
 	if err != nil {
 		h.logger.Error("Failed to generate refresh token",
 			zap.String("user_id", user.ID.String()),
 			zap.Error(err),
 		)
-		// Continue anyway
+		// Continue without refresh token
+		refreshToken = ""
 	}
 
+	h.mergeGuestCart(c, pending.cartToken, user.ID)
+
 	userName := ""
 	if user.Name != nil {
 		userName = *user.Name
 	}
 
-	userEmail := user.Email
-	userID := user.ID.String()
-	userRole := string(user.Role)
-
 	expiresIn := int64(time.Until(expiresAt).Seconds())
 
-	// Redirect to frontend callback with tokens
-	redirectURL := fmt.Sprintf(
-		"%s/auth/callback/google?access_token=%s&user_id=%s&user_name=%s&user_email=%s&user_role=%s&expires_in=%d",
-		h.frontendURL,
-		accessToken,
-		url.QueryEscape(userID),
-		url.QueryEscape(userName),
-		url.QueryEscape(userEmail),
-		url.QueryEscape(userRole),
-		expiresIn,
-	)
+	// Redirect to frontend callback with tokens. They go in the fragment,
+	// which browsers never send to servers or in the Referer header, so
+	// they stay out of access logs.
+	fragment := url.Values{}
+	fragment.Set("access_token", accessToken)
+	if refreshToken != "" {
+		fragment.Set("refresh_token", refreshToken)
+	}
+	fragment.Set("user_id", user.ID.String())
+	fragment.Set("user_name", userName)
+	fragment.Set("user_email", user.Email)
+	fragment.Set("user_role", string(user.Role))
+	fragment.Set("expires_in", strconv.FormatInt(expiresIn, 10))
+
+	redirectURL := fmt.Sprintf("%s/auth/callback/google#%s", h.frontendURL, fragment.Encode())
 
 	return c.Redirect(http.StatusTemporaryRedirect, redirectURL)
 }
 
+// googleUser finds or creates the user logging in with Google. A user found
+// by email gets the Google account linked, unless they unlinked it before.
+// When it returns nil, the string is the error code for the login page.
+func (h *AuthHandler) googleUser(ctx context.Context, userInfo *oauth.GoogleUserInfo) (*auth.User, string) {
+	// Try to find existing user by Google ID
+	user, err := h.authRepo.GetUserByGoogleID(ctx, userInfo.ID)
+	if err == nil {
+		return user, ""
+	}
+
+	// User doesn't exist with this Google ID, try by email
+	user, err = h.authRepo.GetUserByEmail(ctx, userInfo.Email)
+	if err != nil {
+		// User doesn't exist at all, create new user
+		user, err = h.authRepo.CreateUser(ctx, auth.CreateUserInput{
+			Email:    userInfo.Email,
+			Name:     &userInfo.Name,
+			GoogleID: &userInfo.ID,
+		})
+		if err != nil {
+			h.logger.Error("Failed to create user from Google OAuth",
+				zap.String("email", userInfo.Email),
+				zap.Error(err),
+			)
+			return nil, "user_creation_failed"
+		}
+
+		h.logger.Info("New user created via Google OAuth",
+			zap.String("user_id", user.ID.String()),
+			zap.String("email", user.Email),
+		)
+		return user, ""
+	}
+
+	// User exists by email but not linked to Google, link it
+	if err := h.authRepo.LinkGoogleID(ctx, user.ID, userInfo.ID); err != nil {
+		if errors.Is(err, auth.ErrGoogleLinkDisabled) {
+			// The user unlinked Google; only they can link it again
+			h.logger.Info("Google login refused for account that unlinked Google",
+				zap.String("user_id", user.ID.String()),
+			)
+			return nil, "google_not_linked"
+		}
+		h.logger.Error("Failed to link Google ID",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err),
+		)
+		// Continue anyway
+	}
+
+	// Ensure user is verified since Google email is verified
+	if !user.IsVerified {
+		if err := h.authRepo.SetVerified(ctx, user.ID, true); err != nil {
+			h.logger.Error("Failed to verify user",
+				zap.String("user_id", user.ID.String()),
+				zap.Error(err),
+			)
+		}
+		user.IsVerified = true
+	}
+
+	h.logger.Info("Existing user logged in via Google OAuth",
+		zap.String("user_id", user.ID.String()),
+		zap.String("email", user.Email),
+	)
+
+	return user, ""
+}
+
+// linkGoogleFromAccount finishes linking Google from the account page and
+// redirects back to it
+func (h *AuthHandler) linkGoogleFromAccount(c echo.Context, userID uuid.UUID, userInfo *oauth.GoogleUserInfo) error {
+	if err := h.authRepo.LinkGoogleIDFromAccount(c.Request().Context(), userID, userInfo.ID); err != nil {
+		errCode := "google_link_failed"
+		if errors.Is(err, auth.ErrGoogleAccountInUse) {
+			errCode = "google_account_in_use"
+		} else {
+			h.logger.Error("Failed to link Google account",
+				zap.String("user_id", userID.String()),
+				zap.Error(err),
+			)
+		}
+		return c.Redirect(http.StatusTemporaryRedirect,
+			fmt.Sprintf("%s/profile?error=%s", h.frontendURL, errCode))
+	}
+
+	h.logger.Info("Google account linked",
+		zap.String("user_id", userID.String()),
+	)
+
+	return c.Redirect(http.StatusTemporaryRedirect,
+		fmt.Sprintf("%s/profile?linked=google", h.frontendURL))
+}
+
 // GetGoogleAuthURL returns the Google OAuth authorization URL
 func (h *AuthHandler) GetGoogleAuthURL(c echo.Context) error {
 	state := h.generateSecureState()
 
 	// Store state for verification (Production: use Redis with expiry)
-	h.storeOAuthState(state)
+	h.storeOAuthState(state, oauthState{cartToken: c.QueryParam("cart_token")})
 
 	authURL := h.oauthService.GetAuthURL(state)
 
@@ -488,38 +986,70 @@ func (h *AuthHandler) generateSecureState() string {
 
 // storeOAuthState stores state token for verification
 // Production: Replace with Redis/database with TTL
-func (h *AuthHandler) storeOAuthState(state string) {
-	h.oauthStates[state] = time.Now().Add(10 * time.Minute)
+func (h *AuthHandler) storeOAuthState(state string, pending oauthState) {
+	pending.expiry = time.Now().Add(10 * time.Minute)
+	h.oauthStates[state] = pending
 
 	// Clean up expired states
 	go h.cleanExpiredStates()
 }
 
-// verifyOAuthState verifies the state parameter
+// verifyOAuthState verifies the state parameter and returns the pending
+// login stored with it
 // Production: Check Redis/database
-func (h *AuthHandler) verifyOAuthState(state string) bool {
-	expiry, exists := h.oauthStates[state]
+func (h *AuthHandler) verifyOAuthState(state string) (oauthState, bool) {
+	stored, exists := h.oauthStates[state]
 	if !exists {
-		return false
+		return oauthState{}, false
 	}
 
-	if time.Now().After(expiry) {
+	if time.Now().After(stored.expiry) {
 		delete(h.oauthStates, state)
-		return false
+		return oauthState{}, false
 	}
 
 	// Remove state after verification (single use)
 	delete(h.oauthStates, state)
-	return true
+	return stored, true
 }
 
 // cleanExpiredStates removes expired state tokens
 // Production: Not needed if using Redis with TTL
 func (h *AuthHandler) cleanExpiredStates() {
 	now := time.Now()
-	for state, expiry := range h.oauthStates {
-		if now.After(expiry) {
+	for state, stored := range h.oauthStates {
+		if now.After(stored.expiry) {
 			delete(h.oauthStates, state)
 		}
 	}
 }
+
+// issueRefreshToken starts a new refresh token family for a fresh login
+func (h *AuthHandler) issueRefreshToken(ctx context.Context, user *auth.User) (string, error) {
+	tokenID := uuid.New()
+	refreshToken, expiresAt, err := h.tokenService.GenerateRefreshToken(user.ID, user.Email, tokenID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := h.authRepo.CreateRefreshToken(ctx, tokenID, user.ID, uuid.New(), expiresAt); err != nil {
+		return "", err
+	}
+
+	return refreshToken, nil
+}
+
+// mergeGuestCart moves a guest cart into the user's cart after login. Failure
+// only loses the guest cart, so it never blocks the login itself.
+func (h *AuthHandler) mergeGuestCart(c echo.Context, cartToken string, userID uuid.UUID) {
+	if cartToken == "" || h.cartRepo == nil {
+		return
+	}
+
+	if err := h.cartRepo.MergeGuestCart(c.Request().Context(), cartToken, userID); err != nil {
+		h.logger.Warn("Failed to merge guest cart",
+			zap.String("user_id", userID.String()),
+			zap.Error(err),
+		)
+	}
+}
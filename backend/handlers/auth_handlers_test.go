@@ -12,6 +12,8 @@ import (
 
 	"github.com/labstack/echo/v4"
 	"github.com/ramniya/ramniya-backend/auth"
+	"github.com/ramniya/ramniya-backend/cache"
+	"github.com/ramniya/ramniya-backend/cart"
 	"github.com/ramniya/ramniya-backend/database"
 	"github.com/ramniya/ramniya-backend/email"
 	"github.com/ramniya/ramniya-backend/jwt"
@@ -39,18 +41,22 @@ func setupTestHandler(t *testing.T) (*AuthHandler, *auth.AuthRepository, func())
 	// Create repositories and services
 	authRepo := auth.NewAuthRepository(database.DB)
 	tokenService := jwt.NewTokenService("test-secret", 7*24*time.Hour, 30*24*time.Hour)
-	emailSender, _ := email.NewFileEmailSender("/tmp/test-emails", testLogger)
+	outbox := email.NewOutboxRepository(database.DB)
 	oauthService := oauth.NewGoogleOAuthService(oauth.GoogleOAuthConfig{
 		ClientID:     "test-client-id",
 		ClientSecret: "test-client-secret",
 		RedirectURL:  "http://localhost:8080/api/auth/oauth/google/callback",
 	})
 
+	redisClient, _ := cache.NewRedisClient("", testLogger)
+
 	handler := NewAuthHandler(
 		authRepo,
 		tokenService,
-		emailSender,
+		outbox,
 		oauthService,
+		cart.NewCartRepository(database.DB),
+		auth.NewTokenRevoker(authRepo, redisClient, testLogger),
 		testLogger,
 		"http://localhost:8080",
 		"http://localhost:3000",
@@ -336,3 +342,146 @@ func TestRegisterValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestChangeEmail(t *testing.T) {
+	handler, authRepo, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	testEmail := "test-change-email@example.com"
+	newEmail := "test-change-email-new@example.com"
+	testPassword := "testpass123"
+	defer cleanupTestUser(t, authRepo, testEmail)
+	defer cleanupTestUser(t, authRepo, newEmail)
+
+	ctx := context.Background()
+	user, err := authRepo.CreateUser(ctx, auth.CreateUserInput{Email: testEmail, Password: &testPassword})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	e := echo.New()
+	newContext := func(method, body string) (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(method, "/api/me/email", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.Set("user_id", user.ID.String())
+		return c, rec
+	}
+
+	t.Run("Wrong Password", func(t *testing.T) {
+		c, rec := newContext(http.MethodPost, `{"email":"`+newEmail+`","password":"wrongpass123"}`)
+		if assert.NoError(t, handler.ChangeEmail(c)) {
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+		}
+	})
+
+	t.Run("Request Change", func(t *testing.T) {
+		c, rec := newContext(http.MethodPost, `{"email":"`+newEmail+`","password":"`+testPassword+`"}`)
+		if assert.NoError(t, handler.ChangeEmail(c)) {
+			assert.Equal(t, http.StatusAccepted, rec.Code)
+		}
+
+		c, rec = newContext(http.MethodGet, "")
+		if assert.NoError(t, handler.GetProfile(c)) {
+			var profile ProfileResponse
+			json.Unmarshal(rec.Body.Bytes(), &profile)
+
+			assert.Equal(t, testEmail, profile.Email)
+			assert.Equal(t, newEmail, profile.PendingEmail)
+			assert.Equal(t, LoginMethods{Password: true}, profile.LoginMethods)
+		}
+	})
+
+	tokenService := jwt.NewTokenService("test-secret", 7*24*time.Hour, 30*24*time.Hour)
+	verificationToken, err := tokenService.GenerateEmailVerificationToken(user.ID, newEmail)
+	assert.NoError(t, err)
+
+	t.Run("Confirm Change", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/auth/verify?token="+verificationToken, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		if assert.NoError(t, handler.VerifyEmail(c)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+
+		changed, err := authRepo.GetUserByID(ctx, user.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, newEmail, changed.Email)
+		assert.Nil(t, changed.PendingEmail)
+	})
+}
+
+func TestUnlinkGoogleThenOAuthLogin(t *testing.T) {
+	handler, authRepo, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	testEmail := "test-unlink-google@example.com"
+	testPassword := "testpass123"
+	googleID := "google-unlink-test-id"
+	defer cleanupTestUser(t, authRepo, testEmail)
+
+	ctx := context.Background()
+	user, err := authRepo.CreateUser(ctx, auth.CreateUserInput{
+		Email:    testEmail,
+		Password: &testPassword,
+		GoogleID: &googleID,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	userInfo := &oauth.GoogleUserInfo{ID: googleID, Email: testEmail, VerifiedEmail: true}
+
+	e := echo.New()
+	newContext := func(method, target string) (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(method, target, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.Set("user_id", user.ID.String())
+		return c, rec
+	}
+
+	t.Run("Unlink", func(t *testing.T) {
+		c, rec := newContext(http.MethodDelete, "/api/me/logins/google")
+		if assert.NoError(t, handler.UnlinkGoogle(c)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+	})
+
+	t.Run("OAuth Login Does Not Relink", func(t *testing.T) {
+		loggedIn, errCode := handler.googleUser(ctx, userInfo)
+		assert.Nil(t, loggedIn)
+		assert.Equal(t, "google_not_linked", errCode)
+
+		unlinked, err := authRepo.GetUserByID(ctx, user.ID)
+		assert.NoError(t, err)
+		assert.Nil(t, unlinked.GoogleID)
+	})
+
+	t.Run("Link From Account Page", func(t *testing.T) {
+		c, rec := newContext(http.MethodGet, "/api/me/logins/google/link")
+		if !assert.NoError(t, handler.LinkGoogle(c)) {
+			return
+		}
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response map[string]string
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		pending, ok := handler.verifyOAuthState(response["state"])
+		assert.True(t, ok)
+		assert.Equal(t, user.ID, pending.linkUserID)
+
+		c, rec = newContext(http.MethodGet, "/api/auth/oauth/google/callback")
+		if assert.NoError(t, handler.linkGoogleFromAccount(c, pending.linkUserID, userInfo)) {
+			assert.Equal(t, http.StatusTemporaryRedirect, rec.Code)
+			assert.Equal(t, "http://localhost:3000/profile?linked=google", rec.Header().Get(echo.HeaderLocation))
+		}
+
+		loggedIn, _ := handler.googleUser(ctx, userInfo)
+		if assert.NotNil(t, loggedIn) {
+			assert.Equal(t, user.ID, loggedIn.ID)
+		}
+	})
+}
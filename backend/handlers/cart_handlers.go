@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/ramniya/ramniya-backend/cart"
+	"github.com/ramniya/ramniya-backend/products"
+	"go.uber.org/zap"
+)
+
+// CartTokenHeader carries the anonymous guest cart token
+const CartTokenHeader = "X-Cart-Token"
+
+// CartHandler handles shopping cart endpoints
+type CartHandler struct {
+	cartRepo    *cart.CartRepository
+	productRepo *products.ProductRepository
+	logger      *zap.Logger
+	baseURL     string
+}
+
+// NewCartHandler creates a new cart handler
+func NewCartHandler(
+	cartRepo *cart.CartRepository,
+	productRepo *products.ProductRepository,
+	logger *zap.Logger,
+	baseURL string,
+) *CartHandler {
+	return &CartHandler{
+		cartRepo:    cartRepo,
+		productRepo: productRepo,
+		logger:      logger,
+		baseURL:     baseURL,
+	}
+}
+
+// AddCartItemRequest represents a request to add an item to the cart
+type AddCartItemRequest struct {
+	ProductID uuid.UUID  `json:"product_id"`
+	VariantID *uuid.UUID `json:"variant_id,omitempty"`
+	Quantity  int        `json:"quantity"`
+}
+
+// UpdateCartItemRequest represents a cart line quantity update
+type UpdateCartItemRequest struct {
+	Quantity int `json:"quantity"`
+}
+
+// GetCart handles GET /api/cart
+func (h *CartHandler) GetCart(c echo.Context) error {
+	userCart, err := h.resolveCart(c, false)
+	if err != nil {
+		return h.cartError(c, err)
+	}
+
+	if userCart == nil {
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"cart": cart.PricedCart{
+				Items:    []cart.PricedLine{},
+				Currency: "INR",
+			},
+		})
+	}
+
+	return h.respondWithCart(c, http.StatusOK, userCart)
+}
+
+// AddItem handles POST /api/cart/items
+func (h *CartHandler) AddItem(c echo.Context) error {
+	var req AddCartItemRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.ProductID == uuid.Nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "product_id is required",
+		})
+	}
+
+	if req.Quantity <= 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Quantity must be greater than 0",
+		})
+	}
+
+	ctx := c.Request().Context()
+
+	variantID := uuid.Nil
+	if req.VariantID != nil {
+		variantID = *req.VariantID
+	}
+
+	priced, err := h.productRepo.GetPricedItem(ctx, req.ProductID, variantID, h.baseURL)
+	if err != nil {
+		switch {
+		case errors.Is(err, products.ErrProductNotFound):
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Product not found"})
+		case errors.Is(err, products.ErrVariantNotFound):
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Variant not found"})
+		case errors.Is(err, products.ErrVariantMismatch):
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Variant does not belong to product"})
+		case errors.Is(err, products.ErrVariantRequired):
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "variant_id is required for this product"})
+		}
+		h.logger.Error("Failed to price cart item",
+			zap.String("product_id", req.ProductID.String()),
+			zap.Error(err),
+		)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to add item",
+		})
+	}
+
+	userCart, err := h.resolveCart(c, true)
+	if err != nil {
+		return h.cartError(c, err)
+	}
+
+	// Check stock against the combined quantity already in the cart
+	if priced.HasVariant {
+		inCart := 0
+		for _, item := range userCart.Items {
+			if item.ProductID == req.ProductID && item.VariantID != nil && *item.VariantID == variantID {
+				inCart = item.Quantity
+			}
+		}
+		if inCart+req.Quantity > priced.Stock {
+			return c.JSON(http.StatusConflict, map[string]interface{}{
+				"error":           fmt.Sprintf("Only %d left in stock", priced.Stock),
+				"available_stock": priced.Stock,
+				"in_cart":         inCart,
+			})
+		}
+	} else {
+		req.VariantID = nil
+	}
+
+	if _, err := h.cartRepo.AddItem(ctx, userCart.ID, req.ProductID, req.VariantID, req.Quantity, priced.UnitPriceCents); err != nil {
+		h.logger.Error("Failed to add cart item",
+			zap.String("cart_id", userCart.ID.String()),
+			zap.Error(err),
+		)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to add item",
+		})
+	}
+
+	return h.reloadAndRespond(c, userCart, http.StatusCreated)
+}
+
+// UpdateItem handles PUT /api/cart/items/:item_id
+func (h *CartHandler) UpdateItem(c echo.Context) error {
+	itemID, err := uuid.Parse(c.Param("item_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid item ID",
+		})
+	}
+
+	var req UpdateCartItemRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.Quantity <= 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Quantity must be greater than 0",
+		})
+	}
+
+	userCart, err := h.resolveCart(c, false)
+	if err != nil {
+		return h.cartError(c, err)
+	}
+	if userCart == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "Cart item not found",
+		})
+	}
+
+	ctx := c.Request().Context()
+
+	item, err := h.cartRepo.GetItem(ctx, userCart.ID, itemID)
+	if err != nil {
+		return h.cartError(c, err)
+	}
+
+	if item.VariantID != nil {
+		priced, err := h.productRepo.GetPricedItem(ctx, item.ProductID, *item.VariantID, h.baseURL)
+		if err == nil && req.Quantity > priced.Stock {
+			return c.JSON(http.StatusConflict, map[string]interface{}{
+				"error":           fmt.Sprintf("Only %d left in stock", priced.Stock),
+				"available_stock": priced.Stock,
+			})
+		}
+	}
+
+	if err := h.cartRepo.UpdateItemQuantity(ctx, userCart.ID, itemID, req.Quantity); err != nil {
+		return h.cartError(c, err)
+	}
+
+	return h.reloadAndRespond(c, userCart, http.StatusOK)
+}
+
+// RemoveItem handles DELETE /api/cart/items/:item_id
+func (h *CartHandler) RemoveItem(c echo.Context) error {
+	itemID, err := uuid.Parse(c.Param("item_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid item ID",
+		})
+	}
+
+	userCart, err := h.resolveCart(c, false)
+	if err != nil {
+		return h.cartError(c, err)
+	}
+	if userCart == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "Cart item not found",
+		})
+	}
+
+	if err := h.cartRepo.RemoveItem(c.Request().Context(), userCart.ID, itemID); err != nil {
+		return h.cartError(c, err)
+	}
+
+	return h.reloadAndRespond(c, userCart, http.StatusOK)
+}
+
+// ClearCart handles DELETE /api/cart
+func (h *CartHandler) ClearCart(c echo.Context) error {
+	userCart, err := h.resolveCart(c, false)
+	if err != nil {
+		return h.cartError(c, err)
+	}
+
+	if userCart != nil {
+		if err := h.cartRepo.Clear(c.Request().Context(), userCart.ID); err != nil {
+			return h.cartError(c, err)
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Cart cleared",
+	})
+}
+
+// resolveCart finds the cart for the authenticated user or the guest token.
+// When create is true a guest cart is created if none exists; otherwise a
+// nil cart is returned for guests without a valid token.
+func (h *CartHandler) resolveCart(c echo.Context, create bool) (*cart.Cart, error) {
+	ctx := c.Request().Context()
+
+	if userIDStr, ok := c.Get("user_id").(string); ok {
+		userID, err := uuid.Parse(userIDStr)
+		if err != nil {
+			return nil, err
+		}
+		return h.cartRepo.GetOrCreateUserCart(ctx, userID)
+	}
+
+	if token := c.Request().Header.Get(CartTokenHeader); token != "" {
+		guestCart, err := h.cartRepo.GetCartByToken(ctx, token)
+		if err == nil {
+			return guestCart, nil
+		}
+		if err.Error() != "cart not found" {
+			return nil, err
+		}
+	}
+
+	if !create {
+		return nil, nil
+	}
+
+	return h.cartRepo.CreateGuestCart(ctx)
+}
+
+func (h *CartHandler) reloadAndRespond(c echo.Context, userCart *cart.Cart, status int) error {
+	ctx := c.Request().Context()
+
+	var reloaded *cart.Cart
+	var err error
+	if userCart.UserID != nil {
+		reloaded, err = h.cartRepo.GetOrCreateUserCart(ctx, *userCart.UserID)
+	} else {
+		reloaded, err = h.cartRepo.GetCartByToken(ctx, *userCart.Token)
+	}
+	if err != nil {
+		return h.cartError(c, err)
+	}
+
+	return h.respondWithCart(c, status, reloaded)
+}
+
+func (h *CartHandler) respondWithCart(c echo.Context, status int, userCart *cart.Cart) error {
+	priced, err := cart.PriceCart(c.Request().Context(), h.productRepo, h.baseURL, userCart)
+	if err != nil {
+		return h.cartError(c, err)
+	}
+
+	// Guests must keep the token to access the cart again
+	if userCart.UserID == nil && userCart.Token != nil {
+		c.Response().Header().Set(CartTokenHeader, *userCart.Token)
+		return c.JSON(status, map[string]interface{}{
+			"cart":       priced,
+			"cart_token": *userCart.Token,
+		})
+	}
+
+	return c.JSON(status, map[string]interface{}{
+		"cart": priced,
+	})
+}
+
+func (h *CartHandler) cartError(c echo.Context, err error) error {
+	if err.Error() == "cart item not found" {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "Cart item not found",
+		})
+	}
+
+	h.logger.Error("Cart operation failed", zap.Error(err))
+	return c.JSON(http.StatusInternalServerError, map[string]string{
+		"error": "Failed to process cart",
+	})
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/ramniya/ramniya-backend/invoices"
+	"github.com/ramniya/ramniya-backend/orders"
+	"go.uber.org/zap"
+)
+
+// InvoiceHandler serves order invoices and credit notes
+type InvoiceHandler struct {
+	orderRepo   *orders.OrderRepository
+	invoiceRepo *invoices.InvoiceRepository
+	generator   *invoices.Generator
+	logger      *zap.Logger
+}
+
+// NewInvoiceHandler creates a new invoice handler
+func NewInvoiceHandler(orderRepo *orders.OrderRepository, invoiceRepo *invoices.InvoiceRepository, generator *invoices.Generator, logger *zap.Logger) *InvoiceHandler {
+	return &InvoiceHandler{
+		orderRepo:   orderRepo,
+		invoiceRepo: invoiceRepo,
+		generator:   generator,
+		logger:      logger,
+	}
+}
+
+// GetOrderInvoice handles GET /api/orders/:id/invoice
+func (h *InvoiceHandler) GetOrderInvoice(c echo.Context) error {
+	userIDStr, ok := c.Get("user_id").(string)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "User not authenticated",
+		})
+	}
+
+	order, err := h.loadOrder(c)
+	if err != nil {
+		return err
+	}
+	if order == nil {
+		return nil
+	}
+
+	// Verify user owns the order
+	if order.UserID.String() != userIDStr {
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": "Access denied",
+		})
+	}
+
+	return h.sendOrderInvoice(c, order)
+}
+
+// GetOrderInvoiceAdmin handles GET /api/admin/orders/:id/invoice
+func (h *InvoiceHandler) GetOrderInvoiceAdmin(c echo.Context) error {
+	order, err := h.loadOrder(c)
+	if err != nil {
+		return err
+	}
+	if order == nil {
+		return nil
+	}
+
+	return h.sendOrderInvoice(c, order)
+}
+
+// ListOrderInvoicesAdmin handles GET /api/admin/orders/:id/invoices. Returns
+// the order's invoice and credit notes.
+func (h *InvoiceHandler) ListOrderInvoicesAdmin(c echo.Context) error {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid order ID",
+		})
+	}
+
+	documents, err := h.invoiceRepo.ListOrderInvoices(c.Request().Context(), orderID)
+	if err != nil {
+		h.logger.Error("Failed to list invoices", zap.String("order_id", orderID.String()), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to list invoices",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"invoices": documents,
+	})
+}
+
+// GetInvoicePDFAdmin handles GET /api/admin/orders/:id/invoices/:invoiceId,
+// which downloads an invoice or credit note of the order
+func (h *InvoiceHandler) GetInvoicePDFAdmin(c echo.Context) error {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid order ID",
+		})
+	}
+	invoiceID, err := uuid.Parse(c.Param("invoiceId"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid invoice ID",
+		})
+	}
+
+	invoice, err := h.invoiceRepo.GetInvoice(c.Request().Context(), invoiceID)
+	if err != nil {
+		if errors.Is(err, invoices.ErrInvoiceNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "Invoice not found",
+			})
+		}
+		h.logger.Error("Failed to get invoice", zap.String("invoice_id", invoiceID.String()), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to get invoice",
+		})
+	}
+	if invoice.OrderID != orderID {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "Invoice not found",
+		})
+	}
+
+	return h.sendPDF(c, invoice)
+}
+
+// loadOrder fetches the order named in the path. On failure it writes the
+// response and returns a nil order.
+func (h *InvoiceHandler) loadOrder(c echo.Context) (*orders.Order, error) {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil, c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid order ID",
+		})
+	}
+
+	order, err := h.orderRepo.GetOrder(c.Request().Context(), orderID)
+	if err != nil {
+		if err.Error() == "order not found" {
+			return nil, c.JSON(http.StatusNotFound, map[string]string{
+				"error": "Order not found",
+			})
+		}
+		h.logger.Error("Failed to get order", zap.String("order_id", orderID.String()), zap.Error(err))
+		return nil, c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to get order",
+		})
+	}
+
+	return order, nil
+}
+
+// sendOrderInvoice sends the order's invoice, issuing it now if the
+// background job has not got to it yet
+func (h *InvoiceHandler) sendOrderInvoice(c echo.Context, order *orders.Order) error {
+	invoice, err := h.generator.InvoiceForOrder(c.Request().Context(), order)
+	if err != nil {
+		if errors.Is(err, invoices.ErrNotInvoiceable) {
+			return c.JSON(http.StatusConflict, map[string]string{
+				"error": "Invoice is available once the order is paid",
+			})
+		}
+		h.logger.Error("Failed to issue invoice", zap.String("order_id", order.ID.String()), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to get invoice",
+		})
+	}
+
+	return h.sendPDF(c, invoice)
+}
+
+// sendPDF sends a stored invoice PDF as a download
+func (h *InvoiceHandler) sendPDF(c echo.Context, invoice *invoices.Invoice) error {
+	pdf, err := h.generator.PDF(invoice)
+	if err != nil {
+		h.logger.Error("Failed to read invoice PDF",
+			zap.String("invoice_id", invoice.ID.String()),
+			zap.String("path", invoice.FilePath),
+			zap.Error(err),
+		)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to get invoice",
+		})
+	}
+
+	filename := strings.ReplaceAll(invoice.Number, "/", "-") + ".pdf"
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	return c.Blob(http.StatusOK, "application/pdf", pdf)
+}
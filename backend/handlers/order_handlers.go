@@ -1,55 +1,111 @@
 package handlers
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"github.com/ramniya/ramniya-backend/cart"
 	"github.com/ramniya/ramniya-backend/orders"
-	"github.com/ramniya/ramniya-backend/razorpay"
+	"github.com/ramniya/ramniya-backend/payments"
+	"github.com/ramniya/ramniya-backend/products"
+	"github.com/ramniya/ramniya-backend/promotions"
+	"github.com/ramniya/ramniya-backend/shipping"
+	"github.com/ramniya/ramniya-backend/tax"
 	"go.uber.org/zap"
 )
 
 // OrderHandler handles order-related endpoints
 type OrderHandler struct {
-	orderRepo       *orders.OrderRepository
-	razorpayService *razorpay.RazorpayService
-	logger          *zap.Logger
-	razorpayKeyID   string
+	orderRepo   *orders.OrderRepository
+	productRepo *products.ProductRepository
+	cartRepo    *cart.CartRepository
+	couponRepo  *promotions.CouponRepository
+	gateways    *payments.Registry
+	cod         *payments.CODRules
+	taxCalc     *tax.Calculator
+	shipping    *shipping.Calculator
+	webhooks    *payments.WebhookProcessor
+	logger      *zap.Logger
+	baseURL     string
 }
 
 // NewOrderHandler creates a new order handler
 func NewOrderHandler(
 	orderRepo *orders.OrderRepository,
-	razorpayService *razorpay.RazorpayService,
+	productRepo *products.ProductRepository,
+	cartRepo *cart.CartRepository,
+	couponRepo *promotions.CouponRepository,
+	gateways *payments.Registry,
+	cod *payments.CODRules,
+	taxCalc *tax.Calculator,
+	shippingCalc *shipping.Calculator,
+	webhooks *payments.WebhookProcessor,
 	logger *zap.Logger,
-	razorpayKeyID string,
+	baseURL string,
 ) *OrderHandler {
 	return &OrderHandler{
-		orderRepo:       orderRepo,
-		razorpayService: razorpayService,
-		logger:          logger,
-		razorpayKeyID:   razorpayKeyID,
+		orderRepo:   orderRepo,
+		productRepo: productRepo,
+		cartRepo:    cartRepo,
+		couponRepo:  couponRepo,
+		gateways:    gateways,
+		cod:         cod,
+		taxCalc:     taxCalc,
+		shipping:    shippingCalc,
+		webhooks:    webhooks,
+		logger:      logger,
+		baseURL:     baseURL,
 	}
 }
 
-// CreateOrderRequest represents the checkout request
+// CheckoutItemRequest identifies a product/variant and quantity to purchase.
+// Any title or price sent by the client is ignored.
+type CheckoutItemRequest struct {
+	ProductID uuid.UUID `json:"product_id"`
+	VariantID uuid.UUID `json:"variant_id,omitempty"`
+	Quantity  int       `json:"quantity"`
+}
+
+// CreateOrderRequest represents the checkout request. PaymentMethod "cod"
+// places a cash on delivery order; anything else pays online. ShippingCents
+// is the shipping from the customer's quote: when set, the order is refused
+// if shipping has changed since.
 type CreateOrderRequest struct {
-	Items           []orders.OrderItem     `json:"items"`
+	Items           []CheckoutItemRequest  `json:"items"`
 	ShippingAddress orders.ShippingAddress `json:"shipping_address"`
 	PaymentMethod   string                 `json:"payment_method"`
+	CouponCode      string                 `json:"coupon_code,omitempty"`
+	ShippingCents   *int                   `json:"shipping_cents,omitempty"`
 }
 
-// CreateOrderResponse represents the checkout response
+// CreateOrderResponse represents the checkout response. ClientData holds what
+// the gateway's checkout needs; the Razorpay fields are kept for older clients.
+// Cash on delivery orders have no gateway fields.
 type CreateOrderResponse struct {
-	OrderID         string `json:"order_id"`
-	RazorpayOrderID string `json:"razorpay_order_id"`
-	Amount          int    `json:"amount"`
-	Currency        string `json:"currency"`
-	KeyID           string `json:"key_id"`
+	OrderID         string            `json:"order_id"`
+	Status          string            `json:"status"`
+	PaymentMethod   string            `json:"payment_method,omitempty"`
+	Gateway         string            `json:"gateway,omitempty"`
+	GatewayOrderID  string            `json:"gateway_order_id,omitempty"`
+	ClientData      map[string]string `json:"client_data,omitempty"`
+	RazorpayOrderID string            `json:"razorpay_order_id,omitempty"`
+	Amount          int               `json:"amount"`
+	DiscountCents   int               `json:"discount_cents,omitempty"`
+	TaxCents        int               `json:"tax_cents"`
+	ShippingCents   int               `json:"shipping_cents"`
+	CODFeeCents     int               `json:"cod_fee_cents,omitempty"`
+	Currency        string            `json:"currency"`
+	KeyID           string            `json:"key_id,omitempty"`
 }
 
 // CreateOrder handles POST /api/checkout/create-order
@@ -84,47 +140,174 @@ func (h *OrderHandler) CreateOrder(c echo.Context) error {
 		})
 	}
 
-	// Validate shipping address
-	if err := validateShippingAddress(req.ShippingAddress); err != nil {
+	return h.placeOrder(c, userID, checkoutRequest{
+		Items:           req.Items,
+		ShippingAddress: req.ShippingAddress,
+		PaymentMethod:   req.PaymentMethod,
+		CouponCode:      req.CouponCode,
+		ShippingCents:   req.ShippingCents,
+	}, nil)
+}
+
+// CreateOrderFromCartRequest represents checkout of the user's saved cart
+type CreateOrderFromCartRequest struct {
+	ShippingAddress orders.ShippingAddress `json:"shipping_address"`
+	PaymentMethod   string                 `json:"payment_method"`
+	CouponCode      string                 `json:"coupon_code,omitempty"`
+	ShippingCents   *int                   `json:"shipping_cents,omitempty"`
+}
+
+// CreateOrderFromCart handles POST /api/checkout/cart
+func (h *OrderHandler) CreateOrderFromCart(c echo.Context) error {
+	userIDStr, ok := c.Get("user_id").(string)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "User not authenticated",
+		})
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": fmt.Sprintf("Invalid shipping address: %s", err.Error()),
+			"error": "Invalid user ID",
 		})
 	}
 
-	// Calculate total amount
-	totalCents := 0
-	for _, item := range req.Items {
-		if item.Quantity <= 0 {
-			return c.JSON(http.StatusBadRequest, map[string]string{
-				"error": fmt.Sprintf("Invalid quantity for item: %s", item.Title),
-			})
+	var req CreateOrderFromCartRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request body",
+		})
+	}
+
+	userCart, err := h.cartRepo.GetOrCreateUserCart(c.Request().Context(), userID)
+	if err != nil {
+		h.logger.Error("Failed to load cart for checkout",
+			zap.String("user_id", userIDStr),
+			zap.Error(err),
+		)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to load cart",
+		})
+	}
+
+	if len(userCart.Items) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Cart is empty",
+		})
+	}
+
+	// The order now holds the items, so empty the cart once it is placed
+	return h.placeOrder(c, userID, checkoutRequest{
+		Items:           cartCheckoutItems(userCart),
+		ShippingAddress: req.ShippingAddress,
+		PaymentMethod:   req.PaymentMethod,
+		CouponCode:      req.CouponCode,
+		ShippingCents:   req.ShippingCents,
+	}, func(order *orders.Order) {
+		if err := h.cartRepo.Clear(c.Request().Context(), userCart.ID); err != nil {
+			h.logger.Warn("Failed to clear cart after checkout",
+				zap.String("cart_id", userCart.ID.String()),
+				zap.String("order_id", order.ID.String()),
+				zap.Error(err),
+			)
 		}
-		if item.PriceCents <= 0 {
-			return c.JSON(http.StatusBadRequest, map[string]string{
-				"error": fmt.Sprintf("Invalid price for item: %s", item.Title),
-			})
+	})
+}
+
+// cartCheckoutItems lists a saved cart's items for checkout
+func cartCheckoutItems(userCart *cart.Cart) []CheckoutItemRequest {
+	items := make([]CheckoutItemRequest, 0, len(userCart.Items))
+	for _, item := range userCart.Items {
+		reqItem := CheckoutItemRequest{
+			ProductID: item.ProductID,
+			Quantity:  item.Quantity,
+		}
+		if item.VariantID != nil {
+			reqItem.VariantID = *item.VariantID
 		}
-		totalCents += item.PriceCents * item.Quantity
+		items = append(items, reqItem)
 	}
+	return items
+}
 
-	if totalCents <= 0 {
+// checkoutRequest is what the customer is checking out
+type checkoutRequest struct {
+	Items           []CheckoutItemRequest
+	ShippingAddress orders.ShippingAddress
+	PaymentMethod   string
+	CouponCode      string
+	ShippingCents   *int // Shipping the customer was quoted, if any
+}
+
+// placeOrder validates the address, prices the checkout, creates the order
+// with its stock reservation and initializes payment, or confirms it for cash
+// on delivery. onPlaced runs after payment setup succeeds.
+func (h *OrderHandler) placeOrder(c echo.Context, userID uuid.UUID, req checkoutRequest, onPlaced func(order *orders.Order)) error {
+	// Validate shipping address
+	if err := validateShippingAddress(req.ShippingAddress); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid order total",
+			"error": fmt.Sprintf("Invalid shipping address: %s", err.Error()),
+		})
+	}
+
+	quote, err := h.quote(c.Request().Context(), req)
+	if err != nil {
+		return h.checkoutErrorResponse(c, userID, "Failed to create order", err)
+	}
+
+	// The customer must be charged the shipping they were shown
+	if req.ShippingCents != nil && *req.ShippingCents != quote.Shipping.ShippingCents {
+		return c.JSON(http.StatusConflict, map[string]interface{}{
+			"error":  "Shipping charges have changed, please review your order",
+			"reason": "shipping_changed",
+			"quote":  quote,
 		})
 	}
 
+	cod := strings.EqualFold(req.PaymentMethod, orders.PaymentMethodCOD)
+
 	// Create order in database
 	orderInput := orders.CreateOrderInput{
 		UserID:          userID,
-		Items:           req.Items,
+		Items:           quote.Items,
 		ShippingAddress: req.ShippingAddress,
-		AmountCents:     totalCents,
-		Currency:        "INR",
+		AmountCents:     quote.TotalCents,
+		Currency:        quote.Currency,
 		PaymentMethod:   req.PaymentMethod,
+		Discount:        quote.discount,
+		Tax:             quote.Tax,
+		ShippingCents:   quote.Shipping.ShippingCents,
+		ShippingZone:    quote.Shipping.Zone,
+		ShippingWeight:  quote.Shipping.WeightGrams,
+		CODFeeCents:     quote.CODFeeCents,
+	}
+
+	var gateway payments.Gateway
+	if cod {
+		orderInput.PaymentMethod = orders.PaymentMethodCOD
+		orderInput.PaymentGateway = orders.PaymentMethodCOD
+	} else {
+		gateway = h.gateways.Default()
+		orderInput.PaymentGateway = gateway.Name()
 	}
 
 	order, err := h.orderRepo.CreateOrder(c.Request().Context(), orderInput)
 	if err != nil {
+		var stockErr *orders.InsufficientStockError
+		if errors.As(err, &stockErr) {
+			return c.JSON(http.StatusConflict, map[string]interface{}{
+				"error": "Some items are out of stock",
+				"items": stockErr.Items,
+			})
+		}
+		var couponErr *promotions.CouponError
+		if errors.As(err, &couponErr) {
+			return c.JSON(http.StatusConflict, map[string]string{
+				"error":  couponErr.Message,
+				"reason": couponErr.Reason,
+			})
+		}
 		h.logger.Error("Failed to create order",
 			zap.String("user_id", userID.String()),
 			zap.Error(err),
@@ -134,22 +317,24 @@ func (h *OrderHandler) CreateOrder(c echo.Context) error {
 		})
 	}
 
-	// Create Razorpay order
-	razorpayReq := razorpay.CreateOrderRequest{
-		Amount:   totalCents, // Amount in paise
+	if cod {
+		return h.confirmCODOrder(c, order, onPlaced)
+	}
+
+	// Start the payment at the gateway
+	gatewayOrder, err := gateway.CreateOrder(payments.CreateOrderInput{
+		Amount:   order.AmountCents, // Amount in paise
 		Currency: "INR",
 		Receipt:  order.ID.String(),
 		Notes: map[string]string{
 			"order_id": order.ID.String(),
 			"user_id":  userID.String(),
 		},
-		PartialPayment: false,
-	}
-
-	razorpayOrder, err := h.razorpayService.CreateOrder(razorpayReq)
+	})
 	if err != nil {
-		h.logger.Error("Failed to create Razorpay order",
+		h.logger.Error("Failed to create gateway order",
 			zap.String("order_id", order.ID.String()),
+			zap.String("gateway", gateway.Name()),
 			zap.Error(err),
 		)
 		return c.JSON(http.StatusInternalServerError, map[string]string{
@@ -157,11 +342,11 @@ func (h *OrderHandler) CreateOrder(c echo.Context) error {
 		})
 	}
 
-	// Update order with Razorpay order ID
-	if err := h.orderRepo.UpdateOrderRazorpayID(c.Request().Context(), order.ID, razorpayOrder.ID); err != nil {
-		h.logger.Error("Failed to update order with Razorpay ID",
+	// Update order with the gateway order ID
+	if err := h.orderRepo.UpdateOrderRazorpayID(c.Request().Context(), order.ID, gatewayOrder.ID); err != nil {
+		h.logger.Error("Failed to update order with gateway order ID",
 			zap.String("order_id", order.ID.String()),
-			zap.String("razorpay_order_id", razorpayOrder.ID),
+			zap.String("gateway_order_id", gatewayOrder.ID),
 			zap.Error(err),
 		)
 		return c.JSON(http.StatusInternalServerError, map[string]string{
@@ -171,25 +356,325 @@ func (h *OrderHandler) CreateOrder(c echo.Context) error {
 
 	h.logger.Info("Order created successfully",
 		zap.String("order_id", order.ID.String()),
-		zap.String("razorpay_order_id", razorpayOrder.ID),
-		zap.Int("amount", totalCents),
+		zap.String("gateway", gateway.Name()),
+		zap.String("gateway_order_id", gatewayOrder.ID),
+		zap.Int("amount", order.AmountCents),
 	)
 
-	// Return response for frontend Razorpay Checkout
+	if onPlaced != nil {
+		onPlaced(order)
+	}
+
+	// Return response for the frontend checkout
 	response := CreateOrderResponse{
-		OrderID:         order.ID.String(),
-		RazorpayOrderID: razorpayOrder.ID,
-		Amount:          totalCents,
-		Currency:        "INR",
-		KeyID:           h.razorpayKeyID,
+		OrderID:        order.ID.String(),
+		Status:         string(orders.OrderStatusPending),
+		PaymentMethod:  req.PaymentMethod,
+		Gateway:        gateway.Name(),
+		GatewayOrderID: gatewayOrder.ID,
+		ClientData:     gatewayOrder.ClientData,
+		Amount:         order.AmountCents,
+		DiscountCents:  order.DiscountCents,
+		TaxCents:       order.TaxCents,
+		ShippingCents:  order.ShippingCents,
+		Currency:       "INR",
+	}
+	if gateway.Name() == payments.GatewayRazorpay {
+		response.RazorpayOrderID = gatewayOrder.ID
+		response.KeyID = gatewayOrder.ClientData["key_id"]
 	}
 
 	return c.JSON(http.StatusCreated, response)
 }
 
-// VerifyPaymentRequest represents payment verification request
+// confirmCODOrder moves a new order into the cash on delivery flow instead
+// of starting an online payment
+func (h *OrderHandler) confirmCODOrder(c echo.Context, order *orders.Order, onPlaced func(order *orders.Order)) error {
+	if err := h.orderRepo.ConfirmCODOrder(c.Request().Context(), order.ID); err != nil {
+		h.logger.Error("Failed to confirm cash on delivery order",
+			zap.String("order_id", order.ID.String()),
+			zap.Error(err),
+		)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to update order",
+		})
+	}
+
+	h.logger.Info("Cash on delivery order created successfully",
+		zap.String("order_id", order.ID.String()),
+		zap.Int("amount", order.AmountCents),
+		zap.Int("cod_fee", order.CODFeeCents),
+	)
+
+	if onPlaced != nil {
+		onPlaced(order)
+	}
+
+	return c.JSON(http.StatusCreated, CreateOrderResponse{
+		OrderID:       order.ID.String(),
+		Status:        string(orders.OrderStatusCODPending),
+		PaymentMethod: orders.PaymentMethodCOD,
+		Amount:        order.AmountCents,
+		DiscountCents: order.DiscountCents,
+		TaxCents:      order.TaxCents,
+		ShippingCents: order.ShippingCents,
+		CODFeeCents:   order.CODFeeCents,
+		Currency:      order.Currency,
+	})
+}
+
+// QuoteRequest asks for the price of a checkout before placing it. Without
+// items the user's saved cart is quoted. The address needs at least the
+// state and pincode, which decide tax and shipping.
+type QuoteRequest struct {
+	Items           []CheckoutItemRequest  `json:"items,omitempty"`
+	ShippingAddress orders.ShippingAddress `json:"shipping_address"`
+	PaymentMethod   string                 `json:"payment_method"`
+	CouponCode      string                 `json:"coupon_code,omitempty"`
+}
+
+// CheckoutQuote is the price of a checkout: what CreateOrder will charge for
+// the same items, address, coupon and payment method
+type CheckoutQuote struct {
+	Items         []orders.OrderItem `json:"items"`
+	SubtotalCents int                `json:"subtotal_cents"`
+	DiscountCents int                `json:"discount_cents"`
+	Shipping      *shipping.Quote    `json:"shipping"`
+	TaxCents      int                `json:"tax_cents"`
+	Tax           *tax.Breakdown     `json:"tax"`
+	CODFeeCents   int                `json:"cod_fee_cents,omitempty"`
+	TotalCents    int                `json:"total_cents"`
+	Currency      string             `json:"currency"`
+
+	discount *promotions.Discount
+}
+
+// QuoteCheckout handles POST /api/checkout/quote
+func (h *OrderHandler) QuoteCheckout(c echo.Context) error {
+	userIDStr, ok := c.Get("user_id").(string)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "User not authenticated",
+		})
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "Invalid user ID",
+		})
+	}
+
+	var req QuoteRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request body",
+		})
+	}
+
+	if strings.TrimSpace(req.ShippingAddress.State) == "" || strings.TrimSpace(req.ShippingAddress.Pincode) == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Shipping state and pincode are required",
+		})
+	}
+
+	items := req.Items
+	if len(items) == 0 {
+		userCart, err := h.cartRepo.GetOrCreateUserCart(c.Request().Context(), userID)
+		if err != nil {
+			h.logger.Error("Failed to load cart for quote",
+				zap.String("user_id", userIDStr),
+				zap.Error(err),
+			)
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "Failed to load cart",
+			})
+		}
+		if len(userCart.Items) == 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Cart is empty",
+			})
+		}
+		items = cartCheckoutItems(userCart)
+	}
+
+	quote, err := h.quote(c.Request().Context(), checkoutRequest{
+		Items:           items,
+		ShippingAddress: req.ShippingAddress,
+		PaymentMethod:   req.PaymentMethod,
+		CouponCode:      req.CouponCode,
+	})
+	if err != nil {
+		return h.checkoutErrorResponse(c, userID, "Failed to quote order", err)
+	}
+
+	return c.JSON(http.StatusOK, quote)
+}
+
+// quote prices a checkout: catalog prices, coupon discount, GST, shipping to
+// the pincode and the COD fee
+func (h *OrderHandler) quote(ctx context.Context, req checkoutRequest) (*CheckoutQuote, error) {
+	// Price items from the catalog
+	items, parcels, subtotalCents, err := h.priceItems(ctx, req.Items)
+	if err != nil {
+		return nil, err
+	}
+
+	if subtotalCents <= 0 {
+		return nil, &checkoutError{status: http.StatusBadRequest, message: "Invalid order total"}
+	}
+
+	quote := &CheckoutQuote{
+		Items:         items,
+		SubtotalCents: subtotalCents,
+		Currency:      "INR",
+	}
+	totalCents := subtotalCents
+
+	if strings.TrimSpace(req.CouponCode) != "" {
+		quote.discount, err = h.applyCoupon(ctx, req.CouponCode, items)
+		if err != nil {
+			return nil, err
+		}
+		quote.DiscountCents = quote.discount.DiscountCents
+		totalCents -= quote.DiscountCents
+		if totalCents <= 0 {
+			return nil, &checkoutError{status: http.StatusBadRequest, message: "Coupon cannot cover the whole order"}
+		}
+	}
+
+	// Work out GST on the discounted lines. Tax-exclusive prices add it to
+	// the total; tax-inclusive prices already contain it.
+	taxLines := orders.TaxLines(items, quote.discount)
+	goodsTax := h.taxCalc.Calculate(taxLines, req.ShippingAddress.State)
+	if !goodsTax.PricesIncludeTax {
+		totalCents += goodsTax.TaxCents
+	}
+
+	// Shipping is charged on top of the goods
+	quote.Shipping, err = h.shipping.Quote(parcels, totalCents, req.ShippingAddress.Pincode)
+	if err != nil {
+		switch {
+		case errors.Is(err, shipping.ErrInvalidPincode):
+			return nil, &checkoutError{status: http.StatusBadRequest, message: "Invalid pincode"}
+		case errors.Is(err, shipping.ErrNotServiceable):
+			return nil, &checkoutError{status: http.StatusBadRequest, message: "Delivery is not available for this pincode", reason: "not_serviceable"}
+		}
+		return nil, err
+	}
+	totalCents += quote.Shipping.ShippingCents
+
+	if strings.EqualFold(req.PaymentMethod, orders.PaymentMethodCOD) {
+		if err := h.cod.Check(totalCents, req.ShippingAddress.Pincode); err != nil {
+			return nil, &checkoutError{status: http.StatusBadRequest, message: codErrorMessage(err)}
+		}
+		quote.CODFeeCents = h.cod.FeeCents
+	}
+
+	// Shipping and the COD fee are part of the same supply as the goods and
+	// are taxed at the highest rate among them
+	charges := []tax.Line{}
+	if quote.Shipping.ShippingCents > 0 {
+		charges = append(charges, h.taxCalc.ChargeLine(taxLines, orders.ChargeShipping, quote.Shipping.ShippingCents))
+	}
+	if quote.CODFeeCents > 0 {
+		charges = append(charges, h.taxCalc.ChargeLine(taxLines, orders.ChargeCODFee, quote.CODFeeCents))
+	}
+	quote.Tax = h.taxCalc.Calculate(append(taxLines, charges...), req.ShippingAddress.State)
+	quote.TaxCents = quote.Tax.TaxCents
+	quote.TotalCents = quote.Tax.TotalCents
+	return quote, nil
+}
+
+// checkoutError is a client-facing checkout failure
+type checkoutError struct {
+	status  int
+	message string
+	reason  string
+}
+
+func (e *checkoutError) Error() string {
+	return e.message
+}
+
+// checkoutErrorResponse writes the response for a failed quote. Unexpected
+// errors are logged and reported as failMessage.
+func (h *OrderHandler) checkoutErrorResponse(c echo.Context, userID uuid.UUID, failMessage string, err error) error {
+	var checkoutErr *checkoutError
+	if errors.As(err, &checkoutErr) {
+		body := map[string]string{"error": checkoutErr.message}
+		if checkoutErr.reason != "" {
+			body["reason"] = checkoutErr.reason
+		}
+		return c.JSON(checkoutErr.status, body)
+	}
+
+	var itemErr *checkoutItemError
+	if errors.As(err, &itemErr) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": itemErr.Error(),
+		})
+	}
+
+	var couponErr *promotions.CouponError
+	if errors.As(err, &couponErr) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error":  couponErr.Message,
+			"reason": couponErr.Reason,
+		})
+	}
+
+	h.logger.Error("Failed to price checkout",
+		zap.String("user_id", userID.String()),
+		zap.Error(err),
+	)
+	return c.JSON(http.StatusInternalServerError, map[string]string{
+		"error": failMessage,
+	})
+}
+
+// applyCoupon works out a coupon's discount on the priced items
+func (h *OrderHandler) applyCoupon(ctx context.Context, code string, items []orders.OrderItem) (*promotions.Discount, error) {
+	coupon, err := h.couponRepo.GetCouponByCode(ctx, code)
+	if err != nil {
+		if errors.Is(err, promotions.ErrCouponNotFound) {
+			return nil, promotions.ErrCouponInvalid
+		}
+		return nil, err
+	}
+
+	lines := make([]promotions.Line, len(items))
+	for i, item := range items {
+		lines[i] = promotions.Line{
+			ProductID:  item.ProductID,
+			VariantID:  item.VariantID,
+			Category:   item.Category,
+			TotalCents: item.PriceCents * item.Quantity,
+		}
+	}
+
+	return coupon.Apply(lines, time.Now())
+}
+
+// codErrorMessage returns the checkout error for a failed COD rule check
+func codErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, payments.ErrCODLimitExceeded):
+		return "Order value exceeds the cash on delivery limit"
+	case errors.Is(err, payments.ErrCODPincodeNotServiceable):
+		return "Cash on delivery is not available for this pincode"
+	default:
+		return "Cash on delivery is not available"
+	}
+}
+
+// VerifyPaymentRequest represents payment verification request. Older clients
+// send the razorpay_ fields instead of the gateway-neutral ones.
 type VerifyPaymentRequest struct {
 	OrderID           string `json:"order_id"`
+	GatewayOrderID    string `json:"gateway_order_id"`
+	PaymentID         string `json:"payment_id"`
+	Signature         string `json:"signature"`
 	RazorpayOrderID   string `json:"razorpay_order_id"`
 	RazorpayPaymentID string `json:"razorpay_payment_id"`
 	RazorpaySignature string `json:"razorpay_signature"`
@@ -204,21 +689,14 @@ func (h *OrderHandler) VerifyPayment(c echo.Context) error {
 		})
 	}
 
-	// Verify signature
-	isValid := h.razorpayService.VerifyPaymentSignature(
-		req.RazorpayOrderID,
-		req.RazorpayPaymentID,
-		req.RazorpaySignature,
-	)
-
-	if !isValid {
-		h.logger.Warn("Invalid payment signature",
-			zap.String("razorpay_order_id", req.RazorpayOrderID),
-			zap.String("razorpay_payment_id", req.RazorpayPaymentID),
-		)
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid payment signature",
-		})
+	if req.GatewayOrderID == "" {
+		req.GatewayOrderID = req.RazorpayOrderID
+	}
+	if req.PaymentID == "" {
+		req.PaymentID = req.RazorpayPaymentID
+	}
+	if req.Signature == "" {
+		req.Signature = req.RazorpaySignature
 	}
 
 	// Parse order ID
@@ -229,146 +707,171 @@ func (h *OrderHandler) VerifyPayment(c echo.Context) error {
 		})
 	}
 
-	// Update order status
-	updateInput := orders.UpdateOrderStatusInput{
-		Status:            orders.OrderStatusPaid,
-		RazorpayPaymentID: &req.RazorpayPaymentID,
-		RazorpaySignature: &req.RazorpaySignature,
-	}
+	userIDStr, _ := c.Get("user_id").(string)
 
-	order, err := h.orderRepo.UpdateOrderStatus(c.Request().Context(), orderID, updateInput)
+	// The paid gateway order must be the one created for this order and user
+	existing, err := h.orderRepo.GetOrder(c.Request().Context(), orderID)
 	if err != nil {
-		h.logger.Error("Failed to update order status",
+		if err.Error() == "order not found" {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "Order not found",
+			})
+		}
+		h.logger.Error("Failed to get order",
 			zap.String("order_id", req.OrderID),
 			zap.Error(err),
 		)
 		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to update order",
+			"error": "Failed to verify payment",
 		})
 	}
 
-	h.logger.Info("Payment verified successfully",
-		zap.String("order_id", order.ID.String()),
-		zap.String("razorpay_payment_id", req.RazorpayPaymentID),
-	)
+	if existing.UserID.String() != userIDStr {
+		h.logger.Warn("Payment verification for another user's order",
+			zap.String("order_id", req.OrderID),
+			zap.String("user_id", userIDStr),
+		)
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "Order not found",
+		})
+	}
 
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"success": true,
-		"order":   order,
-	})
-}
+	if existing.PaymentGateway == orders.PaymentMethodCOD {
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": "Cash on delivery orders are paid on delivery",
+		})
+	}
 
-// RazorpayWebhook handles POST /api/webhooks/razorpay
-func (h *OrderHandler) RazorpayWebhook(c echo.Context) error {
-	// Read raw body for signature verification
-	body, err := io.ReadAll(c.Request().Body)
+	gateway, err := h.gateways.Get(existing.PaymentGateway)
 	if err != nil {
-		h.logger.Error("Failed to read webhook body", zap.Error(err))
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Failed to read request body",
+		h.logger.Error("Payment gateway for order unavailable",
+			zap.String("order_id", req.OrderID),
+			zap.String("gateway", existing.PaymentGateway),
+		)
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{
+			"error": "Payment gateway unavailable",
 		})
 	}
 
-	// Get signature from header
-	signature := c.Request().Header.Get("X-Razorpay-Signature")
-	if signature == "" {
-		h.logger.Warn("Missing Razorpay signature header")
+	// Verify signature
+	if !gateway.VerifyPaymentSignature(req.GatewayOrderID, req.PaymentID, req.Signature) {
+		h.logger.Warn("Invalid payment signature",
+			zap.String("gateway", gateway.Name()),
+			zap.String("gateway_order_id", req.GatewayOrderID),
+			zap.String("payment_id", req.PaymentID),
+		)
 		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Missing signature",
+			"error": "Invalid payment signature",
 		})
 	}
 
-	// Verify webhook signature
-	if !h.razorpayService.VerifyWebhookSignature(body, signature) {
-		h.logger.Warn("Invalid webhook signature")
-		return c.JSON(http.StatusUnauthorized, map[string]string{
-			"error": "Invalid signature",
+	if existing.RazorpayOrderID == nil || *existing.RazorpayOrderID != req.GatewayOrderID {
+		h.logger.Warn("Gateway order does not belong to order",
+			zap.String("order_id", req.OrderID),
+			zap.String("gateway_order_id", req.GatewayOrderID),
+		)
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Payment does not belong to this order",
 		})
 	}
 
-	// Parse webhook payload
-	payload, err := razorpay.ParseWebhookPayload(body)
-	if err != nil {
-		h.logger.Error("Failed to parse webhook payload", zap.Error(err))
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid payload",
+	if existing.Status == orders.OrderStatusPaymentReview {
+		return c.JSON(http.StatusAccepted, map[string]interface{}{
+			"success": false,
+			"message": "Payment is being reviewed",
+			"order":   existing,
 		})
 	}
 
-	// Extract event ID for idempotency
-	eventID := fmt.Sprintf("%s_%d", payload.Event, payload.CreatedAt)
-
-	// Check for duplicate event
-	isNew, err := h.orderRepo.RecordWebhookEvent(c.Request().Context(), eventID, payload.Event, body)
+	// The signature does not cover the amount, so check what was captured
+	payment, err := gateway.FetchPayment(req.PaymentID)
 	if err != nil {
-		h.logger.Error("Failed to record webhook event",
-			zap.String("event_id", eventID),
+		h.logger.Error("Failed to fetch payment for verification",
+			zap.String("order_id", req.OrderID),
+			zap.String("payment_id", req.PaymentID),
 			zap.Error(err),
 		)
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to process webhook",
+		return c.JSON(http.StatusBadGateway, map[string]string{
+			"error": "Could not confirm payment yet, it will be confirmed shortly",
 		})
 	}
 
-	if !isNew {
-		h.logger.Info("Duplicate webhook event ignored",
-			zap.String("event_id", eventID),
-			zap.String("event", payload.Event),
-		)
-		return c.JSON(http.StatusOK, map[string]string{
-			"status": "already_processed",
+	if payment.OrderID != req.GatewayOrderID {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Payment does not belong to this order",
 		})
 	}
 
-	// Handle different event types
-	switch payload.Event {
-	case "payment.captured":
-		return h.handlePaymentCaptured(c, payload)
-	case "payment.failed":
-		return h.handlePaymentFailed(c, payload)
-	case "order.paid":
-		return h.handleOrderPaid(c, payload)
+	switch payment.Status {
+	case payments.PaymentStatusCaptured, payments.PaymentStatusAuthorized:
+	case payments.PaymentStatusFailed:
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Payment failed",
+		})
 	default:
-		h.logger.Info("Unhandled webhook event",
-			zap.String("event", payload.Event),
-		)
-		return c.JSON(http.StatusOK, map[string]string{
-			"status": "event_ignored",
+		return c.JSON(http.StatusAccepted, map[string]interface{}{
+			"success": false,
+			"message": "Payment is processing, it will be confirmed shortly",
+			"order":   existing,
 		})
 	}
-}
-
-func (h *OrderHandler) handlePaymentCaptured(c echo.Context, payload *razorpay.WebhookPayload) error {
-	payment := payload.Payload.Payment.Entity
 
-	h.logger.Info("Processing payment.captured event",
-		zap.String("payment_id", payment.ID),
-		zap.String("order_id", payment.OrderID),
-		zap.Int("amount", payment.Amount),
-	)
-
-	// Get order by Razorpay order ID
-	order, err := h.orderRepo.GetOrderByRazorpayOrderID(c.Request().Context(), payment.OrderID)
-	if err != nil {
-		h.logger.Error("Order not found for payment",
-			zap.String("razorpay_order_id", payment.OrderID),
-			zap.Error(err),
+	if reason := existing.PaymentMismatch(payment.Amount, payment.Currency); reason != "" {
+		h.logger.Warn("Payment does not match order, holding for review",
+			zap.String("order_id", req.OrderID),
+			zap.String("reason", reason),
 		)
-		return c.JSON(http.StatusNotFound, map[string]string{
-			"error": "Order not found",
+		order, err := h.orderRepo.UpdateOrderStatus(c.Request().Context(), orderID, orders.UpdateOrderStatusInput{
+			Status:              orders.OrderStatusPaymentReview,
+			RazorpayPaymentID:   &req.PaymentID,
+			RazorpaySignature:   &req.Signature,
+			ReviewReason:        &reason,
+			CapturedAmountCents: &payment.Amount,
+			CapturedCurrency:    &payment.Currency,
+			Actor:               orders.Actor{Type: orders.ActorUser, ID: userIDStr},
+			Note:                reason,
+		})
+		if err != nil {
+			var invalid *orders.InvalidTransitionError
+			if errors.As(err, &invalid) {
+				return c.JSON(http.StatusConflict, map[string]string{
+					"error": fmt.Sprintf("Order is %s and can no longer be paid", invalid.From),
+				})
+			}
+			h.logger.Error("Failed to hold order for review",
+				zap.String("order_id", req.OrderID),
+				zap.Error(err),
+			)
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "Failed to update order",
+			})
+		}
+		return c.JSON(http.StatusAccepted, map[string]interface{}{
+			"success": false,
+			"message": "Payment is being reviewed",
+			"order":   order,
 		})
 	}
 
-	// Update order status to paid
+	// Update order status
 	updateInput := orders.UpdateOrderStatusInput{
 		Status:            orders.OrderStatusPaid,
-		RazorpayPaymentID: &payment.ID,
+		RazorpayPaymentID: &req.PaymentID,
+		RazorpaySignature: &req.Signature,
+		Actor:             orders.Actor{Type: orders.ActorUser, ID: userIDStr},
+		Note:              "Payment verified at checkout",
 	}
 
-	_, err = h.orderRepo.UpdateOrderStatus(c.Request().Context(), order.ID, updateInput)
+	order, err := h.orderRepo.UpdateOrderStatus(c.Request().Context(), orderID, updateInput)
 	if err != nil {
+		var invalid *orders.InvalidTransitionError
+		if errors.As(err, &invalid) {
+			return c.JSON(http.StatusConflict, map[string]string{
+				"error": fmt.Sprintf("Order is %s and can no longer be paid", invalid.From),
+			})
+		}
 		h.logger.Error("Failed to update order status",
-			zap.String("order_id", order.ID.String()),
+			zap.String("order_id", req.OrderID),
 			zap.Error(err),
 		)
 		return c.JSON(http.StatusInternalServerError, map[string]string{
@@ -376,108 +879,92 @@ func (h *OrderHandler) handlePaymentCaptured(c echo.Context, payload *razorpay.W
 		})
 	}
 
-	h.logger.Info("Order marked as paid via webhook",
+	h.logger.Info("Payment verified successfully",
 		zap.String("order_id", order.ID.String()),
-		zap.String("payment_id", payment.ID),
+		zap.String("payment_id", req.PaymentID),
 	)
 
-	return c.JSON(http.StatusOK, map[string]string{
-		"status": "success",
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"order":   order,
 	})
 }
 
-func (h *OrderHandler) handlePaymentFailed(c echo.Context, payload *razorpay.WebhookPayload) error {
-	payment := payload.Payload.Payment.Entity
-
-	h.logger.Info("Processing payment.failed event",
-		zap.String("payment_id", payment.ID),
-		zap.String("order_id", payment.OrderID),
-		zap.String("error", payment.ErrorDescription),
-	)
-
-	// Get order by Razorpay order ID
-	order, err := h.orderRepo.GetOrderByRazorpayOrderID(c.Request().Context(), payment.OrderID)
+// PaymentWebhook handles POST /api/webhooks/:gateway
+func (h *OrderHandler) PaymentWebhook(c echo.Context) error {
+	gateway, err := h.gateways.Get(c.Param("gateway"))
 	if err != nil {
-		h.logger.Error("Order not found for failed payment",
-			zap.String("razorpay_order_id", payment.OrderID),
-			zap.Error(err),
-		)
 		return c.JSON(http.StatusNotFound, map[string]string{
-			"error": "Order not found",
+			"error": "Unknown payment gateway",
 		})
 	}
 
-	// Update order status to failed
-	updateInput := orders.UpdateOrderStatusInput{
-		Status:            orders.OrderStatusFailed,
-		RazorpayPaymentID: &payment.ID,
-	}
-
-	_, err = h.orderRepo.UpdateOrderStatus(c.Request().Context(), order.ID, updateInput)
+	// Read raw body for signature verification
+	body, err := io.ReadAll(c.Request().Body)
 	if err != nil {
-		h.logger.Error("Failed to update order status",
-			zap.String("order_id", order.ID.String()),
-			zap.Error(err),
-		)
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to update order",
+		h.logger.Error("Failed to read webhook body", zap.Error(err))
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Failed to read request body",
 		})
 	}
 
-	h.logger.Info("Order marked as failed via webhook",
-		zap.String("order_id", order.ID.String()),
-		zap.String("payment_id", payment.ID),
-	)
-
-	return c.JSON(http.StatusOK, map[string]string{
-		"status": "success",
-	})
-}
+	// Verify webhook signature
+	if !gateway.VerifyWebhook(body, c.Request().Header) {
+		h.logger.Warn("Invalid webhook signature", zap.String("gateway", gateway.Name()))
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "Invalid signature",
+		})
+	}
 
-func (h *OrderHandler) handleOrderPaid(c echo.Context, payload *razorpay.WebhookPayload) error {
-	orderEntity := payload.Payload.Order.Entity
+	// Parse webhook payload
+	payload, err := gateway.ParseWebhook(body, c.Request().Header)
+	if err != nil {
+		h.logger.Error("Failed to parse webhook payload", zap.Error(err))
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid payload",
+		})
+	}
 
-	h.logger.Info("Processing order.paid event",
-		zap.String("razorpay_order_id", orderEntity.ID),
-		zap.Int("amount_paid", orderEntity.AmountPaid),
-	)
+	// Gateways send the same event ID on every redelivery of an event
+	eventID := payload.ID
+	if eventID == "" {
+		sum := sha256.Sum256(body)
+		eventID = "sha256_" + hex.EncodeToString(sum[:])
+	}
 
-	// Get order by Razorpay order ID
-	order, err := h.orderRepo.GetOrderByRazorpayOrderID(c.Request().Context(), orderEntity.ID)
+	// Store every event, including ones we do not handle, for audit
+	event, isNew, err := h.orderRepo.RecordWebhookEvent(c.Request().Context(), gateway.Name(), eventID, payload.Type, body)
 	if err != nil {
-		h.logger.Error("Order not found",
-			zap.String("razorpay_order_id", orderEntity.ID),
+		h.logger.Error("Failed to record webhook event",
+			zap.String("event_id", eventID),
 			zap.Error(err),
 		)
-		return c.JSON(http.StatusNotFound, map[string]string{
-			"error": "Order not found",
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to process webhook",
 		})
 	}
 
-	// Only update if not already paid
-	if order.Status != orders.OrderStatusPaid {
-		updateInput := orders.UpdateOrderStatusInput{
-			Status: orders.OrderStatusPaid,
-		}
-
-		_, err = h.orderRepo.UpdateOrderStatus(c.Request().Context(), order.ID, updateInput)
-		if err != nil {
-			h.logger.Error("Failed to update order status",
-				zap.String("order_id", order.ID.String()),
-				zap.Error(err),
-			)
-			return c.JSON(http.StatusInternalServerError, map[string]string{
-				"error": "Failed to update order",
-			})
-		}
-
-		h.logger.Info("Order marked as paid",
-			zap.String("order_id", order.ID.String()),
+	if !isNew && (event.Status == orders.WebhookEventProcessed || event.Status == orders.WebhookEventIgnored) {
+		h.logger.Info("Duplicate webhook event ignored",
+			zap.String("event_id", eventID),
+			zap.String("event", payload.Type),
 		)
+		return c.JSON(http.StatusOK, map[string]string{
+			"status": payments.OutcomeAlreadyHandled,
+		})
+	}
+
+	outcome, err := h.webhooks.Process(c.Request().Context(), event, false)
+	if err != nil {
+		// The failure is recorded on the event and retried in the background,
+		// so the gateway does not need to redeliver it
+		return c.JSON(http.StatusOK, map[string]string{
+			"status": "retry_scheduled",
+		})
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{
-		"status": "success",
+		"status": outcome,
 	})
 }
 
@@ -594,6 +1081,92 @@ func (h *OrderHandler) ListOrders(c echo.Context) error {
 	})
 }
 
+// checkoutItemError is a client-facing problem with a requested checkout item
+type checkoutItemError struct {
+	index int
+	msg   string
+}
+
+func (e *checkoutItemError) Error() string {
+	return fmt.Sprintf("Item %d: %s", e.index, e.msg)
+}
+
+// Limits on a checkout, so line totals cannot overflow
+const (
+	maxCheckoutItems    = 50
+	maxCheckoutQuantity = 100
+)
+
+// priceItems resolves requested items against the catalog and returns
+// order items carrying the authoritative title, SKU, image and price, along
+// with the parcels to ship
+func (h *OrderHandler) priceItems(ctx context.Context, reqItems []CheckoutItemRequest) ([]orders.OrderItem, []shipping.Item, int, error) {
+	if len(reqItems) > maxCheckoutItems {
+		return nil, nil, 0, &checkoutError{
+			status:  http.StatusBadRequest,
+			message: fmt.Sprintf("An order can have at most %d items", maxCheckoutItems),
+		}
+	}
+
+	items := make([]orders.OrderItem, 0, len(reqItems))
+	parcels := make([]shipping.Item, 0, len(reqItems))
+	totalCents := 0
+
+	for i, reqItem := range reqItems {
+		if reqItem.Quantity <= 0 {
+			return nil, nil, 0, &checkoutItemError{index: i, msg: "quantity must be greater than 0"}
+		}
+		if reqItem.Quantity > maxCheckoutQuantity {
+			return nil, nil, 0, &checkoutItemError{index: i, msg: fmt.Sprintf("quantity must be at most %d", maxCheckoutQuantity)}
+		}
+		if reqItem.ProductID == uuid.Nil {
+			return nil, nil, 0, &checkoutItemError{index: i, msg: "product_id is required"}
+		}
+
+		priced, err := h.productRepo.GetPricedItem(ctx, reqItem.ProductID, reqItem.VariantID, h.baseURL)
+		if err != nil {
+			switch {
+			case errors.Is(err, products.ErrProductNotFound):
+				return nil, nil, 0, &checkoutItemError{index: i, msg: "product not found"}
+			case errors.Is(err, products.ErrVariantNotFound):
+				return nil, nil, 0, &checkoutItemError{index: i, msg: "variant not found"}
+			case errors.Is(err, products.ErrVariantMismatch):
+				return nil, nil, 0, &checkoutItemError{index: i, msg: "variant does not belong to product"}
+			case errors.Is(err, products.ErrVariantRequired):
+				return nil, nil, 0, &checkoutItemError{index: i, msg: "variant_id is required for this product"}
+			}
+			return nil, nil, 0, err
+		}
+
+		if priced.UnitPriceCents <= 0 {
+			return nil, nil, 0, &checkoutItemError{index: i, msg: "product is not available for purchase"}
+		}
+
+		items = append(items, orders.OrderItem{
+			ProductID:  priced.ProductID,
+			VariantID:  priced.VariantID,
+			Title:      priced.Title,
+			SKU:        priced.SKU,
+			Quantity:   reqItem.Quantity,
+			PriceCents: priced.UnitPriceCents,
+			ImageURL:   priced.ImageURL,
+			Category:   priced.Category,
+			HSNCode:    priced.HSNCode,
+			GSTRateBps: priced.GSTRateBps,
+		})
+		parcels = append(parcels, shipping.Item{
+			WeightGrams: priced.WeightGrams,
+			LengthCm:    priced.LengthCm,
+			BreadthCm:   priced.BreadthCm,
+			HeightCm:    priced.HeightCm,
+			Quantity:    reqItem.Quantity,
+		})
+		totalCents += priced.UnitPriceCents * reqItem.Quantity
+	}
+
+	return items, parcels, totalCents, nil
+}
+
 func validateShippingAddress(addr orders.ShippingAddress) error {
 	if addr.Name == "" {
 		return fmt.Errorf("name is required")
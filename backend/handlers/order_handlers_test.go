@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriceItemsLimits(t *testing.T) {
+	// Limits are checked before the catalog is read
+	h := &OrderHandler{}
+
+	tooMany := make([]CheckoutItemRequest, maxCheckoutItems+1)
+	for i := range tooMany {
+		tooMany[i] = CheckoutItemRequest{ProductID: uuid.New(), Quantity: 1}
+	}
+	_, _, _, err := h.priceItems(context.Background(), tooMany)
+	var checkoutErr *checkoutError
+	assert.True(t, errors.As(err, &checkoutErr), "expected a checkout error, got %v", err)
+
+	huge := []CheckoutItemRequest{{ProductID: uuid.New(), Quantity: maxCheckoutQuantity + 1}}
+	_, _, _, err = h.priceItems(context.Background(), huge)
+	var itemErr *checkoutItemError
+	assert.True(t, errors.As(err, &itemErr), "expected an item error, got %v", err)
+	assert.Equal(t, "Item 0: quantity must be at most 100", err.Error())
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/ramniya/ramniya-backend/cache"
 	"github.com/ramniya/ramniya-backend/products"
+	"github.com/ramniya/ramniya-backend/tax"
 	"github.com/ramniya/ramniya-backend/upload"
 	"go.uber.org/zap"
 )
@@ -62,6 +63,18 @@ func (h *ProductHandler) CreateProduct(c echo.Context) error {
 		})
 	}
 
+	if input.GSTRateBps != nil && !tax.ValidRate(*input.GSTRateBps) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "GST rate must be one of 0, 25, 300, 500, 1200, 1800 or 2800 basis points",
+		})
+	}
+
+	if !positiveOrUnset(input.WeightGrams, input.LengthCm, input.BreadthCm, input.HeightCm) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Weight and dimensions must be greater than 0",
+		})
+	}
+
 	// Validate variants
 	if len(input.Variants) > 0 {
 		skuMap := make(map[string]bool)
@@ -387,6 +400,18 @@ func (h *ProductHandler) UpdateProduct(c echo.Context) error {
 		})
 	}
 
+	if input.GSTRateBps != nil && !tax.ValidRate(*input.GSTRateBps) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "GST rate must be one of 0, 25, 300, 500, 1200, 1800 or 2800 basis points",
+		})
+	}
+
+	if !positiveOrUnset(input.WeightGrams, input.LengthCm, input.BreadthCm, input.HeightCm) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Weight and dimensions must be greater than 0",
+		})
+	}
+
 	product, err := h.productRepo.UpdateProduct(c.Request().Context(), productID, input)
 	if err != nil {
 		if err.Error() == "product not found" {
@@ -460,3 +485,13 @@ func (h *ProductHandler) DeleteProduct(c echo.Context) error {
 		"message": "Product deleted successfully",
 	})
 }
+
+// positiveOrUnset reports whether every value is either nil or above zero
+func positiveOrUnset(values ...*int) bool {
+	for _, v := range values {
+		if v != nil && *v <= 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,571 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/ramniya/ramniya-backend/auth"
+	"github.com/ramniya/ramniya-backend/orders"
+	"github.com/ramniya/ramniya-backend/payments"
+	"github.com/ramniya/ramniya-backend/shipping"
+	"go.uber.org/zap"
+)
+
+// ShipmentHandler books order shipments with the shipping carrier and applies
+// the carrier's tracking updates to order fulfilment
+type ShipmentHandler struct {
+	orderRepo    *orders.OrderRepository
+	shipmentRepo *shipping.ShipmentRepository
+	authRepo     *auth.AuthRepository
+	carriers     *shipping.Carriers
+	logger       *zap.Logger
+}
+
+// NewShipmentHandler creates a new shipment handler
+func NewShipmentHandler(
+	orderRepo *orders.OrderRepository,
+	shipmentRepo *shipping.ShipmentRepository,
+	authRepo *auth.AuthRepository,
+	carriers *shipping.Carriers,
+	logger *zap.Logger,
+) *ShipmentHandler {
+	return &ShipmentHandler{
+		orderRepo:    orderRepo,
+		shipmentRepo: shipmentRepo,
+		authRepo:     authRepo,
+		carriers:     carriers,
+		logger:       logger,
+	}
+}
+
+// CreateShipmentRequest represents an admin shipment booking. The parcel
+// weight defaults to the order's shipping weight and the dimensions to a
+// standard box.
+type CreateShipmentRequest struct {
+	WeightGrams *int `json:"weight_grams,omitempty"`
+	LengthCm    *int `json:"length_cm,omitempty"`
+	BreadthCm   *int `json:"breadth_cm,omitempty"`
+	HeightCm    *int `json:"height_cm,omitempty"`
+}
+
+// CreateShipment handles POST /api/admin/orders/:id/shipment. The parcel is
+// booked with the default carrier and the order marked packed with its AWB.
+func (h *ShipmentHandler) CreateShipment(c echo.Context) error {
+	order, err := h.loadOrder(c)
+	if err != nil || order == nil {
+		return err
+	}
+
+	var req CreateShipmentRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request body",
+		})
+	}
+	if !positiveOrUnset(req.WeightGrams, req.LengthCm, req.BreadthCm, req.HeightCm) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Weight and dimensions must be greater than 0",
+		})
+	}
+
+	switch order.Status {
+	case orders.OrderStatusPaid, orders.OrderStatusPartiallyRefunded, orders.OrderStatusCODPending:
+	default:
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": "Only paid orders can be shipped",
+		})
+	}
+	switch order.FulfilmentStatus {
+	case orders.FulfilmentUnfulfilled, orders.FulfilmentProcessing, orders.FulfilmentPacked:
+	default:
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": fmt.Sprintf("Order is already %s", order.FulfilmentStatus),
+		})
+	}
+
+	existing, err := h.shipmentRepo.GetOrderShipment(c.Request().Context(), order.ID)
+	if err != nil && !errors.Is(err, shipping.ErrShipmentNotFound) {
+		h.logger.Error("Failed to get shipment", zap.String("order_id", order.ID.String()), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to create shipment",
+		})
+	}
+	if existing != nil && existing.Status != shipping.ShipmentCancelled {
+		return c.JSON(http.StatusConflict, map[string]interface{}{
+			"error":    "Order already has a shipment",
+			"shipment": existing,
+		})
+	}
+
+	shipmentReq := h.shipmentRequest(c, order, req)
+	carrier := h.carriers.Default()
+
+	booking, err := carrier.CreateShipment(shipmentReq)
+	if err != nil {
+		h.logger.Error("Failed to book shipment",
+			zap.String("order_id", order.ID.String()),
+			zap.String("carrier", carrier.Name()),
+			zap.Error(err),
+		)
+		return c.JSON(http.StatusBadGateway, map[string]string{
+			"error": "Failed to book shipment with the carrier",
+		})
+	}
+
+	shipment, err := h.shipmentRepo.CreateShipment(c.Request().Context(), shipping.CreateShipmentInput{
+		OrderID:     order.ID,
+		Carrier:     carrier.Name(),
+		Booking:     booking,
+		WeightGrams: shipmentReq.WeightGrams,
+		LengthCm:    shipmentReq.LengthCm,
+		BreadthCm:   shipmentReq.BreadthCm,
+		HeightCm:    shipmentReq.HeightCm,
+	})
+	if err != nil {
+		if errors.Is(err, shipping.ErrShipmentExists) {
+			return c.JSON(http.StatusConflict, map[string]string{
+				"error": "Order already has a shipment",
+			})
+		}
+		h.logger.Error("Failed to record shipment",
+			zap.String("order_id", order.ID.String()),
+			zap.String("awb", booking.AWB),
+			zap.Error(err),
+		)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to create shipment",
+		})
+	}
+
+	// The parcel is ready for pickup. The shipment is booked either way, so a
+	// failure here is logged for the admin to fix by hand.
+	adminID, _ := c.Get("user_id").(string)
+	courier := booking.CourierName
+	if courier == "" {
+		courier = carrier.Name()
+	}
+	_, err = h.orderRepo.UpdateFulfilment(c.Request().Context(), order.ID, orders.UpdateFulfilmentInput{
+		Status:         orders.FulfilmentPacked,
+		Carrier:        &courier,
+		TrackingNumber: &booking.AWB,
+		TrackingURL:    optionalString(booking.TrackingURL),
+		Actor:          orders.Actor{Type: orders.ActorAdmin, ID: adminID},
+		Note:           fmt.Sprintf("Shipment booked with %s, AWB %s", carrier.Name(), booking.AWB),
+	})
+	if err != nil {
+		h.logger.Error("Failed to mark order packed after booking shipment",
+			zap.String("order_id", order.ID.String()),
+			zap.String("awb", booking.AWB),
+			zap.Error(err),
+		)
+	}
+
+	h.logger.Info("Shipment created by admin",
+		zap.String("order_id", order.ID.String()),
+		zap.String("carrier", carrier.Name()),
+		zap.String("awb", booking.AWB),
+		zap.String("admin_id", adminID),
+	)
+
+	return c.JSON(http.StatusCreated, shipment)
+}
+
+// GetShipment handles GET /api/admin/orders/:id/shipment
+func (h *ShipmentHandler) GetShipment(c echo.Context) error {
+	shipment, err := h.loadShipment(c)
+	if err != nil || shipment == nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, shipment)
+}
+
+// GetLabel handles GET /api/admin/orders/:id/shipment/label
+func (h *ShipmentHandler) GetLabel(c echo.Context) error {
+	shipment, err := h.loadShipment(c)
+	if err != nil || shipment == nil {
+		return err
+	}
+
+	carrier, err := h.carriers.Get(shipment.Carrier)
+	if err != nil {
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": fmt.Sprintf("Carrier %s is no longer configured", shipment.Carrier),
+		})
+	}
+
+	label, err := carrier.Label(shipment.CarrierShipmentID)
+	if err != nil {
+		h.logger.Error("Failed to get shipping label",
+			zap.String("shipment_id", shipment.ID.String()),
+			zap.String("carrier", shipment.Carrier),
+			zap.Error(err),
+		)
+		return c.JSON(http.StatusBadGateway, map[string]string{
+			"error": "Failed to get label from the carrier",
+		})
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", "label-"+shipment.AWB+".pdf"))
+	return c.Blob(http.StatusOK, "application/pdf", label)
+}
+
+// SchedulePickupRequest represents an admin pickup request. Date is an IST
+// calendar date (YYYY-MM-DD); empty leaves the day to the courier.
+type SchedulePickupRequest struct {
+	Date string `json:"date,omitempty"`
+}
+
+// SchedulePickup handles POST /api/admin/orders/:id/shipment/pickup
+func (h *ShipmentHandler) SchedulePickup(c echo.Context) error {
+	var req SchedulePickupRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request body",
+		})
+	}
+
+	var date time.Time
+	if req.Date != "" {
+		ist := time.FixedZone("IST", 5*60*60+30*60)
+		var err error
+		date, err = time.ParseInLocation("2006-01-02", req.Date, ist)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "date must be YYYY-MM-DD",
+			})
+		}
+		now := time.Now().In(ist)
+		if date.Before(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, ist)) {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "date must not be in the past",
+			})
+		}
+	}
+
+	shipment, err := h.loadShipment(c)
+	if err != nil || shipment == nil {
+		return err
+	}
+	if shipment.Status != shipping.ShipmentBooked && shipment.Status != shipping.ShipmentPickupScheduled {
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": fmt.Sprintf("Shipment is already %s", shipment.Status),
+		})
+	}
+
+	carrier, err := h.carriers.Get(shipment.Carrier)
+	if err != nil {
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": fmt.Sprintf("Carrier %s is no longer configured", shipment.Carrier),
+		})
+	}
+
+	pickup, err := carrier.SchedulePickup(shipment.CarrierShipmentID, date)
+	if err != nil {
+		h.logger.Error("Failed to schedule pickup",
+			zap.String("shipment_id", shipment.ID.String()),
+			zap.String("carrier", shipment.Carrier),
+			zap.Error(err),
+		)
+		return c.JSON(http.StatusBadGateway, map[string]string{
+			"error": "Failed to schedule pickup with the carrier",
+		})
+	}
+
+	shipment, err = h.shipmentRepo.RecordPickup(c.Request().Context(), shipment.ID, pickup)
+	if err != nil {
+		h.logger.Error("Failed to record pickup", zap.String("order_id", c.Param("id")), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to schedule pickup",
+		})
+	}
+
+	return c.JSON(http.StatusOK, shipment)
+}
+
+// TrackingWebhook handles POST /api/webhooks/tracking. The carrier is
+// identified by the webhook's signature. Unknown shipments and stale events
+// are acknowledged so the carrier stops redelivering them.
+func (h *ShipmentHandler) TrackingWebhook(c echo.Context) error {
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		h.logger.Error("Failed to read tracking webhook body", zap.Error(err))
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Failed to read request body",
+		})
+	}
+
+	carrier := h.carriers.ForWebhook(body, c.Request().Header)
+	if carrier == nil {
+		h.logger.Warn("Invalid tracking webhook signature")
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "Invalid signature",
+		})
+	}
+
+	event, err := carrier.ParseWebhook(body)
+	if err != nil {
+		h.logger.Error("Failed to parse tracking webhook", zap.String("carrier", carrier.Name()), zap.Error(err))
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid payload",
+		})
+	}
+
+	ctx := c.Request().Context()
+	shipment, err := h.shipmentRepo.GetShipmentByAWB(ctx, carrier.Name(), event.AWB)
+	if errors.Is(err, shipping.ErrShipmentNotFound) {
+		// Probably booked directly in the carrier's panel
+		h.logger.Info("Tracking webhook for unknown shipment",
+			zap.String("carrier", carrier.Name()),
+			zap.String("awb", event.AWB),
+		)
+		return c.JSON(http.StatusOK, map[string]string{
+			"status": payments.OutcomeEventIgnored,
+		})
+	}
+	if err != nil {
+		h.logger.Error("Failed to get shipment", zap.String("awb", event.AWB), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to process webhook",
+		})
+	}
+
+	shipment, applied, err := h.shipmentRepo.RecordTrackingEvent(ctx, shipment.ID, event)
+	if err != nil {
+		h.logger.Error("Failed to record tracking event", zap.String("awb", event.AWB), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to process webhook",
+		})
+	}
+	if !applied {
+		return c.JSON(http.StatusOK, map[string]string{
+			"status": payments.OutcomeTransitionIgnored,
+		})
+	}
+
+	target, ok := shipmentFulfilment[event.Status]
+	if !ok {
+		return c.JSON(http.StatusOK, map[string]string{
+			"status": payments.OutcomeSuccess,
+		})
+	}
+
+	outcome, err := h.applyTracking(c, shipment, target, event)
+	if err != nil {
+		h.logger.Error("Failed to update fulfilment from tracking",
+			zap.String("order_id", shipment.OrderID.String()),
+			zap.String("awb", event.AWB),
+			zap.String("status", string(event.Status)),
+			zap.Error(err),
+		)
+		// The carrier redelivers failed webhooks
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to process webhook",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"status": outcome,
+	})
+}
+
+// shipmentFulfilment maps carrier progress to the order fulfilment status it
+// implies. Other shipment statuses leave fulfilment alone.
+var shipmentFulfilment = map[shipping.ShipmentStatus]orders.FulfilmentStatus{
+	shipping.ShipmentInTransit:      orders.FulfilmentShipped,
+	shipping.ShipmentOutForDelivery: orders.FulfilmentOutForDelivery,
+	shipping.ShipmentDelivered:      orders.FulfilmentDelivered,
+	shipping.ShipmentReturned:       orders.FulfilmentReturned,
+}
+
+// applyTracking moves the order's fulfilment to target. An order that has
+// not been marked shipped yet, because the pickup scan was missed, is
+// shipped first.
+func (h *ShipmentHandler) applyTracking(c echo.Context, shipment *shipping.Shipment, target orders.FulfilmentStatus, event *shipping.TrackingEvent) (string, error) {
+	ctx := c.Request().Context()
+	order, err := h.orderRepo.GetOrder(ctx, shipment.OrderID)
+	if err != nil {
+		return "", err
+	}
+
+	actor := orders.Actor{Type: orders.ActorWebhook, ID: shipment.Carrier + ":" + event.AWB}
+	note := fmt.Sprintf("%s tracking: %s", shipment.Carrier, event.CarrierStatus)
+
+	steps := []orders.FulfilmentStatus{target}
+	switch order.FulfilmentStatus {
+	case orders.FulfilmentUnfulfilled, orders.FulfilmentProcessing, orders.FulfilmentPacked:
+		if target != orders.FulfilmentShipped {
+			steps = []orders.FulfilmentStatus{orders.FulfilmentShipped, target}
+		}
+	}
+
+	for _, status := range steps {
+		input := orders.UpdateFulfilmentInput{
+			Status: status,
+			Actor:  actor,
+			Note:   note,
+		}
+		if status == orders.FulfilmentShipped {
+			// Carry the AWB in case the order was never marked packed
+			input.TrackingNumber = &shipment.AWB
+			input.Carrier = shipment.CourierName
+			if input.Carrier == nil {
+				input.Carrier = &shipment.Carrier
+			}
+			input.TrackingURL = shipment.TrackingURL
+		}
+
+		_, err := h.orderRepo.UpdateFulfilment(ctx, order.ID, input)
+		var invalid *orders.InvalidFulfilmentTransitionError
+		if errors.As(err, &invalid) || errors.Is(err, orders.ErrOrderNotFulfillable) {
+			h.logger.Info("Tracking update ignored",
+				zap.String("order_id", order.ID.String()),
+				zap.String("awb", event.AWB),
+				zap.String("fulfilment_status", string(status)),
+				zap.Error(err),
+			)
+			return payments.OutcomeTransitionIgnored, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	h.logger.Info("Order fulfilment updated from tracking",
+		zap.String("order_id", order.ID.String()),
+		zap.String("awb", event.AWB),
+		zap.String("fulfilment_status", string(target)),
+	)
+
+	return payments.OutcomeSuccess, nil
+}
+
+// shipmentRequest builds the carrier booking for an order
+func (h *ShipmentHandler) shipmentRequest(c echo.Context, order *orders.Order, req CreateShipmentRequest) shipping.ShipmentRequest {
+	address := order.ShippingAddress
+	shipmentReq := shipping.ShipmentRequest{
+		OrderID:   order.ID.String(),
+		OrderDate: order.CreatedAt,
+		Address: shipping.Address{
+			Name:    address.Name,
+			Phone:   address.Phone,
+			Line1:   address.Line1,
+			Line2:   address.Line2,
+			City:    address.City,
+			State:   address.State,
+			Pincode: address.Pincode,
+			Country: address.Country,
+		},
+		COD:           order.Status == orders.OrderStatusCODPending,
+		SubtotalCents: order.AmountCents,
+		WeightGrams:   order.ShippingWeight,
+		LengthCm:      shipping.DefaultParcelLengthCm,
+		BreadthCm:     shipping.DefaultParcelBreadthCm,
+		HeightCm:      shipping.DefaultParcelHeightCm,
+	}
+
+	// Carriers notify the customer by email where they have one
+	if user, err := h.authRepo.GetUserByID(c.Request().Context(), order.UserID); err == nil {
+		shipmentReq.Address.Email = user.Email
+	} else {
+		h.logger.Warn("Failed to get customer email for shipment",
+			zap.String("order_id", order.ID.String()),
+			zap.Error(err),
+		)
+	}
+
+	items := 0
+	for _, item := range order.Items {
+		shipmentReq.Items = append(shipmentReq.Items, shipping.ShipmentItem{
+			Name:       item.Title,
+			SKU:        item.SKU,
+			Quantity:   item.Quantity,
+			PriceCents: item.PriceCents,
+			HSNCode:    item.HSNCode,
+		})
+		items += item.Quantity
+	}
+
+	// Orders placed before shipping was quoted have no recorded weight
+	if shipmentReq.WeightGrams <= 0 {
+		shipmentReq.WeightGrams = items * shipping.DefaultItemWeightGrams
+	}
+
+	if req.WeightGrams != nil {
+		shipmentReq.WeightGrams = *req.WeightGrams
+	}
+	if req.LengthCm != nil {
+		shipmentReq.LengthCm = *req.LengthCm
+	}
+	if req.BreadthCm != nil {
+		shipmentReq.BreadthCm = *req.BreadthCm
+	}
+	if req.HeightCm != nil {
+		shipmentReq.HeightCm = *req.HeightCm
+	}
+
+	return shipmentReq
+}
+
+// loadOrder fetches the order named in the path. On failure it writes the
+// response and returns a nil order.
+func (h *ShipmentHandler) loadOrder(c echo.Context) (*orders.Order, error) {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil, c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid order ID",
+		})
+	}
+
+	order, err := h.orderRepo.GetOrder(c.Request().Context(), orderID)
+	if err != nil {
+		if err.Error() == "order not found" {
+			return nil, c.JSON(http.StatusNotFound, map[string]string{
+				"error": "Order not found",
+			})
+		}
+		h.logger.Error("Failed to get order", zap.String("order_id", orderID.String()), zap.Error(err))
+		return nil, c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to get order",
+		})
+	}
+
+	return order, nil
+}
+
+// loadShipment fetches the shipment of the order named in the path. On
+// failure it writes the response and returns a nil shipment.
+func (h *ShipmentHandler) loadShipment(c echo.Context) (*shipping.Shipment, error) {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil, c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid order ID",
+		})
+	}
+
+	shipment, err := h.shipmentRepo.GetOrderShipment(c.Request().Context(), orderID)
+	if err != nil {
+		if errors.Is(err, shipping.ErrShipmentNotFound) {
+			return nil, c.JSON(http.StatusNotFound, map[string]string{
+				"error": "Shipment not found",
+			})
+		}
+		h.logger.Error("Failed to get shipment", zap.String("order_id", orderID.String()), zap.Error(err))
+		return nil, c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to get shipment",
+		})
+	}
+
+	return shipment, nil
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
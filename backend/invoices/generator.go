@@ -0,0 +1,265 @@
+package invoices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/ramniya/ramniya-backend/orders"
+	"github.com/ramniya/ramniya-backend/tax"
+	"github.com/ramniya/ramniya-backend/upload"
+	"go.uber.org/zap"
+)
+
+// ErrNotInvoiceable is returned for orders that have not been paid
+var ErrNotInvoiceable = errors.New("order has not been paid")
+
+// OrderStore is the subset of the order repository used by the generator
+type OrderStore interface {
+	GetOrder(ctx context.Context, orderID uuid.UUID) (*orders.Order, error)
+	GetRefund(ctx context.Context, refundID uuid.UUID) (*orders.Refund, error)
+}
+
+// GeneratorConfig controls invoice numbering and the seller details
+type GeneratorConfig struct {
+	Seller           Seller
+	InvoiceSeries    string // e.g. RC, giving RC/2026-27/00001
+	CreditNoteSeries string
+	BatchSize        int
+}
+
+// RunResult summarises a generator run
+type RunResult struct {
+	Invoices    int
+	CreditNotes int
+	Errors      int
+}
+
+// Generator issues invoices for paid orders and credit notes for their
+// refunds, and stores the rendered PDFs
+type Generator struct {
+	repo    *InvoiceRepository
+	orders  OrderStore
+	storage *upload.UploadService
+	taxCalc *tax.Calculator
+	logger  *zap.Logger
+	config  GeneratorConfig
+}
+
+// NewGenerator creates a new invoice generator. storage should be private:
+// invoices must not be served from the public uploads directory.
+func NewGenerator(repo *InvoiceRepository, orderStore OrderStore, storage *upload.UploadService, taxCalc *tax.Calculator, logger *zap.Logger, config GeneratorConfig) *Generator {
+	if config.InvoiceSeries == "" {
+		config.InvoiceSeries = "IN"
+	}
+	if config.CreditNoteSeries == "" {
+		config.CreditNoteSeries = "CN"
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 50
+	}
+
+	return &Generator{
+		repo:    repo,
+		orders:  orderStore,
+		storage: storage,
+		taxCalc: taxCalc,
+		logger:  logger,
+		config:  config,
+	}
+}
+
+// invoiceable reports whether an order has been paid, including orders that
+// were refunded since
+func invoiceable(order *orders.Order) bool {
+	switch order.Status {
+	case orders.OrderStatusPaid, orders.OrderStatusPartiallyRefunded, orders.OrderStatusRefunded:
+		return order.PaidAt != nil
+	}
+	return false
+}
+
+// InvoiceForOrder returns the order's invoice, issuing it first if needed.
+// Returns ErrNotInvoiceable if the order has not been paid.
+func (g *Generator) InvoiceForOrder(ctx context.Context, order *orders.Order) (*Invoice, error) {
+	invoice, err := g.repo.GetOrderInvoice(ctx, order.ID)
+	if err == nil {
+		return invoice, nil
+	}
+	if !errors.Is(err, ErrInvoiceNotFound) {
+		return nil, err
+	}
+
+	if !invoiceable(order) {
+		return nil, ErrNotInvoiceable
+	}
+
+	// Orders placed before GST was recorded are taxed from their items
+	breakdown := order.Tax
+	if breakdown == nil {
+		breakdown = g.taxCalc.Calculate(orders.TaxLines(order.Items, order.Discount), order.ShippingAddress.State)
+	}
+
+	invoice, err = g.repo.Issue(ctx, &Invoice{
+		OrderID:     order.ID,
+		Kind:        KindInvoice,
+		AmountCents: order.AmountCents,
+		TaxCents:    breakdown.TaxCents,
+		Tax:         breakdown,
+	}, g.config.InvoiceSeries, func(invoice *Invoice) error {
+		return g.store(invoice, document{
+			Kind:       KindInvoice,
+			Number:     invoice.Number,
+			IssuedAt:   invoice.IssuedAt,
+			Seller:     g.config.Seller,
+			Order:      order,
+			Tax:        breakdown,
+			TotalCents: order.AmountCents,
+		})
+	})
+	if errors.Is(err, ErrInvoiceExists) {
+		// Issued concurrently by another request or the background job
+		return g.repo.GetOrderInvoice(ctx, order.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	g.logger.Info("Invoice issued",
+		zap.String("order_id", order.ID.String()),
+		zap.String("number", invoice.Number),
+	)
+
+	return invoice, nil
+}
+
+// CreditNoteForRefund issues a credit note for a processed refund against the
+// order's invoice. The refund is split across the invoice lines in
+// proportion to their totals; any part beyond the taxed lines, such as a
+// refunded shipping or COD fee, is credited untaxed.
+func (g *Generator) CreditNoteForRefund(ctx context.Context, refund *orders.Refund) (*Invoice, error) {
+	if refund.Status != orders.RefundStatusProcessed {
+		return nil, fmt.Errorf("refund %s is not processed", refund.ID)
+	}
+
+	order, err := g.orders.GetOrder(ctx, refund.OrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	original, err := g.InvoiceForOrder(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+
+	breakdown := original.Tax.Scale(refund.AmountCents)
+	refundID := refund.ID
+
+	creditNote, err := g.repo.Issue(ctx, &Invoice{
+		OrderID:     order.ID,
+		RefundID:    &refundID,
+		Kind:        KindCreditNote,
+		AmountCents: refund.AmountCents,
+		TaxCents:    breakdown.TaxCents,
+		Tax:         breakdown,
+	}, g.config.CreditNoteSeries, func(invoice *Invoice) error {
+		return g.store(invoice, document{
+			Kind:       KindCreditNote,
+			Number:     invoice.Number,
+			IssuedAt:   invoice.IssuedAt,
+			Seller:     g.config.Seller,
+			Order:      order,
+			Reference:  fmt.Sprintf("Against invoice %s dated %s", original.Number, original.IssuedAt.In(istLocation).Format("02 Jan 2006")),
+			Tax:        breakdown,
+			TotalCents: refund.AmountCents,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	g.logger.Info("Credit note issued",
+		zap.String("order_id", order.ID.String()),
+		zap.String("refund_id", refund.ID.String()),
+		zap.String("number", creditNote.Number),
+	)
+
+	return creditNote, nil
+}
+
+// store renders a document and saves it under the financial year
+func (g *Generator) store(invoice *Invoice, doc document) error {
+	filename := strings.ReplaceAll(invoice.Number, "/", "-") + ".pdf"
+	result, err := g.storage.SaveBytes(invoice.FinancialYear+"/"+filename, render(doc))
+	if err != nil {
+		return fmt.Errorf("failed to store invoice: %w", err)
+	}
+	invoice.FilePath = result.Path
+	return nil
+}
+
+// PDF returns the stored PDF of an invoice or credit note
+func (g *Generator) PDF(invoice *Invoice) ([]byte, error) {
+	return g.storage.ReadFile(invoice.FilePath)
+}
+
+// Run issues invoices for paid orders and credit notes for processed refunds
+// that do not have one yet. Failures are logged and retried on the next run.
+func (g *Generator) Run(ctx context.Context) (*RunResult, error) {
+	result := &RunResult{}
+
+	orderIDs, err := g.repo.ListOrdersAwaitingInvoice(ctx, g.config.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	for _, orderID := range orderIDs {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+
+		order, err := g.orders.GetOrder(ctx, orderID)
+		if err == nil {
+			_, err = g.InvoiceForOrder(ctx, order)
+		}
+		if err != nil {
+			g.logger.Error("Failed to issue invoice",
+				zap.String("order_id", orderID.String()),
+				zap.Error(err),
+			)
+			result.Errors++
+			continue
+		}
+		result.Invoices++
+	}
+
+	refundIDs, err := g.repo.ListRefundsAwaitingCreditNote(ctx, g.config.BatchSize)
+	if err != nil {
+		return result, err
+	}
+	for _, refundID := range refundIDs {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+
+		refund, err := g.orders.GetRefund(ctx, refundID)
+		if err == nil {
+			_, err = g.CreditNoteForRefund(ctx, refund)
+		}
+		if errors.Is(err, ErrInvoiceExists) {
+			continue
+		}
+		if err != nil {
+			g.logger.Error("Failed to issue credit note",
+				zap.String("refund_id", refundID.String()),
+				zap.Error(err),
+			)
+			result.Errors++
+			continue
+		}
+		result.CreditNotes++
+	}
+
+	return result, nil
+}
@@ -0,0 +1,281 @@
+package invoices
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ramniya/ramniya-backend/orders"
+	"github.com/ramniya/ramniya-backend/tax"
+)
+
+// Kind is the type of a tax document
+type Kind string
+
+const (
+	KindInvoice    Kind = "invoice"
+	KindCreditNote Kind = "credit_note"
+)
+
+var (
+	ErrInvoiceNotFound = errors.New("invoice not found")
+	ErrInvoiceExists   = errors.New("invoice already issued")
+)
+
+// istLocation is India Standard Time, which decides invoice dates and the
+// financial year
+var istLocation = time.FixedZone("IST", 5*60*60+30*60)
+
+// Invoice is a tax invoice for a paid order or a credit note for one of its
+// refunds. Both share a table; credit notes carry the refund they reverse.
+type Invoice struct {
+	ID            uuid.UUID      `json:"id"`
+	OrderID       uuid.UUID      `json:"order_id"`
+	RefundID      *uuid.UUID     `json:"refund_id,omitempty"`
+	Kind          Kind           `json:"kind"`
+	Number        string         `json:"number"`
+	FinancialYear string         `json:"financial_year"`
+	AmountCents   int            `json:"amount_cents"`
+	TaxCents      int            `json:"tax_cents"`
+	Tax           *tax.Breakdown `json:"tax,omitempty"`
+	FilePath      string         `json:"-"`
+	IssuedAt      time.Time      `json:"issued_at"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+// FinancialYear returns the Indian financial year (April to March) a time
+// falls in, e.g. "2026-27"
+func FinancialYear(t time.Time) string {
+	t = t.In(istLocation)
+	start := t.Year()
+	if t.Month() < time.April {
+		start--
+	}
+	return fmt.Sprintf("%d-%02d", start, (start+1)%100)
+}
+
+const invoiceColumns = `id, order_id, refund_id, kind, number, financial_year, amount_cents, tax_cents,
+		       tax_breakdown, file_path, issued_at, created_at`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanInvoice(row rowScanner) (*Invoice, error) {
+	var invoice Invoice
+	var taxData []byte
+	err := row.Scan(
+		&invoice.ID, &invoice.OrderID, &invoice.RefundID, &invoice.Kind, &invoice.Number,
+		&invoice.FinancialYear, &invoice.AmountCents, &invoice.TaxCents, &taxData,
+		&invoice.FilePath, &invoice.IssuedAt, &invoice.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(taxData) > 0 {
+		if err := json.Unmarshal(taxData, &invoice.Tax); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal tax breakdown: %w", err)
+		}
+	}
+
+	return &invoice, nil
+}
+
+// InvoiceRepository handles invoice database operations
+type InvoiceRepository struct {
+	db *sql.DB
+}
+
+// NewInvoiceRepository creates a new invoice repository
+func NewInvoiceRepository(db *sql.DB) *InvoiceRepository {
+	return &InvoiceRepository{db: db}
+}
+
+// Issue numbers and records a document. The next number in the series for
+// the current financial year is taken inside the transaction, so numbers are
+// gapless: store is called with the numbered invoice to render and save the
+// PDF and must set FilePath, and if it fails the number is not used. Returns
+// ErrInvoiceExists if the order already has an invoice, or the refund a
+// credit note.
+func (r *InvoiceRepository) Issue(ctx context.Context, invoice *Invoice, series string, store func(invoice *Invoice) error) (*Invoice, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	invoice.IssuedAt = time.Now()
+	invoice.FinancialYear = FinancialYear(invoice.IssuedAt)
+
+	// The upsert locks the series row, so concurrent issues queue here
+	var number int
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO invoice_sequences (series, financial_year, last_number)
+		VALUES ($1, $2, 1)
+		ON CONFLICT (series, financial_year)
+		DO UPDATE SET last_number = invoice_sequences.last_number + 1
+		RETURNING last_number
+	`, series, invoice.FinancialYear).Scan(&number)
+	if err != nil {
+		return nil, fmt.Errorf("failed to allocate invoice number: %w", err)
+	}
+
+	var exists bool
+	if invoice.Kind == KindCreditNote {
+		err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM invoices WHERE refund_id = $1)", invoice.RefundID).Scan(&exists)
+	} else {
+		err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM invoices WHERE order_id = $1 AND kind = $2)", invoice.OrderID, KindInvoice).Scan(&exists)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to check existing invoice: %w", err)
+	}
+	if exists {
+		return nil, ErrInvoiceExists
+	}
+
+	invoice.Number = fmt.Sprintf("%s/%s/%05d", series, invoice.FinancialYear, number)
+	if err := store(invoice); err != nil {
+		return nil, err
+	}
+
+	var taxJSON []byte
+	if invoice.Tax != nil {
+		if taxJSON, err = json.Marshal(invoice.Tax); err != nil {
+			return nil, fmt.Errorf("failed to marshal tax breakdown: %w", err)
+		}
+	}
+
+	issued, err := scanInvoice(tx.QueryRowContext(ctx, `
+		INSERT INTO invoices (order_id, refund_id, kind, number, financial_year, amount_cents, tax_cents,
+		                      tax_breakdown, file_path, issued_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING `+invoiceColumns,
+		invoice.OrderID, invoice.RefundID, invoice.Kind, invoice.Number, invoice.FinancialYear,
+		invoice.AmountCents, invoice.TaxCents, taxJSON, invoice.FilePath, invoice.IssuedAt,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create invoice: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return issued, nil
+}
+
+// GetInvoice retrieves an invoice or credit note by ID
+func (r *InvoiceRepository) GetInvoice(ctx context.Context, id uuid.UUID) (*Invoice, error) {
+	invoice, err := scanInvoice(r.db.QueryRowContext(ctx, `
+		SELECT `+invoiceColumns+`
+		FROM invoices
+		WHERE id = $1
+	`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrInvoiceNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoice: %w", err)
+	}
+	return invoice, nil
+}
+
+// GetOrderInvoice retrieves the tax invoice of an order
+func (r *InvoiceRepository) GetOrderInvoice(ctx context.Context, orderID uuid.UUID) (*Invoice, error) {
+	invoice, err := scanInvoice(r.db.QueryRowContext(ctx, `
+		SELECT `+invoiceColumns+`
+		FROM invoices
+		WHERE order_id = $1 AND kind = $2
+	`, orderID, KindInvoice))
+	if err == sql.ErrNoRows {
+		return nil, ErrInvoiceNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoice: %w", err)
+	}
+	return invoice, nil
+}
+
+// ListOrderInvoices returns the invoice and credit notes of an order, oldest
+// first
+func (r *InvoiceRepository) ListOrderInvoices(ctx context.Context, orderID uuid.UUID) ([]Invoice, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+invoiceColumns+`
+		FROM invoices
+		WHERE order_id = $1
+		ORDER BY issued_at
+	`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invoices: %w", err)
+	}
+	defer rows.Close()
+
+	invoices := []Invoice{}
+	for rows.Next() {
+		invoice, err := scanInvoice(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan invoice: %w", err)
+		}
+		invoices = append(invoices, *invoice)
+	}
+
+	return invoices, rows.Err()
+}
+
+// ListOrdersAwaitingInvoice returns paid orders that have no invoice yet,
+// oldest payment first
+func (r *InvoiceRepository) ListOrdersAwaitingInvoice(ctx context.Context, limit int) ([]uuid.UUID, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT o.id
+		FROM orders o
+		WHERE o.status IN ($1, $2, $3)
+		  AND o.paid_at IS NOT NULL
+		  AND NOT EXISTS (SELECT 1 FROM invoices i WHERE i.order_id = o.id AND i.kind = $4)
+		ORDER BY o.paid_at
+		LIMIT $5
+	`, orders.OrderStatusPaid, orders.OrderStatusPartiallyRefunded, orders.OrderStatusRefunded, KindInvoice, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list orders awaiting invoice: %w", err)
+	}
+	defer rows.Close()
+
+	return scanIDs(rows)
+}
+
+// ListRefundsAwaitingCreditNote returns processed refunds of invoiced orders
+// that have no credit note yet, oldest first
+func (r *InvoiceRepository) ListRefundsAwaitingCreditNote(ctx context.Context, limit int) ([]uuid.UUID, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT rf.id
+		FROM refunds rf
+		JOIN invoices i ON i.order_id = rf.order_id AND i.kind = $1
+		WHERE rf.status = $2
+		  AND NOT EXISTS (SELECT 1 FROM invoices cn WHERE cn.refund_id = rf.id)
+		ORDER BY rf.processed_at
+		LIMIT $3
+	`, KindInvoice, orders.RefundStatusProcessed, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list refunds awaiting credit note: %w", err)
+	}
+	defer rows.Close()
+
+	return scanIDs(rows)
+}
+
+func scanIDs(rows *sql.Rows) ([]uuid.UUID, error) {
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
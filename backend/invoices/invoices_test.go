@@ -0,0 +1,139 @@
+package invoices
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ramniya/ramniya-backend/orders"
+	"github.com/ramniya/ramniya-backend/tax"
+)
+
+func TestFinancialYear(t *testing.T) {
+	tests := map[string]string{
+		"2026-03-31T18:29:59Z": "2025-26", // 23:59 IST on 31 March
+		"2026-03-31T18:30:00Z": "2026-27", // Midnight IST on 1 April
+		"2026-10-16T10:00:00Z": "2026-27",
+		"2099-12-31T00:00:00Z": "2099-00",
+	}
+	for at, want := range tests {
+		ts, _ := time.Parse(time.RFC3339, at)
+		if got := FinancialYear(ts); got != want {
+			t.Errorf("FinancialYear(%s) = %s, want %s", at, got, want)
+		}
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	cents := map[int]string{
+		0:          "0.00",
+		99:         "0.99",
+		123456:     "1,234.56",
+		10500000:   "1,05,000.00",
+		1234567890: "1,23,45,678.90",
+	}
+	for in, want := range cents {
+		if got := formatCents(in); got != want {
+			t.Errorf("formatCents(%d) = %s, want %s", in, got, want)
+		}
+	}
+
+	rates := map[int]string{0: "0%", 25: "0.25%", 300: "3%", 1800: "18%"}
+	for in, want := range rates {
+		if got := formatRate(in); got != want {
+			t.Errorf("formatRate(%d) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	calc := tax.NewCalculator(tax.Config{SellerState: "Karnataka", PricesIncludeTax: true, DefaultRateBps: 500, DefaultHSN: "5007"})
+	order := &orders.Order{
+		ID: uuid.New(),
+		ShippingAddress: orders.ShippingAddress{
+			Name: "Asha Rao", Line1: "12 (Old) MG Road", City: "Chennai", State: "Tamil Nadu", Pincode: "600001",
+		},
+		ShippingCents: 6900,
+		CODFeeCents:   4900,
+	}
+	// Enough lines to spill onto a second page
+	for i := 0; i < 60; i++ {
+		order.Items = append(order.Items, orders.OrderItem{Title: fmt.Sprintf("Kanjivaram silk saree %d", i), Quantity: 1, PriceCents: 105000})
+	}
+	breakdown := calc.Calculate(orders.TaxLines(order.Items, nil), order.ShippingAddress.State)
+
+	pdf := render(document{
+		Kind:       KindInvoice,
+		Number:     "RC/2026-27/00001",
+		IssuedAt:   time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC),
+		Seller:     Seller{Name: "Ramniya Creations", Address: "1 Silk Street, Bengaluru 560001", GSTIN: "29ABCDE1234F1Z5", State: "Karnataka"},
+		Order:      order,
+		Tax:        breakdown,
+		TotalCents: breakdown.TotalCents + order.ShippingCents + order.CODFeeCents,
+	})
+
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Fatal("Output is not a complete PDF")
+	}
+	if !bytes.Contains(pdf, []byte("/Count 2")) {
+		t.Error("Expected the line items to span two pages")
+	}
+	for _, text := range []string{"(TAX INVOICE)", "(Invoice No: RC/2026-27/00001)", "(IGST)", "(Place of supply: Tamil Nadu \\(33\\))", "(Shipping \\(not taxable\\))", "(Cash on delivery fee)"} {
+		if !bytes.Contains(pdf, []byte(text)) {
+			t.Errorf("PDF does not contain %s", text)
+		}
+	}
+
+	// Every xref entry must point at the start of its object
+	xrefAt, err := strconv.Atoi(string(regexp.MustCompile(`startxref\n(\d+)`).FindSubmatch(pdf)[1]))
+	if err != nil || !bytes.HasPrefix(pdf[xrefAt:], []byte("xref")) {
+		t.Fatalf("startxref does not point at the xref table")
+	}
+	entries := regexp.MustCompile(`(\d{10}) 00000 n`).FindAllSubmatch(pdf[xrefAt:], -1)
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(string(entry[1]))
+		if !bytes.HasPrefix(pdf[offset:], []byte(fmt.Sprintf("%d 0 obj", i+1))) {
+			t.Errorf("xref entry %d points at the wrong offset", i+1)
+		}
+	}
+}
+
+func TestRenderTaxedCharges(t *testing.T) {
+	calc := tax.NewCalculator(tax.Config{SellerState: "Karnataka", PricesIncludeTax: true, DefaultRateBps: 500, DefaultHSN: "5007"})
+	order := &orders.Order{
+		ID:            uuid.New(),
+		Items:         []orders.OrderItem{{Title: "Kanjivaram silk saree", Quantity: 1, PriceCents: 105000}},
+		ShippingCents: 6900,
+		CODFeeCents:   4900,
+	}
+	lines := orders.TaxLines(order.Items, nil)
+	lines = append(lines,
+		calc.ChargeLine(lines, orders.ChargeShipping, order.ShippingCents),
+		calc.ChargeLine(lines, orders.ChargeCODFee, order.CODFeeCents),
+	)
+	breakdown := calc.Calculate(lines, "Karnataka")
+
+	pdf := render(document{
+		Kind:       KindInvoice,
+		Number:     "RC/2026-27/00002",
+		IssuedAt:   time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC),
+		Seller:     Seller{Name: "Ramniya Creations", Address: "1 Silk Street, Bengaluru 560001", State: "Karnataka"},
+		Order:      order,
+		Tax:        breakdown,
+		TotalCents: breakdown.TotalCents,
+	})
+
+	// Taxed charges are line items, not untaxed totals
+	for _, text := range []string{"(Shipping)", "(Cash on delivery fee)", "(5%)"} {
+		if !bytes.Contains(pdf, []byte(text)) {
+			t.Errorf("PDF does not contain %s", text)
+		}
+	}
+	if bytes.Contains(pdf, []byte("not taxable")) {
+		t.Error("Expected no untaxed charges")
+	}
+}
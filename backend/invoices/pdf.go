@@ -0,0 +1,172 @@
+package invoices
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// A4 page size in points
+const (
+	pageWidth  = 595.0
+	pageHeight = 842.0
+)
+
+// pdfWriter builds a small PDF using the standard Helvetica fonts, which every
+// viewer provides, so no font files need to be embedded. Coordinates are in
+// points measured from the top left of the page.
+type pdfWriter struct {
+	pages []*bytes.Buffer
+	page  *bytes.Buffer
+}
+
+func newPDFWriter() *pdfWriter {
+	w := &pdfWriter{}
+	w.addPage()
+	return w
+}
+
+func (w *pdfWriter) addPage() {
+	w.page = &bytes.Buffer{}
+	w.pages = append(w.pages, w.page)
+	w.page.WriteString("0.5 w\n")
+}
+
+// text draws s with its left edge at x and baseline at y
+func (w *pdfWriter) text(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(w.page, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, pageHeight-y, pdfEscape(s))
+}
+
+// textRight draws s with its right edge at x
+func (w *pdfWriter) textRight(x, y, size float64, bold bool, s string) {
+	w.text(x-textWidth(s, size, bold), y, size, bold, s)
+}
+
+// line draws a straight line
+func (w *pdfWriter) line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(w.page, "%.2f %.2f m %.2f %.2f l S\n", x1, pageHeight-y1, x2, pageHeight-y2)
+}
+
+// bytes assembles the document
+func (w *pdfWriter) bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n")
+
+	// Objects 1-4 are fixed; each page adds a page and a content object
+	kids := make([]string, len(w.pages))
+	for i := range w.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(w.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, page := range w.pages {
+		object(fmt.Sprintf(
+			"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, 6+2*i,
+		))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return out.Bytes()
+}
+
+// pdfEscape escapes a string for a PDF literal. Characters outside printable
+// ASCII are replaced, since the standard fonts cannot show them.
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range pdfText(s) {
+		switch r {
+		case '\\', '(', ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// pdfText maps s to the characters the standard fonts can draw
+func pdfText(s string) string {
+	s = strings.ReplaceAll(s, "₹", "Rs.")
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 32 && r <= 126:
+			return r
+		case r == '\t' || r == '\n':
+			return ' '
+		case r == '‘' || r == '’':
+			return '\''
+		case r == '“' || r == '”':
+			return '"'
+		case r == '–' || r == '—':
+			return '-'
+		}
+		return '?'
+	}, s)
+}
+
+// textWidth returns the width of s in points
+func textWidth(s string, size float64, bold bool) float64 {
+	widths := &helveticaWidths
+	if bold {
+		widths = &helveticaBoldWidths
+	}
+	total := 0
+	for _, r := range pdfText(s) {
+		total += widths[r-32]
+	}
+	return float64(total) * size / 1000
+}
+
+// fitText shortens s with an ellipsis so it fits within maxWidth points
+func fitText(s string, maxWidth, size float64, bold bool) string {
+	s = pdfText(s)
+	if textWidth(s, size, bold) <= maxWidth {
+		return s
+	}
+	for len(s) > 0 && textWidth(s+"...", size, bold) > maxWidth {
+		s = s[:len(s)-1]
+	}
+	return strings.TrimSpace(s) + "..."
+}
+
+// Glyph widths of printable ASCII in 1/1000 em, from the standard font metrics
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var helveticaBoldWidths = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
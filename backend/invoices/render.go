@@ -0,0 +1,315 @@
+package invoices
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ramniya/ramniya-backend/orders"
+	"github.com/ramniya/ramniya-backend/tax"
+)
+
+// Seller is the business printed on invoices
+type Seller struct {
+	Name    string
+	Address string
+	GSTIN   string
+	State   string
+}
+
+// document holds everything printed on an invoice or credit note
+type document struct {
+	Kind       Kind
+	Number     string
+	IssuedAt   time.Time
+	Seller     Seller
+	Order      *orders.Order
+	Reference  string // Original invoice, for credit notes
+	Tax        *tax.Breakdown
+	TotalCents int // Tax.TotalCents plus any untaxed charges
+}
+
+// Page layout in points
+const (
+	marginLeft   = 40.0
+	marginRight  = pageWidth - 40
+	pageBottom   = 770.0
+	rowHeight    = 14.0
+	bodySize     = 9.0
+	tableSize    = 8.0
+	itemColWidth = 150.0
+)
+
+// render draws the document as a PDF
+func render(doc document) []byte {
+	w := newPDFWriter()
+
+	title := "TAX INVOICE"
+	numberLabel := "Invoice No"
+	if doc.Kind == KindCreditNote {
+		title = "CREDIT NOTE"
+		numberLabel = "Credit Note No"
+	}
+
+	// Heading and document details
+	w.text(marginLeft, 55, 18, true, title)
+	y := 45.0
+	for _, detail := range []string{
+		numberLabel + ": " + doc.Number,
+		"Date: " + doc.IssuedAt.In(istLocation).Format("02 Jan 2006"),
+		"Order: " + doc.Order.ID.String(),
+		doc.Reference,
+	} {
+		if detail != "" {
+			w.textRight(marginRight, y, bodySize, false, detail)
+			y += 12
+		}
+	}
+
+	// Seller and customer
+	y = 100
+	w.text(marginLeft, y, 11, true, doc.Seller.Name)
+	sellerY := y + 14
+	for _, line := range wrapText(doc.Seller.Address, 240, bodySize, false) {
+		w.text(marginLeft, sellerY, bodySize, false, line)
+		sellerY += 12
+	}
+	if doc.Seller.GSTIN != "" {
+		w.text(marginLeft, sellerY, bodySize, false, "GSTIN: "+doc.Seller.GSTIN)
+		sellerY += 12
+	}
+	w.text(marginLeft, sellerY, bodySize, false, "State: "+stateLabel(doc.Seller.State))
+	sellerY += 12
+
+	address := doc.Order.ShippingAddress
+	buyerX := 320.0
+	w.text(buyerX, y, 11, true, "Bill to / Ship to")
+	buyerY := y + 14
+	for _, line := range []string{
+		address.Name,
+		address.Line1,
+		address.Line2,
+		strings.TrimSpace(address.City + " - " + address.Pincode),
+		address.State,
+		address.Phone,
+		"Place of supply: " + stateLabel(address.State),
+	} {
+		if line == "" {
+			continue
+		}
+		w.text(buyerX, buyerY, bodySize, false, fitText(line, marginRight-buyerX, bodySize, false))
+		buyerY += 12
+	}
+
+	y = sellerY
+	if buyerY > y {
+		y = buyerY
+	}
+	y += 16
+
+	// Line items
+	columns := taxColumns(doc.Tax.Interstate)
+	y = drawTableHeader(w, y, columns)
+	for i, line := range doc.Tax.Lines {
+		if y > pageBottom {
+			w.addPage()
+			y = drawTableHeader(w, 50, columns)
+		}
+
+		var item orders.OrderItem
+		if line.Charge != "" {
+			item.Title = chargeLabel(line.Charge)
+		} else if line.Line < len(doc.Order.Items) {
+			item = doc.Order.Items[line.Line]
+		}
+		quantity := ""
+		if doc.Kind == KindInvoice && line.Charge == "" {
+			quantity = strconv.Itoa(item.Quantity)
+		}
+
+		w.text(marginLeft, y, tableSize, false, strconv.Itoa(i+1))
+		w.text(60, y, tableSize, false, fitText(item.Title, itemColWidth, tableSize, false))
+		w.text(215, y, tableSize, false, line.HSNCode)
+		w.textRight(285, y, tableSize, false, quantity)
+		w.textRight(325, y, tableSize, false, formatRate(line.RateBps))
+		w.textRight(385, y, tableSize, false, formatCents(line.TaxableCents))
+		if doc.Tax.Interstate {
+			w.textRight(495, y, tableSize, false, formatCents(line.IGSTCents))
+		} else {
+			w.textRight(440, y, tableSize, false, formatCents(line.CGSTCents))
+			w.textRight(495, y, tableSize, false, formatCents(line.SGSTCents))
+		}
+		w.textRight(marginRight, y, tableSize, false, formatCents(line.TotalCents))
+		y += rowHeight
+	}
+	w.line(marginLeft, y-rowHeight+4, marginRight, y-rowHeight+4)
+
+	// Totals
+	if y > pageBottom-90 {
+		w.addPage()
+		y = 50
+	}
+	y += 6
+	totals := [][2]string{{"Taxable value", formatCents(doc.Tax.TaxableCents)}}
+	if doc.Tax.Interstate {
+		totals = append(totals, [2]string{"IGST", formatCents(doc.Tax.IGSTCents)})
+	} else {
+		totals = append(totals,
+			[2]string{"CGST", formatCents(doc.Tax.CGSTCents)},
+			[2]string{"SGST", formatCents(doc.Tax.SGSTCents)},
+		)
+	}
+	// Charges outside the taxed lines. Orders placed before shipping and the
+	// COD fee were taxed list them here on the invoice; a credit note only
+	// knows how much was refunded beyond the taxed lines.
+	other := doc.TotalCents - doc.Tax.TotalCents
+	if doc.Kind == KindInvoice {
+		if doc.Order.ShippingCents > 0 && !doc.Tax.HasCharge(orders.ChargeShipping) {
+			totals = append(totals, [2]string{"Shipping (not taxable)", formatCents(doc.Order.ShippingCents)})
+			other -= doc.Order.ShippingCents
+		}
+		if doc.Order.CODFeeCents > 0 && !doc.Tax.HasCharge(orders.ChargeCODFee) {
+			totals = append(totals, [2]string{"Cash on delivery fee", formatCents(doc.Order.CODFeeCents)})
+			other -= doc.Order.CODFeeCents
+		}
+	}
+	if other > 0 {
+		totals = append(totals, [2]string{"Other charges (not taxable)", formatCents(other)})
+	}
+	for _, total := range totals {
+		w.text(360, y, bodySize, false, total[0])
+		w.textRight(marginRight, y, bodySize, false, total[1])
+		y += 13
+	}
+	w.line(360, y-9, marginRight, y-9)
+	y += 4
+	totalLabel := "Total (INR)"
+	if doc.Kind == KindCreditNote {
+		totalLabel = "Amount credited (INR)"
+	}
+	w.text(360, y, 10, true, totalLabel)
+	w.textRight(marginRight, y, 10, true, formatCents(doc.TotalCents))
+
+	// Notes
+	y += 24
+	notes := []string{}
+	if doc.Tax.PricesIncludeTax {
+		notes = append(notes, "Prices are inclusive of GST.")
+	}
+	if doc.Order.CouponCode != nil && doc.Order.DiscountCents > 0 {
+		notes = append(notes, fmt.Sprintf("Amounts are net of a discount of Rs. %s (coupon %s).",
+			formatCents(doc.Order.DiscountCents), *doc.Order.CouponCode))
+	}
+	for _, note := range notes {
+		w.text(marginLeft, y, tableSize, false, note)
+		y += 11
+	}
+
+	w.text(marginLeft, 805, tableSize, false, "This is a computer generated document and does not require a signature.")
+
+	return w.bytes()
+}
+
+// chargeLabel names a taxed fee in the line items
+func chargeLabel(charge string) string {
+	switch charge {
+	case orders.ChargeShipping:
+		return "Shipping"
+	case orders.ChargeCODFee:
+		return "Cash on delivery fee"
+	}
+	return charge
+}
+
+// column is a right-aligned table column
+type column struct {
+	label string
+	right float64
+}
+
+// taxColumns returns the tax columns: IGST for interstate supply, otherwise
+// CGST and SGST
+func taxColumns(interstate bool) []column {
+	if interstate {
+		return []column{{"IGST", 495}}
+	}
+	return []column{{"CGST", 440}, {"SGST", 495}}
+}
+
+// drawTableHeader draws the line item headings at y and returns the y of the
+// first row
+func drawTableHeader(w *pdfWriter, y float64, taxCols []column) float64 {
+	w.line(marginLeft, y-10, marginRight, y-10)
+	w.text(marginLeft, y, tableSize, true, "#")
+	w.text(60, y, tableSize, true, "Item")
+	w.text(215, y, tableSize, true, "HSN")
+	w.textRight(285, y, tableSize, true, "Qty")
+	w.textRight(325, y, tableSize, true, "GST")
+	w.textRight(385, y, tableSize, true, "Taxable")
+	for _, col := range taxCols {
+		w.textRight(col.right, y, tableSize, true, col.label)
+	}
+	w.textRight(marginRight, y, tableSize, true, "Total")
+	w.line(marginLeft, y+5, marginRight, y+5)
+	return y + rowHeight + 4
+}
+
+// stateLabel prints a state with its GST state code
+func stateLabel(state string) string {
+	if code := tax.StateCode(state); code != "" {
+		return fmt.Sprintf("%s (%s)", state, code)
+	}
+	return state
+}
+
+// formatRate prints a rate in basis points as a percentage
+func formatRate(rateBps int) string {
+	if rateBps%100 == 0 {
+		return fmt.Sprintf("%d%%", rateBps/100)
+	}
+	return strings.TrimRight(fmt.Sprintf("%.2f", float64(rateBps)/100), "0") + "%"
+}
+
+// formatCents prints paise as rupees with Indian digit grouping, e.g.
+// 10500000 as 1,05,000.00
+func formatCents(cents int) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	rupees := strconv.Itoa(cents / 100)
+	if len(rupees) > 3 {
+		head, tail := rupees[:len(rupees)-3], rupees[len(rupees)-3:]
+		var groups []string
+		for len(head) > 2 {
+			groups = append([]string{head[len(head)-2:]}, groups...)
+			head = head[:len(head)-2]
+		}
+		groups = append([]string{head}, groups...)
+		rupees = strings.Join(groups, ",") + "," + tail
+	}
+	return fmt.Sprintf("%s%s.%02d", sign, rupees, cents%100)
+}
+
+// wrapText breaks s into lines no wider than maxWidth points
+func wrapText(s string, maxWidth, size float64, bold bool) []string {
+	var lines []string
+	current := ""
+	for _, word := range strings.Fields(pdfText(s)) {
+		candidate := word
+		if current != "" {
+			candidate = current + " " + word
+		}
+		if current != "" && textWidth(candidate, size, bold) > maxWidth {
+			lines = append(lines, current)
+			candidate = word
+		}
+		current = candidate
+	}
+	if current != "" {
+		lines = append(lines, current)
+	}
+	return lines
+}
@@ -0,0 +1,102 @@
+package jobs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Job is a unit of background work run on a fixed interval
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Scheduler runs registered jobs periodically until stopped
+type Scheduler struct {
+	jobs   []Job
+	logger *zap.Logger
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewScheduler creates a new job scheduler
+func NewScheduler(logger *zap.Logger) *Scheduler {
+	return &Scheduler{
+		logger: logger,
+	}
+}
+
+// Add registers a job. Jobs must be added before Start is called.
+func (s *Scheduler) Add(job Job) {
+	s.jobs = append(s.jobs, job)
+}
+
+// Start launches every registered job in its own goroutine
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(ctx, job)
+
+		s.logger.Info("Background job started",
+			zap.String("job", job.Name),
+			zap.Duration("interval", job.Interval),
+		)
+	}
+}
+
+// Stop cancels running jobs and waits for them to return
+func (s *Scheduler) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.runOnce(ctx, job)
+		}
+	}
+}
+
+func (s *Scheduler) runOnce(ctx context.Context, job Job) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Error("Background job panicked",
+				zap.String("job", job.Name),
+				zap.Any("panic", r),
+			)
+		}
+	}()
+
+	start := time.Now()
+	if err := job.Run(ctx); err != nil {
+		s.logger.Error("Background job failed",
+			zap.String("job", job.Name),
+			zap.Error(err),
+		)
+		return
+	}
+
+	s.logger.Debug("Background job completed",
+		zap.String("job", job.Name),
+		zap.Duration("duration", time.Since(start)),
+	)
+}
@@ -42,7 +42,8 @@ func NewTokenService(secret string, accessExpiry, refreshExpiry time.Duration) *
 	}
 }
 
-// GenerateAccessToken generates a new access token
+// GenerateAccessToken generates a new access token with a random jti so it
+// can be revoked individually
 func (s *TokenService) GenerateAccessToken(userID uuid.UUID, email string) (string, time.Time, error) {
 	expiresAt := time.Now().Add(s.accessTokenExpiry)
 
@@ -51,6 +52,7 @@ func (s *TokenService) GenerateAccessToken(userID uuid.UUID, email string) (stri
 		Email:   email,
 		Purpose: PurposeAccess,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
@@ -68,8 +70,9 @@ func (s *TokenService) GenerateAccessToken(userID uuid.UUID, email string) (stri
 	return tokenString, expiresAt, nil
 }
 
-// GenerateRefreshToken generates a new refresh token
-func (s *TokenService) GenerateRefreshToken(userID uuid.UUID, email string) (string, time.Time, error) {
+// GenerateRefreshToken generates a new refresh token. tokenID is stored as the
+// jti claim so the token can be tracked and rotated server-side.
+func (s *TokenService) GenerateRefreshToken(userID uuid.UUID, email string, tokenID uuid.UUID) (string, time.Time, error) {
 	expiresAt := time.Now().Add(s.refreshTokenExpiry)
 
 	claims := Claims{
@@ -77,6 +80,7 @@ func (s *TokenService) GenerateRefreshToken(userID uuid.UUID, email string) (str
 		Email:   email,
 		Purpose: PurposeRefresh,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID.String(),
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
@@ -120,8 +124,9 @@ func (s *TokenService) GenerateEmailVerificationToken(userID uuid.UUID, email st
 	return tokenString, nil
 }
 
-// GeneratePasswordResetToken generates a token for password reset
-func (s *TokenService) GeneratePasswordResetToken(userID uuid.UUID, email string) (string, error) {
+// GeneratePasswordResetToken generates a token for password reset. tokenID is
+// stored as the jti claim so the token can only be redeemed once.
+func (s *TokenService) GeneratePasswordResetToken(userID uuid.UUID, email string, tokenID uuid.UUID) (string, time.Time, error) {
 	expiresAt := time.Now().Add(1 * time.Hour) // 1 hour expiry for password reset
 
 	claims := Claims{
@@ -129,6 +134,7 @@ func (s *TokenService) GeneratePasswordResetToken(userID uuid.UUID, email string
 		Email:   email,
 		Purpose: PurposeResetPassword,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID.String(),
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
@@ -140,10 +146,10 @@ func (s *TokenService) GeneratePasswordResetToken(userID uuid.UUID, email string
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err := token.SignedString([]byte(s.secret))
 	if err != nil {
-		return "", fmt.Errorf("failed to sign token: %w", err)
+		return "", time.Time{}, fmt.Errorf("failed to sign token: %w", err)
 	}
 
-	return tokenString, nil
+	return tokenString, expiresAt, nil
 }
 
 // VerifyToken verifies and parses a JWT token
@@ -182,3 +188,11 @@ func (s *TokenService) VerifyToken(tokenString string, expectedPurpose TokenPurp
 func (c *Claims) GetUserID() (uuid.UUID, error) {
 	return uuid.Parse(c.UserID)
 }
+
+// GetTokenID extracts the token ID (jti) from claims
+func (c *Claims) GetTokenID() (uuid.UUID, error) {
+	if c.ID == "" {
+		return uuid.Nil, fmt.Errorf("token has no id")
+	}
+	return uuid.Parse(c.ID)
+}
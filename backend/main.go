@@ -3,9 +3,11 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -13,18 +15,27 @@ import (
 	echomiddleware "github.com/labstack/echo/v4/middleware"
 	"github.com/ramniya/ramniya-backend/auth"
 	"github.com/ramniya/ramniya-backend/cache"
+	"github.com/ramniya/ramniya-backend/cart"
 	"github.com/ramniya/ramniya-backend/config"
 	"github.com/ramniya/ramniya-backend/database"
 	"github.com/ramniya/ramniya-backend/email"
 	"github.com/ramniya/ramniya-backend/handlers"
+	"github.com/ramniya/ramniya-backend/invoices"
+	"github.com/ramniya/ramniya-backend/jobs"
 	"github.com/ramniya/ramniya-backend/jwt"
 	"github.com/ramniya/ramniya-backend/logger"
 	"github.com/ramniya/ramniya-backend/middleware"
 	"github.com/ramniya/ramniya-backend/migrate"
+	"github.com/ramniya/ramniya-backend/notifications"
 	"github.com/ramniya/ramniya-backend/oauth"
 	"github.com/ramniya/ramniya-backend/orders"
+	"github.com/ramniya/ramniya-backend/payments"
 	"github.com/ramniya/ramniya-backend/products"
+	"github.com/ramniya/ramniya-backend/promotions"
 	"github.com/ramniya/ramniya-backend/razorpay"
+	"github.com/ramniya/ramniya-backend/shipping"
+	"github.com/ramniya/ramniya-backend/stripe"
+	"github.com/ramniya/ramniya-backend/tax"
 	"github.com/ramniya/ramniya-backend/upload"
 	"go.uber.org/zap"
 )
@@ -76,6 +87,11 @@ func main() {
 	authRepo := auth.NewAuthRepository(database.DB)
 	productRepo := products.NewProductRepository(database.DB)
 	orderRepo := orders.NewOrderRepository(database.DB)
+	cartRepo := cart.NewCartRepository(database.DB)
+	couponRepo := promotions.NewCouponRepository(database.DB)
+	invoiceRepo := invoices.NewInvoiceRepository(database.DB)
+	shipmentRepo := shipping.NewShipmentRepository(database.DB)
+	orderRepo.SetReservationTTL(time.Duration(cfg.StockReservationMinutes) * time.Minute)
 
 	// Initialize JWT token service
 	tokenService := jwt.NewTokenService(
@@ -84,18 +100,47 @@ func main() {
 		30*24*time.Hour, // 30 days for refresh token
 	)
 
+	// Initialize token revocation (Redis denylist with Postgres fallback)
+	tokenRevoker := auth.NewTokenRevoker(authRepo, redisClient, logger.Log)
+
 	// Initialize email sender
+	emailRenderer, err := email.NewRenderer(cfg.EmailTemplateDir)
+	if err != nil {
+		logger.Fatal("Failed to load email templates", zap.Error(err))
+	}
+
+	emailHeaders := email.Headers{
+		ReplyTo:         cfg.EmailReplyTo,
+		ListUnsubscribe: cfg.EmailListUnsubscribe,
+	}
+	emailTimeout := time.Duration(cfg.EmailTimeoutSeconds) * time.Second
+
 	var emailSender email.EmailSender
-	if cfg.SMTPUsername != "" && cfg.SMTPPassword != "" {
+	switch cfg.EmailTransport {
+	case "smtp":
 		emailSender = email.NewSMTPEmailSender(email.SMTPConfig{
 			Host:     cfg.SMTPHost,
 			Port:     cfg.SMTPPort,
 			Username: cfg.SMTPUsername,
 			Password: cfg.SMTPPassword,
 			From:     cfg.SMTPFrom,
+			Security: email.SMTPSecurity(cfg.SMTPSecurity),
+			Timeout:  emailTimeout,
+			// One connection per outbox worker
+			PoolSize: cfg.EmailWorkers,
+			Headers:  emailHeaders,
 		}, logger.Log)
 		logger.Info("SMTP email sender initialized")
-	} else {
+	case "api":
+		emailSender = email.NewAPIEmailSender(email.APIConfig{
+			URL:     cfg.EmailAPIURL,
+			Key:     cfg.EmailAPIKey,
+			From:    cfg.SMTPFrom,
+			Timeout: emailTimeout,
+			Headers: emailHeaders,
+		}, logger.Log)
+		logger.Info("HTTP API email sender initialized")
+	default:
 		fileEmailSender, err := email.NewFileEmailSender("./dev-emails", logger.Log)
 		if err != nil {
 			logger.Fatal("Failed to create file email sender", zap.Error(err))
@@ -104,6 +149,13 @@ func main() {
 		logger.Warn("Using file-based email sender (dev mode) - emails will be written to ./dev-emails/")
 	}
 
+	// Emails are queued in the outbox and sent by the send_emails job
+	emailOutbox := email.NewOutboxRepository(database.DB)
+	emailWorker := email.NewWorker(emailOutbox, emailRenderer, emailSender, logger.Log, email.WorkerConfig{
+		Workers:     cfg.EmailWorkers,
+		MaxAttempts: cfg.EmailMaxAttempts,
+	})
+
 	// Initialize OAuth service
 	var oauthService *oauth.GoogleOAuthService
 	//oauthService := oauth.NewGoogleOAuthService(
@@ -137,17 +189,122 @@ func main() {
 		zap.String("environment", cfg.Environment),
 	)
 
-	// Initialize Razorpay service
-	var razorpayService *razorpay.RazorpayService
-	if cfg.RazorpayKeyID != "" && cfg.RazorpayKeySecret != "" {
-		razorpayService = razorpay.NewRazorpayService(razorpay.RazorpayConfig{
+	// Invoices are kept outside the public uploads directory
+	invoiceDir := "./storage/invoices"
+	if cfg.IsProduction() {
+		invoiceDir = "/var/www/ramniya/invoices"
+	}
+	invoiceStorage, err := upload.NewUploadService(invoiceDir, logger.Log)
+	if err != nil {
+		logger.Fatal("Failed to create invoice storage", zap.Error(err))
+	}
+
+	// Initialize payment gateways. Orders keep the gateway they were placed
+	// with, so every configured gateway stays available for webhooks and refunds.
+	var configuredGateways []payments.Gateway
+	if cfg.HasRazorpay() {
+		razorpayService := razorpay.NewRazorpayService(razorpay.RazorpayConfig{
 			KeyID:     cfg.RazorpayKeyID,
 			KeySecret: cfg.RazorpayKeySecret,
+			BaseURL:   cfg.RazorpayAPIURL,
+		}, logger.Log)
+		configuredGateways = append(configuredGateways, payments.NewRazorpayGateway(razorpayService, cfg.RazorpayKeyID))
+		logger.Info("Razorpay gateway initialized")
+	}
+	if cfg.HasStripe() {
+		stripeService := stripe.NewStripeService(stripe.StripeConfig{
+			SecretKey:      cfg.StripeSecretKey,
+			PublishableKey: cfg.StripePublishableKey,
+			WebhookSecret:  cfg.StripeWebhookSecret,
+			BaseURL:        cfg.StripeAPIURL,
 		}, logger.Log)
-		logger.Info("Razorpay service initialized")
+		configuredGateways = append(configuredGateways, payments.NewStripeGateway(stripeService))
+		logger.Info("Stripe gateway initialized")
+	}
+	if cfg.PaymentGateway == "fake" {
+		// Config validation guarantees this is not production
+		configuredGateways = append(configuredGateways, payments.NewFakeGateway())
+		logger.Warn("Using the fake payment gateway - payments always succeed")
+	}
+
+	// Config validation guarantees the checkout gateway is configured
+	var checkoutGateway payments.Gateway
+	for _, gateway := range configuredGateways {
+		if gateway.Name() == cfg.PaymentGateway {
+			checkoutGateway = gateway
+		}
+	}
+	gateways := payments.NewRegistry(checkoutGateway, configuredGateways...)
+	logger.Info("Checkout payment gateway selected", zap.String("gateway", checkoutGateway.Name()))
+
+	codRules := payments.NewCODRules(cfg.CODEnabled, cfg.CODMaxOrderCents, cfg.CODFeeCents, cfg.CODPincodes)
+	if cfg.CODEnabled {
+		logger.Info("Cash on delivery enabled",
+			zap.Int("max_order_cents", cfg.CODMaxOrderCents),
+			zap.Int("fee_cents", cfg.CODFeeCents),
+		)
+	}
+
+	taxCalc := tax.NewCalculator(tax.Config{
+		SellerState:      cfg.GSTSellerState,
+		PricesIncludeTax: cfg.GSTPricesIncludeTax,
+		DefaultRateBps:   cfg.GSTDefaultRateBps,
+		DefaultHSN:       cfg.GSTDefaultHSN,
+	})
+	if cfg.GSTSellerState == "" {
+		logger.Warn("GST_SELLER_STATE not set - every sale will be charged IGST")
+	}
+
+	shippingConfig := shipping.DefaultConfig()
+	if cfg.ShippingConfigFile != "" {
+		shippingConfig, err = shipping.LoadConfig(cfg.ShippingConfigFile)
+		if err != nil {
+			logger.Fatal("Failed to load shipping config", zap.Error(err))
+		}
 	} else {
-		logger.Fatal("Razorpay credentials not configured - add RAZORPAY_KEY_ID and RAZORPAY_KEY_SECRET to .env")
+		logger.Warn("SHIPPING_CONFIG_FILE not set - shipping is free to every pincode")
 	}
+	shippingCalc := shipping.NewCalculator(shippingConfig)
+
+	// Initialize shipping carriers. Shipments keep the carrier they were
+	// booked with, so every configured carrier stays available for labels
+	// and tracking.
+	var configuredCarriers []shipping.Carrier
+	if cfg.HasShiprocket() {
+		configuredCarriers = append(configuredCarriers, shipping.NewShiprocketCarrier(shipping.ShiprocketConfig{
+			Email:          cfg.ShiprocketEmail,
+			Password:       cfg.ShiprocketPassword,
+			PickupLocation: cfg.ShiprocketPickupLocation,
+			WebhookToken:   cfg.ShiprocketWebhookToken,
+			BaseURL:        cfg.ShiprocketAPIURL,
+		}, logger.Log))
+		logger.Info("Shiprocket carrier initialized")
+	}
+	if cfg.ShippingCarrier == "fake" {
+		// Config validation guarantees this is not production
+		configuredCarriers = append(configuredCarriers, shipping.NewFakeCarrier())
+		logger.Warn("Using the fake shipping carrier - labels and tracking are simulated")
+	}
+
+	// Config validation guarantees the shipment carrier is configured
+	var shipmentCarrier shipping.Carrier
+	for _, carrier := range configuredCarriers {
+		if carrier.Name() == cfg.ShippingCarrier {
+			shipmentCarrier = carrier
+		}
+	}
+	carriers := shipping.NewCarriers(shipmentCarrier, configuredCarriers...)
+
+	invoiceGenerator := invoices.NewGenerator(invoiceRepo, orderRepo, invoiceStorage, taxCalc, logger.Log, invoices.GeneratorConfig{
+		Seller: invoices.Seller{
+			Name:    cfg.SellerName,
+			Address: cfg.SellerAddress,
+			GSTIN:   cfg.SellerGSTIN,
+			State:   cfg.GSTSellerState,
+		},
+		InvoiceSeries:    cfg.InvoiceSeries,
+		CreditNoteSeries: cfg.CreditNoteSeries,
+	})
 
 	// Determine base URLs
 	baseURL := fmt.Sprintf("http://localhost:%s", cfg.Port)
@@ -157,12 +314,19 @@ func main() {
 		frontendURL = "https://ramniya.com"
 	}
 
+	orderNotifier := notifications.NewNotifier(orderRepo, authRepo, emailOutbox, logger.Log, notifications.Config{
+		FrontendURL: frontendURL,
+		AdminEmail:  cfg.OrderNotificationEmail,
+	})
+
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(
 		authRepo,
 		tokenService,
-		emailSender,
+		emailOutbox,
 		oauthService,
+		cartRepo,
+		tokenRevoker,
 		logger.Log,
 		baseURL,
 		frontendURL,
@@ -176,15 +340,70 @@ func main() {
 		cacheService, // Pass cache service
 	)
 
+	webhookProcessor := payments.NewWebhookProcessor(orderRepo, gateways, logger.Log)
+
 	orderHandler := handlers.NewOrderHandler(
 		orderRepo,
-		razorpayService,
+		productRepo,
+		cartRepo,
+		couponRepo,
+		gateways,
+		codRules,
+		taxCalc,
+		shippingCalc,
+		webhookProcessor,
 		logger.Log,
-		cfg.RazorpayKeyID,
+		baseURL,
+	)
+
+	cartHandler := handlers.NewCartHandler(
+		cartRepo,
+		productRepo,
+		logger.Log,
+		baseURL,
 	)
 
 	adminOrderHandler := handlers.NewAdminOrderHandler(
 		orderRepo,
+		gateways,
+		logger.Log,
+	)
+
+	adminWebhookHandler := handlers.NewAdminWebhookHandler(
+		orderRepo,
+		webhookProcessor,
+		logger.Log,
+	)
+
+	invoiceHandler := handlers.NewInvoiceHandler(
+		orderRepo,
+		invoiceRepo,
+		invoiceGenerator,
+		logger.Log,
+	)
+
+	shipmentHandler := handlers.NewShipmentHandler(
+		orderRepo,
+		shipmentRepo,
+		authRepo,
+		carriers,
+		logger.Log,
+	)
+
+	adminCouponHandler := handlers.NewAdminCouponHandler(
+		couponRepo,
+		logger.Log,
+	)
+
+	adminUserHandler := handlers.NewAdminUserHandler(
+		authRepo,
+		tokenRevoker,
+		logger.Log,
+	)
+
+	adminEmailHandler := handlers.NewAdminEmailHandler(
+		emailRenderer,
+		emailOutbox,
 		logger.Log,
 	)
 
@@ -231,7 +450,8 @@ func main() {
 	e.Use(echomiddleware.CORSWithConfig(echomiddleware.CORSConfig{
 		AllowOrigins:     []string{"http://localhost:3000", "http://localhost:3001"},
 		AllowMethods:     []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodOptions},
-		AllowHeaders:     []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization},
+		AllowHeaders:     []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization, handlers.CartTokenHeader},
+		ExposeHeaders:    []string{handlers.CartTokenHeader},
 		AllowCredentials: true,
 	}))
 
@@ -288,6 +508,16 @@ func main() {
 	}
 
 	authGroup.POST("/register", authHandler.Register)
+	authGroup.POST("/refresh", authHandler.RefreshToken)
+	authGroup.POST("/reset-password", authHandler.ResetPassword)
+	authGroup.POST("/logout", authHandler.Logout, AuthMiddleware(tokenService, tokenRevoker))
+
+	// Password reset emails are limited per IP here and per account in the handler
+	if redisClient.IsEnabled() {
+		authGroup.POST("/forgot-password", authHandler.ForgotPassword, middleware.PasswordResetRateLimiter(redisClient, logger.Log))
+	} else {
+		authGroup.POST("/forgot-password", authHandler.ForgotPassword)
+	}
 	authGroup.GET("/verify", authHandler.VerifyEmail)
 
 	// OAuth endpoints (if configured)
@@ -302,24 +532,52 @@ func main() {
 
 	// Protected user endpoints (require authentication)
 	userGroup := e.Group("/api")
-	userGroup.Use(AuthMiddleware(tokenService))
+	userGroup.Use(AuthMiddleware(tokenService, tokenRevoker))
 
 	// Order endpoints for users
 	userGroup.GET("/orders", orderHandler.ListOrders)
 	userGroup.GET("/orders/:id", orderHandler.GetOrder)
+	userGroup.GET("/orders/:id/invoice", invoiceHandler.GetOrderInvoice)
+
+	// Account endpoints for the logged-in user
+	meGroup := e.Group("/api/me")
+	meGroup.Use(AuthMiddleware(tokenService, tokenRevoker))
+	meGroup.GET("", authHandler.GetProfile)
+	meGroup.PATCH("", authHandler.UpdateProfile)
+	meGroup.POST("/password", authHandler.ChangePassword)
+	meGroup.POST("/email", authHandler.ChangeEmail)
+	meGroup.GET("/logins", authHandler.GetLoginMethods)
+	meGroup.DELETE("/logins/google", authHandler.UnlinkGoogle)
+	if oauthService != nil {
+		meGroup.GET("/logins/google/link", authHandler.LinkGoogle)
+	}
+
+	// Cart endpoints (guests identified by the X-Cart-Token header)
+	cartGroup := e.Group("/api/cart")
+	cartGroup.Use(OptionalAuthMiddleware(tokenService, tokenRevoker))
+	cartGroup.GET("", cartHandler.GetCart)
+	cartGroup.DELETE("", cartHandler.ClearCart)
+	cartGroup.POST("/items", cartHandler.AddItem)
+	cartGroup.PUT("/items/:item_id", cartHandler.UpdateItem)
+	cartGroup.DELETE("/items/:item_id", cartHandler.RemoveItem)
 
 	// Checkout endpoints
 	checkoutGroup := e.Group("/api/checkout")
-	checkoutGroup.Use(AuthMiddleware(tokenService))
+	checkoutGroup.Use(AuthMiddleware(tokenService, tokenRevoker))
+	checkoutGroup.POST("/quote", orderHandler.QuoteCheckout)
 	checkoutGroup.POST("/create-order", orderHandler.CreateOrder)
+	checkoutGroup.POST("/cart", orderHandler.CreateOrderFromCart)
 	checkoutGroup.POST("/verify-payment", orderHandler.VerifyPayment)
 
-	// Webhook endpoint (public, but signature verified)
-	e.POST("/api/webhooks/razorpay", orderHandler.RazorpayWebhook)
+	// Payment gateway webhooks, e.g. /api/webhooks/razorpay (public, but signature verified)
+	e.POST("/api/webhooks/:gateway", orderHandler.PaymentWebhook)
+
+	// Shipping carrier tracking webhooks (public, carrier identified by its signature)
+	e.POST("/api/webhooks/tracking", shipmentHandler.TrackingWebhook)
 
 	// Admin endpoints (protected - require admin role)
 	adminGroup := e.Group("/api/admin")
-	adminGroup.Use(AuthMiddleware(tokenService))
+	adminGroup.Use(AuthMiddleware(tokenService, tokenRevoker))
 	adminGroup.Use(middleware.RequireAdmin(database.DB, logger.Log))
 
 	// Admin product endpoints
@@ -332,12 +590,274 @@ func main() {
 	adminGroup.GET("/orders", adminOrderHandler.ListAllOrders)
 	adminGroup.GET("/orders/:id", adminOrderHandler.GetOrderAdmin)
 	adminGroup.PUT("/orders/:id/status", adminOrderHandler.UpdateOrderStatusAdmin)
+	adminGroup.PUT("/orders/:id/fulfilment", adminOrderHandler.UpdateFulfilmentAdmin)
 	adminGroup.GET("/orders/stats", adminOrderHandler.GetOrderStats)
+	adminGroup.GET("/orders/:id/history", adminOrderHandler.GetOrderHistory)
+	adminGroup.POST("/orders/:id/refunds", adminOrderHandler.CreateRefund)
+	adminGroup.GET("/orders/:id/refunds", adminOrderHandler.ListRefunds)
+	adminGroup.POST("/orders/:id/refunds/:refund_id/retry", adminOrderHandler.RetryRefund)
+	adminGroup.POST("/orders/:id/cod/collect", adminOrderHandler.CollectCODPayment)
+	adminGroup.GET("/orders/:id/invoice", invoiceHandler.GetOrderInvoiceAdmin)
+	adminGroup.GET("/orders/:id/invoices", invoiceHandler.ListOrderInvoicesAdmin)
+	adminGroup.GET("/orders/:id/invoices/:invoiceId", invoiceHandler.GetInvoicePDFAdmin)
+	adminGroup.POST("/orders/:id/shipment", shipmentHandler.CreateShipment)
+	adminGroup.GET("/orders/:id/shipment", shipmentHandler.GetShipment)
+	adminGroup.GET("/orders/:id/shipment/label", shipmentHandler.GetLabel)
+	adminGroup.POST("/orders/:id/shipment/pickup", shipmentHandler.SchedulePickup)
+
+	// Admin report endpoints
+	adminGroup.GET("/reports/gst", adminOrderHandler.GetGSTReport)
+
+	// Admin coupon endpoints
+	adminGroup.POST("/coupons", adminCouponHandler.CreateCoupon)
+	adminGroup.GET("/coupons", adminCouponHandler.ListCoupons)
+	adminGroup.GET("/coupons/:id", adminCouponHandler.GetCoupon)
+	adminGroup.PUT("/coupons/:id", adminCouponHandler.UpdateCoupon)
+	adminGroup.DELETE("/coupons/:id", adminCouponHandler.DeleteCoupon)
+
+	// Admin webhook endpoints
+	adminGroup.GET("/webhooks", adminWebhookHandler.ListWebhookEvents)
+	adminGroup.GET("/webhooks/:id", adminWebhookHandler.GetWebhookEvent)
+	adminGroup.POST("/webhooks/:id/replay", adminWebhookHandler.ReplayWebhookEvent)
+
+	// Admin user endpoints
+	adminGroup.POST("/users/:id/logout", adminUserHandler.ForceLogout)
+	adminGroup.PUT("/users/:id/role", adminUserHandler.UpdateRole)
+
+	// Admin email endpoints
+	adminGroup.GET("/emails", adminEmailHandler.ListEmails)
+	adminGroup.GET("/emails/:id", adminEmailHandler.GetEmail)
+	adminGroup.POST("/emails/:id/resend", adminEmailHandler.ResendEmail)
+	adminGroup.GET("/emails/templates", adminEmailHandler.ListTemplates)
+	adminGroup.GET("/emails/templates/:name/preview", adminEmailHandler.PreviewTemplate)
 
 	// OAuth endpoints (if configured)
 	//auth.GET("/oauth/google", authHandler.GetGoogleAuthURL)
 	//auth.GET("/oauth/google/callback", authHandler.GoogleOAuthCallback)
 
+	// Background jobs
+	scheduler := jobs.NewScheduler(logger.Log)
+	scheduler.Add(jobs.Job{
+		Name:     "release_expired_reservations",
+		Interval: time.Minute,
+		Run: func(ctx context.Context) error {
+			released, err := orderRepo.ReleaseExpiredReservations(ctx, 500)
+			if err != nil {
+				return err
+			}
+			if released > 0 {
+				logger.Info("Released expired stock reservations", zap.Int("count", released))
+			}
+			return nil
+		},
+	})
+	scheduler.Add(jobs.Job{
+		Name:     "delete_stale_guest_carts",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			deleted, err := cartRepo.DeleteStaleGuestCarts(ctx, 30*24*time.Hour)
+			if err != nil {
+				return err
+			}
+			if deleted > 0 {
+				logger.Info("Deleted stale guest carts", zap.Int64("count", deleted))
+			}
+			return nil
+		},
+	})
+	scheduler.Add(jobs.Job{
+		Name:     "delete_expired_auth_tokens",
+		Interval: 24 * time.Hour,
+		Run: func(ctx context.Context) error {
+			deleted, err := authRepo.DeleteExpiredRefreshTokens(ctx)
+			if err != nil {
+				return err
+			}
+			if deleted > 0 {
+				logger.Info("Deleted expired refresh tokens", zap.Int64("count", deleted))
+			}
+
+			deleted, err = authRepo.DeleteExpiredPasswordResetTokens(ctx)
+			if err != nil {
+				return err
+			}
+			if deleted > 0 {
+				logger.Info("Deleted expired password reset tokens", zap.Int64("count", deleted))
+			}
+
+			deleted, err = authRepo.DeleteExpiredRevokedTokens(ctx)
+			if err != nil {
+				return err
+			}
+			if deleted > 0 {
+				logger.Info("Deleted expired revoked access tokens", zap.Int64("count", deleted))
+			}
+			return nil
+		},
+	})
+	reconciler := payments.NewReconciler(orderRepo, gateways, logger.Log, payments.ReconcilerConfig{
+		MinAge: time.Duration(cfg.PaymentReconcileAfterMinutes) * time.Minute,
+	})
+	scheduler.Add(jobs.Job{
+		Name:     "reconcile_payments",
+		Interval: 5 * time.Minute,
+		Run: func(ctx context.Context) error {
+			// Only one replica reconciles payments at a time
+			release, acquired, err := database.TryAdvisoryLock(ctx, database.DB, database.LockKeyReconcilePayments)
+			if err != nil {
+				return err
+			}
+			if !acquired {
+				return nil
+			}
+			defer release()
+
+			result, err := reconciler.Run(ctx)
+			if err != nil {
+				return err
+			}
+			if result.Paid > 0 || result.Failed > 0 || result.Review > 0 || result.Discrepancies > 0 || result.Errors > 0 {
+				logger.Info("Reconciled payments",
+					zap.Int("checked", result.Checked),
+					zap.Int("paid", result.Paid),
+					zap.Int("failed", result.Failed),
+					zap.Int("review", result.Review),
+					zap.Int("discrepancies", result.Discrepancies),
+					zap.Int("errors", result.Errors),
+				)
+			}
+			return nil
+		},
+	})
+	expirer := payments.NewOrderExpirer(orderRepo, gateways, logger.Log, payments.ExpiryConfig{
+		TTL:    time.Duration(cfg.OrderExpiryMinutes) * time.Minute,
+		Status: orders.OrderStatus(cfg.OrderExpiryStatus),
+	})
+	scheduler.Add(jobs.Job{
+		Name:     "expire_unpaid_orders",
+		Interval: 5 * time.Minute,
+		Run: func(ctx context.Context) error {
+			// Only one replica expires orders at a time
+			release, acquired, err := database.TryAdvisoryLock(ctx, database.DB, database.LockKeyExpireOrders)
+			if err != nil {
+				return err
+			}
+			if !acquired {
+				return nil
+			}
+			defer release()
+
+			result, err := expirer.Run(ctx)
+			if err != nil {
+				return err
+			}
+			if result.Expired > 0 || result.Skipped > 0 || result.Errors > 0 {
+				logger.Info("Expired unpaid orders",
+					zap.Int("checked", result.Checked),
+					zap.Int("expired", result.Expired),
+					zap.Int("skipped", result.Skipped),
+					zap.Int("errors", result.Errors),
+				)
+			}
+			return nil
+		},
+	})
+	scheduler.Add(jobs.Job{
+		Name:     "retry_webhook_events",
+		Interval: time.Minute,
+		Run: func(ctx context.Context) error {
+			result, err := webhookProcessor.RetryFailed(ctx, 50)
+			if err != nil {
+				return err
+			}
+			if result.Retried > 0 {
+				logger.Info("Retried webhook events",
+					zap.Int("retried", result.Retried),
+					zap.Int("succeeded", result.Succeeded),
+					zap.Int("failed", result.Failed),
+				)
+			}
+			return nil
+		},
+	})
+	scheduler.Add(jobs.Job{
+		Name:     "issue_invoices",
+		Interval: time.Minute,
+		Run: func(ctx context.Context) error {
+			// Only one replica issues invoices at a time
+			release, acquired, err := database.TryAdvisoryLock(ctx, database.DB, database.LockKeyIssueInvoices)
+			if err != nil {
+				return err
+			}
+			if !acquired {
+				return nil
+			}
+			defer release()
+
+			result, err := invoiceGenerator.Run(ctx)
+			if err != nil {
+				return err
+			}
+			if result.Invoices > 0 || result.CreditNotes > 0 || result.Errors > 0 {
+				logger.Info("Issued invoices",
+					zap.Int("invoices", result.Invoices),
+					zap.Int("credit_notes", result.CreditNotes),
+					zap.Int("errors", result.Errors),
+				)
+			}
+			return nil
+		},
+	})
+	scheduler.Add(jobs.Job{
+		Name:     "send_order_emails",
+		Interval: time.Minute,
+		Run: func(ctx context.Context) error {
+			// Only one replica sends order emails, so each goes out once
+			release, acquired, err := database.TryAdvisoryLock(ctx, database.DB, database.LockKeySendOrderEmails)
+			if err != nil {
+				return err
+			}
+			if !acquired {
+				return nil
+			}
+			defer release()
+
+			result, err := orderNotifier.Run(ctx)
+			if err != nil {
+				return err
+			}
+			if result.Queued > 0 || result.Errors > 0 {
+				logger.Info("Queued order emails",
+					zap.Int("queued", result.Queued),
+					zap.Int("errors", result.Errors),
+				)
+			}
+			return nil
+		},
+	})
+	scheduler.Add(jobs.Job{
+		Name:     "send_emails",
+		Interval: 5 * time.Second,
+		Run: func(ctx context.Context) error {
+			// Claims skip rows locked by other replicas, so every replica
+			// can send
+			result, err := emailWorker.Run(ctx)
+			if err != nil {
+				return err
+			}
+			if result.Sent > 0 || result.Failed > 0 || result.DeadLettered > 0 || result.LeaseLost > 0 {
+				logger.Info("Sent queued emails",
+					zap.Int("sent", result.Sent),
+					zap.Int("failed", result.Failed),
+					zap.Int("dead_lettered", result.DeadLettered),
+					zap.Int("lease_lost", result.LeaseLost),
+				)
+			}
+			return nil
+		},
+	})
+	scheduler.Start()
+
 	// Start server with graceful shutdown
 	go func() {
 		logger.Info("Server starting", zap.String("port", cfg.Port))
@@ -361,11 +881,18 @@ func main() {
 		logger.Fatal("Server forced to shutdown", zap.Error(err))
 	}
 
+	scheduler.Stop()
+
+	// Close pooled SMTP connections
+	if closer, ok := emailSender.(io.Closer); ok {
+		closer.Close()
+	}
+
 	logger.Info("Server stopped gracefully")
 }
 
 // AuthMiddleware validates JWT tokens for protected routes
-func AuthMiddleware(tokenService *jwt.TokenService) echo.MiddlewareFunc {
+func AuthMiddleware(tokenService *jwt.TokenService, revoker *auth.TokenRevoker) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			// Get token from Authorization header
@@ -377,10 +904,8 @@ func AuthMiddleware(tokenService *jwt.TokenService) echo.MiddlewareFunc {
 			}
 
 			// Extract token (format: "Bearer <token>")
-			tokenString := ""
-			if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
-				tokenString = authHeader[7:]
-			} else {
+			tokenString, ok := bearerToken(authHeader)
+			if !ok {
 				return c.JSON(http.StatusUnauthorized, map[string]string{
 					"error": "Invalid authorization header format",
 				})
@@ -397,15 +922,89 @@ func AuthMiddleware(tokenService *jwt.TokenService) echo.MiddlewareFunc {
 				})
 			}
 
+			if rejected, err := rejectRevoked(c, revoker, claims); rejected {
+				return err
+			}
+
 			// Set user info in context
 			c.Set("user_id", claims.UserID)
 			c.Set("user_email", claims.Email)
+			c.Set("token_claims", claims)
 
 			return next(c)
 		}
 	}
 }
 
+// OptionalAuthMiddleware sets user info when a valid token is present but
+// lets anonymous requests through, for endpoints that also serve guests
+func OptionalAuthMiddleware(tokenService *jwt.TokenService, revoker *auth.TokenRevoker) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			authHeader := c.Request().Header.Get("Authorization")
+			if authHeader == "" {
+				return next(c)
+			}
+
+			tokenString, ok := bearerToken(authHeader)
+			if !ok {
+				return c.JSON(http.StatusUnauthorized, map[string]string{
+					"error": "Invalid authorization header format",
+				})
+			}
+
+			// A bad token is an error rather than silently falling back to a guest
+			claims, err := tokenService.VerifyToken(tokenString, jwt.PurposeAccess)
+			if err != nil {
+				return c.JSON(http.StatusUnauthorized, map[string]string{
+					"error": "Invalid or expired token",
+				})
+			}
+
+			if rejected, err := rejectRevoked(c, revoker, claims); rejected {
+				return err
+			}
+
+			c.Set("user_id", claims.UserID)
+			c.Set("user_email", claims.Email)
+			c.Set("token_claims", claims)
+
+			return next(c)
+		}
+	}
+}
+
+// rejectRevoked writes an error response and returns true when the token
+// has been revoked or its status cannot be checked
+func rejectRevoked(c echo.Context, revoker *auth.TokenRevoker, claims *jwt.Claims) (bool, error) {
+	revoked, err := revoker.IsRevoked(c.Request().Context(), claims)
+	if err != nil {
+		logger.Log.Error("Failed to check token revocation",
+			zap.String("user_id", claims.UserID),
+			zap.Error(err),
+		)
+		return true, c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to validate token",
+		})
+	}
+
+	if revoked {
+		return true, c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "Token has been revoked",
+		})
+	}
+
+	return false, nil
+}
+
+// bearerToken extracts the token from a "Bearer <token>" header value
+func bearerToken(authHeader string) (string, bool) {
+	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+		return authHeader[7:], true
+	}
+	return "", false
+}
+
 func handleCLICommands(args []string, cfg *config.Config) {
 	if len(args) == 0 {
 		return
@@ -428,11 +1027,30 @@ func handleCLICommands(args []string, cfg *config.Config) {
 
 		runMigrations(direction)
 
+	case "email-preview":
+		if len(args) < 2 {
+			fmt.Println("Usage: backend email-preview <template> [html|text]")
+			fmt.Printf("Templates: %s\n", strings.Join(email.Templates, ", "))
+			os.Exit(1)
+		}
+
+		format := "html"
+		if len(args) > 2 {
+			format = args[2]
+		}
+		if format != "html" && format != "text" {
+			fmt.Println("Format must be 'html' or 'text'")
+			os.Exit(1)
+		}
+
+		previewEmail(args[1], format, cfg)
+
 	default:
 		fmt.Printf("Unknown command: %s\n", command)
 		fmt.Println("Available commands:")
 		fmt.Println("  migrate up   - Run pending migrations")
 		fmt.Println("  migrate down - Rollback last migration")
+		fmt.Println("  email-preview <template> [html|text] - Render an email template with sample data")
 		os.Exit(1)
 	}
 }
@@ -473,3 +1091,30 @@ func runMigrations(direction string) {
 		logger.Warn("Down migrations not implemented yet. Use golang-migrate for down migrations.")
 	}
 }
+
+// previewEmail renders an email template with sample data to stdout
+func previewEmail(name, format string, cfg *config.Config) {
+	renderer, err := email.NewRenderer(cfg.EmailTemplateDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load email templates: %v\n", err)
+		os.Exit(1)
+	}
+
+	data, err := email.SampleData(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\nTemplates: %s\n", err, strings.Join(email.Templates, ", "))
+		os.Exit(1)
+	}
+	msg, err := renderer.Render(name, data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to render %s: %v\n", name, err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "Subject: %s\n", msg.Subject)
+	if format == "text" {
+		fmt.Print(msg.Text)
+	} else {
+		fmt.Print(msg.HTML)
+	}
+}
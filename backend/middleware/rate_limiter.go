@@ -98,6 +98,15 @@ func LoginRateLimiter(redis *cache.RedisClient, logger *zap.Logger) echo.Middlew
 	})
 }
 
+// PasswordResetRateLimiter creates a rate limiter for password reset requests
+func PasswordResetRateLimiter(redis *cache.RedisClient, logger *zap.Logger) echo.MiddlewareFunc {
+	return RedisRateLimiter(redis, logger, RateLimiterConfig{
+		RequestsPerWindow: 5,                // 5 requests
+		WindowDuration:    15 * time.Minute, // per 15 minutes
+		KeyPrefix:         "rate_limit:password_reset",
+	})
+}
+
 // APIRateLimiter creates a general API rate limiter
 func APIRateLimiter(redis *cache.RedisClient, logger *zap.Logger) echo.MiddlewareFunc {
 	return RedisRateLimiter(redis, logger, RateLimiterConfig{
@@ -0,0 +1,6 @@
+-- Drop trigger
+DROP TRIGGER IF EXISTS stock_reservations_updated_at ON stock_reservations;
+DROP FUNCTION IF EXISTS update_stock_reservations_updated_at();
+
+-- Drop table
+DROP TABLE IF EXISTS stock_reservations CASCADE;
@@ -0,0 +1,39 @@
+-- Create stock_reservations table
+CREATE TABLE stock_reservations (
+                                    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                                    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+                                    variant_id UUID NOT NULL REFERENCES product_variants(id) ON DELETE CASCADE,
+                                    quantity INTEGER NOT NULL CHECK (quantity > 0),
+                                    status TEXT NOT NULL DEFAULT 'reserved',
+                                    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+                                    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+                                    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+                                    CONSTRAINT valid_reservation_status CHECK (status IN ('reserved', 'committed', 'released', 'backordered')),
+                                    CONSTRAINT unique_order_variant UNIQUE (order_id, variant_id)
+);
+
+-- Indexes for performance
+CREATE INDEX idx_stock_reservations_order_id ON stock_reservations(order_id);
+CREATE INDEX idx_stock_reservations_variant_id ON stock_reservations(variant_id);
+CREATE INDEX idx_stock_reservations_expires_at ON stock_reservations(expires_at) WHERE status = 'reserved';
+
+-- Trigger to update updated_at on stock_reservations
+CREATE OR REPLACE FUNCTION update_stock_reservations_updated_at()
+    RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = NOW();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER stock_reservations_updated_at
+    BEFORE UPDATE ON stock_reservations
+    FOR EACH ROW
+EXECUTE FUNCTION update_stock_reservations_updated_at();
+
+-- Comments for documentation
+COMMENT ON TABLE stock_reservations IS 'Variant stock held for unpaid orders';
+COMMENT ON COLUMN stock_reservations.quantity IS 'Units deducted from product_variants.stock for this order';
+COMMENT ON COLUMN stock_reservations.status IS 'reserved (held), committed (order paid), released (returned to stock), backordered (paid after release with no stock left)';
+COMMENT ON COLUMN stock_reservations.expires_at IS 'When an unpaid reservation is returned to stock';
@@ -0,0 +1,8 @@
+-- Drop triggers
+DROP TRIGGER IF EXISTS cart_items_updated_at ON cart_items;
+DROP TRIGGER IF EXISTS carts_updated_at ON carts;
+DROP FUNCTION IF EXISTS update_carts_updated_at();
+
+-- Drop tables
+DROP TABLE IF EXISTS cart_items CASCADE;
+DROP TABLE IF EXISTS carts CASCADE;
@@ -0,0 +1,55 @@
+-- Create carts table
+CREATE TABLE carts (
+                       id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                       user_id UUID UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+                       token TEXT UNIQUE,
+                       created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+                       updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+                       CONSTRAINT cart_owner CHECK (user_id IS NOT NULL OR token IS NOT NULL)
+);
+
+-- Create cart_items table
+CREATE TABLE cart_items (
+                            id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                            cart_id UUID NOT NULL REFERENCES carts(id) ON DELETE CASCADE,
+                            product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+                            variant_id UUID REFERENCES product_variants(id) ON DELETE CASCADE,
+                            quantity INTEGER NOT NULL CHECK (quantity > 0),
+                            unit_price_cents INTEGER NOT NULL DEFAULT 0,
+                            created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+                            updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Indexes for performance
+CREATE INDEX idx_carts_updated_at ON carts(updated_at) WHERE user_id IS NULL;
+CREATE INDEX idx_cart_items_cart_id ON cart_items(cart_id);
+
+-- One line per product/variant in a cart
+CREATE UNIQUE INDEX idx_cart_items_unique_line
+    ON cart_items(cart_id, product_id, COALESCE(variant_id, '00000000-0000-0000-0000-000000000000'::uuid));
+
+-- Trigger to update updated_at on carts
+CREATE OR REPLACE FUNCTION update_carts_updated_at()
+    RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = NOW();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER carts_updated_at
+    BEFORE UPDATE ON carts
+    FOR EACH ROW
+EXECUTE FUNCTION update_carts_updated_at();
+
+CREATE TRIGGER cart_items_updated_at
+    BEFORE UPDATE ON cart_items
+    FOR EACH ROW
+EXECUTE FUNCTION update_carts_updated_at();
+
+-- Comments for documentation
+COMMENT ON TABLE carts IS 'Shopping carts owned by a user or by an anonymous guest token';
+COMMENT ON COLUMN carts.token IS 'Opaque guest cart token, cleared when merged into a user cart';
+COMMENT ON TABLE cart_items IS 'Cart lines; prices are re-validated against the catalog on every read';
+COMMENT ON COLUMN cart_items.unit_price_cents IS 'Unit price in paise when the line was last added, used to flag price changes';
@@ -0,0 +1,24 @@
+-- Create refresh_tokens table
+CREATE TABLE refresh_tokens (
+                                id UUID PRIMARY KEY,
+                                user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                                family_id UUID NOT NULL,
+                                expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+                                used_at TIMESTAMP WITH TIME ZONE,
+                                replaced_by UUID,
+                                revoked_at TIMESTAMP WITH TIME ZONE,
+                                created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Indexes for performance
+CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens(user_id);
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+CREATE INDEX idx_refresh_tokens_expires_at ON refresh_tokens(expires_at);
+
+-- Comments for documentation
+COMMENT ON TABLE refresh_tokens IS 'Issued refresh tokens, rotated on every use';
+COMMENT ON COLUMN refresh_tokens.id IS 'The jti claim of the refresh token';
+COMMENT ON COLUMN refresh_tokens.family_id IS 'Shared by every token rotated from the same login';
+COMMENT ON COLUMN refresh_tokens.used_at IS 'Set when the token is exchanged; a second use revokes the whole family';
+COMMENT ON COLUMN refresh_tokens.replaced_by IS 'The token issued in exchange for this one';
+COMMENT ON COLUMN refresh_tokens.revoked_at IS 'Set when the token family is revoked';
@@ -0,0 +1,17 @@
+-- Create password_reset_tokens table
+CREATE TABLE password_reset_tokens (
+                                       id UUID PRIMARY KEY,
+                                       user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                                       expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+                                       used_at TIMESTAMP WITH TIME ZONE,
+                                       created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Indexes for performance
+CREATE INDEX idx_password_reset_tokens_user_id ON password_reset_tokens(user_id, created_at DESC);
+CREATE INDEX idx_password_reset_tokens_expires_at ON password_reset_tokens(expires_at);
+
+-- Comments for documentation
+COMMENT ON TABLE password_reset_tokens IS 'Issued password reset links, each redeemable once';
+COMMENT ON COLUMN password_reset_tokens.id IS 'The jti claim of the reset token';
+COMMENT ON COLUMN password_reset_tokens.used_at IS 'Set when the token is redeemed or superseded by a completed reset';
@@ -0,0 +1,5 @@
+-- Drop tables
+DROP TABLE IF EXISTS revoked_access_tokens CASCADE;
+
+-- Remove tokens_valid_after column from users
+ALTER TABLE users DROP COLUMN IF EXISTS tokens_valid_after;
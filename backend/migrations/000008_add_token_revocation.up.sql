@@ -0,0 +1,18 @@
+-- Access tokens issued at or before this time are rejected
+ALTER TABLE users ADD COLUMN tokens_valid_after TIMESTAMP WITH TIME ZONE;
+
+-- Create revoked_access_tokens table
+CREATE TABLE revoked_access_tokens (
+                                       id UUID PRIMARY KEY,
+                                       user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                                       expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+                                       created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Indexes for performance
+CREATE INDEX idx_revoked_access_tokens_expires_at ON revoked_access_tokens(expires_at);
+
+-- Comments for documentation
+COMMENT ON COLUMN users.tokens_valid_after IS 'Access tokens issued at or before this time are rejected (logout everywhere, password or role change)';
+COMMENT ON TABLE revoked_access_tokens IS 'Denylist of individually revoked access tokens, kept until they expire';
+COMMENT ON COLUMN revoked_access_tokens.id IS 'The jti claim of the revoked token';
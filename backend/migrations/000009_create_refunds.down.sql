@@ -0,0 +1,15 @@
+-- Drop trigger
+DROP TRIGGER IF EXISTS refunds_updated_at ON refunds;
+DROP FUNCTION IF EXISTS update_refunds_updated_at();
+
+-- Drop tables
+DROP TABLE IF EXISTS refunds CASCADE;
+
+-- Remove refunded_cents column from orders
+ALTER TABLE orders DROP COLUMN IF EXISTS refunded_cents;
+
+-- Restore original status constraint
+UPDATE orders SET status = 'paid' WHERE status = 'partially_refunded';
+ALTER TABLE orders DROP CONSTRAINT valid_status;
+ALTER TABLE orders ADD CONSTRAINT valid_status
+    CHECK (status IN ('created', 'pending', 'paid', 'failed', 'cancelled', 'refunded'));
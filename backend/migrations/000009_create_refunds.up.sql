@@ -0,0 +1,51 @@
+-- Allow partially refunded orders
+ALTER TABLE orders DROP CONSTRAINT valid_status;
+ALTER TABLE orders ADD CONSTRAINT valid_status
+    CHECK (status IN ('created', 'pending', 'paid', 'failed', 'cancelled', 'refunded', 'partially_refunded'));
+
+-- Running total of processed refunds
+ALTER TABLE orders ADD COLUMN refunded_cents INTEGER NOT NULL DEFAULT 0 CHECK (refunded_cents >= 0);
+
+-- Create refunds table
+CREATE TABLE refunds (
+                         id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                         order_id UUID NOT NULL REFERENCES orders(id) ON DELETE RESTRICT,
+                         razorpay_payment_id TEXT NOT NULL,
+                         razorpay_refund_id TEXT UNIQUE,
+                         amount_cents INTEGER NOT NULL CHECK (amount_cents > 0),
+                         currency TEXT NOT NULL DEFAULT 'INR',
+                         reason TEXT,
+                         status TEXT NOT NULL DEFAULT 'pending',
+                         failure_reason TEXT,
+                         created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+                         created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+                         updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+                         processed_at TIMESTAMP WITH TIME ZONE,
+
+                         CONSTRAINT valid_refund_status CHECK (status IN ('pending', 'processed', 'failed'))
+);
+
+-- Indexes for performance
+CREATE INDEX idx_refunds_order_id ON refunds(order_id);
+CREATE INDEX idx_refunds_status ON refunds(status);
+
+-- Trigger to update updated_at on refunds
+CREATE OR REPLACE FUNCTION update_refunds_updated_at()
+    RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = NOW();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER refunds_updated_at
+    BEFORE UPDATE ON refunds
+    FOR EACH ROW
+EXECUTE FUNCTION update_refunds_updated_at();
+
+-- Comments for documentation
+COMMENT ON TABLE refunds IS 'Refunds issued against paid orders through Razorpay';
+COMMENT ON COLUMN refunds.amount_cents IS 'Refund amount in paise';
+COMMENT ON COLUMN refunds.status IS 'Refund status: pending (submitted), processed (money returned), failed';
+COMMENT ON COLUMN refunds.razorpay_refund_id IS 'Razorpay refund ID, set once the refund is accepted by Razorpay';
+COMMENT ON COLUMN orders.refunded_cents IS 'Sum of processed refunds in paise';
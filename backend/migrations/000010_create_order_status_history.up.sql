@@ -0,0 +1,22 @@
+-- Create order_status_history table
+CREATE TABLE order_status_history (
+                                      id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                                      order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+                                      from_status TEXT,
+                                      to_status TEXT NOT NULL,
+                                      actor_type TEXT NOT NULL,
+                                      actor_id TEXT,
+                                      note TEXT,
+                                      created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+                                      CONSTRAINT valid_actor_type CHECK (actor_type IN ('user', 'admin', 'webhook', 'system'))
+);
+
+-- Indexes for performance
+CREATE INDEX idx_order_status_history_order_id ON order_status_history(order_id, created_at);
+
+-- Comments for documentation
+COMMENT ON TABLE order_status_history IS 'Audit trail of order status changes';
+COMMENT ON COLUMN order_status_history.from_status IS 'Previous status; NULL for the initial status';
+COMMENT ON COLUMN order_status_history.actor_type IS 'Who made the change: user, admin, webhook, system';
+COMMENT ON COLUMN order_status_history.actor_id IS 'User or admin ID, webhook event ID, or job name';
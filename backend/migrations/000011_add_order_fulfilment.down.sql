@@ -0,0 +1,16 @@
+-- Remove fulfilment history entries
+DELETE FROM order_status_history WHERE kind = 'fulfilment';
+ALTER TABLE order_status_history DROP CONSTRAINT IF EXISTS valid_history_kind;
+ALTER TABLE order_status_history DROP COLUMN IF EXISTS kind;
+
+-- Remove fulfilment columns from orders
+DROP INDEX IF EXISTS idx_orders_fulfilment_status;
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS valid_fulfilment_status;
+ALTER TABLE orders DROP COLUMN IF EXISTS returned_at;
+ALTER TABLE orders DROP COLUMN IF EXISTS delivered_at;
+ALTER TABLE orders DROP COLUMN IF EXISTS shipped_at;
+ALTER TABLE orders DROP COLUMN IF EXISTS packed_at;
+ALTER TABLE orders DROP COLUMN IF EXISTS tracking_url;
+ALTER TABLE orders DROP COLUMN IF EXISTS tracking_number;
+ALTER TABLE orders DROP COLUMN IF EXISTS carrier;
+ALTER TABLE orders DROP COLUMN IF EXISTS fulfilment_status;
@@ -0,0 +1,24 @@
+-- Fulfilment is tracked separately from payment status
+ALTER TABLE orders ADD COLUMN fulfilment_status TEXT NOT NULL DEFAULT 'unfulfilled';
+ALTER TABLE orders ADD CONSTRAINT valid_fulfilment_status
+    CHECK (fulfilment_status IN ('unfulfilled', 'processing', 'packed', 'shipped', 'out_for_delivery',
+                                 'delivered', 'return_requested', 'returned'));
+
+ALTER TABLE orders ADD COLUMN carrier TEXT;
+ALTER TABLE orders ADD COLUMN tracking_number TEXT;
+ALTER TABLE orders ADD COLUMN tracking_url TEXT;
+ALTER TABLE orders ADD COLUMN packed_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE orders ADD COLUMN shipped_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE orders ADD COLUMN delivered_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE orders ADD COLUMN returned_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_orders_fulfilment_status ON orders(fulfilment_status);
+
+-- Status history covers both payment and fulfilment changes
+ALTER TABLE order_status_history ADD COLUMN kind TEXT NOT NULL DEFAULT 'payment';
+ALTER TABLE order_status_history ADD CONSTRAINT valid_history_kind CHECK (kind IN ('payment', 'fulfilment'));
+
+-- Comments for documentation
+COMMENT ON COLUMN orders.fulfilment_status IS 'Dispatch progress: unfulfilled, processing, packed, shipped, out_for_delivery, delivered, return_requested, returned';
+COMMENT ON COLUMN orders.tracking_number IS 'Carrier AWB / tracking number';
+COMMENT ON COLUMN order_status_history.kind IS 'Which status changed: payment or fulfilment';
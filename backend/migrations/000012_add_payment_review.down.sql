@@ -0,0 +1,19 @@
+-- Orders held for review have an unverified payment that the previous
+-- statuses cannot express; resolve them before rolling back
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM orders WHERE status = 'payment_review') THEN
+        RAISE EXCEPTION 'orders in payment_review must be resolved before rolling back';
+    END IF;
+END
+$$;
+
+-- Remove review columns from orders
+ALTER TABLE orders DROP COLUMN IF EXISTS captured_currency;
+ALTER TABLE orders DROP COLUMN IF EXISTS captured_amount_cents;
+ALTER TABLE orders DROP COLUMN IF EXISTS payment_review_reason;
+
+-- Restore previous status constraint
+ALTER TABLE orders DROP CONSTRAINT valid_status;
+ALTER TABLE orders ADD CONSTRAINT valid_status
+    CHECK (status IN ('created', 'pending', 'paid', 'failed', 'cancelled', 'refunded', 'partially_refunded'));
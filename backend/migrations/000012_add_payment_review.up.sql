@@ -0,0 +1,14 @@
+-- Payments whose amount or currency does not match the order are held for review
+ALTER TABLE orders DROP CONSTRAINT valid_status;
+ALTER TABLE orders ADD CONSTRAINT valid_status
+    CHECK (status IN ('created', 'pending', 'paid', 'failed', 'cancelled', 'refunded', 'partially_refunded',
+                      'payment_review'));
+
+ALTER TABLE orders ADD COLUMN payment_review_reason TEXT;
+ALTER TABLE orders ADD COLUMN captured_amount_cents INTEGER;
+ALTER TABLE orders ADD COLUMN captured_currency TEXT;
+
+-- Comments for documentation
+COMMENT ON COLUMN orders.payment_review_reason IS 'Why the captured payment was held for review, e.g. amount mismatch';
+COMMENT ON COLUMN orders.captured_amount_cents IS 'Amount the gateway captured for an order held for review; caps refunds instead of amount_cents';
+COMMENT ON COLUMN orders.captured_currency IS 'Currency of captured_amount_cents';
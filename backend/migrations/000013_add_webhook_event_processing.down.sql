@@ -0,0 +1,13 @@
+-- Drop trigger
+DROP TRIGGER IF EXISTS webhook_events_updated_at ON webhook_events;
+DROP FUNCTION IF EXISTS update_webhook_events_updated_at();
+
+-- Remove processing columns from webhook_events
+DROP INDEX IF EXISTS idx_webhook_events_status;
+ALTER TABLE webhook_events DROP CONSTRAINT IF EXISTS valid_webhook_status;
+ALTER TABLE webhook_events DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE webhook_events DROP COLUMN IF EXISTS processed_at;
+ALTER TABLE webhook_events DROP COLUMN IF EXISTS next_attempt_at;
+ALTER TABLE webhook_events DROP COLUMN IF EXISTS last_error;
+ALTER TABLE webhook_events DROP COLUMN IF EXISTS attempts;
+ALTER TABLE webhook_events DROP COLUMN IF EXISTS status;
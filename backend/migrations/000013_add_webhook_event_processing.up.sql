@@ -0,0 +1,33 @@
+-- Track the processing outcome of each webhook event
+ALTER TABLE webhook_events ADD COLUMN status TEXT NOT NULL DEFAULT 'received';
+ALTER TABLE webhook_events ADD CONSTRAINT valid_webhook_status
+    CHECK (status IN ('received', 'processing', 'processed', 'ignored', 'failed'));
+ALTER TABLE webhook_events ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE webhook_events ADD COLUMN last_error TEXT;
+ALTER TABLE webhook_events ADD COLUMN next_attempt_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE webhook_events ADD COLUMN processed_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE webhook_events ADD COLUMN updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW();
+
+-- Events recorded before this migration were handled inline
+UPDATE webhook_events SET status = 'processed', processed_at = created_at WHERE processed = TRUE;
+
+CREATE INDEX idx_webhook_events_status ON webhook_events(status, next_attempt_at);
+
+-- Trigger to update updated_at on webhook_events
+CREATE OR REPLACE FUNCTION update_webhook_events_updated_at()
+    RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = NOW();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER webhook_events_updated_at
+    BEFORE UPDATE ON webhook_events
+    FOR EACH ROW
+EXECUTE FUNCTION update_webhook_events_updated_at();
+
+-- Comments for documentation
+COMMENT ON COLUMN webhook_events.event_id IS 'Razorpay x-razorpay-event-id header, or a hash of the payload when absent';
+COMMENT ON COLUMN webhook_events.status IS 'received, processing, processed, ignored (no handler or no-op), failed (retried until attempts run out)';
+COMMENT ON COLUMN webhook_events.next_attempt_at IS 'When a failed event is retried next; NULL once retries are exhausted';
@@ -0,0 +1,15 @@
+-- Event IDs shared by two gateways cannot be made globally unique again
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM webhook_events GROUP BY event_id HAVING COUNT(*) > 1) THEN
+        RAISE EXCEPTION 'webhook events from different gateways share an event_id; remove one before rolling back';
+    END IF;
+END
+$$;
+
+-- Remove payment gateway columns
+DROP INDEX IF EXISTS idx_orders_payment_gateway;
+ALTER TABLE webhook_events DROP CONSTRAINT IF EXISTS webhook_events_gateway_event_id_key;
+ALTER TABLE webhook_events DROP COLUMN IF EXISTS gateway;
+ALTER TABLE webhook_events ADD CONSTRAINT webhook_events_event_id_key UNIQUE (event_id);
+ALTER TABLE orders DROP COLUMN IF EXISTS payment_gateway;
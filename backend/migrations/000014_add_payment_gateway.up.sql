@@ -0,0 +1,13 @@
+-- Record which payment gateway processed each order and delivered each webhook
+ALTER TABLE orders ADD COLUMN payment_gateway TEXT NOT NULL DEFAULT 'razorpay';
+ALTER TABLE webhook_events ADD COLUMN gateway TEXT NOT NULL DEFAULT 'razorpay';
+
+-- Event IDs are only unique within the gateway that issued them
+ALTER TABLE webhook_events DROP CONSTRAINT webhook_events_event_id_key;
+ALTER TABLE webhook_events ADD CONSTRAINT webhook_events_gateway_event_id_key UNIQUE (gateway, event_id);
+
+CREATE INDEX idx_orders_payment_gateway ON orders(payment_gateway);
+
+-- Comments for documentation
+COMMENT ON COLUMN orders.payment_gateway IS 'razorpay, stripe or fake; the razorpay_* columns hold this gateway''s order and payment IDs';
+COMMENT ON COLUMN webhook_events.gateway IS 'Gateway that delivered the event';
@@ -0,0 +1,12 @@
+-- Remove cod_fee_cents column from orders
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS cod_fee_non_negative;
+ALTER TABLE orders DROP COLUMN IF EXISTS cod_fee_cents;
+
+COMMENT ON COLUMN orders.payment_gateway IS 'razorpay, stripe or fake; the razorpay_* columns hold this gateway''s order and payment IDs';
+
+-- Restore previous status constraint
+UPDATE orders SET status = 'cancelled' WHERE status = 'cod_pending';
+ALTER TABLE orders DROP CONSTRAINT valid_status;
+ALTER TABLE orders ADD CONSTRAINT valid_status
+    CHECK (status IN ('created', 'pending', 'paid', 'failed', 'cancelled', 'refunded', 'partially_refunded',
+                      'payment_review'));
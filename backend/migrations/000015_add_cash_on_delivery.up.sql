@@ -0,0 +1,12 @@
+-- Cash on delivery orders wait in cod_pending until the courier's collection is confirmed
+ALTER TABLE orders DROP CONSTRAINT valid_status;
+ALTER TABLE orders ADD CONSTRAINT valid_status
+    CHECK (status IN ('created', 'pending', 'paid', 'failed', 'cancelled', 'refunded', 'partially_refunded',
+                      'payment_review', 'cod_pending'));
+
+ALTER TABLE orders ADD COLUMN cod_fee_cents INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD CONSTRAINT cod_fee_non_negative CHECK (cod_fee_cents >= 0);
+
+-- Comments for documentation
+COMMENT ON COLUMN orders.cod_fee_cents IS 'Cash on delivery fee included in amount_cents';
+COMMENT ON COLUMN orders.payment_gateway IS 'razorpay, stripe, fake, or cod for cash on delivery; the razorpay_* columns hold this gateway''s order and payment IDs';
@@ -0,0 +1,13 @@
+-- Remove coupon columns from orders
+DROP INDEX IF EXISTS idx_orders_coupon_code;
+ALTER TABLE orders DROP COLUMN IF EXISTS discount;
+ALTER TABLE orders DROP COLUMN IF EXISTS discount_cents;
+ALTER TABLE orders DROP COLUMN IF EXISTS coupon_code;
+
+-- Drop trigger
+DROP TRIGGER IF EXISTS coupons_updated_at ON coupons;
+DROP FUNCTION IF EXISTS update_coupons_updated_at();
+
+-- Drop tables
+DROP TABLE IF EXISTS coupon_redemptions CASCADE;
+DROP TABLE IF EXISTS coupons CASCADE;
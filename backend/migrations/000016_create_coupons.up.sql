@@ -0,0 +1,71 @@
+-- Create coupons table
+CREATE TABLE coupons (
+                         id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                         code TEXT UNIQUE NOT NULL,
+                         description TEXT,
+                         discount_type TEXT NOT NULL,
+                         value INTEGER NOT NULL CHECK (value > 0),
+                         min_cart_cents INTEGER NOT NULL DEFAULT 0 CHECK (min_cart_cents >= 0),
+                         max_discount_cents INTEGER CHECK (max_discount_cents > 0),
+                         starts_at TIMESTAMP WITH TIME ZONE,
+                         ends_at TIMESTAMP WITH TIME ZONE,
+                         usage_limit INTEGER CHECK (usage_limit > 0),
+                         per_user_limit INTEGER CHECK (per_user_limit > 0),
+                         used_count INTEGER NOT NULL DEFAULT 0 CHECK (used_count >= 0),
+                         product_ids UUID[] NOT NULL DEFAULT '{}',
+                         categories TEXT[] NOT NULL DEFAULT '{}',
+                         active BOOLEAN NOT NULL DEFAULT TRUE,
+                         created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+                         updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+                         CONSTRAINT valid_discount_type CHECK (discount_type IN ('percentage', 'flat')),
+                         CONSTRAINT valid_percentage CHECK (discount_type <> 'percentage' OR value <= 100),
+                         CONSTRAINT valid_coupon_window CHECK (ends_at IS NULL OR starts_at IS NULL OR ends_at > starts_at)
+);
+
+-- Create coupon_redemptions table
+CREATE TABLE coupon_redemptions (
+                                    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                                    coupon_id UUID NOT NULL REFERENCES coupons(id) ON DELETE RESTRICT,
+                                    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                                    order_id UUID UNIQUE NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+                                    discount_cents INTEGER NOT NULL CHECK (discount_cents >= 0),
+                                    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+                                    released_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Applied coupon and discount breakdown on orders
+ALTER TABLE orders ADD COLUMN coupon_code TEXT;
+ALTER TABLE orders ADD COLUMN discount_cents INTEGER NOT NULL DEFAULT 0 CHECK (discount_cents >= 0);
+ALTER TABLE orders ADD COLUMN discount JSONB;
+
+-- Indexes for performance
+CREATE INDEX idx_coupons_active ON coupons(active) WHERE active = TRUE;
+CREATE INDEX idx_coupon_redemptions_coupon_user ON coupon_redemptions(coupon_id, user_id) WHERE released_at IS NULL;
+CREATE INDEX idx_orders_coupon_code ON orders(coupon_code) WHERE coupon_code IS NOT NULL;
+
+-- Trigger to update updated_at on coupons
+CREATE OR REPLACE FUNCTION update_coupons_updated_at()
+    RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = NOW();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER coupons_updated_at
+    BEFORE UPDATE ON coupons
+    FOR EACH ROW
+EXECUTE FUNCTION update_coupons_updated_at();
+
+-- Comments for documentation
+COMMENT ON TABLE coupons IS 'Discount codes applied at checkout';
+COMMENT ON COLUMN coupons.code IS 'Upper-case code entered by customers';
+COMMENT ON COLUMN coupons.value IS 'Percent off for percentage coupons, paise off for flat coupons';
+COMMENT ON COLUMN coupons.min_cart_cents IS 'Minimum cart subtotal in paise before the discount';
+COMMENT ON COLUMN coupons.used_count IS 'Redemptions not released by a cancelled order';
+COMMENT ON COLUMN coupons.product_ids IS 'Products the discount applies to; empty with no categories means the whole cart';
+COMMENT ON COLUMN coupons.categories IS 'Product categories (metadata.category) the discount applies to';
+COMMENT ON TABLE coupon_redemptions IS 'Coupon use per order; released when the order is cancelled';
+COMMENT ON COLUMN orders.discount_cents IS 'Coupon discount in paise, already taken off amount_cents';
+COMMENT ON COLUMN orders.discount IS 'Applied coupon and per-line discount breakdown';
@@ -0,0 +1,8 @@
+-- Remove GST columns from orders
+ALTER TABLE orders DROP COLUMN IF EXISTS tax_breakdown;
+ALTER TABLE orders DROP COLUMN IF EXISTS tax_cents;
+
+-- Remove GST columns from products
+ALTER TABLE products DROP CONSTRAINT IF EXISTS valid_gst_rate;
+ALTER TABLE products DROP COLUMN IF EXISTS gst_rate_bps;
+ALTER TABLE products DROP COLUMN IF EXISTS hsn_code;
@@ -0,0 +1,15 @@
+-- GST classification per product
+ALTER TABLE products ADD COLUMN hsn_code TEXT;
+ALTER TABLE products ADD COLUMN gst_rate_bps INTEGER;
+ALTER TABLE products ADD CONSTRAINT valid_gst_rate
+    CHECK (gst_rate_bps IS NULL OR gst_rate_bps IN (0, 25, 300, 500, 1200, 1800, 2800));
+
+-- GST charged on each order
+ALTER TABLE orders ADD COLUMN tax_cents INTEGER NOT NULL DEFAULT 0 CHECK (tax_cents >= 0);
+ALTER TABLE orders ADD COLUMN tax_breakdown JSONB;
+
+-- Comments for documentation
+COMMENT ON COLUMN products.hsn_code IS 'HSN code printed on invoices; the configured default applies when NULL';
+COMMENT ON COLUMN products.gst_rate_bps IS 'GST rate in basis points (500 = 5%); the configured default applies when NULL';
+COMMENT ON COLUMN orders.tax_cents IS 'GST in paise, included in amount_cents';
+COMMENT ON COLUMN orders.tax_breakdown IS 'Line-level taxable value and CGST/SGST/IGST split';
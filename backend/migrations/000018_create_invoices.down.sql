@@ -0,0 +1,7 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_invoices_order_id;
+DROP INDEX IF EXISTS idx_invoices_order_invoice;
+
+-- Drop tables
+DROP TABLE IF EXISTS invoices;
+DROP TABLE IF EXISTS invoice_sequences;
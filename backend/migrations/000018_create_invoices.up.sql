@@ -0,0 +1,38 @@
+-- Create invoice_sequences table
+CREATE TABLE invoice_sequences (
+                                   series TEXT NOT NULL,
+                                   financial_year TEXT NOT NULL,
+                                   last_number INTEGER NOT NULL DEFAULT 0 CHECK (last_number >= 0),
+
+                                   PRIMARY KEY (series, financial_year)
+);
+
+-- Create invoices table
+CREATE TABLE invoices (
+                          id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                          order_id UUID NOT NULL REFERENCES orders(id) ON DELETE RESTRICT,
+                          refund_id UUID UNIQUE REFERENCES refunds(id) ON DELETE RESTRICT,
+                          kind TEXT NOT NULL,
+                          number TEXT UNIQUE NOT NULL,
+                          financial_year TEXT NOT NULL,
+                          amount_cents INTEGER NOT NULL CHECK (amount_cents >= 0),
+                          tax_cents INTEGER NOT NULL CHECK (tax_cents >= 0),
+                          tax_breakdown JSONB,
+                          file_path TEXT NOT NULL,
+                          issued_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+                          created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+                          CONSTRAINT valid_invoice_kind CHECK (kind IN ('invoice', 'credit_note')),
+                          CONSTRAINT credit_note_refund CHECK ((kind = 'credit_note') = (refund_id IS NOT NULL))
+);
+
+-- One tax invoice per order
+CREATE UNIQUE INDEX idx_invoices_order_invoice ON invoices(order_id) WHERE kind = 'invoice';
+CREATE INDEX idx_invoices_order_id ON invoices(order_id);
+
+-- Comments for documentation
+COMMENT ON TABLE invoice_sequences IS 'Last number issued per document series and financial year';
+COMMENT ON TABLE invoices IS 'Tax invoices for paid orders and credit notes for their refunds';
+COMMENT ON COLUMN invoices.number IS 'Sequential per series and financial year, e.g. RC/2026-27/00001';
+COMMENT ON COLUMN invoices.financial_year IS 'Indian financial year (April to March), e.g. 2026-27';
+COMMENT ON COLUMN invoices.file_path IS 'PDF path relative to the invoice storage directory';
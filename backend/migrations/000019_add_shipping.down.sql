@@ -0,0 +1,10 @@
+-- Remove shipping columns from orders
+ALTER TABLE orders DROP COLUMN IF EXISTS shipping_weight_grams;
+ALTER TABLE orders DROP COLUMN IF EXISTS shipping_zone;
+ALTER TABLE orders DROP COLUMN IF EXISTS shipping_cents;
+
+-- Remove shipping columns from products
+ALTER TABLE products DROP COLUMN IF EXISTS height_cm;
+ALTER TABLE products DROP COLUMN IF EXISTS breadth_cm;
+ALTER TABLE products DROP COLUMN IF EXISTS length_cm;
+ALTER TABLE products DROP COLUMN IF EXISTS weight_grams;
@@ -0,0 +1,17 @@
+-- Shipping weight and package dimensions per product
+ALTER TABLE products ADD COLUMN weight_grams INTEGER CHECK (weight_grams > 0);
+ALTER TABLE products ADD COLUMN length_cm INTEGER CHECK (length_cm > 0);
+ALTER TABLE products ADD COLUMN breadth_cm INTEGER CHECK (breadth_cm > 0);
+ALTER TABLE products ADD COLUMN height_cm INTEGER CHECK (height_cm > 0);
+
+-- Shipping charged on each order
+ALTER TABLE orders ADD COLUMN shipping_cents INTEGER NOT NULL DEFAULT 0 CHECK (shipping_cents >= 0);
+ALTER TABLE orders ADD COLUMN shipping_zone TEXT;
+ALTER TABLE orders ADD COLUMN shipping_weight_grams INTEGER NOT NULL DEFAULT 0 CHECK (shipping_weight_grams >= 0);
+
+-- Comments for documentation
+COMMENT ON COLUMN products.weight_grams IS 'Packed weight; the shipping default applies when NULL';
+COMMENT ON COLUMN products.length_cm IS 'Packed length, used for volumetric weight with breadth and height';
+COMMENT ON COLUMN orders.shipping_cents IS 'Shipping in paise, included in amount_cents';
+COMMENT ON COLUMN orders.shipping_zone IS 'Shipping zone the order was quoted for';
+COMMENT ON COLUMN orders.shipping_weight_grams IS 'Chargeable weight the shipping was quoted for';
@@ -0,0 +1,10 @@
+-- Drop trigger and function
+DROP TRIGGER IF EXISTS shipments_updated_at ON shipments;
+DROP FUNCTION IF EXISTS update_shipments_updated_at();
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_shipments_carrier_awb;
+DROP INDEX IF EXISTS idx_shipments_order_active;
+
+-- Drop table
+DROP TABLE IF EXISTS shipments;
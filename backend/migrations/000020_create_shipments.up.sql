@@ -0,0 +1,50 @@
+-- Create shipments table
+CREATE TABLE shipments (
+                           id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                           order_id UUID NOT NULL REFERENCES orders(id) ON DELETE RESTRICT,
+                           carrier TEXT NOT NULL,
+                           carrier_order_id TEXT,
+                           carrier_shipment_id TEXT NOT NULL,
+                           awb TEXT NOT NULL,
+                           courier_name TEXT,
+                           tracking_url TEXT,
+                           status TEXT NOT NULL DEFAULT 'booked',
+                           carrier_status TEXT,
+                           weight_grams INTEGER NOT NULL CHECK (weight_grams > 0),
+                           length_cm INTEGER NOT NULL CHECK (length_cm > 0),
+                           breadth_cm INTEGER NOT NULL CHECK (breadth_cm > 0),
+                           height_cm INTEGER NOT NULL CHECK (height_cm > 0),
+                           pickup_scheduled_for TIMESTAMP WITH TIME ZONE,
+                           pickup_reference TEXT,
+                           last_event_at TIMESTAMP WITH TIME ZONE,
+                           created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+                           updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+                           CONSTRAINT valid_shipment_status CHECK (status IN ('booked', 'pickup_scheduled', 'in_transit', 'out_for_delivery',
+                                                                               'delivered', 'returning', 'returned', 'cancelled'))
+);
+
+-- One live shipment per order; a cancelled shipment can be booked again
+CREATE UNIQUE INDEX idx_shipments_order_active ON shipments(order_id) WHERE status <> 'cancelled';
+CREATE UNIQUE INDEX idx_shipments_carrier_awb ON shipments(carrier, awb);
+
+-- Trigger to update updated_at on shipments
+CREATE OR REPLACE FUNCTION update_shipments_updated_at()
+    RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = NOW();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER shipments_updated_at
+    BEFORE UPDATE ON shipments
+    FOR EACH ROW
+EXECUTE FUNCTION update_shipments_updated_at();
+
+-- Comments for documentation
+COMMENT ON TABLE shipments IS 'Parcels booked with a shipping carrier for an order';
+COMMENT ON COLUMN shipments.carrier IS 'Carrier the shipment was booked with: shiprocket or fake';
+COMMENT ON COLUMN shipments.courier_name IS 'Courier assigned by the carrier, e.g. Delhivery';
+COMMENT ON COLUMN shipments.carrier_status IS 'Latest tracking status as the carrier reported it';
+COMMENT ON COLUMN shipments.last_event_at IS 'When the latest tracking event happened, used to ignore stale webhooks';
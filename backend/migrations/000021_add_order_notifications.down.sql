@@ -0,0 +1,6 @@
+-- Remove order notification tracking
+DROP INDEX IF EXISTS idx_refunds_unnotified;
+DROP INDEX IF EXISTS idx_order_status_history_unnotified;
+
+ALTER TABLE refunds DROP COLUMN IF EXISTS notified_at;
+ALTER TABLE order_status_history DROP COLUMN IF EXISTS notified_at;
@@ -0,0 +1,14 @@
+-- Order emails go out from the status history and processed refunds
+ALTER TABLE order_status_history ADD COLUMN notified_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE refunds ADD COLUMN notified_at TIMESTAMP WITH TIME ZONE;
+
+-- Do not email customers about changes made before notifications existed
+UPDATE order_status_history SET notified_at = NOW();
+UPDATE refunds SET notified_at = NOW() WHERE status = 'processed';
+
+CREATE INDEX idx_order_status_history_unnotified ON order_status_history(created_at) WHERE notified_at IS NULL;
+CREATE INDEX idx_refunds_unnotified ON refunds(processed_at) WHERE status = 'processed' AND notified_at IS NULL;
+
+-- Comments for documentation
+COMMENT ON COLUMN order_status_history.notified_at IS 'When the change was emailed to the customer, or found to need no email';
+COMMENT ON COLUMN refunds.notified_at IS 'When the processed refund was emailed to the customer';
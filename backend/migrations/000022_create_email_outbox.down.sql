@@ -0,0 +1,11 @@
+-- Drop trigger and function
+DROP TRIGGER IF EXISTS email_outbox_updated_at ON email_outbox;
+DROP FUNCTION IF EXISTS update_email_outbox_updated_at();
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_email_outbox_created_at;
+DROP INDEX IF EXISTS idx_email_outbox_due;
+DROP INDEX IF EXISTS idx_email_outbox_dedupe;
+
+-- Drop table
+DROP TABLE IF EXISTS email_outbox;
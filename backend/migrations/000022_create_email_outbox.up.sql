@@ -0,0 +1,44 @@
+-- Create email_outbox table
+CREATE TABLE email_outbox (
+                              id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                              template TEXT NOT NULL,
+                              recipient TEXT NOT NULL,
+                              data JSONB NOT NULL,
+                              dedupe_key TEXT,
+                              status TEXT NOT NULL DEFAULT 'pending',
+                              attempts INTEGER NOT NULL DEFAULT 0,
+                              next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+                              locked_until TIMESTAMP WITH TIME ZONE,
+                              last_error TEXT,
+                              sent_at TIMESTAMP WITH TIME ZONE,
+                              created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+                              updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+                              CONSTRAINT valid_email_outbox_status CHECK (status IN ('pending', 'sending', 'sent', 'dead'))
+);
+
+-- A recipient gets each deduplicated email once
+CREATE UNIQUE INDEX idx_email_outbox_dedupe ON email_outbox(recipient, dedupe_key) WHERE dedupe_key IS NOT NULL;
+CREATE INDEX idx_email_outbox_due ON email_outbox(next_attempt_at) WHERE status IN ('pending', 'sending');
+CREATE INDEX idx_email_outbox_created_at ON email_outbox(created_at DESC);
+
+-- Trigger to update updated_at on email_outbox
+CREATE OR REPLACE FUNCTION update_email_outbox_updated_at()
+    RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = NOW();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER email_outbox_updated_at
+    BEFORE UPDATE ON email_outbox
+    FOR EACH ROW
+EXECUTE FUNCTION update_email_outbox_updated_at();
+
+-- Comments for documentation
+COMMENT ON TABLE email_outbox IS 'Emails queued with the change they announce and sent by the email workers';
+COMMENT ON COLUMN email_outbox.data IS 'Template data, rendered when the email is sent';
+COMMENT ON COLUMN email_outbox.dedupe_key IS 'Identifies the event the email is about, e.g. welcome:<user id>; NULL allows repeats';
+COMMENT ON COLUMN email_outbox.status IS 'pending, sending (claimed until locked_until), sent, dead (attempts ran out)';
+COMMENT ON COLUMN email_outbox.next_attempt_at IS 'When a pending email is sent next';
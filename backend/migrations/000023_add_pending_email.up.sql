@@ -0,0 +1,5 @@
+-- An email change takes effect once the new address is verified
+ALTER TABLE users ADD COLUMN pending_email TEXT;
+
+-- Comments for documentation
+COMMENT ON COLUMN users.pending_email IS 'Requested new email address, applied when its verification link is opened';
@@ -0,0 +1,6 @@
+-- Set when a user unlinks their Google account, so logging in with Google
+-- under the same email no longer links it back
+ALTER TABLE users ADD COLUMN google_link_disabled BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Comments for documentation
+COMMENT ON COLUMN users.google_link_disabled IS 'Google login may not link itself to this account by email; cleared when the user links Google from their account page';
@@ -0,0 +1,347 @@
+package notifications
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/ramniya/ramniya-backend/auth"
+	"github.com/ramniya/ramniya-backend/email"
+	"github.com/ramniya/ramniya-backend/orders"
+	"go.uber.org/zap"
+)
+
+// OrderStore is the subset of the order repository used by the notifier
+type OrderStore interface {
+	GetOrder(ctx context.Context, orderID uuid.UUID) (*orders.Order, error)
+	ListUnnotifiedStatusChanges(ctx context.Context, limit int) ([]orders.StatusChange, error)
+	MarkStatusChangeNotified(ctx context.Context, id uuid.UUID, then func(tx *sql.Tx) error) error
+	ListUnnotifiedRefunds(ctx context.Context, limit int) ([]orders.Refund, error)
+	MarkRefundNotified(ctx context.Context, id uuid.UUID, then func(tx *sql.Tx) error) error
+}
+
+// Outbox queues emails for sending
+type Outbox interface {
+	Enqueue(ctx context.Context, exec email.Execer, out email.Outgoing) (bool, error)
+}
+
+// UserStore looks up the customers orders belong to
+type UserStore interface {
+	GetUserByID(ctx context.Context, id uuid.UUID) (*auth.User, error)
+}
+
+// Config controls where order emails link to and who gets the admin copy
+type Config struct {
+	FrontendURL string
+	AdminEmail  string // receives new-order notifications; empty disables
+	BatchSize   int
+}
+
+// RunResult summarises a notifier run
+type RunResult struct {
+	Queued int
+	Errors int
+}
+
+// Kind is the email a status change calls for, named after its template
+type Kind string
+
+const (
+	KindNone          Kind = ""
+	KindConfirmation  Kind = email.TemplateOrderConfirmation
+	KindPaymentFailed Kind = email.TemplatePaymentFailed
+	KindShipped       Kind = email.TemplateOrderShipped
+	KindDelivered     Kind = email.TemplateOrderDelivered
+	KindCancelled     Kind = email.TemplateOrderCancelled
+)
+
+// KindFor returns the email a status change calls for, if any. Orders are
+// confirmed when paid or placed for cash on delivery; collecting the cash
+// later, or a capture on a cancelled order that will be refunded, sends
+// nothing. Only orders the customer was told about get a cancellation email:
+// unpaid orders that expire or are abandoned do not.
+func KindFor(change orders.StatusChange) Kind {
+	from := ""
+	if change.FromStatus != nil {
+		from = *change.FromStatus
+	}
+
+	switch change.Kind {
+	case orders.StatusKindPayment:
+		switch orders.OrderStatus(change.ToStatus) {
+		case orders.OrderStatusPaid:
+			if from == string(orders.OrderStatusCODPending) || from == string(orders.OrderStatusCancelled) {
+				return KindNone
+			}
+			return KindConfirmation
+		case orders.OrderStatusCODPending:
+			return KindConfirmation
+		case orders.OrderStatusFailed:
+			return KindPaymentFailed
+		case orders.OrderStatusCancelled:
+			if from == string(orders.OrderStatusCODPending) || from == string(orders.OrderStatusPaymentReview) {
+				return KindCancelled
+			}
+		}
+	case orders.StatusKindFulfilment:
+		switch orders.FulfilmentStatus(change.ToStatus) {
+		case orders.FulfilmentShipped:
+			return KindShipped
+		case orders.FulfilmentDelivered:
+			return KindDelivered
+		}
+	}
+	return KindNone
+}
+
+// Notifier emails customers about their orders. It works through the order
+// status history and processed refunds, so every transition recorded by
+// the orders package is considered exactly once whichever handler, webhook
+// or job made it. Emails are queued in the outbox in the same transaction
+// that marks the change notified.
+type Notifier struct {
+	orders OrderStore
+	users  UserStore
+	outbox Outbox
+	logger *zap.Logger
+	config Config
+}
+
+// NewNotifier creates a new order notifier
+func NewNotifier(orderStore OrderStore, users UserStore, outbox Outbox, logger *zap.Logger, config Config) *Notifier {
+	if config.BatchSize <= 0 {
+		config.BatchSize = 50
+	}
+
+	return &Notifier{
+		orders: orderStore,
+		users:  users,
+		outbox: outbox,
+		logger: logger,
+		config: config,
+	}
+}
+
+// Run queues the emails called for by new status changes and processed
+// refunds. A change whose email cannot be prepared is retried on the next
+// run, and later changes to the same order wait for it so emails are queued
+// in order.
+func (n *Notifier) Run(ctx context.Context) (*RunResult, error) {
+	result := &RunResult{}
+
+	changes, err := n.orders.ListUnnotifiedStatusChanges(ctx, n.config.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	held := map[uuid.UUID]bool{}
+	for _, change := range changes {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		if held[change.OrderID] {
+			continue
+		}
+
+		var emails []email.Outgoing
+		if kind := KindFor(change); kind != KindNone {
+			emails, err = n.statusEmails(ctx, change, kind)
+			if err != nil {
+				n.logger.Error("Failed to prepare order email",
+					zap.String("order_id", change.OrderID.String()),
+					zap.String("kind", string(kind)),
+					zap.Error(err),
+				)
+				result.Errors++
+				held[change.OrderID] = true
+				continue
+			}
+		}
+
+		if err := n.orders.MarkStatusChangeNotified(ctx, change.ID, n.enqueue(ctx, emails)); err != nil {
+			return result, err
+		}
+		result.Queued += len(emails)
+	}
+
+	refunds, err := n.orders.ListUnnotifiedRefunds(ctx, n.config.BatchSize)
+	if err != nil {
+		return result, err
+	}
+	for _, refund := range refunds {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		if held[refund.OrderID] {
+			continue
+		}
+
+		out, err := n.refundEmail(ctx, &refund)
+		if err != nil {
+			n.logger.Error("Failed to prepare refund email",
+				zap.String("order_id", refund.OrderID.String()),
+				zap.String("refund_id", refund.ID.String()),
+				zap.Error(err),
+			)
+			result.Errors++
+			continue
+		}
+
+		if err := n.orders.MarkRefundNotified(ctx, refund.ID, n.enqueue(ctx, []email.Outgoing{out})); err != nil {
+			return result, err
+		}
+		result.Queued++
+	}
+
+	return result, nil
+}
+
+// enqueue returns a callback that queues emails in the transaction marking
+// their change notified
+func (n *Notifier) enqueue(ctx context.Context, emails []email.Outgoing) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, out := range emails {
+			if _, err := n.outbox.Enqueue(ctx, tx, out); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// statusEmails returns the customer's email for a status change, and the
+// admin copy of new orders. Each order gets one email of a kind, except
+// payment failures, which are sent for every failed attempt.
+func (n *Notifier) statusEmails(ctx context.Context, change orders.StatusChange, kind Kind) ([]email.Outgoing, error) {
+	order, user, err := n.load(ctx, change.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	msg := n.orderEmail(order, user)
+
+	dedupeKey := fmt.Sprintf("%s:%s", kind, order.ID)
+	if kind == KindPaymentFailed {
+		dedupeKey = fmt.Sprintf("%s:%s", kind, change.ID)
+	}
+
+	emails := []email.Outgoing{{
+		To:        user.Email,
+		Template:  string(kind),
+		Data:      email.OrderData{Name: customerName(user), Order: msg},
+		DedupeKey: dedupeKey,
+	}}
+
+	if kind == KindConfirmation && n.config.AdminEmail != "" {
+		msg.URL = n.config.FrontendURL + "/admin/orders"
+		emails = append(emails, email.Outgoing{
+			To:        n.config.AdminEmail,
+			Template:  email.TemplateNewOrder,
+			Data:      email.OrderData{Order: msg},
+			DedupeKey: fmt.Sprintf("%s:%s", email.TemplateNewOrder, order.ID),
+		})
+	}
+
+	return emails, nil
+}
+
+// refundEmail returns the customer's email for a processed refund
+func (n *Notifier) refundEmail(ctx context.Context, refund *orders.Refund) (email.Outgoing, error) {
+	order, user, err := n.load(ctx, refund.OrderID)
+	if err != nil {
+		return email.Outgoing{}, err
+	}
+
+	msg := n.orderEmail(order, user)
+	msg.RefundCents = refund.AmountCents
+	if refund.Reason != nil {
+		msg.RefundReason = *refund.Reason
+	}
+
+	return email.Outgoing{
+		To:        user.Email,
+		Template:  email.TemplateOrderRefunded,
+		Data:      email.OrderData{Name: customerName(user), Order: msg},
+		DedupeKey: fmt.Sprintf("%s:%s", email.TemplateOrderRefunded, refund.ID),
+	}, nil
+}
+
+func (n *Notifier) load(ctx context.Context, orderID uuid.UUID) (*orders.Order, *auth.User, error) {
+	order, err := n.orders.GetOrder(ctx, orderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	user, err := n.users.GetUserByID(ctx, order.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return order, user, nil
+}
+
+// orderEmail collects the details order emails show
+func (n *Notifier) orderEmail(order *orders.Order, user *auth.User) email.OrderEmail {
+	msg := email.OrderEmail{
+		Reference:      Reference(order.ID),
+		URL:            fmt.Sprintf("%s/orders/%s", n.config.FrontendURL, order.ID),
+		CustomerName:   user.Email,
+		CustomerEmail:  user.Email,
+		DiscountCents:  order.DiscountCents,
+		TaxCents:       order.TaxCents,
+		TaxIncluded:    order.Tax != nil && order.Tax.PricesIncludeTax,
+		ShippingCents:  order.ShippingCents,
+		CODFeeCents:    order.CODFeeCents,
+		TotalCents:     order.AmountCents,
+		CashOnDelivery: order.PaymentGateway == orders.PaymentMethodCOD,
+	}
+	if user.Name != nil && *user.Name != "" {
+		msg.CustomerName = *user.Name
+	}
+	if order.CouponCode != nil {
+		msg.CouponCode = *order.CouponCode
+	}
+
+	for _, item := range order.Items {
+		msg.Items = append(msg.Items, email.OrderEmailItem{
+			Title:      item.Title,
+			Quantity:   item.Quantity,
+			PriceCents: item.PriceCents,
+		})
+		msg.SubtotalCents += item.PriceCents * item.Quantity
+	}
+
+	address := order.ShippingAddress
+	msg.ShippingAddress = []string{address.Name, address.Line1}
+	if address.Line2 != "" {
+		msg.ShippingAddress = append(msg.ShippingAddress, address.Line2)
+	}
+	msg.ShippingAddress = append(msg.ShippingAddress,
+		fmt.Sprintf("%s, %s %s", address.City, address.State, address.Pincode),
+		"Phone: "+address.Phone,
+	)
+
+	if order.Carrier != nil {
+		msg.Courier = *order.Carrier
+	}
+	if order.TrackingNumber != nil {
+		msg.TrackingNumber = *order.TrackingNumber
+	}
+	if order.TrackingURL != nil {
+		msg.TrackingURL = *order.TrackingURL
+	}
+
+	return msg
+}
+
+// Reference is the short order number shown to customers
+func Reference(orderID uuid.UUID) string {
+	return strings.ToUpper(orderID.String()[:8])
+}
+
+// customerName is how emails greet a customer
+func customerName(user *auth.User) string {
+	if user.Name != nil && *user.Name != "" {
+		return *user.Name
+	}
+	return "there"
+}
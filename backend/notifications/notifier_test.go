@@ -0,0 +1,231 @@
+package notifications
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/ramniya/ramniya-backend/auth"
+	"github.com/ramniya/ramniya-backend/email"
+	"github.com/ramniya/ramniya-backend/orders"
+	"go.uber.org/zap"
+)
+
+type fakeStore struct {
+	orders          map[uuid.UUID]*orders.Order
+	changes         []orders.StatusChange
+	refunds         []orders.Refund
+	notifiedChanges map[uuid.UUID]bool
+	notifiedRefunds map[uuid.UUID]bool
+}
+
+func (s *fakeStore) GetOrder(ctx context.Context, orderID uuid.UUID) (*orders.Order, error) {
+	order, ok := s.orders[orderID]
+	if !ok {
+		return nil, fmt.Errorf("order not found")
+	}
+	return order, nil
+}
+
+func (s *fakeStore) ListUnnotifiedStatusChanges(ctx context.Context, limit int) ([]orders.StatusChange, error) {
+	var changes []orders.StatusChange
+	for _, change := range s.changes {
+		if !s.notifiedChanges[change.ID] {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+func (s *fakeStore) MarkStatusChangeNotified(ctx context.Context, id uuid.UUID, then func(tx *sql.Tx) error) error {
+	if err := then(nil); err != nil {
+		return err
+	}
+	s.notifiedChanges[id] = true
+	return nil
+}
+
+func (s *fakeStore) ListUnnotifiedRefunds(ctx context.Context, limit int) ([]orders.Refund, error) {
+	var refunds []orders.Refund
+	for _, refund := range s.refunds {
+		if !s.notifiedRefunds[refund.ID] {
+			refunds = append(refunds, refund)
+		}
+	}
+	return refunds, nil
+}
+
+func (s *fakeStore) MarkRefundNotified(ctx context.Context, id uuid.UUID, then func(tx *sql.Tx) error) error {
+	if err := then(nil); err != nil {
+		return err
+	}
+	s.notifiedRefunds[id] = true
+	return nil
+}
+
+type fakeUsers map[uuid.UUID]*auth.User
+
+func (u fakeUsers) GetUserByID(ctx context.Context, id uuid.UUID) (*auth.User, error) {
+	user, ok := u[id]
+	if !ok {
+		return nil, fmt.Errorf("user not found")
+	}
+	return user, nil
+}
+
+// fakeOutbox records each queued email as "template to reference", and
+// drops repeats of a recipient's dedupe key
+type fakeOutbox struct {
+	queued []string
+	keys   map[string]bool
+}
+
+func (o *fakeOutbox) Enqueue(ctx context.Context, exec email.Execer, out email.Outgoing) (bool, error) {
+	if o.keys[out.To+" "+out.DedupeKey] {
+		return false, nil
+	}
+	o.keys[out.To+" "+out.DedupeKey] = true
+
+	order := out.Data.(email.OrderData).Order
+	template := out.Template
+	switch template {
+	case email.TemplateOrderShipped:
+		template += ":" + order.TrackingNumber
+	case email.TemplateOrderRefunded:
+		template += fmt.Sprintf(":%d", order.RefundCents)
+	}
+	o.queued = append(o.queued, template+" "+out.To+" "+order.Reference)
+	return true, nil
+}
+
+func change(orderID uuid.UUID, kind orders.StatusKind, from, to string) orders.StatusChange {
+	c := orders.StatusChange{ID: uuid.New(), OrderID: orderID, Kind: kind, ToStatus: to}
+	if from != "" {
+		c.FromStatus = &from
+	}
+	return c
+}
+
+func TestKindFor(t *testing.T) {
+	id := uuid.New()
+	payment, fulfilment := orders.StatusKindPayment, orders.StatusKindFulfilment
+
+	tests := []struct {
+		change orders.StatusChange
+		want   Kind
+	}{
+		{change(id, payment, "", "created"), KindNone},
+		{change(id, payment, "created", "pending"), KindNone},
+		{change(id, payment, "pending", "paid"), KindConfirmation},
+		{change(id, payment, "payment_review", "paid"), KindConfirmation},
+		{change(id, payment, "created", "cod_pending"), KindConfirmation},
+		{change(id, payment, "cod_pending", "paid"), KindNone},
+		{change(id, payment, "cancelled", "paid"), KindNone},
+		{change(id, payment, "pending", "failed"), KindPaymentFailed},
+		{change(id, payment, "pending", "cancelled"), KindNone},
+		{change(id, payment, "cod_pending", "cancelled"), KindCancelled},
+		{change(id, payment, "payment_review", "cancelled"), KindCancelled},
+		{change(id, payment, "paid", "refunded"), KindNone},
+		{change(id, fulfilment, "packed", "shipped"), KindShipped},
+		{change(id, fulfilment, "out_for_delivery", "delivered"), KindDelivered},
+		{change(id, fulfilment, "unfulfilled", "packed"), KindNone},
+	}
+	for _, tt := range tests {
+		if got := KindFor(tt.change); got != tt.want {
+			t.Errorf("KindFor(%s %v -> %s) = %q, want %q", tt.change.Kind, tt.change.FromStatus, tt.change.ToStatus, got, tt.want)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	userID := uuid.New()
+	paid := &orders.Order{ID: uuid.New(), UserID: userID, PaymentGateway: "razorpay", AmountCents: 105000}
+	awb := "FAKE00000001"
+	paid.TrackingNumber = &awb
+	cod := &orders.Order{ID: uuid.New(), UserID: userID, PaymentGateway: orders.PaymentMethodCOD, AmountCents: 50000}
+
+	store := &fakeStore{
+		orders: map[uuid.UUID]*orders.Order{paid.ID: paid, cod.ID: cod},
+		changes: []orders.StatusChange{
+			change(paid.ID, orders.StatusKindPayment, "", "created"),
+			change(paid.ID, orders.StatusKindPayment, "pending", "paid"),
+			change(cod.ID, orders.StatusKindPayment, "created", "cod_pending"),
+			change(paid.ID, orders.StatusKindFulfilment, "packed", "shipped"),
+		},
+		refunds:         []orders.Refund{{ID: uuid.New(), OrderID: paid.ID, AmountCents: 20000, Status: orders.RefundStatusProcessed}},
+		notifiedChanges: map[uuid.UUID]bool{},
+		notifiedRefunds: map[uuid.UUID]bool{},
+	}
+	name := "Asha"
+	users := fakeUsers{}
+	outbox := &fakeOutbox{keys: map[string]bool{}}
+
+	notifier := NewNotifier(store, users, outbox, zap.NewNop(), Config{
+		FrontendURL: "https://ramniya.com",
+		AdminEmail:  "orders@ramniya.com",
+	})
+
+	// While the customer cannot be loaded nothing is marked, and later
+	// emails for the same order wait so they are queued in order
+	result, err := notifier.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.Queued != 0 || result.Errors != 2 {
+		t.Errorf("Failing run = %+v, want 2 errors", *result)
+	}
+	if len(store.notifiedChanges) != 1 || len(store.notifiedRefunds) != 0 {
+		t.Errorf("Marked %d changes and %d refunds, want only the created change", len(store.notifiedChanges), len(store.notifiedRefunds))
+	}
+
+	users[userID] = &auth.User{ID: userID, Email: "asha@example.com", Name: &name}
+	result, err = notifier.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.Queued != 6 || result.Errors != 0 {
+		t.Errorf("Run = %+v, want 6 queued", *result)
+	}
+
+	paidRef, codRef := Reference(paid.ID), Reference(cod.ID)
+	want := []string{
+		"order_confirmation asha@example.com " + paidRef,
+		"new_order orders@ramniya.com " + paidRef,
+		"order_confirmation asha@example.com " + codRef,
+		"new_order orders@ramniya.com " + codRef,
+		"order_shipped:FAKE00000001 asha@example.com " + paidRef,
+		"order_refunded:20000 asha@example.com " + paidRef,
+	}
+	if fmt.Sprint(outbox.queued) != fmt.Sprint(want) {
+		t.Errorf("Queued:\n%v\nwant:\n%v", outbox.queued, want)
+	}
+
+	// Nothing is queued twice
+	outbox.queued = nil
+	if _, err := notifier.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(outbox.queued) != 0 {
+		t.Errorf("Second run queued %v", outbox.queued)
+	}
+
+	// A repeated shipment of the order is not emailed again, but every
+	// failed payment is
+	store.changes = append(store.changes,
+		change(paid.ID, orders.StatusKindFulfilment, "packed", "shipped"),
+		change(cod.ID, orders.StatusKindPayment, "pending", "failed"),
+		change(cod.ID, orders.StatusKindPayment, "pending", "failed"),
+	)
+	if _, err := notifier.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	want = []string{
+		"payment_failed asha@example.com " + codRef,
+		"payment_failed asha@example.com " + codRef,
+	}
+	if fmt.Sprint(outbox.queued) != fmt.Sprint(want) {
+		t.Errorf("Queued:\n%v\nwant:\n%v", outbox.queued, want)
+	}
+}
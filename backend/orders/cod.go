@@ -0,0 +1,119 @@
+package orders
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrCODCollectionRequired is returned when a cash on delivery order is
+	// marked paid other than by confirming collection
+	ErrCODCollectionRequired = errors.New("cash on delivery orders are paid by confirming collection")
+	// ErrCODNotDelivered is returned when collection is confirmed before delivery
+	ErrCODNotDelivered = errors.New("cash on delivery order has not been delivered")
+	// ErrNotCODOrder is returned when an order paid online is moved to
+	// cod_pending
+	ErrNotCODOrder = errors.New("order is not a cash on delivery order")
+)
+
+// ConfirmCODOrder moves a newly created order to cod_pending. Its stock is
+// committed straight away, since nothing is paid until the parcel arrives
+// and the reservation must not expire in the meantime.
+func (r *OrderRepository) ConfirmCODOrder(ctx context.Context, orderID uuid.UUID) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	from, err := lockOrderStatus(ctx, tx, orderID)
+	if err != nil {
+		return err
+	}
+
+	if !CanTransition(from, OrderStatusCODPending) {
+		return &InvalidTransitionError{From: from, To: OrderStatusCODPending}
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE orders SET status = $1, updated_at = NOW() WHERE id = $2",
+		OrderStatusCODPending, orderID,
+	); err != nil {
+		return fmt.Errorf("failed to update order: %w", err)
+	}
+
+	if err := commitReservations(ctx, tx, orderID); err != nil {
+		return err
+	}
+
+	if from != OrderStatusCODPending {
+		if err := recordTransition(ctx, tx, orderID, &from, OrderStatusCODPending, Actor{Type: ActorSystem, ID: "checkout"}, "Cash on delivery"); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// CollectCODPayment marks a delivered cash on delivery order as paid once the
+// cash has been collected. Returns ErrCODNotDelivered before delivery.
+func (r *OrderRepository) CollectCODPayment(ctx context.Context, orderID uuid.UUID, actor Actor, note string) (*Order, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var from OrderStatus
+	var fulfilment FulfilmentStatus
+	err = tx.QueryRowContext(ctx,
+		"SELECT status, fulfilment_status FROM orders WHERE id = $1 FOR UPDATE",
+		orderID,
+	).Scan(&from, &fulfilment)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("order not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	if from != OrderStatusCODPending {
+		return nil, &InvalidTransitionError{From: from, To: OrderStatusPaid}
+	}
+	if fulfilment != FulfilmentDelivered {
+		return nil, ErrCODNotDelivered
+	}
+
+	query := `
+		UPDATE orders
+		SET status = $1, paid_at = NOW(), updated_at = NOW()
+		WHERE id = $2
+		RETURNING ` + orderColumns + `
+	`
+
+	order, err := scanOrder(tx.QueryRowContext(ctx, query, OrderStatusPaid, orderID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update order: %w", err)
+	}
+
+	if note == "" {
+		note = "Cash collected on delivery"
+	}
+	if err := recordTransition(ctx, tx, orderID, &from, OrderStatusPaid, actor, note); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return order, nil
+}
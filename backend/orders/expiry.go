@@ -0,0 +1,107 @@
+package orders
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ramniya/ramniya-backend/promotions"
+)
+
+// DefaultOrderExpiry is how long an order may stay unpaid before it is expired
+const DefaultOrderExpiry = 2 * time.Hour
+
+// expiryActor is recorded in the status history for expired orders
+var expiryActor = Actor{Type: ActorSystem, ID: "order_expiry"}
+
+// ListExpiredUnpaidOrders returns created or pending orders older than
+// olderThan, oldest first
+func (r *OrderRepository) ListExpiredUnpaidOrders(ctx context.Context, olderThan time.Duration, limit int) ([]Order, error) {
+	query := `
+		SELECT ` + orderColumns + `
+		FROM orders
+		WHERE status IN ('created', 'pending')
+		  AND created_at < $1
+		ORDER BY created_at
+		LIMIT $2
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, time.Now().Add(-olderThan), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expired orders: %w", err)
+	}
+	defer rows.Close()
+
+	orders := []Order{}
+	for rows.Next() {
+		o, err := scanOrder(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order: %w", err)
+		}
+		orders = append(orders, *o)
+	}
+
+	return orders, rows.Err()
+}
+
+// ExpireOrder moves an unpaid order older than olderThan to status (cancelled
+// or failed) and releases its reserved stock and its coupon use. It re-checks
+// the order under a row lock and returns false without changes if it was paid
+// or otherwise moved on in the meantime, so repeated runs are safe.
+func (r *OrderRepository) ExpireOrder(ctx context.Context, orderID uuid.UUID, olderThan time.Duration, status OrderStatus) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var from OrderStatus
+	var createdAt time.Time
+	err = tx.QueryRowContext(ctx,
+		"SELECT status, created_at FROM orders WHERE id = $1 FOR UPDATE",
+		orderID,
+	).Scan(&from, &createdAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	if from != OrderStatusCreated && from != OrderStatusPending {
+		return false, nil
+	}
+	if time.Since(createdAt) < olderThan {
+		return false, nil
+	}
+	if !CanTransition(from, status) {
+		return false, &InvalidTransitionError{From: from, To: status}
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE orders SET status = $1, updated_at = NOW() WHERE id = $2",
+		status, orderID,
+	); err != nil {
+		return false, fmt.Errorf("failed to expire order: %w", err)
+	}
+
+	if err := releaseReservations(ctx, tx, orderID); err != nil {
+		return false, err
+	}
+
+	if err := promotions.Release(ctx, tx, orderID); err != nil {
+		return false, err
+	}
+
+	if err := recordTransition(ctx, tx, orderID, &from, status, expiryActor, "Expired unpaid"); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,173 @@
+package orders
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// FulfilmentStatus tracks a paid order through dispatch and delivery
+type FulfilmentStatus string
+
+const (
+	FulfilmentUnfulfilled     FulfilmentStatus = "unfulfilled"
+	FulfilmentProcessing      FulfilmentStatus = "processing"
+	FulfilmentPacked          FulfilmentStatus = "packed"
+	FulfilmentShipped         FulfilmentStatus = "shipped"
+	FulfilmentOutForDelivery  FulfilmentStatus = "out_for_delivery"
+	FulfilmentDelivered       FulfilmentStatus = "delivered"
+	FulfilmentReturnRequested FulfilmentStatus = "return_requested"
+	FulfilmentReturned        FulfilmentStatus = "returned"
+)
+
+var (
+	// ErrOrderNotFulfillable is returned when dispatch starts on an unpaid
+	// order that is not cash on delivery
+	ErrOrderNotFulfillable = errors.New("order is not paid")
+	// ErrTrackingRequired is returned when an order is shipped without tracking details
+	ErrTrackingRequired = errors.New("carrier and tracking number are required to ship an order")
+)
+
+// fulfilmentTransitions lists the fulfilment statuses each status may move to
+var fulfilmentTransitions = map[FulfilmentStatus][]FulfilmentStatus{
+	FulfilmentUnfulfilled: {FulfilmentProcessing, FulfilmentPacked, FulfilmentShipped},
+	FulfilmentProcessing:  {FulfilmentPacked, FulfilmentShipped},
+	FulfilmentPacked:      {FulfilmentShipped},
+	// Undeliverable parcels come back to us (RTO) without a return request
+	FulfilmentShipped:        {FulfilmentOutForDelivery, FulfilmentDelivered, FulfilmentReturned},
+	FulfilmentOutForDelivery: {FulfilmentDelivered, FulfilmentReturned},
+	FulfilmentDelivered:      {FulfilmentReturnRequested},
+	// A rejected return request leaves the order delivered
+	FulfilmentReturnRequested: {FulfilmentReturned, FulfilmentDelivered},
+	FulfilmentReturned:        {},
+}
+
+// IsValid reports whether s is a known fulfilment status
+func (s FulfilmentStatus) IsValid() bool {
+	_, ok := fulfilmentTransitions[s]
+	return ok
+}
+
+// CanFulfilmentTransition reports whether an order's fulfilment may move from
+// one status to another
+func CanFulfilmentTransition(from, to FulfilmentStatus) bool {
+	if from == to {
+		return from.IsValid()
+	}
+	for _, allowed := range fulfilmentTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// InvalidFulfilmentTransitionError is returned when a fulfilment change is not allowed
+type InvalidFulfilmentTransitionError struct {
+	From FulfilmentStatus
+	To   FulfilmentStatus
+}
+
+func (e *InvalidFulfilmentTransitionError) Error() string {
+	return fmt.Sprintf("cannot change fulfilment status from %s to %s", e.From, e.To)
+}
+
+// UpdateFulfilmentInput represents a fulfilment status update. Nil tracking
+// fields keep their current values.
+type UpdateFulfilmentInput struct {
+	Status         FulfilmentStatus
+	Carrier        *string
+	TrackingNumber *string
+	TrackingURL    *string
+	Actor          Actor
+	Note           string
+}
+
+// UpdateFulfilment moves an order's fulfilment status and records the change
+// in the status history. Dispatch can only start once the order is paid or
+// confirmed for cash on delivery, and shipping requires a carrier and
+// tracking number.
+func (r *OrderRepository) UpdateFulfilment(ctx context.Context, orderID uuid.UUID, input UpdateFulfilmentInput) (*Order, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status OrderStatus
+	var from FulfilmentStatus
+	var carrier, trackingNumber *string
+	err = tx.QueryRowContext(ctx, `
+		SELECT status, fulfilment_status, carrier, tracking_number
+		FROM orders
+		WHERE id = $1
+		FOR UPDATE
+	`, orderID).Scan(&status, &from, &carrier, &trackingNumber)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("order not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	if !CanFulfilmentTransition(from, input.Status) {
+		return nil, &InvalidFulfilmentTransitionError{From: from, To: input.Status}
+	}
+
+	if from == FulfilmentUnfulfilled && input.Status != from &&
+		status != OrderStatusPaid && status != OrderStatusPartiallyRefunded && status != OrderStatusCODPending {
+		return nil, ErrOrderNotFulfillable
+	}
+
+	if input.Carrier != nil {
+		carrier = input.Carrier
+	}
+	if input.TrackingNumber != nil {
+		trackingNumber = input.TrackingNumber
+	}
+	if input.Status == FulfilmentShipped && (isBlank(carrier) || isBlank(trackingNumber)) {
+		return nil, ErrTrackingRequired
+	}
+
+	query := `
+		UPDATE orders
+		SET fulfilment_status = $1,
+		    carrier = COALESCE($2, carrier),
+		    tracking_number = COALESCE($3, tracking_number),
+		    tracking_url = COALESCE($4, tracking_url),
+		    packed_at = CASE WHEN $1 = 'packed' AND packed_at IS NULL THEN NOW() ELSE packed_at END,
+		    shipped_at = CASE WHEN $1 = 'shipped' AND shipped_at IS NULL THEN NOW() ELSE shipped_at END,
+		    delivered_at = CASE WHEN $1 = 'delivered' AND delivered_at IS NULL THEN NOW() ELSE delivered_at END,
+		    returned_at = CASE WHEN $1 = 'returned' AND returned_at IS NULL THEN NOW() ELSE returned_at END,
+		    updated_at = NOW()
+		WHERE id = $5
+		RETURNING ` + orderColumns + `
+	`
+
+	order, err := scanOrder(tx.QueryRowContext(ctx, query,
+		input.Status, input.Carrier, input.TrackingNumber, input.TrackingURL, orderID,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update fulfilment: %w", err)
+	}
+
+	if from != input.Status {
+		fromStr := string(from)
+		if err := recordStatusChange(ctx, tx, orderID, StatusKindFulfilment, &fromStr, string(input.Status), input.Actor, input.Note); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return order, nil
+}
+
+func isBlank(s *string) bool {
+	return s == nil || *s == ""
+}
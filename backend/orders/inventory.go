@@ -0,0 +1,319 @@
+package orders
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// ReservationStatus represents the state of a stock reservation
+type ReservationStatus string
+
+const (
+	ReservationReserved    ReservationStatus = "reserved"
+	ReservationCommitted   ReservationStatus = "committed"
+	ReservationReleased    ReservationStatus = "released"
+	ReservationBackordered ReservationStatus = "backordered"
+)
+
+// DefaultReservationTTL is how long unpaid orders hold stock
+const DefaultReservationTTL = 30 * time.Minute
+
+// StockShortage describes an order line that current stock cannot cover
+type StockShortage struct {
+	ProductID uuid.UUID `json:"product_id"`
+	VariantID uuid.UUID `json:"variant_id"`
+	Title     string    `json:"title"`
+	SKU       string    `json:"sku,omitempty"`
+	Requested int       `json:"requested"`
+	Available int       `json:"available"`
+}
+
+// InsufficientStockError is returned when an order cannot be reserved
+type InsufficientStockError struct {
+	Items []StockShortage
+}
+
+func (e *InsufficientStockError) Error() string {
+	skus := make([]string, 0, len(e.Items))
+	for _, item := range e.Items {
+		skus = append(skus, item.SKU)
+	}
+	return fmt.Sprintf("insufficient stock for: %s", strings.Join(skus, ", "))
+}
+
+// reserveStock locks the variant rows for the given items, deducts the
+// requested quantities and records a reservation for the order
+func reserveStock(ctx context.Context, tx *sql.Tx, orderID uuid.UUID, items []OrderItem, expiresAt time.Time) error {
+	// Aggregate quantities per variant so repeated lines are checked together
+	requested := make(map[uuid.UUID]int)
+	lines := make(map[uuid.UUID]OrderItem)
+	variantIDs := []string{}
+	for _, item := range items {
+		if item.VariantID == uuid.Nil {
+			continue // Products without variants are not stock tracked
+		}
+		if _, seen := requested[item.VariantID]; !seen {
+			variantIDs = append(variantIDs, item.VariantID.String())
+			lines[item.VariantID] = item
+		}
+		requested[item.VariantID] += item.Quantity
+	}
+
+	if len(variantIDs) == 0 {
+		return nil
+	}
+
+	// Lock in a stable order to avoid deadlocks between concurrent checkouts
+	sort.Strings(variantIDs)
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, stock
+		FROM product_variants
+		WHERE id = ANY($1)
+		ORDER BY id
+		FOR UPDATE
+	`, pq.Array(variantIDs))
+	if err != nil {
+		return fmt.Errorf("failed to lock variants: %w", err)
+	}
+
+	available := make(map[uuid.UUID]int)
+	for rows.Next() {
+		var id uuid.UUID
+		var stock int
+		if err := rows.Scan(&id, &stock); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan variant stock: %w", err)
+		}
+		available[id] = stock
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read variant stock: %w", err)
+	}
+
+	shortages := []StockShortage{}
+	for _, idStr := range variantIDs {
+		id := uuid.MustParse(idStr)
+		if requested[id] > available[id] {
+			line := lines[id]
+			shortages = append(shortages, StockShortage{
+				ProductID: line.ProductID,
+				VariantID: id,
+				Title:     line.Title,
+				SKU:       line.SKU,
+				Requested: requested[id],
+				Available: available[id],
+			})
+		}
+	}
+	if len(shortages) > 0 {
+		return &InsufficientStockError{Items: shortages}
+	}
+
+	for _, idStr := range variantIDs {
+		id := uuid.MustParse(idStr)
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE product_variants SET stock = stock - $1 WHERE id = $2",
+			requested[id], id,
+		); err != nil {
+			return fmt.Errorf("failed to deduct stock: %w", err)
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO stock_reservations (order_id, variant_id, quantity, status, expires_at)
+			VALUES ($1, $2, $3, $4, $5)
+		`, orderID, id, requested[id], ReservationReserved, expiresAt); err != nil {
+			return fmt.Errorf("failed to record reservation: %w", err)
+		}
+	}
+
+	return nil
+}
+
+type reservationRow struct {
+	id        uuid.UUID
+	variantID uuid.UUID
+	quantity  int
+	status    ReservationStatus
+}
+
+// lockReservations locks an order's reservations in variant order
+func lockReservations(ctx context.Context, tx *sql.Tx, orderID uuid.UUID) ([]reservationRow, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, variant_id, quantity, status
+		FROM stock_reservations
+		WHERE order_id = $1
+		ORDER BY variant_id
+		FOR UPDATE
+	`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock reservations: %w", err)
+	}
+	defer rows.Close()
+
+	reservations := []reservationRow{}
+	for rows.Next() {
+		var res reservationRow
+		if err := rows.Scan(&res.id, &res.variantID, &res.quantity, &res.status); err != nil {
+			return nil, fmt.Errorf("failed to scan reservation: %w", err)
+		}
+		reservations = append(reservations, res)
+	}
+
+	return reservations, rows.Err()
+}
+
+// commitReservations makes an order's held stock permanent. Reservations that
+// were already released (e.g. expired before payment arrived) are re-taken if
+// stock allows, otherwise flagged as backordered for manual follow-up.
+func commitReservations(ctx context.Context, tx *sql.Tx, orderID uuid.UUID) error {
+	reservations, err := lockReservations(ctx, tx, orderID)
+	if err != nil {
+		return err
+	}
+
+	for _, res := range reservations {
+		newStatus := ReservationCommitted
+
+		switch res.status {
+		case ReservationReserved:
+			// Stock was deducted at checkout
+		case ReservationReleased:
+			result, err := tx.ExecContext(ctx,
+				"UPDATE product_variants SET stock = stock - $1 WHERE id = $2 AND stock >= $1",
+				res.quantity, res.variantID,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to re-take stock: %w", err)
+			}
+			if n, _ := result.RowsAffected(); n == 0 {
+				newStatus = ReservationBackordered
+			}
+		default:
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE stock_reservations SET status = $1 WHERE id = $2",
+			newStatus, res.id,
+		); err != nil {
+			return fmt.Errorf("failed to commit reservation: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// releaseReservations returns an order's held stock to inventory
+func releaseReservations(ctx context.Context, tx *sql.Tx, orderID uuid.UUID) error {
+	reservations, err := lockReservations(ctx, tx, orderID)
+	if err != nil {
+		return err
+	}
+
+	for _, res := range reservations {
+		if res.status != ReservationReserved {
+			continue
+		}
+		if err := releaseReservation(ctx, tx, res); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// restockReservations returns an order's committed stock to inventory, e.g.
+// when a cash on delivery order is cancelled before it was paid for
+func restockReservations(ctx context.Context, tx *sql.Tx, orderID uuid.UUID) error {
+	reservations, err := lockReservations(ctx, tx, orderID)
+	if err != nil {
+		return err
+	}
+
+	for _, res := range reservations {
+		if res.status != ReservationCommitted {
+			continue
+		}
+		if err := releaseReservation(ctx, tx, res); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func releaseReservation(ctx context.Context, tx *sql.Tx, res reservationRow) error {
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE product_variants SET stock = stock + $1 WHERE id = $2",
+		res.quantity, res.variantID,
+	); err != nil {
+		return fmt.Errorf("failed to restore stock: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE stock_reservations SET status = $1 WHERE id = $2",
+		ReservationReleased, res.id,
+	); err != nil {
+		return fmt.Errorf("failed to release reservation: %w", err)
+	}
+
+	return nil
+}
+
+// ReleaseExpiredReservations returns stock held by unpaid reservations past
+// their expiry. Rows locked by a concurrent checkout or payment are skipped
+// and picked up on the next run.
+func (r *OrderRepository) ReleaseExpiredReservations(ctx context.Context, limit int) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, variant_id, quantity, status
+		FROM stock_reservations
+		WHERE status = $1 AND expires_at < NOW()
+		ORDER BY variant_id
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	`, ReservationReserved, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find expired reservations: %w", err)
+	}
+
+	expired := []reservationRow{}
+	for rows.Next() {
+		var res reservationRow
+		if err := rows.Scan(&res.id, &res.variantID, &res.quantity, &res.status); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan reservation: %w", err)
+		}
+		expired = append(expired, res)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read reservations: %w", err)
+	}
+
+	for _, res := range expired {
+		if err := releaseReservation(ctx, tx, res); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return len(expired), nil
+}
@@ -0,0 +1,103 @@
+package orders
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// ListUnnotifiedStatusChanges returns status changes that have not been
+// considered for a customer email yet, oldest first
+func (r *OrderRepository) ListUnnotifiedStatusChanges(ctx context.Context, limit int) ([]StatusChange, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, order_id, kind, from_status, to_status, actor_type, actor_id, note, created_at
+		FROM order_status_history
+		WHERE notified_at IS NULL
+		ORDER BY created_at, id
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unnotified status changes: %w", err)
+	}
+	defer rows.Close()
+
+	changes := []StatusChange{}
+	for rows.Next() {
+		var change StatusChange
+		if err := rows.Scan(
+			&change.ID, &change.OrderID, &change.Kind, &change.FromStatus, &change.ToStatus,
+			&change.ActorType, &change.ActorID, &change.Note, &change.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan status change: %w", err)
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, rows.Err()
+}
+
+// MarkStatusChangeNotified records that a status change was emailed, or
+// needed no email. then is called in the same transaction to queue the
+// emails, and may be nil.
+func (r *OrderRepository) MarkStatusChangeNotified(ctx context.Context, id uuid.UUID, then func(tx *sql.Tx) error) error {
+	return r.markNotified(ctx, "UPDATE order_status_history SET notified_at = NOW() WHERE id = $1", id, then)
+}
+
+// ListUnnotifiedRefunds returns processed refunds that have not been emailed
+// to the customer yet, oldest first
+func (r *OrderRepository) ListUnnotifiedRefunds(ctx context.Context, limit int) ([]Refund, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+refundColumns+`
+		FROM refunds
+		WHERE status = $1 AND notified_at IS NULL
+		ORDER BY processed_at, id
+		LIMIT $2
+	`, RefundStatusProcessed, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unnotified refunds: %w", err)
+	}
+	defer rows.Close()
+
+	refunds := []Refund{}
+	for rows.Next() {
+		refund, err := scanRefund(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan refund: %w", err)
+		}
+		refunds = append(refunds, *refund)
+	}
+
+	return refunds, rows.Err()
+}
+
+// MarkRefundNotified records that a processed refund was emailed. then is
+// called in the same transaction to queue the email, and may be nil.
+func (r *OrderRepository) MarkRefundNotified(ctx context.Context, id uuid.UUID, then func(tx *sql.Tx) error) error {
+	return r.markNotified(ctx, "UPDATE refunds SET notified_at = NOW() WHERE id = $1", id, then)
+}
+
+func (r *OrderRepository) markNotified(ctx context.Context, query string, id uuid.UUID, then func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to mark notified: %w", err)
+	}
+
+	if then != nil {
+		if err := then(tx); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
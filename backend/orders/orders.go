@@ -4,10 +4,14 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/ramniya/ramniya-backend/promotions"
+	"github.com/ramniya/ramniya-backend/tax"
 )
 
 // OrderStatus represents possible order states
@@ -20,8 +24,16 @@ const (
 	OrderStatusFailed    OrderStatus = "failed"
 	OrderStatusCancelled OrderStatus = "cancelled"
 	OrderStatusRefunded  OrderStatus = "refunded"
+
+	OrderStatusPartiallyRefunded OrderStatus = "partially_refunded"
+	OrderStatusPaymentReview     OrderStatus = "payment_review"
+	OrderStatusCODPending        OrderStatus = "cod_pending"
 )
 
+// PaymentMethodCOD is the payment method, and payment gateway, of cash on
+// delivery orders
+const PaymentMethodCOD = "cod"
+
 // OrderItem represents a single item in an order
 type OrderItem struct {
 	ProductID  uuid.UUID `json:"product_id"`
@@ -31,6 +43,9 @@ type OrderItem struct {
 	Quantity   int       `json:"quantity"`
 	PriceCents int       `json:"price_cents"`
 	ImageURL   string    `json:"image_url,omitempty"`
+	Category   string    `json:"category,omitempty"`
+	HSNCode    string    `json:"hsn_code,omitempty"`
+	GSTRateBps *int      `json:"gst_rate_bps,omitempty"`
 }
 
 // ShippingAddress represents delivery address
@@ -45,33 +60,104 @@ type ShippingAddress struct {
 	Country string `json:"country"`
 }
 
-// Order represents a customer order
+// Order represents a customer order. The Razorpay* fields hold the order and
+// payment IDs of whichever PaymentGateway processed it. AmountCents is the
+// item total less DiscountCents, plus ShippingCents, CODFeeCents for cash on
+// delivery orders, and TaxCents when catalog prices exclude GST. Shipping and
+// the COD fee are taxed with the items.
 type Order struct {
-	ID                uuid.UUID       `json:"id"`
-	UserID            uuid.UUID       `json:"user_id"`
-	Items             []OrderItem     `json:"items"`
-	ShippingAddress   ShippingAddress `json:"shipping_address"`
-	AmountCents       int             `json:"amount_cents"`
-	Currency          string          `json:"currency"`
-	Status            OrderStatus     `json:"status"`
-	RazorpayOrderID   *string         `json:"razorpay_order_id,omitempty"`
-	RazorpayPaymentID *string         `json:"razorpay_payment_id,omitempty"`
-	RazorpaySignature *string         `json:"razorpay_signature,omitempty"`
-	PaymentMethod     *string         `json:"payment_method,omitempty"`
-	Notes             json.RawMessage `json:"notes,omitempty"`
-	CreatedAt         time.Time       `json:"created_at"`
-	UpdatedAt         time.Time       `json:"updated_at"`
-	PaidAt            *time.Time      `json:"paid_at,omitempty"`
+	ID                uuid.UUID            `json:"id"`
+	UserID            uuid.UUID            `json:"user_id"`
+	Items             []OrderItem          `json:"items"`
+	ShippingAddress   ShippingAddress      `json:"shipping_address"`
+	AmountCents       int                  `json:"amount_cents"`
+	Currency          string               `json:"currency"`
+	Status            OrderStatus          `json:"status"`
+	PaymentGateway    string               `json:"payment_gateway"`
+	RazorpayOrderID   *string              `json:"razorpay_order_id,omitempty"`
+	RazorpayPaymentID *string              `json:"razorpay_payment_id,omitempty"`
+	RazorpaySignature *string              `json:"razorpay_signature,omitempty"`
+	PaymentMethod     *string              `json:"payment_method,omitempty"`
+	Notes             json.RawMessage      `json:"notes,omitempty"`
+	CreatedAt         time.Time            `json:"created_at"`
+	UpdatedAt         time.Time            `json:"updated_at"`
+	PaidAt            *time.Time           `json:"paid_at,omitempty"`
+	RefundedCents     int                  `json:"refunded_cents"`
+	FulfilmentStatus  FulfilmentStatus     `json:"fulfilment_status"`
+	Carrier           *string              `json:"carrier,omitempty"`
+	TrackingNumber    *string              `json:"tracking_number,omitempty"`
+	TrackingURL       *string              `json:"tracking_url,omitempty"`
+	PackedAt          *time.Time           `json:"packed_at,omitempty"`
+	ShippedAt         *time.Time           `json:"shipped_at,omitempty"`
+	DeliveredAt       *time.Time           `json:"delivered_at,omitempty"`
+	ReturnedAt        *time.Time           `json:"returned_at,omitempty"`
+	ReviewReason      *string              `json:"payment_review_reason,omitempty"`
+	CODFeeCents       int                  `json:"cod_fee_cents"`
+	CouponCode        *string              `json:"coupon_code,omitempty"`
+	DiscountCents     int                  `json:"discount_cents"`
+	Discount          *promotions.Discount `json:"discount,omitempty"`
+	TaxCents          int                  `json:"tax_cents"`
+	Tax               *tax.Breakdown       `json:"tax,omitempty"`
+	ShippingCents     int                  `json:"shipping_cents"`
+	ShippingZone      *string              `json:"shipping_zone,omitempty"`
+	ShippingWeight    int                  `json:"shipping_weight_grams"`
+	// What the gateway captured, recorded when the payment is held for review
+	CapturedAmountCents *int    `json:"captured_amount_cents,omitempty"`
+	CapturedCurrency    *string `json:"captured_currency,omitempty"`
+}
+
+// PaymentMismatch compares a captured amount and currency with the order and
+// returns why they differ, or "" when they match
+func (o *Order) PaymentMismatch(amount int, currency string) string {
+	if !strings.EqualFold(currency, o.Currency) {
+		return fmt.Sprintf("currency mismatch: paid %s, expected %s", currency, o.Currency)
+	}
+	if amount != o.AmountCents {
+		return fmt.Sprintf("amount mismatch: paid %d, expected %d", amount, o.AmountCents)
+	}
+	return ""
+}
+
+// Fees taxed along with an order's items
+const (
+	ChargeShipping = "shipping"
+	ChargeCODFee   = "cod_fee"
+)
+
+// TaxLines turns order items into GST lines, net of each line's share of
+// the coupon discount
+func TaxLines(items []OrderItem, discount *promotions.Discount) []tax.Line {
+	lines := make([]tax.Line, len(items))
+	for i, item := range items {
+		lines[i] = tax.Line{
+			HSNCode:     item.HSNCode,
+			RateBps:     item.GSTRateBps,
+			AmountCents: item.PriceCents * item.Quantity,
+		}
+	}
+	if discount != nil {
+		for _, line := range discount.Lines {
+			lines[line.Line].AmountCents -= line.DiscountCents
+		}
+	}
+	return lines
 }
 
 // CreateOrderInput represents input for creating an order
 type CreateOrderInput struct {
-	UserID          uuid.UUID       `json:"user_id"`
-	Items           []OrderItem     `json:"items"`
-	ShippingAddress ShippingAddress `json:"shipping_address"`
-	AmountCents     int             `json:"amount_cents"`
-	Currency        string          `json:"currency"`
-	PaymentMethod   string          `json:"payment_method"`
+	UserID          uuid.UUID            `json:"user_id"`
+	Items           []OrderItem          `json:"items"`
+	ShippingAddress ShippingAddress      `json:"shipping_address"`
+	AmountCents     int                  `json:"amount_cents"`
+	Currency        string               `json:"currency"`
+	PaymentMethod   string               `json:"payment_method"`
+	PaymentGateway  string               `json:"payment_gateway"`
+	CODFeeCents     int                  `json:"cod_fee_cents"`
+	Discount        *promotions.Discount `json:"discount,omitempty"` // Redeemed with the order
+	Tax             *tax.Breakdown       `json:"tax,omitempty"`
+	ShippingCents   int                  `json:"shipping_cents"`
+	ShippingZone    string               `json:"shipping_zone,omitempty"`
+	ShippingWeight  int                  `json:"shipping_weight_grams"`
 }
 
 // UpdateOrderStatusInput represents input for updating order status
@@ -79,29 +165,110 @@ type UpdateOrderStatusInput struct {
 	Status            OrderStatus `json:"status"`
 	RazorpayPaymentID *string     `json:"razorpay_payment_id,omitempty"`
 	RazorpaySignature *string     `json:"razorpay_signature,omitempty"`
+	ReviewReason      *string     `json:"-"` // Set when moving to payment_review
+	// What the gateway captured, set when moving to payment_review
+	CapturedAmountCents *int    `json:"-"`
+	CapturedCurrency    *string `json:"-"`
+	Actor               Actor   `json:"-"`
+	Note                string  `json:"-"`
 }
 
 // ListOrdersFilter represents filters for listing orders
 type ListOrdersFilter struct {
-	UserID    *uuid.UUID
-	Status    *OrderStatus
-	Limit     int
-	Offset    int
-	SortBy    string // "created_at", "amount_cents"
-	SortOrder string // "asc", "desc"
+	UserID           *uuid.UUID
+	Status           *OrderStatus
+	FulfilmentStatus *FulfilmentStatus
+	Limit            int
+	Offset           int
+	SortBy           string // "created_at", "amount_cents"
+	SortOrder        string // "asc", "desc"
+}
+
+// orderColumns lists the order columns in the order scanOrder expects them
+const orderColumns = `id, user_id, items, shipping_address, amount_cents, currency, status,
+		       razorpay_order_id, razorpay_payment_id, razorpay_signature,
+		       payment_method, notes, created_at, updated_at, paid_at, refunded_cents,
+		       fulfilment_status, carrier, tracking_number, tracking_url,
+		       packed_at, shipped_at, delivered_at, returned_at, payment_review_reason, payment_gateway,
+		       cod_fee_cents, coupon_code, discount_cents, discount, tax_cents, tax_breakdown,
+		       shipping_cents, shipping_zone, shipping_weight_grams, captured_amount_cents, captured_currency`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanOrder scans a row selected with orderColumns. Scan errors such as
+// sql.ErrNoRows are returned unwrapped.
+func scanOrder(row rowScanner) (*Order, error) {
+	var order Order
+	var itemsData, addressData, notesData, discountData, taxData []byte
+
+	err := row.Scan(
+		&order.ID, &order.UserID, &itemsData, &addressData, &order.AmountCents, &order.Currency,
+		&order.Status, &order.RazorpayOrderID, &order.RazorpayPaymentID, &order.RazorpaySignature,
+		&order.PaymentMethod, &notesData, &order.CreatedAt, &order.UpdatedAt, &order.PaidAt,
+		&order.RefundedCents, &order.FulfilmentStatus, &order.Carrier, &order.TrackingNumber, &order.TrackingURL,
+		&order.PackedAt, &order.ShippedAt, &order.DeliveredAt, &order.ReturnedAt, &order.ReviewReason,
+		&order.PaymentGateway, &order.CODFeeCents, &order.CouponCode, &order.DiscountCents, &discountData,
+		&order.TaxCents, &taxData, &order.ShippingCents, &order.ShippingZone, &order.ShippingWeight,
+		&order.CapturedAmountCents, &order.CapturedCurrency,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(itemsData, &order.Items); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal items: %w", err)
+	}
+
+	if err := json.Unmarshal(addressData, &order.ShippingAddress); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal address: %w", err)
+	}
+
+	if len(notesData) > 0 {
+		order.Notes = notesData
+	}
+
+	if len(discountData) > 0 {
+		if err := json.Unmarshal(discountData, &order.Discount); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal discount: %w", err)
+		}
+	}
+
+	if len(taxData) > 0 {
+		if err := json.Unmarshal(taxData, &order.Tax); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal tax breakdown: %w", err)
+		}
+	}
+
+	return &order, nil
 }
 
 // OrderRepository handles order database operations
 type OrderRepository struct {
-	db *sql.DB
+	db             *sql.DB
+	reservationTTL time.Duration
 }
 
 // NewOrderRepository creates a new order repository
 func NewOrderRepository(db *sql.DB) *OrderRepository {
-	return &OrderRepository{db: db}
+	return &OrderRepository{
+		db:             db,
+		reservationTTL: DefaultReservationTTL,
+	}
+}
+
+// SetReservationTTL sets how long new orders hold stock before it is released
+func (r *OrderRepository) SetReservationTTL(ttl time.Duration) {
+	if ttl > 0 {
+		r.reservationTTL = ttl
+	}
 }
 
-// CreateOrder creates a new order
+// CreateOrder creates a new order, reserves stock for its items and redeems
+// its coupon in the same transaction. Returns *InsufficientStockError if any
+// item is short, or a *promotions.CouponError if the coupon ran out.
 func (r *OrderRepository) CreateOrder(ctx context.Context, input CreateOrderInput) (*Order, error) {
 	itemsJSON, err := json.Marshal(input.Items)
 	if err != nil {
@@ -113,60 +280,90 @@ func (r *OrderRepository) CreateOrder(ctx context.Context, input CreateOrderInpu
 		return nil, fmt.Errorf("failed to marshal address: %w", err)
 	}
 
+	var couponCode *string
+	var discountCents int
+	var discountJSON []byte
+	if input.Discount != nil {
+		couponCode = &input.Discount.Code
+		discountCents = input.Discount.DiscountCents
+		if discountJSON, err = json.Marshal(input.Discount); err != nil {
+			return nil, fmt.Errorf("failed to marshal discount: %w", err)
+		}
+	}
+
+	var shippingZone *string
+	if input.ShippingZone != "" {
+		shippingZone = &input.ShippingZone
+	}
+
+	var taxCents int
+	var taxJSON []byte
+	if input.Tax != nil {
+		taxCents = input.Tax.TaxCents
+		if taxJSON, err = json.Marshal(input.Tax); err != nil {
+			return nil, fmt.Errorf("failed to marshal tax breakdown: %w", err)
+		}
+	}
+
 	query := `
-		INSERT INTO orders (user_id, items, shipping_address, amount_cents, currency, payment_method, status)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		RETURNING id, user_id, items, shipping_address, amount_cents, currency, status, 
-		          payment_method, notes, created_at, updated_at
+		INSERT INTO orders (user_id, items, shipping_address, amount_cents, currency, payment_method, status,
+		                    payment_gateway, cod_fee_cents, coupon_code, discount_cents, discount, tax_cents, tax_breakdown,
+		                    shipping_cents, shipping_zone, shipping_weight_grams)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+		RETURNING ` + orderColumns + `
 	`
 
-	var order Order
-	var itemsData, addressData, notesData []byte
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
 
-	err = r.db.QueryRowContext(
+	order, err := scanOrder(tx.QueryRowContext(
 		ctx, query,
 		input.UserID, itemsJSON, addressJSON, input.AmountCents, input.Currency, input.PaymentMethod, OrderStatusCreated,
-	).Scan(
-		&order.ID, &order.UserID, &itemsData, &addressData, &order.AmountCents, &order.Currency,
-		&order.Status, &order.PaymentMethod, &notesData, &order.CreatedAt, &order.UpdatedAt,
-	)
+		input.PaymentGateway, input.CODFeeCents, couponCode, discountCents, discountJSON, taxCents, taxJSON,
+		input.ShippingCents, shippingZone, input.ShippingWeight,
+	))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create order: %w", err)
 	}
 
-	if err := json.Unmarshal(itemsData, &order.Items); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal items: %w", err)
+	if err := reserveStock(ctx, tx, order.ID, input.Items, time.Now().Add(r.reservationTTL)); err != nil {
+		return nil, err
 	}
 
-	if err := json.Unmarshal(addressData, &order.ShippingAddress); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal address: %w", err)
+	if input.Discount != nil {
+		if err := promotions.Redeem(ctx, tx, promotions.Redemption{
+			CouponID:      input.Discount.CouponID,
+			UserID:        input.UserID,
+			OrderID:       order.ID,
+			DiscountCents: input.Discount.DiscountCents,
+		}); err != nil {
+			return nil, err
+		}
 	}
 
-	if len(notesData) > 0 {
-		order.Notes = notesData
+	if err := recordTransition(ctx, tx, order.ID, nil, order.Status, Actor{Type: ActorUser, ID: input.UserID.String()}, ""); err != nil {
+		return nil, err
 	}
 
-	return &order, nil
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return order, nil
 }
 
 // GetOrder retrieves an order by ID
 func (r *OrderRepository) GetOrder(ctx context.Context, orderID uuid.UUID) (*Order, error) {
 	query := `
-		SELECT id, user_id, items, shipping_address, amount_cents, currency, status,
-		       razorpay_order_id, razorpay_payment_id, razorpay_signature, 
-		       payment_method, notes, created_at, updated_at, paid_at
+		SELECT ` + orderColumns + `
 		FROM orders
 		WHERE id = $1
 	`
 
-	var order Order
-	var itemsData, addressData, notesData []byte
-
-	err := r.db.QueryRowContext(ctx, query, orderID).Scan(
-		&order.ID, &order.UserID, &itemsData, &addressData, &order.AmountCents, &order.Currency,
-		&order.Status, &order.RazorpayOrderID, &order.RazorpayPaymentID, &order.RazorpaySignature,
-		&order.PaymentMethod, &notesData, &order.CreatedAt, &order.UpdatedAt, &order.PaidAt,
-	)
+	order, err := scanOrder(r.db.QueryRowContext(ctx, query, orderID))
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("order not found")
 	}
@@ -174,39 +371,20 @@ func (r *OrderRepository) GetOrder(ctx context.Context, orderID uuid.UUID) (*Ord
 		return nil, fmt.Errorf("failed to get order: %w", err)
 	}
 
-	if err := json.Unmarshal(itemsData, &order.Items); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal items: %w", err)
-	}
-
-	if err := json.Unmarshal(addressData, &order.ShippingAddress); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal address: %w", err)
-	}
-
-	if len(notesData) > 0 {
-		order.Notes = notesData
-	}
-
-	return &order, nil
+	return order, nil
 }
 
-// GetOrderByRazorpayOrderID retrieves an order by Razorpay order ID
-func (r *OrderRepository) GetOrderByRazorpayOrderID(ctx context.Context, razorpayOrderID string) (*Order, error) {
+// GetOrderByGatewayOrderID retrieves an order by the gateway it was placed
+// with and that gateway's order ID. Requiring both keeps one gateway's
+// webhooks from settling another gateway's orders.
+func (r *OrderRepository) GetOrderByGatewayOrderID(ctx context.Context, gateway, gatewayOrderID string) (*Order, error) {
 	query := `
-		SELECT id, user_id, items, shipping_address, amount_cents, currency, status,
-		       razorpay_order_id, razorpay_payment_id, razorpay_signature,
-		       payment_method, notes, created_at, updated_at, paid_at
+		SELECT ` + orderColumns + `
 		FROM orders
-		WHERE razorpay_order_id = $1
+		WHERE payment_gateway = $1 AND razorpay_order_id = $2
 	`
 
-	var order Order
-	var itemsData, addressData, notesData []byte
-
-	err := r.db.QueryRowContext(ctx, query, razorpayOrderID).Scan(
-		&order.ID, &order.UserID, &itemsData, &addressData, &order.AmountCents, &order.Currency,
-		&order.Status, &order.RazorpayOrderID, &order.RazorpayPaymentID, &order.RazorpaySignature,
-		&order.PaymentMethod, &notesData, &order.CreatedAt, &order.UpdatedAt, &order.PaidAt,
-	)
+	order, err := scanOrder(r.db.QueryRowContext(ctx, query, gateway, gatewayOrderID))
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("order not found")
 	}
@@ -214,47 +392,64 @@ func (r *OrderRepository) GetOrderByRazorpayOrderID(ctx context.Context, razorpa
 		return nil, fmt.Errorf("failed to get order: %w", err)
 	}
 
-	if err := json.Unmarshal(itemsData, &order.Items); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal items: %w", err)
+	return order, nil
+}
+
+// UpdateOrderRazorpayID records the gateway order ID and moves the order to
+// pending payment. Retrying a failed order takes its coupon use back.
+func (r *OrderRepository) UpdateOrderRazorpayID(ctx context.Context, orderID uuid.UUID, razorpayOrderID string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	if err := json.Unmarshal(addressData, &order.ShippingAddress); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal address: %w", err)
+	from, err := lockOrderStatus(ctx, tx, orderID)
+	if err != nil {
+		return err
 	}
 
-	if len(notesData) > 0 {
-		order.Notes = notesData
+	if !CanTransition(from, OrderStatusPending) {
+		return &InvalidTransitionError{From: from, To: OrderStatusPending}
 	}
 
-	return &order, nil
-}
+	if revivesOrder(from, OrderStatusPending) {
+		if err := promotions.Reinstate(ctx, tx, orderID, true); err != nil {
+			return err
+		}
+	}
 
-// UpdateOrderRazorpayID updates the Razorpay order ID
-func (r *OrderRepository) UpdateOrderRazorpayID(ctx context.Context, orderID uuid.UUID, razorpayOrderID string) error {
 	query := `
 		UPDATE orders
 		SET razorpay_order_id = $1, status = $2, updated_at = NOW()
 		WHERE id = $3
 	`
 
-	result, err := r.db.ExecContext(ctx, query, razorpayOrderID, OrderStatusPending, orderID)
-	if err != nil {
+	if _, err := tx.ExecContext(ctx, query, razorpayOrderID, OrderStatusPending, orderID); err != nil {
 		return fmt.Errorf("failed to update order: %w", err)
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+	if from != OrderStatusPending {
+		if err := recordTransition(ctx, tx, orderID, &from, OrderStatusPending, Actor{Type: ActorSystem, ID: "checkout"}, "Payment initiated"); err != nil {
+			return err
+		}
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("order not found")
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	return nil
 }
 
-// UpdateOrderStatus updates the order status and payment details
+// UpdateOrderStatus updates the order status and payment details. The change
+// must be allowed by the transition table and is recorded in the status
+// history. Reserved stock is committed when the order is paid and released
+// when it fails or is cancelled, along with its coupon use. A failed or
+// cancelled order that is retried or paid takes its coupon use back; if the
+// coupon has run out meanwhile, a retry is rejected with the coupon error and
+// a payment is held for review. Admins are limited to CanAdminTransition and
+// may only move cash on delivery orders to cod_pending.
 func (r *OrderRepository) UpdateOrderStatus(ctx context.Context, orderID uuid.UUID, input UpdateOrderStatusInput) (*Order, error) {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -262,27 +457,72 @@ func (r *OrderRepository) UpdateOrderStatus(ctx context.Context, orderID uuid.UU
 	}
 	defer tx.Rollback()
 
+	from, err := lockOrderStatus(ctx, tx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !CanTransition(from, input.Status) {
+		return nil, &InvalidTransitionError{From: from, To: input.Status}
+	}
+
+	if from == OrderStatusCODPending && input.Status == OrderStatusPaid {
+		return nil, ErrCODCollectionRequired
+	}
+
+	if input.Actor.Type == ActorAdmin && from != input.Status {
+		if !CanAdminTransition(from, input.Status) {
+			return nil, &InvalidTransitionError{From: from, To: input.Status}
+		}
+		if input.Status == OrderStatusCODPending {
+			var gateway string
+			if err := tx.QueryRowContext(ctx, "SELECT payment_gateway FROM orders WHERE id = $1", orderID).Scan(&gateway); err != nil {
+				return nil, fmt.Errorf("failed to get order: %w", err)
+			}
+			if gateway != PaymentMethodCOD {
+				return nil, ErrNotCODOrder
+			}
+		}
+	}
+
+	if revivesOrder(from, input.Status) {
+		if err := promotions.Reinstate(ctx, tx, orderID, true); err != nil {
+			var couponErr *promotions.CouponError
+			if !errors.As(err, &couponErr) || input.Status == OrderStatusPending {
+				return nil, err
+			}
+			// The customer already paid the discounted price, so count the
+			// use anyway and hold the order for an admin to decide
+			if err := promotions.Reinstate(ctx, tx, orderID, false); err != nil {
+				return nil, err
+			}
+			reason := "Coupon no longer available: " + couponErr.Reason
+			if input.ReviewReason != nil {
+				reason = *input.ReviewReason + "; " + reason
+			}
+			input.Status = OrderStatusPaymentReview
+			input.ReviewReason = &reason
+		}
+	}
+
 	query := `
 		UPDATE orders
 		SET status = $1,
 		    razorpay_payment_id = COALESCE($2, razorpay_payment_id),
 		    razorpay_signature = COALESCE($3, razorpay_signature),
 		    paid_at = CASE WHEN $1 = 'paid' AND paid_at IS NULL THEN NOW() ELSE paid_at END,
+		    payment_review_reason = COALESCE($5, payment_review_reason),
+		    captured_amount_cents = COALESCE($6, captured_amount_cents),
+		    captured_currency = COALESCE($7, captured_currency),
 		    updated_at = NOW()
 		WHERE id = $4
-		RETURNING id, user_id, items, shipping_address, amount_cents, currency, status,
-		          razorpay_order_id, razorpay_payment_id, razorpay_signature,
-		          payment_method, notes, created_at, updated_at, paid_at
+		RETURNING ` + orderColumns + `
 	`
 
-	var order Order
-	var itemsData, addressData, notesData []byte
-
-	err = tx.QueryRowContext(ctx, query, input.Status, input.RazorpayPaymentID, input.RazorpaySignature, orderID).Scan(
-		&order.ID, &order.UserID, &itemsData, &addressData, &order.AmountCents, &order.Currency,
-		&order.Status, &order.RazorpayOrderID, &order.RazorpayPaymentID, &order.RazorpaySignature,
-		&order.PaymentMethod, &notesData, &order.CreatedAt, &order.UpdatedAt, &order.PaidAt,
-	)
+	order, err := scanOrder(tx.QueryRowContext(ctx, query,
+		input.Status, input.RazorpayPaymentID, input.RazorpaySignature, orderID, input.ReviewReason,
+		input.CapturedAmountCents, input.CapturedCurrency,
+	))
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("order not found")
 	}
@@ -290,31 +530,44 @@ func (r *OrderRepository) UpdateOrderStatus(ctx context.Context, orderID uuid.UU
 		return nil, fmt.Errorf("failed to update order: %w", err)
 	}
 
-	if err := json.Unmarshal(itemsData, &order.Items); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal items: %w", err)
-	}
-
-	if err := json.Unmarshal(addressData, &order.ShippingAddress); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal address: %w", err)
+	if from != order.Status {
+		if err := recordTransition(ctx, tx, order.ID, &from, order.Status, input.Actor, input.Note); err != nil {
+			return nil, err
+		}
 	}
 
-	if len(notesData) > 0 {
-		order.Notes = notesData
+	// Keep held stock in step with the payment outcome
+	switch order.Status {
+	case OrderStatusPaid, OrderStatusCODPending:
+		if err := commitReservations(ctx, tx, order.ID); err != nil {
+			return nil, err
+		}
+	case OrderStatusFailed, OrderStatusCancelled:
+		if err := releaseReservations(ctx, tx, order.ID); err != nil {
+			return nil, err
+		}
+		// Cash on delivery orders committed their stock when placed
+		if from == OrderStatusCODPending {
+			if err := restockReservations(ctx, tx, order.ID); err != nil {
+				return nil, err
+			}
+		}
+		if err := promotions.Release(ctx, tx, order.ID); err != nil {
+			return nil, err
+		}
 	}
 
 	if err := tx.Commit(); err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	return &order, nil
+	return order, nil
 }
 
 // ListOrders retrieves orders with optional filters
 func (r *OrderRepository) ListOrders(ctx context.Context, filter ListOrdersFilter) ([]Order, int, error) {
 	query := `
-		SELECT id, user_id, items, shipping_address, amount_cents, currency, status,
-		       razorpay_order_id, razorpay_payment_id, razorpay_signature,
-		       payment_method, notes, created_at, updated_at, paid_at
+		SELECT ` + orderColumns + `
 		FROM orders
 		WHERE 1=1
 	`
@@ -341,6 +594,13 @@ func (r *OrderRepository) ListOrders(ctx context.Context, filter ListOrdersFilte
 		argCount++
 	}
 
+	if filter.FulfilmentStatus != nil {
+		query += fmt.Sprintf(" AND fulfilment_status = $%d", argCount)
+		countQuery += fmt.Sprintf(" AND fulfilment_status = $%d", argCount)
+		args = append(args, *filter.FulfilmentStatus)
+		argCount++
+	}
+
 	// Get total count
 	var total int
 	err := r.db.QueryRowContext(ctx, countQuery, args...).Scan(&total)
@@ -376,59 +636,47 @@ func (r *OrderRepository) ListOrders(ctx context.Context, filter ListOrdersFilte
 
 	orders := []Order{}
 	for rows.Next() {
-		var o Order
-		var itemsData, addressData, notesData []byte
-
-		err := rows.Scan(
-			&o.ID, &o.UserID, &itemsData, &addressData, &o.AmountCents, &o.Currency,
-			&o.Status, &o.RazorpayOrderID, &o.RazorpayPaymentID, &o.RazorpaySignature,
-			&o.PaymentMethod, &notesData, &o.CreatedAt, &o.UpdatedAt, &o.PaidAt,
-		)
+		o, err := scanOrder(rows)
 		if err != nil {
 			return nil, 0, fmt.Errorf("failed to scan order: %w", err)
 		}
 
-		if err := json.Unmarshal(itemsData, &o.Items); err != nil {
-			return nil, 0, fmt.Errorf("failed to unmarshal items: %w", err)
-		}
-
-		if err := json.Unmarshal(addressData, &o.ShippingAddress); err != nil {
-			return nil, 0, fmt.Errorf("failed to unmarshal address: %w", err)
-		}
-
-		if len(notesData) > 0 {
-			o.Notes = notesData
-		}
-
-		orders = append(orders, o)
+		orders = append(orders, *o)
 	}
 
 	return orders, total, nil
 }
 
-// RecordWebhookEvent records a webhook event for idempotency
-func (r *OrderRepository) RecordWebhookEvent(ctx context.Context, eventID, eventType string, payload json.RawMessage) (bool, error) {
-	// Check if event already exists
-	var exists bool
-	err := r.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM webhook_events WHERE event_id = $1)", eventID).Scan(&exists)
-	if err != nil {
-		return false, fmt.Errorf("failed to check webhook event: %w", err)
-	}
-
-	if exists {
-		return false, nil // Already processed
-	}
-
-	// Insert new event
+// ListUnsettledOrders returns orders that were sent to Razorpay but are still
+// awaiting a payment outcome (or recorded as failed) after olderThan, ignoring
+// orders older than maxAge. Oldest first.
+func (r *OrderRepository) ListUnsettledOrders(ctx context.Context, olderThan, maxAge time.Duration, limit int) ([]Order, error) {
 	query := `
-		INSERT INTO webhook_events (event_id, event_type, payload, processed)
-		VALUES ($1, $2, $3, TRUE)
+		SELECT ` + orderColumns + `
+		FROM orders
+		WHERE status IN ('created', 'pending', 'failed')
+		  AND razorpay_order_id IS NOT NULL
+		  AND created_at < $1
+		  AND created_at > $2
+		ORDER BY created_at
+		LIMIT $3
 	`
 
-	_, err = r.db.ExecContext(ctx, query, eventID, eventType, payload)
+	now := time.Now()
+	rows, err := r.db.QueryContext(ctx, query, now.Add(-olderThan), now.Add(-maxAge), limit)
 	if err != nil {
-		return false, fmt.Errorf("failed to record webhook event: %w", err)
+		return nil, fmt.Errorf("failed to list unsettled orders: %w", err)
+	}
+	defer rows.Close()
+
+	orders := []Order{}
+	for rows.Next() {
+		o, err := scanOrder(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order: %w", err)
+		}
+		orders = append(orders, *o)
 	}
 
-	return true, nil
+	return orders, rows.Err()
 }
@@ -0,0 +1,27 @@
+package orders
+
+import "testing"
+
+func TestPaymentMismatch(t *testing.T) {
+	order := &Order{AmountCents: 49900, Currency: "INR"}
+
+	tests := []struct {
+		name     string
+		amount   int
+		currency string
+		mismatch bool
+	}{
+		{"exact match", 49900, "INR", false},
+		{"currency case ignored", 49900, "inr", false},
+		{"underpaid", 49800, "INR", true},
+		{"overpaid", 50000, "INR", true},
+		{"wrong currency", 49900, "USD", true},
+	}
+
+	for _, tt := range tests {
+		reason := order.PaymentMismatch(tt.amount, tt.currency)
+		if (reason != "") != tt.mismatch {
+			t.Errorf("%s: PaymentMismatch(%d, %s) = %q", tt.name, tt.amount, tt.currency, reason)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package orders
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefundStatus represents the state of a refund
+type RefundStatus string
+
+const (
+	RefundStatusPending   RefundStatus = "pending"
+	RefundStatusProcessed RefundStatus = "processed"
+	RefundStatusFailed    RefundStatus = "failed"
+)
+
+// ErrOrderNotRefundable is returned when an order has no captured payment to refund
+var ErrOrderNotRefundable = errors.New("order cannot be refunded")
+
+// RefundTooLargeError is returned when a refund exceeds what is left to refund
+type RefundTooLargeError struct {
+	RemainingCents int
+}
+
+func (e *RefundTooLargeError) Error() string {
+	return fmt.Sprintf("refund exceeds refundable amount of %d", e.RemainingCents)
+}
+
+// Refund represents a refund issued against an order
+type Refund struct {
+	ID                uuid.UUID    `json:"id"`
+	OrderID           uuid.UUID    `json:"order_id"`
+	RazorpayPaymentID string       `json:"razorpay_payment_id"`
+	RazorpayRefundID  *string      `json:"razorpay_refund_id,omitempty"`
+	AmountCents       int          `json:"amount_cents"`
+	Currency          string       `json:"currency"`
+	Reason            *string      `json:"reason,omitempty"`
+	Status            RefundStatus `json:"status"`
+	FailureReason     *string      `json:"failure_reason,omitempty"`
+	CreatedBy         *uuid.UUID   `json:"created_by,omitempty"`
+	CreatedAt         time.Time    `json:"created_at"`
+	UpdatedAt         time.Time    `json:"updated_at"`
+	ProcessedAt       *time.Time   `json:"processed_at,omitempty"`
+}
+
+// CreateRefundInput represents input for creating a refund
+type CreateRefundInput struct {
+	OrderID     uuid.UUID
+	AmountCents int // Zero refunds everything not yet refunded
+	Reason      string
+	CreatedBy   *uuid.UUID
+}
+
+// RefundUpdate reports the outcome of a refund from the payment gateway.
+// The refund is matched by ID if set, otherwise by Razorpay refund ID.
+type RefundUpdate struct {
+	RefundID         uuid.UUID
+	RazorpayRefundID string
+	Status           RefundStatus
+	FailureReason    string
+	Actor            Actor
+}
+
+const refundColumns = `id, order_id, razorpay_payment_id, razorpay_refund_id, amount_cents, currency,
+		       reason, status, failure_reason, created_by, created_at, updated_at, processed_at`
+
+func scanRefund(row rowScanner) (*Refund, error) {
+	var refund Refund
+	err := row.Scan(
+		&refund.ID, &refund.OrderID, &refund.RazorpayPaymentID, &refund.RazorpayRefundID,
+		&refund.AmountCents, &refund.Currency, &refund.Reason, &refund.Status,
+		&refund.FailureReason, &refund.CreatedBy, &refund.CreatedAt, &refund.UpdatedAt, &refund.ProcessedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// CreateRefund records a pending refund for a paid order. The amount is checked
+// against the order total minus refunds that are pending or processed, with
+// the order row locked so concurrent refunds cannot overshoot. For an order
+// held for review, the amount the gateway captured stands in for the total.
+func (r *OrderRepository) CreateRefund(ctx context.Context, input CreateRefundInput) (*Refund, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status OrderStatus
+	var amountCents int
+	var currency string
+	var paymentID *string
+	err = tx.QueryRowContext(ctx, `
+		SELECT status, COALESCE(captured_amount_cents, amount_cents), COALESCE(captured_currency, currency),
+		       razorpay_payment_id
+		FROM orders
+		WHERE id = $1
+		FOR UPDATE
+	`, input.OrderID).Scan(&status, &amountCents, &currency, &paymentID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("order not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	if (status != OrderStatusPaid && status != OrderStatusPartiallyRefunded && status != OrderStatusPaymentReview) || paymentID == nil {
+		return nil, ErrOrderNotRefundable
+	}
+
+	var committed int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(amount_cents), 0)
+		FROM refunds
+		WHERE order_id = $1 AND status IN ($2, $3)
+	`, input.OrderID, RefundStatusPending, RefundStatusProcessed).Scan(&committed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum refunds: %w", err)
+	}
+
+	remaining := amountCents - committed
+	amount := input.AmountCents
+	if amount == 0 {
+		amount = remaining
+	}
+	if amount <= 0 || amount > remaining {
+		return nil, &RefundTooLargeError{RemainingCents: remaining}
+	}
+
+	var reason *string
+	if input.Reason != "" {
+		reason = &input.Reason
+	}
+
+	refund, err := scanRefund(tx.QueryRowContext(ctx, `
+		INSERT INTO refunds (order_id, razorpay_payment_id, amount_cents, currency, reason, status, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+refundColumns,
+		input.OrderID, *paymentID, amount, currency, reason, RefundStatusPending, input.CreatedBy,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create refund: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return refund, nil
+}
+
+// UpdateRefundStatus applies a gateway refund outcome. A processed refund is
+// added to the order's refunded total and moves the order to refunded or
+// partially_refunded. Updates to a refund that already reached a final state
+// are ignored, so repeated webhooks are harmless.
+func (r *OrderRepository) UpdateRefundStatus(ctx context.Context, update RefundUpdate) (*Refund, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	refund, err := scanRefund(tx.QueryRowContext(ctx, `
+		SELECT `+refundColumns+`
+		FROM refunds
+		WHERE id = $1 OR ($2 <> '' AND razorpay_refund_id = $2)
+		FOR UPDATE
+	`, update.RefundID, update.RazorpayRefundID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("refund not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get refund: %w", err)
+	}
+
+	// Record the gateway ID the first time it is seen
+	if refund.RazorpayRefundID == nil && update.RazorpayRefundID != "" {
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE refunds SET razorpay_refund_id = $1 WHERE id = $2",
+			update.RazorpayRefundID, refund.ID,
+		); err != nil {
+			return nil, fmt.Errorf("failed to update refund: %w", err)
+		}
+		refund.RazorpayRefundID = &update.RazorpayRefundID
+	}
+
+	// Only pending refunds move to a final state
+	if refund.Status != RefundStatusPending || update.Status == RefundStatusPending {
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return refund, nil
+	}
+
+	var failureReason *string
+	if update.FailureReason != "" {
+		failureReason = &update.FailureReason
+	}
+
+	refund, err = scanRefund(tx.QueryRowContext(ctx, `
+		UPDATE refunds
+		SET status = $1,
+		    failure_reason = $2,
+		    processed_at = CASE WHEN $1 = 'processed' THEN NOW() ELSE processed_at END
+		WHERE id = $3
+		RETURNING `+refundColumns,
+		update.Status, failureReason, refund.ID,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update refund: %w", err)
+	}
+
+	if refund.Status == RefundStatusProcessed {
+		if err := applyProcessedRefund(ctx, tx, refund, update.Actor); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return refund, nil
+}
+
+// applyProcessedRefund adds a processed refund to the order total and moves
+// the order to refunded or partially_refunded
+func applyProcessedRefund(ctx context.Context, tx *sql.Tx, refund *Refund, actor Actor) error {
+	from, err := lockOrderStatus(ctx, tx, refund.OrderID)
+	if err != nil {
+		return err
+	}
+
+	var amountCents, refundedCents int
+	err = tx.QueryRowContext(ctx, `
+		UPDATE orders
+		SET refunded_cents = refunded_cents + $1
+		WHERE id = $2
+		RETURNING COALESCE(captured_amount_cents, amount_cents), refunded_cents
+	`, refund.AmountCents, refund.OrderID).Scan(&amountCents, &refundedCents)
+	if err != nil {
+		return fmt.Errorf("failed to update order refund total: %w", err)
+	}
+
+	to := OrderStatusPartiallyRefunded
+	if refundedCents >= amountCents {
+		to = OrderStatusRefunded
+	}
+
+	if from == to {
+		return nil
+	}
+
+	if !CanTransition(from, to) {
+		return &InvalidTransitionError{From: from, To: to}
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE orders SET status = $1 WHERE id = $2", to, refund.OrderID); err != nil {
+		return fmt.Errorf("failed to update order status: %w", err)
+	}
+
+	note := fmt.Sprintf("Refund %s of %d processed", refund.ID, refund.AmountCents)
+	return recordTransition(ctx, tx, refund.OrderID, &from, to, actor, note)
+}
+
+// ListRefunds returns the refunds of an order, oldest first
+func (r *OrderRepository) ListRefunds(ctx context.Context, orderID uuid.UUID) ([]Refund, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+refundColumns+`
+		FROM refunds
+		WHERE order_id = $1
+		ORDER BY created_at
+	`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list refunds: %w", err)
+	}
+	defer rows.Close()
+
+	refunds := []Refund{}
+	for rows.Next() {
+		refund, err := scanRefund(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan refund: %w", err)
+		}
+		refunds = append(refunds, *refund)
+	}
+
+	return refunds, rows.Err()
+}
+
+// GetRefund retrieves a refund by ID
+func (r *OrderRepository) GetRefund(ctx context.Context, refundID uuid.UUID) (*Refund, error) {
+	refund, err := scanRefund(r.db.QueryRowContext(ctx, `
+		SELECT `+refundColumns+`
+		FROM refunds
+		WHERE id = $1
+	`, refundID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("refund not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get refund: %w", err)
+	}
+	return refund, nil
+}
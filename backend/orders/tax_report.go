@@ -0,0 +1,56 @@
+package orders
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// GSTSummaryRow totals the GST charged on paid orders for one HSN code and
+// rate
+type GSTSummaryRow struct {
+	HSNCode      string `json:"hsn_code"`
+	RateBps      int    `json:"rate_bps"`
+	Orders       int    `json:"orders"`
+	TaxableCents int    `json:"taxable_cents"`
+	CGSTCents    int    `json:"cgst_cents"`
+	SGSTCents    int    `json:"sgst_cents"`
+	IGSTCents    int    `json:"igst_cents"`
+	TaxCents     int    `json:"tax_cents"`
+}
+
+// GSTSummary totals the stored tax breakdowns of orders paid in [from, to)
+// by HSN code and rate. Fully refunded orders are left out.
+func (r *OrderRepository) GSTSummary(ctx context.Context, from, to time.Time) ([]GSTSummaryRow, error) {
+	query := `
+		SELECT COALESCE(line->>'hsn_code', ''), (line->>'rate_bps')::int,
+		       COUNT(DISTINCT o.id),
+		       SUM((line->>'taxable_cents')::int), SUM((line->>'cgst_cents')::int),
+		       SUM((line->>'sgst_cents')::int), SUM((line->>'igst_cents')::int),
+		       SUM((line->>'tax_cents')::int)
+		FROM orders o, jsonb_array_elements(o.tax_breakdown->'lines') AS line
+		WHERE o.tax_breakdown IS NOT NULL
+		  AND o.status IN ($1, $2)
+		  AND o.paid_at >= $3 AND o.paid_at < $4
+		GROUP BY 1, 2
+		ORDER BY 1, 2
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, OrderStatusPaid, OrderStatusPartiallyRefunded, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarise GST: %w", err)
+	}
+	defer rows.Close()
+
+	summary := []GSTSummaryRow{}
+	for rows.Next() {
+		var row GSTSummaryRow
+		if err := rows.Scan(&row.HSNCode, &row.RateBps, &row.Orders, &row.TaxableCents,
+			&row.CGSTCents, &row.SGSTCents, &row.IGSTCents, &row.TaxCents); err != nil {
+			return nil, fmt.Errorf("failed to scan GST summary: %w", err)
+		}
+		summary = append(summary, row)
+	}
+
+	return summary, rows.Err()
+}
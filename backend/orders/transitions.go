@@ -0,0 +1,199 @@
+package orders
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// transitions lists the statuses each status may move to. Moving to the same
+// status is always allowed and treated as an idempotent update.
+var transitions = map[OrderStatus][]OrderStatus{
+	OrderStatusCreated: {OrderStatusPending, OrderStatusPaid, OrderStatusFailed, OrderStatusCancelled, OrderStatusPaymentReview, OrderStatusCODPending},
+	OrderStatusPending: {OrderStatusPaid, OrderStatusFailed, OrderStatusCancelled, OrderStatusPaymentReview},
+	// A failed attempt can be retried, and a capture can still arrive late
+	OrderStatusFailed: {OrderStatusPending, OrderStatusPaid, OrderStatusCancelled, OrderStatusPaymentReview},
+	// Money captured after cancellation must still be recorded so it can be refunded
+	OrderStatusCancelled: {OrderStatusPaid, OrderStatusPaymentReview},
+	// A mismatched payment is approved, refunded or the order cancelled by an admin
+	OrderStatusPaymentReview: {OrderStatusPaid, OrderStatusCancelled, OrderStatusPartiallyRefunded, OrderStatusRefunded},
+	// Cash is collected on delivery, or the order is cancelled if it is refused
+	OrderStatusCODPending:        {OrderStatusPaid, OrderStatusCancelled},
+	OrderStatusPaid:              {OrderStatusPartiallyRefunded, OrderStatusRefunded},
+	OrderStatusPartiallyRefunded: {OrderStatusRefunded},
+	OrderStatusRefunded:          {},
+}
+
+// CanTransition reports whether an order may move from one status to another
+func CanTransition(from, to OrderStatus) bool {
+	if from == to {
+		_, known := transitions[from]
+		return known
+	}
+	for _, allowed := range transitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// adminTransitions lists the status changes an admin may make by hand.
+// Payments are recorded by the gateways, so an admin only approves a held
+// payment, confirms a cash on delivery order or gives up on an order.
+var adminTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusCreated:       {OrderStatusFailed, OrderStatusCancelled, OrderStatusCODPending},
+	OrderStatusPending:       {OrderStatusFailed, OrderStatusCancelled},
+	OrderStatusFailed:        {OrderStatusCancelled},
+	OrderStatusPaymentReview: {OrderStatusPaid, OrderStatusCancelled},
+	OrderStatusCODPending:    {OrderStatusCancelled},
+}
+
+// CanAdminTransition reports whether an admin may move an order from one
+// status to another through the status endpoint
+func CanAdminTransition(from, to OrderStatus) bool {
+	for _, allowed := range adminTransitions[from] {
+		if allowed == to {
+			return CanTransition(from, to)
+		}
+	}
+	return false
+}
+
+// revivesOrder reports whether a status change brings back a failed or
+// cancelled order, which gave up its stock and coupon use
+func revivesOrder(from, to OrderStatus) bool {
+	if from != OrderStatusFailed && from != OrderStatusCancelled {
+		return false
+	}
+	return to == OrderStatusPending || to == OrderStatusPaid || to == OrderStatusPaymentReview
+}
+
+// InvalidTransitionError is returned when a status change is not allowed
+type InvalidTransitionError struct {
+	From OrderStatus
+	To   OrderStatus
+}
+
+func (e *InvalidTransitionError) Error() string {
+	return fmt.Sprintf("cannot change order status from %s to %s", e.From, e.To)
+}
+
+// ActorType identifies who changed an order's status
+type ActorType string
+
+const (
+	ActorUser    ActorType = "user"
+	ActorAdmin   ActorType = "admin"
+	ActorWebhook ActorType = "webhook"
+	ActorSystem  ActorType = "system"
+)
+
+// Actor is recorded with every status change. ID is a user ID for users and
+// admins, the event ID for webhooks and a job name for the system.
+type Actor struct {
+	Type ActorType
+	ID   string
+}
+
+// StatusKind says which of an order's statuses a history entry changed
+type StatusKind string
+
+const (
+	StatusKindPayment    StatusKind = "payment"
+	StatusKindFulfilment StatusKind = "fulfilment"
+)
+
+// StatusChange is an entry in an order's status history. FromStatus and
+// ToStatus hold an OrderStatus or a FulfilmentStatus depending on Kind.
+type StatusChange struct {
+	ID         uuid.UUID  `json:"id"`
+	OrderID    uuid.UUID  `json:"order_id"`
+	Kind       StatusKind `json:"kind"`
+	FromStatus *string    `json:"from_status,omitempty"`
+	ToStatus   string     `json:"to_status"`
+	ActorType  ActorType  `json:"actor_type"`
+	ActorID    *string    `json:"actor_id,omitempty"`
+	Note       *string    `json:"note,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// lockOrderStatus locks the order row and returns its current status
+func lockOrderStatus(ctx context.Context, tx *sql.Tx, orderID uuid.UUID) (OrderStatus, error) {
+	var status OrderStatus
+	err := tx.QueryRowContext(ctx, "SELECT status FROM orders WHERE id = $1 FOR UPDATE", orderID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("order not found")
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get order: %w", err)
+	}
+	return status, nil
+}
+
+// recordTransition appends a payment status change to the order's history.
+// from is nil for the initial status.
+func recordTransition(ctx context.Context, tx *sql.Tx, orderID uuid.UUID, from *OrderStatus, to OrderStatus, actor Actor, note string) error {
+	var fromStr *string
+	if from != nil {
+		s := string(*from)
+		fromStr = &s
+	}
+	return recordStatusChange(ctx, tx, orderID, StatusKindPayment, fromStr, string(to), actor, note)
+}
+
+func recordStatusChange(ctx context.Context, tx *sql.Tx, orderID uuid.UUID, kind StatusKind, from *string, to string, actor Actor, note string) error {
+	var actorID, notePtr *string
+	if actor.ID != "" {
+		actorID = &actor.ID
+	}
+	if note != "" {
+		notePtr = &note
+	}
+
+	actorType := actor.Type
+	if actorType == "" {
+		actorType = ActorSystem
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO order_status_history (order_id, kind, from_status, to_status, actor_type, actor_id, note)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, orderID, kind, from, to, actorType, actorID, notePtr)
+	if err != nil {
+		return fmt.Errorf("failed to record status history: %w", err)
+	}
+
+	return nil
+}
+
+// GetStatusHistory returns an order's status changes, oldest first
+func (r *OrderRepository) GetStatusHistory(ctx context.Context, orderID uuid.UUID) ([]StatusChange, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, order_id, kind, from_status, to_status, actor_type, actor_id, note, created_at
+		FROM order_status_history
+		WHERE order_id = $1
+		ORDER BY created_at, id
+	`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status history: %w", err)
+	}
+	defer rows.Close()
+
+	history := []StatusChange{}
+	for rows.Next() {
+		var change StatusChange
+		if err := rows.Scan(
+			&change.ID, &change.OrderID, &change.Kind, &change.FromStatus, &change.ToStatus,
+			&change.ActorType, &change.ActorID, &change.Note, &change.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan status change: %w", err)
+		}
+		history = append(history, change)
+	}
+
+	return history, rows.Err()
+}
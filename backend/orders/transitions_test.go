@@ -0,0 +1,130 @@
+package orders
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to OrderStatus
+		want     bool
+	}{
+		{OrderStatusCreated, OrderStatusPending, true},
+		{OrderStatusPending, OrderStatusPaid, true},
+		{OrderStatusFailed, OrderStatusPaid, true},
+		{OrderStatusCancelled, OrderStatusPaid, true},
+		{OrderStatusPaid, OrderStatusPartiallyRefunded, true},
+		{OrderStatusPartiallyRefunded, OrderStatusRefunded, true},
+		{OrderStatusCreated, OrderStatusCODPending, true},
+		{OrderStatusCODPending, OrderStatusPaid, true},
+		{OrderStatusCODPending, OrderStatusCancelled, true},
+		{OrderStatusPaid, OrderStatusPaid, true},
+		{OrderStatusPaid, OrderStatusFailed, false},
+		{OrderStatusPaid, OrderStatusPending, false},
+		{OrderStatusPaid, OrderStatusCancelled, false},
+		{OrderStatusRefunded, OrderStatusCreated, false},
+		{OrderStatusRefunded, OrderStatusPaid, false},
+		{OrderStatusPartiallyRefunded, OrderStatusPaid, false},
+		{OrderStatusPending, OrderStatusCODPending, false},
+		{OrderStatusCODPending, OrderStatusFailed, false},
+		{OrderStatus("unknown"), OrderStatus("unknown"), false},
+	}
+
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestCanAdminTransition(t *testing.T) {
+	tests := []struct {
+		from, to OrderStatus
+		want     bool
+	}{
+		{OrderStatusPaymentReview, OrderStatusPaid, true},
+		{OrderStatusPaymentReview, OrderStatusCancelled, true},
+		{OrderStatusCreated, OrderStatusCODPending, true},
+		{OrderStatusCreated, OrderStatusCancelled, true},
+		{OrderStatusPending, OrderStatusFailed, true},
+		{OrderStatusCODPending, OrderStatusCancelled, true},
+		// Only gateways record payments
+		{OrderStatusCreated, OrderStatusPaid, false},
+		{OrderStatusPending, OrderStatusPaid, false},
+		{OrderStatusFailed, OrderStatusPaid, false},
+		{OrderStatusCancelled, OrderStatusPaid, false},
+		{OrderStatusCODPending, OrderStatusPaid, false},
+		{OrderStatusPending, OrderStatusPaymentReview, false},
+		{OrderStatusFailed, OrderStatusPending, false},
+		{OrderStatusPending, OrderStatusCODPending, false},
+		{OrderStatusPaid, OrderStatusCancelled, false},
+	}
+
+	for _, tt := range tests {
+		if got := CanAdminTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanAdminTransition(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestRevivesOrder(t *testing.T) {
+	tests := []struct {
+		from, to OrderStatus
+		want     bool
+	}{
+		{OrderStatusFailed, OrderStatusPending, true},
+		{OrderStatusFailed, OrderStatusPaid, true},
+		{OrderStatusFailed, OrderStatusPaymentReview, true},
+		{OrderStatusCancelled, OrderStatusPaid, true},
+		{OrderStatusCancelled, OrderStatusPaymentReview, true},
+		{OrderStatusFailed, OrderStatusCancelled, false},
+		{OrderStatusFailed, OrderStatusFailed, false},
+		{OrderStatusPending, OrderStatusPaid, false},
+		{OrderStatusCreated, OrderStatusPending, false},
+	}
+
+	for _, tt := range tests {
+		if got := revivesOrder(tt.from, tt.to); got != tt.want {
+			t.Errorf("revivesOrder(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestInvalidTransitionError(t *testing.T) {
+	var err error = &InvalidTransitionError{From: OrderStatusPaid, To: OrderStatusFailed}
+
+	var invalid *InvalidTransitionError
+	if !errors.As(err, &invalid) {
+		t.Fatal("Expected errors.As to match InvalidTransitionError")
+	}
+	if invalid.From != OrderStatusPaid || invalid.To != OrderStatusFailed {
+		t.Errorf("Unexpected transition %s -> %s", invalid.From, invalid.To)
+	}
+}
+
+func TestCanFulfilmentTransition(t *testing.T) {
+	tests := []struct {
+		from, to FulfilmentStatus
+		want     bool
+	}{
+		{FulfilmentUnfulfilled, FulfilmentProcessing, true},
+		{FulfilmentPacked, FulfilmentShipped, true},
+		{FulfilmentShipped, FulfilmentOutForDelivery, true},
+		{FulfilmentShipped, FulfilmentReturned, true},
+		{FulfilmentDelivered, FulfilmentReturnRequested, true},
+		{FulfilmentReturnRequested, FulfilmentDelivered, true},
+		{FulfilmentShipped, FulfilmentShipped, true},
+		{FulfilmentShipped, FulfilmentPacked, false},
+		{FulfilmentDelivered, FulfilmentShipped, false},
+		{FulfilmentDelivered, FulfilmentReturned, false},
+		{FulfilmentReturned, FulfilmentDelivered, false},
+		{FulfilmentStatus("lost"), FulfilmentStatus("lost"), false},
+	}
+
+	for _, tt := range tests {
+		if got := CanFulfilmentTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanFulfilmentTransition(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package orders
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// WebhookEventStatus represents the processing state of a webhook event
+type WebhookEventStatus string
+
+const (
+	WebhookEventReceived   WebhookEventStatus = "received"
+	WebhookEventProcessing WebhookEventStatus = "processing"
+	WebhookEventProcessed  WebhookEventStatus = "processed"
+	WebhookEventIgnored    WebhookEventStatus = "ignored"
+	WebhookEventFailed     WebhookEventStatus = "failed"
+)
+
+// webhookProcessingLease is how long a claimed event may stay in processing
+// before it is considered abandoned (e.g. the replica crashed) and retried
+const webhookProcessingLease = 5 * time.Minute
+
+// WebhookEvent represents a stored webhook delivery
+type WebhookEvent struct {
+	ID            uuid.UUID          `json:"id"`
+	Gateway       string             `json:"gateway"`
+	EventID       string             `json:"event_id"`
+	EventType     string             `json:"event_type"`
+	Payload       json.RawMessage    `json:"payload"`
+	Status        WebhookEventStatus `json:"status"`
+	Attempts      int                `json:"attempts"`
+	LastError     *string            `json:"last_error,omitempty"`
+	NextAttemptAt *time.Time         `json:"next_attempt_at,omitempty"`
+	ProcessedAt   *time.Time         `json:"processed_at,omitempty"`
+	CreatedAt     time.Time          `json:"created_at"`
+	UpdatedAt     time.Time          `json:"updated_at"`
+}
+
+// ListWebhookEventsFilter represents filters for listing webhook events
+type ListWebhookEventsFilter struct {
+	Status    *WebhookEventStatus
+	Gateway   string
+	EventType string
+	Limit     int
+	Offset    int
+}
+
+const webhookEventColumns = `id, gateway, event_id, event_type, payload, status, attempts, last_error,
+		       next_attempt_at, processed_at, created_at, updated_at`
+
+func scanWebhookEvent(row rowScanner) (*WebhookEvent, error) {
+	var event WebhookEvent
+	var payload []byte
+	err := row.Scan(
+		&event.ID, &event.Gateway, &event.EventID, &event.EventType, &payload, &event.Status, &event.Attempts,
+		&event.LastError, &event.NextAttemptAt, &event.ProcessedAt, &event.CreatedAt, &event.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	event.Payload = payload
+	return &event, nil
+}
+
+// RecordWebhookEvent stores a webhook delivery from a payment gateway.
+// Deliveries are idempotent on the gateway and eventID: a redelivery returns
+// the stored event and isNew false. Gateways issue event IDs independently, so
+// the same ID from two gateways is two events.
+func (r *OrderRepository) RecordWebhookEvent(ctx context.Context, gateway, eventID, eventType string, payload json.RawMessage) (*WebhookEvent, bool, error) {
+	event, err := scanWebhookEvent(r.db.QueryRowContext(ctx, `
+		INSERT INTO webhook_events (gateway, event_id, event_type, payload, status)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (gateway, event_id) DO NOTHING
+		RETURNING `+webhookEventColumns,
+		gateway, eventID, eventType, payload, WebhookEventReceived,
+	))
+	if err == nil {
+		return event, true, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, false, fmt.Errorf("failed to record webhook event: %w", err)
+	}
+
+	event, err = scanWebhookEvent(r.db.QueryRowContext(ctx,
+		"SELECT "+webhookEventColumns+" FROM webhook_events WHERE gateway = $1 AND event_id = $2",
+		gateway, eventID,
+	))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get webhook event: %w", err)
+	}
+
+	return event, false, nil
+}
+
+// GetWebhookEvent retrieves a webhook event by ID
+func (r *OrderRepository) GetWebhookEvent(ctx context.Context, id uuid.UUID) (*WebhookEvent, error) {
+	event, err := scanWebhookEvent(r.db.QueryRowContext(ctx,
+		"SELECT "+webhookEventColumns+" FROM webhook_events WHERE id = $1",
+		id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("webhook event not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook event: %w", err)
+	}
+
+	return event, nil
+}
+
+// ClaimWebhookEvent marks an event as processing and counts the attempt. It
+// returns false if the event is finished or being processed elsewhere; force
+// also claims processed and ignored events so an admin can replay them.
+func (r *OrderRepository) ClaimWebhookEvent(ctx context.Context, id uuid.UUID, force bool) (bool, error) {
+	claimable := []WebhookEventStatus{WebhookEventReceived, WebhookEventFailed}
+	if force {
+		claimable = append(claimable, WebhookEventProcessed, WebhookEventIgnored)
+	}
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE webhook_events
+		SET status = $1, attempts = attempts + 1, next_attempt_at = NULL
+		WHERE id = $2
+		  AND (status = ANY($3) OR (status = $1 AND updated_at < $4))
+	`, WebhookEventProcessing, id, statusArray(claimable), time.Now().Add(-webhookProcessingLease))
+	if err != nil {
+		return false, fmt.Errorf("failed to claim webhook event: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// CompleteWebhookEvent records that a claimed event was processed or ignored
+func (r *OrderRepository) CompleteWebhookEvent(ctx context.Context, id uuid.UUID, status WebhookEventStatus) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE webhook_events
+		SET status = $1, processed = TRUE, processed_at = NOW(), last_error = NULL, next_attempt_at = NULL
+		WHERE id = $2
+	`, status, id)
+	if err != nil {
+		return fmt.Errorf("failed to complete webhook event: %w", err)
+	}
+	return nil
+}
+
+// FailWebhookEvent records a processing error. nextAttempt is nil when no
+// further automatic retries should be made.
+func (r *OrderRepository) FailWebhookEvent(ctx context.Context, id uuid.UUID, errText string, nextAttempt *time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE webhook_events
+		SET status = $1, last_error = $2, next_attempt_at = $3
+		WHERE id = $4
+	`, WebhookEventFailed, errText, nextAttempt, id)
+	if err != nil {
+		return fmt.Errorf("failed to record webhook failure: %w", err)
+	}
+	return nil
+}
+
+// ListRetryableWebhookEvents returns failed events due for a retry and events
+// whose processing was abandoned, oldest first
+func (r *OrderRepository) ListRetryableWebhookEvents(ctx context.Context, limit int) ([]WebhookEvent, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+webhookEventColumns+`
+		FROM webhook_events
+		WHERE (status = $1 AND next_attempt_at <= NOW())
+		   OR (status IN ($2, $3) AND updated_at < $4)
+		ORDER BY created_at
+		LIMIT $5
+	`, WebhookEventFailed, WebhookEventReceived, WebhookEventProcessing, time.Now().Add(-webhookProcessingLease), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list retryable webhook events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []WebhookEvent{}
+	for rows.Next() {
+		event, err := scanWebhookEvent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook event: %w", err)
+		}
+		events = append(events, *event)
+	}
+
+	return events, rows.Err()
+}
+
+// ListWebhookEvents retrieves webhook events, newest first
+func (r *OrderRepository) ListWebhookEvents(ctx context.Context, filter ListWebhookEventsFilter) ([]WebhookEvent, int, error) {
+	where := " WHERE 1=1"
+	args := []interface{}{}
+	argCount := 1
+
+	if filter.Status != nil {
+		where += fmt.Sprintf(" AND status = $%d", argCount)
+		args = append(args, *filter.Status)
+		argCount++
+	}
+
+	if filter.Gateway != "" {
+		where += fmt.Sprintf(" AND gateway = $%d", argCount)
+		args = append(args, filter.Gateway)
+		argCount++
+	}
+
+	if filter.EventType != "" {
+		where += fmt.Sprintf(" AND event_type = $%d", argCount)
+		args = append(args, filter.EventType)
+		argCount++
+	}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM webhook_events"+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count webhook events: %w", err)
+	}
+
+	limit := 20
+	if filter.Limit > 0 && filter.Limit <= 100 {
+		limit = filter.Limit
+	}
+
+	query := "SELECT " + webhookEventColumns + " FROM webhook_events" + where +
+		fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d OFFSET $%d", argCount, argCount+1)
+	args = append(args, limit, filter.Offset)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list webhook events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []WebhookEvent{}
+	for rows.Next() {
+		event, err := scanWebhookEvent(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan webhook event: %w", err)
+		}
+		events = append(events, *event)
+	}
+
+	return events, total, rows.Err()
+}
+
+func statusArray(statuses []WebhookEventStatus) pq.StringArray {
+	arr := make(pq.StringArray, len(statuses))
+	for i, s := range statuses {
+		arr[i] = string(s)
+	}
+	return arr
+}
@@ -0,0 +1,55 @@
+package payments
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrCODDisabled is returned when cash on delivery is switched off
+	ErrCODDisabled = errors.New("cash on delivery is not available")
+	// ErrCODLimitExceeded is returned for orders above the COD limit
+	ErrCODLimitExceeded = errors.New("order value exceeds the cash on delivery limit")
+	// ErrCODPincodeNotServiceable is returned for pincodes couriers do not collect cash in
+	ErrCODPincodeNotServiceable = errors.New("cash on delivery is not available for this pincode")
+)
+
+// CODRules decide which orders may be paid for on delivery
+type CODRules struct {
+	Enabled       bool
+	MaxOrderCents int // 0 means no limit
+	FeeCents      int // Added to the order total
+	pincodes      map[string]bool
+}
+
+// NewCODRules creates COD rules. pincodes is a comma-separated list of
+// serviceable pincodes; empty means every pincode is serviceable.
+func NewCODRules(enabled bool, maxOrderCents, feeCents int, pincodes string) *CODRules {
+	rules := &CODRules{
+		Enabled:       enabled,
+		MaxOrderCents: maxOrderCents,
+		FeeCents:      feeCents,
+		pincodes:      map[string]bool{},
+	}
+	for _, pincode := range strings.Split(pincodes, ",") {
+		if pincode = strings.TrimSpace(pincode); pincode != "" {
+			rules.pincodes[pincode] = true
+		}
+	}
+	return rules
+}
+
+// Check reports whether an order with the given item total may be delivered
+// to pincode as cash on delivery. The limit applies before the COD fee.
+func (r *CODRules) Check(subtotalCents int, pincode string) error {
+	if r == nil || !r.Enabled {
+		return ErrCODDisabled
+	}
+	if r.MaxOrderCents > 0 && subtotalCents > r.MaxOrderCents {
+		return ErrCODLimitExceeded
+	}
+	if len(r.pincodes) > 0 && !r.pincodes[strings.TrimSpace(pincode)] {
+		return ErrCODPincodeNotServiceable
+	}
+	return nil
+}
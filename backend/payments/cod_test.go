@@ -0,0 +1,40 @@
+package payments
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCODRulesCheck(t *testing.T) {
+	rules := NewCODRules(true, 500000, 4900, "560001, 560002,")
+
+	tests := []struct {
+		name     string
+		subtotal int
+		pincode  string
+		want     error
+	}{
+		{"serviceable", 49900, "560001", nil},
+		{"at the limit", 500000, "560002", nil},
+		{"surrounding spaces ignored", 49900, " 560001 ", nil},
+		{"over the limit", 500001, "560001", ErrCODLimitExceeded},
+		{"unlisted pincode", 49900, "110001", ErrCODPincodeNotServiceable},
+	}
+
+	for _, tt := range tests {
+		if err := rules.Check(tt.subtotal, tt.pincode); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Check(%d, %q) = %v, want %v", tt.name, tt.subtotal, tt.pincode, err, tt.want)
+		}
+	}
+
+	// No limit and no pincode list allow every order
+	open := NewCODRules(true, 0, 0, "")
+	if err := open.Check(10000000, "110001"); err != nil {
+		t.Errorf("Expected unrestricted COD to allow the order, got %v", err)
+	}
+
+	disabled := NewCODRules(false, 0, 0, "")
+	if err := disabled.Check(49900, "560001"); !errors.Is(err, ErrCODDisabled) {
+		t.Errorf("Expected ErrCODDisabled, got %v", err)
+	}
+}
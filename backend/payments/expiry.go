@@ -0,0 +1,142 @@
+package payments
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ramniya/ramniya-backend/orders"
+	"go.uber.org/zap"
+)
+
+// ExpiryStore is the subset of the order repository used by the expirer
+type ExpiryStore interface {
+	ListExpiredUnpaidOrders(ctx context.Context, olderThan time.Duration, limit int) ([]orders.Order, error)
+	ExpireOrder(ctx context.Context, orderID uuid.UUID, olderThan time.Duration, status orders.OrderStatus) (bool, error)
+}
+
+// ExpiryConfig controls when and how unpaid orders are expired
+type ExpiryConfig struct {
+	TTL       time.Duration
+	Status    orders.OrderStatus // cancelled or failed
+	BatchSize int
+}
+
+// ExpiryResult summarises an expiry run
+type ExpiryResult struct {
+	Checked int
+	Expired int
+	Skipped int
+	Errors  int
+}
+
+// OrderExpirer cancels orders that were never paid and returns their stock.
+//
+// Gateways such as Razorpay have no API to close an order, so a customer
+// could still pay an order we have expired. Orders that reached a gateway are
+// therefore checked first and left alone while a payment is captured or
+// authorized; the reconciler settles those. A payment that arrives after expiry is still
+// recorded, since cancelled orders may move to paid.
+type OrderExpirer struct {
+	store    ExpiryStore
+	gateways *Registry
+	logger   *zap.Logger
+	config   ExpiryConfig
+}
+
+// NewOrderExpirer creates a new order expirer
+func NewOrderExpirer(store ExpiryStore, gateways *Registry, logger *zap.Logger, config ExpiryConfig) *OrderExpirer {
+	if config.TTL <= 0 {
+		config.TTL = orders.DefaultOrderExpiry
+	}
+	if config.Status == "" {
+		config.Status = orders.OrderStatusCancelled
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+
+	return &OrderExpirer{
+		store:    store,
+		gateways: gateways,
+		logger:   logger,
+		config:   config,
+	}
+}
+
+// Run expires a batch of unpaid orders past the TTL
+func (e *OrderExpirer) Run(ctx context.Context) (*ExpiryResult, error) {
+	expired, err := e.store.ListExpiredUnpaidOrders(ctx, e.config.TTL, e.config.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ExpiryResult{}
+	for i := range expired {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+
+		order := &expired[i]
+		result.Checked++
+
+		if order.RazorpayOrderID != nil {
+			inFlight, err := e.hasLivePayment(order)
+			if err != nil {
+				// Leave the order for the next run rather than risk
+				// cancelling a paid order
+				result.Errors++
+				e.logger.Error("Failed to check payments before expiry",
+					zap.String("order_id", order.ID.String()),
+					zap.Error(err),
+				)
+				continue
+			}
+			if inFlight {
+				result.Skipped++
+				e.logger.Info("Order expiry skipped, payment in progress",
+					zap.String("order_id", order.ID.String()),
+					zap.String("gateway_order_id", *order.RazorpayOrderID),
+				)
+				continue
+			}
+		}
+
+		ok, err := e.store.ExpireOrder(ctx, order.ID, e.config.TTL, e.config.Status)
+		if err != nil {
+			result.Errors++
+			e.logger.Error("Failed to expire order",
+				zap.String("order_id", order.ID.String()),
+				zap.Error(err),
+			)
+			continue
+		}
+		if ok {
+			result.Expired++
+		}
+	}
+
+	return result, nil
+}
+
+// hasLivePayment reports whether a payment against the gateway order has
+// been captured or is authorized and awaiting capture
+func (e *OrderExpirer) hasLivePayment(order *orders.Order) (bool, error) {
+	gateway, err := e.gateways.Get(order.PaymentGateway)
+	if err != nil {
+		return false, err
+	}
+
+	attempts, err := gateway.FetchOrderPayments(*order.RazorpayOrderID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, payment := range attempts {
+		if payment.Status == PaymentStatusCaptured || payment.Status == PaymentStatusAuthorized {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,104 @@
+package payments
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ramniya/ramniya-backend/orders"
+	"github.com/ramniya/ramniya-backend/razorpay"
+	"go.uber.org/zap"
+)
+
+type fakeExpiryStore struct {
+	orders  []orders.Order
+	expired map[uuid.UUID]orders.OrderStatus
+}
+
+func (s *fakeExpiryStore) ListExpiredUnpaidOrders(ctx context.Context, olderThan time.Duration, limit int) ([]orders.Order, error) {
+	return s.orders, nil
+}
+
+func (s *fakeExpiryStore) ExpireOrder(ctx context.Context, orderID uuid.UUID, olderThan time.Duration, status orders.OrderStatus) (bool, error) {
+	if _, done := s.expired[orderID]; done {
+		return false, nil
+	}
+	s.expired[orderID] = status
+	return true, nil
+}
+
+func TestOrderExpirerRun(t *testing.T) {
+	neverSent := newOrder(orders.OrderStatusCreated, "")
+	neverSent.RazorpayOrderID = nil
+	abandoned := newOrder(orders.OrderStatusPending, "order_abandoned")
+	authorized := newOrder(orders.OrderStatusPending, "order_authorized")
+
+	rzp := razorpayStandIn(t,
+		map[string]razorpay.OrderEntity{},
+		map[string][]razorpay.PaymentEntity{
+			"order_abandoned":  {{ID: "pay_1", Status: "failed"}},
+			"order_authorized": {{ID: "pay_2", Status: "authorized"}},
+		},
+	)
+
+	store := &fakeExpiryStore{
+		orders:  []orders.Order{neverSent, abandoned, authorized},
+		expired: map[uuid.UUID]orders.OrderStatus{},
+	}
+	expirer := NewOrderExpirer(store, rzp, zap.NewNop(), ExpiryConfig{TTL: time.Hour, Status: orders.OrderStatusFailed})
+
+	result, err := expirer.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if result.Checked != 3 || result.Expired != 2 || result.Skipped != 1 || result.Errors != 0 {
+		t.Errorf("Unexpected result: %+v", *result)
+	}
+
+	for _, order := range []orders.Order{neverSent, abandoned} {
+		if status, ok := store.expired[order.ID]; !ok || status != orders.OrderStatusFailed {
+			t.Errorf("Expected order %s to be expired as failed, got %q", order.ID, status)
+		}
+	}
+	if _, ok := store.expired[authorized.ID]; ok {
+		t.Error("Order with an authorized payment must not be expired")
+	}
+
+	// A second run must not expire anything again
+	result, err = expirer.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Second run failed: %v", err)
+	}
+	if result.Expired != 0 {
+		t.Errorf("Expected no orders expired on second run, got %d", result.Expired)
+	}
+}
+
+func TestOrderExpirerKeepsOrderWhenRazorpayFails(t *testing.T) {
+	order := newOrder(orders.OrderStatusPending, "order_1")
+
+	rzp := NewRegistry(NewRazorpayGateway(razorpay.NewRazorpayService(razorpay.RazorpayConfig{
+		KeyID:     "wrong_key",
+		KeySecret: "secret",
+		BaseURL:   standInServer(t, nil, nil),
+	}, zap.NewNop()), "wrong_key"))
+
+	store := &fakeExpiryStore{
+		orders:  []orders.Order{order},
+		expired: map[uuid.UUID]orders.OrderStatus{},
+	}
+
+	result, err := NewOrderExpirer(store, rzp, zap.NewNop(), ExpiryConfig{}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if result.Errors != 1 || result.Expired != 0 {
+		t.Errorf("Unexpected result: %+v", *result)
+	}
+	if len(store.expired) != 0 {
+		t.Error("Order must not be expired when Razorpay cannot be checked")
+	}
+}
@@ -0,0 +1,215 @@
+package payments
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ramniya/ramniya-backend/orders"
+)
+
+// fakeSecret signs fake payments and webhooks
+const fakeSecret = "fake_gateway_secret"
+
+// FakeGateway is an in-memory gateway for local development and tests.
+// CreateOrder captures the payment straight away and returns its ID and
+// signature in ClientData, so a checkout page can post them to
+// verify-payment without a real provider. Tests can replace an order's
+// payments with SetPayments.
+type FakeGateway struct {
+	mu       sync.Mutex
+	nextID   int
+	orders   map[string]*GatewayOrder
+	payments map[string][]Payment
+	refunds  []Refund
+}
+
+// NewFakeGateway creates a fake gateway
+func NewFakeGateway() *FakeGateway {
+	return &FakeGateway{
+		orders:   map[string]*GatewayOrder{},
+		payments: map[string][]Payment{},
+	}
+}
+
+// Name implements Gateway
+func (g *FakeGateway) Name() string {
+	return GatewayFake
+}
+
+// CreateOrder implements Gateway
+func (g *FakeGateway) CreateOrder(input CreateOrderInput) (*GatewayOrder, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.nextID++
+	order := &GatewayOrder{
+		ID:         fmt.Sprintf("fake_order_%d", g.nextID),
+		Amount:     input.Amount,
+		AmountPaid: input.Amount,
+		Currency:   strings.ToUpper(input.Currency),
+		Status:     GatewayOrderPaid,
+	}
+	payment := Payment{
+		ID:        fmt.Sprintf("fake_pay_%d", g.nextID),
+		OrderID:   order.ID,
+		Amount:    input.Amount,
+		Currency:  order.Currency,
+		Status:    PaymentStatusCaptured,
+		Method:    "fake",
+		CreatedAt: time.Now().Unix(),
+	}
+	g.orders[order.ID] = order
+	g.payments[order.ID] = []Payment{payment}
+
+	result := *order
+	result.ClientData = map[string]string{
+		"payment_id": payment.ID,
+		"signature":  FakeSign(order.ID + "|" + payment.ID),
+	}
+	return &result, nil
+}
+
+// SetPayments replaces the payment attempts recorded against an order
+func (g *FakeGateway) SetPayments(orderID string, payments ...Payment) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	order, ok := g.orders[orderID]
+	if !ok {
+		order = &GatewayOrder{ID: orderID}
+		g.orders[orderID] = order
+	}
+
+	order.Status = GatewayOrderCreated
+	order.AmountPaid = 0
+	for i := range payments {
+		payments[i].OrderID = orderID
+		switch payments[i].Status {
+		case PaymentStatusCaptured:
+			order.Status = GatewayOrderPaid
+			order.AmountPaid += payments[i].Amount
+		case PaymentStatusFailed:
+			if order.Status == GatewayOrderCreated {
+				order.Status = GatewayOrderAttempted
+			}
+		}
+	}
+	g.payments[orderID] = payments
+}
+
+// Refunds returns the refunds submitted so far
+func (g *FakeGateway) Refunds() []Refund {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]Refund(nil), g.refunds...)
+}
+
+// FetchOrder implements Gateway
+func (g *FakeGateway) FetchOrder(orderID string) (*GatewayOrder, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	order, ok := g.orders[orderID]
+	if !ok {
+		return nil, fmt.Errorf("fake gateway: order %s not found", orderID)
+	}
+	result := *order
+	return &result, nil
+}
+
+// VerifyPaymentSignature implements Gateway
+func (g *FakeGateway) VerifyPaymentSignature(orderID, paymentID, signature string) bool {
+	return hmac.Equal([]byte(FakeSign(orderID+"|"+paymentID)), []byte(signature))
+}
+
+// FetchPayment implements Gateway
+func (g *FakeGateway) FetchPayment(paymentID string) (*Payment, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, payments := range g.payments {
+		for i := range payments {
+			if payments[i].ID == paymentID {
+				payment := payments[i]
+				return &payment, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("fake gateway: payment %s not found", paymentID)
+}
+
+// FetchOrderPayments implements Gateway
+func (g *FakeGateway) FetchOrderPayments(orderID string) ([]Payment, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.orders[orderID]; !ok {
+		return nil, fmt.Errorf("fake gateway: order %s not found", orderID)
+	}
+	return append([]Payment(nil), g.payments[orderID]...), nil
+}
+
+// Refund implements Gateway. Refunds are processed immediately, and
+// resubmitting a receipt returns the refund already made.
+func (g *FakeGateway) Refund(input RefundInput) (*Refund, error) {
+	payment, err := g.FetchPayment(input.PaymentID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRefundRejected, err)
+	}
+	if payment.Status != PaymentStatusCaptured {
+		return nil, fmt.Errorf("%w: fake gateway: payment %s is %s", ErrRefundRejected, payment.ID, payment.Status)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, refund := range g.refunds {
+		if input.Receipt != "" && refund.Receipt == input.Receipt {
+			return &refund, nil
+		}
+	}
+
+	amount := input.Amount
+	if amount == 0 {
+		amount = payment.Amount
+	}
+	refund := Refund{
+		ID:        fmt.Sprintf("fake_rfnd_%d", len(g.refunds)+1),
+		PaymentID: payment.ID,
+		Amount:    amount,
+		Receipt:   input.Receipt,
+		Status:    orders.RefundStatusProcessed,
+	}
+	g.refunds = append(g.refunds, refund)
+	return &refund, nil
+}
+
+// VerifyWebhook implements Gateway. Fake webhooks carry FakeSign(payload) in
+// the X-Fake-Signature header.
+func (g *FakeGateway) VerifyWebhook(payload []byte, header http.Header) bool {
+	return hmac.Equal([]byte(FakeSign(string(payload))), []byte(header.Get("X-Fake-Signature")))
+}
+
+// ParseWebhook implements Gateway. Fake webhook payloads are JSON-encoded
+// WebhookEvents.
+func (g *FakeGateway) ParseWebhook(payload []byte, header http.Header) (*WebhookEvent, error) {
+	var event WebhookEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("failed to parse webhook payload: %w", err)
+	}
+	return &event, nil
+}
+
+// FakeSign signs a message the way the fake gateway expects
+func FakeSign(message string) string {
+	h := hmac.New(sha256.New, []byte(fakeSecret))
+	h.Write([]byte(message))
+	return hex.EncodeToString(h.Sum(nil))
+}
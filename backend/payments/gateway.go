@@ -0,0 +1,192 @@
+package payments
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ramniya/ramniya-backend/orders"
+)
+
+// Gateway names, recorded on orders and webhook events
+const (
+	GatewayRazorpay = "razorpay"
+	GatewayStripe   = "stripe"
+	GatewayFake     = "fake"
+)
+
+// Payment statuses, normalised across gateways
+const (
+	PaymentStatusCreated    = "created" // Awaiting the customer or still processing
+	PaymentStatusAuthorized = "authorized"
+	PaymentStatusCaptured   = "captured"
+	PaymentStatusRefunded   = "refunded"
+	PaymentStatusFailed     = "failed"
+)
+
+// Gateway order statuses, normalised across gateways
+const (
+	GatewayOrderCreated   = "created"
+	GatewayOrderAttempted = "attempted"
+	GatewayOrderPaid      = "paid"
+)
+
+// ErrGatewayUnavailable is returned for gateways that are not configured
+var ErrGatewayUnavailable = errors.New("payment gateway not configured")
+
+// Gateway is a payment provider. Amounts are in the smallest currency unit.
+type Gateway interface {
+	// Name returns the gateway name recorded on orders
+	Name() string
+
+	// CreateOrder starts a payment for one of our orders
+	CreateOrder(input CreateOrderInput) (*GatewayOrder, error)
+
+	// FetchOrder retrieves a gateway order
+	FetchOrder(orderID string) (*GatewayOrder, error)
+
+	// VerifyPaymentSignature checks the result the checkout page reported
+	// back. Callers must still confirm the payment with FetchPayment.
+	VerifyPaymentSignature(orderID, paymentID, signature string) bool
+
+	// FetchPayment retrieves a payment
+	FetchPayment(paymentID string) (*Payment, error)
+
+	// FetchOrderPayments retrieves every payment attempt against a gateway order
+	FetchOrderPayments(orderID string) ([]Payment, error)
+
+	// Refund refunds a captured payment, fully or partially
+	Refund(input RefundInput) (*Refund, error)
+
+	// VerifyWebhook checks the signature of a webhook delivery
+	VerifyWebhook(payload []byte, header http.Header) bool
+
+	// ParseWebhook translates a webhook payload. header is nil when a stored
+	// event is processed again.
+	ParseWebhook(payload []byte, header http.Header) (*WebhookEvent, error)
+}
+
+// CreateOrderInput represents a payment to start
+type CreateOrderInput struct {
+	Amount   int
+	Currency string
+	Receipt  string // Our order ID
+	Notes    map[string]string
+}
+
+// GatewayOrder represents a payment started at a gateway
+type GatewayOrder struct {
+	ID         string
+	Amount     int
+	AmountPaid int
+	Currency   string
+	Status     string // GatewayOrderCreated, GatewayOrderAttempted or GatewayOrderPaid
+
+	// ClientData holds what the checkout page needs to collect the payment,
+	// e.g. a publishable key or client secret
+	ClientData map[string]string
+}
+
+// Payment represents a payment attempt
+type Payment struct {
+	ID               string
+	OrderID          string
+	Amount           int
+	Currency         string
+	Status           string // One of the PaymentStatus constants
+	Method           string
+	ErrorDescription string
+	CreatedAt        int64
+}
+
+// RefundInput represents a refund to submit. Gateways use Receipt as an
+// idempotency key, so submitting the same refund again is safe.
+type RefundInput struct {
+	PaymentID string
+	Amount    int
+	Receipt   string // Our refund ID, echoed back in webhooks
+	Notes     map[string]string
+}
+
+// ErrRefundRejected wraps Refund errors where the gateway definitively
+// refused the refund. After any other error the outcome is unknown: the
+// gateway may have made the refund before the response was lost.
+var ErrRefundRejected = errors.New("refund rejected by payment gateway")
+
+// refundError wraps err in ErrRefundRejected when the gateway answered with
+// a client error. Timeouts, conflicts and rate limits are worth retrying, so
+// they are not treated as rejections.
+func refundError(err error, statusCode int) error {
+	switch statusCode {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooManyRequests:
+		return err
+	}
+	if statusCode >= 400 && statusCode < 500 {
+		return fmt.Errorf("%w: %v", ErrRefundRejected, err)
+	}
+	return err
+}
+
+// Refund represents a refund at a gateway
+type Refund struct {
+	ID        string
+	PaymentID string
+	Amount    int
+	Receipt   string
+	Status    orders.RefundStatus
+}
+
+// WebhookKind is what a webhook event means for our orders
+type WebhookKind string
+
+const (
+	WebhookUnhandled       WebhookKind = ""
+	WebhookPaymentCaptured WebhookKind = "payment_captured"
+	WebhookPaymentFailed   WebhookKind = "payment_failed"
+	WebhookOrderPaid       WebhookKind = "order_paid"
+	WebhookRefundProcessed WebhookKind = "refund_processed"
+	WebhookRefundFailed    WebhookKind = "refund_failed"
+)
+
+// WebhookEvent is a webhook delivery translated from the gateway's format
+type WebhookEvent struct {
+	ID      string // Gateway event ID, empty if the gateway does not send one
+	Type    string // Gateway event name, e.g. payment.captured
+	Kind    WebhookKind
+	Payment *Payment      // Set for payment events
+	Order   *GatewayOrder // Set for WebhookOrderPaid
+	Refund  *Refund       // Set for refund events
+}
+
+// Registry holds the configured gateways. New orders use the default gateway;
+// existing orders use the gateway recorded on them.
+type Registry struct {
+	gateways       map[string]Gateway
+	defaultGateway string
+}
+
+// NewRegistry creates a gateway registry
+func NewRegistry(defaultGateway Gateway, others ...Gateway) *Registry {
+	r := &Registry{
+		gateways:       map[string]Gateway{defaultGateway.Name(): defaultGateway},
+		defaultGateway: defaultGateway.Name(),
+	}
+	for _, gateway := range others {
+		r.gateways[gateway.Name()] = gateway
+	}
+	return r
+}
+
+// Default returns the gateway used for new orders
+func (r *Registry) Default() Gateway {
+	return r.gateways[r.defaultGateway]
+}
+
+// Get returns a gateway by name
+func (r *Registry) Get(name string) (Gateway, error) {
+	gateway, ok := r.gateways[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrGatewayUnavailable, name)
+	}
+	return gateway, nil
+}
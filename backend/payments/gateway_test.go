@@ -0,0 +1,239 @@
+package payments
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ramniya/ramniya-backend/orders"
+	"github.com/ramniya/ramniya-backend/stripe"
+	"go.uber.org/zap"
+)
+
+// stripeStandIn returns a Stripe gateway backed by a server that serves the
+// given PaymentIntents to clients using the sk_test key
+func stripeStandIn(t *testing.T, intents map[string]stripe.PaymentIntent) *StripeGateway {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sk_test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/payment_intents":
+			r.ParseForm()
+			amount := 0
+			fmt.Sscan(r.PostForm.Get("amount"), &amount)
+			json.NewEncoder(w).Encode(stripe.PaymentIntent{
+				ID:           "pi_new",
+				Amount:       amount,
+				Currency:     r.PostForm.Get("currency"),
+				Status:       "requires_payment_method",
+				ClientSecret: "pi_new_secret",
+				Metadata:     map[string]string{"receipt": r.PostForm.Get("metadata[receipt]")},
+			})
+		case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/payment_intents/"):
+			intent, ok := intents[strings.TrimPrefix(r.URL.Path, "/payment_intents/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(intent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return NewStripeGateway(stripe.NewStripeService(stripe.StripeConfig{
+		SecretKey:      "sk_test",
+		PublishableKey: "pk_test",
+		WebhookSecret:  "whsec_test",
+		BaseURL:        server.URL,
+	}, zap.NewNop()))
+}
+
+func TestStripeGateway(t *testing.T) {
+	gateway := stripeStandIn(t, map[string]stripe.PaymentIntent{
+		"pi_paid":     {ID: "pi_paid", Amount: 49900, AmountReceived: 49900, Currency: "inr", Status: "succeeded"},
+		"pi_declined": {ID: "pi_declined", Amount: 49900, Currency: "inr", Status: "requires_payment_method", LastPaymentError: &stripe.PaymentError{Message: "Card declined"}},
+		"pi_waiting":  {ID: "pi_waiting", Amount: 49900, Currency: "inr", Status: "requires_action"},
+	})
+
+	order, err := gateway.CreateOrder(CreateOrderInput{Amount: 49900, Currency: "INR", Receipt: "order-1"})
+	if err != nil {
+		t.Fatalf("CreateOrder failed: %v", err)
+	}
+	if order.ID != "pi_new" || order.Amount != 49900 || order.Currency != "INR" {
+		t.Errorf("Unexpected order: %+v", *order)
+	}
+	if order.ClientData["client_secret"] != "pi_new_secret" || order.ClientData["publishable_key"] != "pk_test" {
+		t.Errorf("Unexpected client data: %v", order.ClientData)
+	}
+
+	tests := []struct {
+		id     string
+		status string
+	}{
+		{"pi_paid", PaymentStatusCaptured},
+		{"pi_declined", PaymentStatusFailed},
+		{"pi_waiting", PaymentStatusCreated},
+	}
+	for _, tt := range tests {
+		payment, err := gateway.FetchPayment(tt.id)
+		if err != nil {
+			t.Fatalf("FetchPayment(%s) failed: %v", tt.id, err)
+		}
+		if payment.Status != tt.status || payment.OrderID != tt.id || payment.Currency != "INR" {
+			t.Errorf("FetchPayment(%s) = %+v, want status %s", tt.id, *payment, tt.status)
+		}
+	}
+
+	if !gateway.VerifyPaymentSignature("pi_paid", "pi_paid", "") {
+		t.Error("Expected the PaymentIntent to verify against itself")
+	}
+	if gateway.VerifyPaymentSignature("pi_paid", "pi_other", "") {
+		t.Error("Expected a different PaymentIntent to be rejected")
+	}
+}
+
+func TestStripeGatewayWebhook(t *testing.T) {
+	gateway := stripeStandIn(t, nil)
+
+	payload := []byte(`{"id":"evt_1","type":"payment_intent.succeeded","data":{"object":` +
+		`{"id":"pi_1","amount":49900,"currency":"inr","status":"succeeded"}}}`)
+
+	sign := func(ts int64, secret string) http.Header {
+		header := http.Header{}
+		header.Set("Stripe-Signature", fmt.Sprintf("t=%d,v1=%s", ts, stripe.SignWebhookPayload(payload, ts, secret)))
+		return header
+	}
+
+	now := time.Now().Unix()
+	if !gateway.VerifyWebhook(payload, sign(now, "whsec_test")) {
+		t.Error("Expected a valid signature to verify")
+	}
+	if gateway.VerifyWebhook(payload, sign(now, "whsec_other")) {
+		t.Error("Expected a signature with the wrong secret to be rejected")
+	}
+	if gateway.VerifyWebhook(payload, sign(now-int64(time.Hour.Seconds()), "whsec_test")) {
+		t.Error("Expected a stale signature to be rejected")
+	}
+
+	event, err := gateway.ParseWebhook(payload, nil)
+	if err != nil {
+		t.Fatalf("ParseWebhook failed: %v", err)
+	}
+	if event.ID != "evt_1" || event.Kind != WebhookPaymentCaptured || event.Payment == nil ||
+		event.Payment.OrderID != "pi_1" || event.Payment.Amount != 49900 {
+		t.Errorf("Unexpected event: %+v", *event)
+	}
+}
+
+func TestStripeGatewayRefundErrors(t *testing.T) {
+	// The payment intent picks the status the stand-in answers with
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		status := 0
+		fmt.Sscan(strings.TrimPrefix(r.PostForm.Get("payment_intent"), "pi_"), &status)
+		w.WriteHeader(status)
+		w.Write([]byte(`{"error":{"message":"test"}}`))
+	}))
+	defer server.Close()
+
+	gateway := NewStripeGateway(stripe.NewStripeService(stripe.StripeConfig{SecretKey: "sk_test", BaseURL: server.URL}, zap.NewNop()))
+
+	tests := []struct {
+		status   int
+		rejected bool
+	}{
+		{http.StatusBadRequest, true},
+		{http.StatusNotFound, true},
+		{http.StatusConflict, false},
+		{http.StatusTooManyRequests, false},
+		{http.StatusInternalServerError, false},
+		{http.StatusBadGateway, false},
+	}
+	for _, tt := range tests {
+		_, err := gateway.Refund(RefundInput{PaymentID: fmt.Sprintf("pi_%d", tt.status), Receipt: "refund-1"})
+		if err == nil || errors.Is(err, ErrRefundRejected) != tt.rejected {
+			t.Errorf("Refund with status %d: got %v, rejected = %v", tt.status, err, tt.rejected)
+		}
+	}
+
+	for _, key := range keys {
+		if key != "refund_refund-1" {
+			t.Errorf("Idempotency-Key = %q, want refund_refund-1", key)
+		}
+	}
+}
+
+func TestFakeGateway(t *testing.T) {
+	gateway := NewFakeGateway()
+
+	order, err := gateway.CreateOrder(CreateOrderInput{Amount: 49900, Currency: "INR", Receipt: "order-1"})
+	if err != nil {
+		t.Fatalf("CreateOrder failed: %v", err)
+	}
+
+	paymentID := order.ClientData["payment_id"]
+	if !gateway.VerifyPaymentSignature(order.ID, paymentID, order.ClientData["signature"]) {
+		t.Error("Expected the returned signature to verify")
+	}
+	if gateway.VerifyPaymentSignature(order.ID, paymentID, "forged") {
+		t.Error("Expected a forged signature to be rejected")
+	}
+
+	payment, err := gateway.FetchPayment(paymentID)
+	if err != nil {
+		t.Fatalf("FetchPayment failed: %v", err)
+	}
+	if payment.Status != PaymentStatusCaptured || payment.Amount != 49900 || payment.OrderID != order.ID {
+		t.Errorf("Unexpected payment: %+v", *payment)
+	}
+
+	refund, err := gateway.Refund(RefundInput{PaymentID: paymentID, Amount: 100, Receipt: "refund-1"})
+	if err != nil {
+		t.Fatalf("Refund failed: %v", err)
+	}
+	if refund.Status != orders.RefundStatusProcessed || refund.Receipt != "refund-1" || len(gateway.Refunds()) != 1 {
+		t.Errorf("Unexpected refund: %+v", *refund)
+	}
+
+	// Resubmitting the same refund does not refund twice
+	again, err := gateway.Refund(RefundInput{PaymentID: paymentID, Amount: 100, Receipt: "refund-1"})
+	if err != nil || again.ID != refund.ID || len(gateway.Refunds()) != 1 {
+		t.Errorf("Resubmitted refund = %+v, %v; want %s again", again, err, refund.ID)
+	}
+	if _, err := gateway.Refund(RefundInput{PaymentID: "fake_missing", Receipt: "refund-2"}); !errors.Is(err, ErrRefundRejected) {
+		t.Errorf("Expected ErrRefundRejected for an unknown payment, got %v", err)
+	}
+
+	gateway.SetPayments(order.ID, Payment{ID: "fake_declined", Amount: 49900, Currency: "INR", Status: PaymentStatusFailed})
+	fetched, err := gateway.FetchOrder(order.ID)
+	if err != nil {
+		t.Fatalf("FetchOrder failed: %v", err)
+	}
+	if fetched.Status != GatewayOrderAttempted {
+		t.Errorf("Expected attempted order after a declined payment, got %s", fetched.Status)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	fake := NewFakeGateway()
+	registry := NewRegistry(fake)
+
+	if registry.Default() != fake {
+		t.Error("Expected the fake gateway as default")
+	}
+	if _, err := registry.Get(GatewayStripe); err == nil {
+		t.Error("Expected an error for an unconfigured gateway")
+	}
+}
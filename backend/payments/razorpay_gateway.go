@@ -0,0 +1,194 @@
+package payments
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ramniya/ramniya-backend/orders"
+	"github.com/ramniya/ramniya-backend/razorpay"
+)
+
+// RazorpayGateway adapts the Razorpay service to Gateway
+type RazorpayGateway struct {
+	service *razorpay.RazorpayService
+	keyID   string
+}
+
+// NewRazorpayGateway creates a Razorpay gateway. keyID is passed to the
+// checkout page.
+func NewRazorpayGateway(service *razorpay.RazorpayService, keyID string) *RazorpayGateway {
+	return &RazorpayGateway{
+		service: service,
+		keyID:   keyID,
+	}
+}
+
+// Name implements Gateway
+func (g *RazorpayGateway) Name() string {
+	return GatewayRazorpay
+}
+
+// CreateOrder implements Gateway
+func (g *RazorpayGateway) CreateOrder(input CreateOrderInput) (*GatewayOrder, error) {
+	order, err := g.service.CreateOrder(razorpay.CreateOrderRequest{
+		Amount:         input.Amount,
+		Currency:       input.Currency,
+		Receipt:        input.Receipt,
+		Notes:          input.Notes,
+		PartialPayment: false,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &GatewayOrder{
+		ID:         order.ID,
+		Amount:     order.Amount,
+		Currency:   order.Currency,
+		Status:     order.Status,
+		ClientData: map[string]string{"key_id": g.keyID},
+	}, nil
+}
+
+// FetchOrder implements Gateway
+func (g *RazorpayGateway) FetchOrder(orderID string) (*GatewayOrder, error) {
+	order, err := g.service.FetchOrder(orderID)
+	if err != nil {
+		return nil, err
+	}
+	return razorpayOrder(order), nil
+}
+
+// VerifyPaymentSignature implements Gateway
+func (g *RazorpayGateway) VerifyPaymentSignature(orderID, paymentID, signature string) bool {
+	return g.service.VerifyPaymentSignature(orderID, paymentID, signature)
+}
+
+// FetchPayment implements Gateway
+func (g *RazorpayGateway) FetchPayment(paymentID string) (*Payment, error) {
+	payment, err := g.service.FetchPayment(paymentID)
+	if err != nil {
+		return nil, err
+	}
+	return razorpayPayment(payment), nil
+}
+
+// FetchOrderPayments implements Gateway
+func (g *RazorpayGateway) FetchOrderPayments(orderID string) ([]Payment, error) {
+	attempts, err := g.service.FetchOrderPayments(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	payments := make([]Payment, len(attempts))
+	for i := range attempts {
+		payments[i] = *razorpayPayment(&attempts[i])
+	}
+	return payments, nil
+}
+
+// Refund implements Gateway
+func (g *RazorpayGateway) Refund(input RefundInput) (*Refund, error) {
+	refund, err := g.service.CreateRefund(input.PaymentID, razorpay.CreateRefundRequest{
+		Amount:  input.Amount,
+		Speed:   "normal",
+		Receipt: input.Receipt,
+		Notes:   input.Notes,
+		// Same key as Stripe, so a resubmitted refund is not made twice
+		IdempotencyKey: "refund_" + input.Receipt,
+	})
+	if err != nil {
+		var apiErr *razorpay.APIError
+		if errors.As(err, &apiErr) {
+			return nil, refundError(err, apiErr.StatusCode)
+		}
+		return nil, err
+	}
+	return razorpayRefund(refund), nil
+}
+
+// VerifyWebhook implements Gateway
+func (g *RazorpayGateway) VerifyWebhook(payload []byte, header http.Header) bool {
+	signature := header.Get("X-Razorpay-Signature")
+	if signature == "" {
+		return false
+	}
+	return g.service.VerifyWebhookSignature(payload, signature)
+}
+
+// ParseWebhook implements Gateway. Razorpay sends the event ID, which is the
+// same on every redelivery, in the X-Razorpay-Event-Id header.
+func (g *RazorpayGateway) ParseWebhook(payload []byte, header http.Header) (*WebhookEvent, error) {
+	parsed, err := razorpay.ParseWebhookPayload(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	event := &WebhookEvent{Type: parsed.Event}
+	if header != nil {
+		event.ID = header.Get("X-Razorpay-Event-Id")
+	}
+
+	switch parsed.Event {
+	case "payment.captured":
+		event.Kind = WebhookPaymentCaptured
+		event.Payment = razorpayPayment(&parsed.Payload.Payment.Entity)
+	case "payment.failed":
+		event.Kind = WebhookPaymentFailed
+		event.Payment = razorpayPayment(&parsed.Payload.Payment.Entity)
+	case "order.paid":
+		event.Kind = WebhookOrderPaid
+		event.Order = razorpayOrder(&parsed.Payload.Order.Entity)
+	case "refund.processed":
+		event.Kind = WebhookRefundProcessed
+		event.Refund = razorpayRefund(&parsed.Payload.Refund.Entity)
+	case "refund.failed":
+		event.Kind = WebhookRefundFailed
+		event.Refund = razorpayRefund(&parsed.Payload.Refund.Entity)
+	}
+
+	return event, nil
+}
+
+// razorpayPayment converts a Razorpay payment; Razorpay's statuses are the
+// ones Payment uses
+func razorpayPayment(payment *razorpay.PaymentEntity) *Payment {
+	return &Payment{
+		ID:               payment.ID,
+		OrderID:          payment.OrderID,
+		Amount:           payment.Amount,
+		Currency:         payment.Currency,
+		Status:           payment.Status,
+		Method:           payment.Method,
+		ErrorDescription: payment.ErrorDescription,
+		CreatedAt:        payment.CreatedAt,
+	}
+}
+
+func razorpayOrder(order *razorpay.OrderEntity) *GatewayOrder {
+	return &GatewayOrder{
+		ID:         order.ID,
+		Amount:     order.Amount,
+		AmountPaid: order.AmountPaid,
+		Currency:   order.Currency,
+		Status:     order.Status,
+	}
+}
+
+func razorpayRefund(refund *razorpay.RefundEntity) *Refund {
+	status := orders.RefundStatusPending
+	switch refund.Status {
+	case "processed":
+		status = orders.RefundStatusProcessed
+	case "failed":
+		status = orders.RefundStatusFailed
+	}
+
+	return &Refund{
+		ID:        refund.ID,
+		PaymentID: refund.PaymentID,
+		Amount:    refund.Amount,
+		Receipt:   refund.Receipt,
+		Status:    status,
+	}
+}
@@ -0,0 +1,243 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ramniya/ramniya-backend/orders"
+	"go.uber.org/zap"
+)
+
+// Discrepancies logged when our records disagree with the payment gateway
+const (
+	DiscrepancyAmountMismatch     = "amount_mismatch"
+	DiscrepancyCapturedButFailed  = "captured_but_failed"
+	DiscrepancyPaidWithoutCapture = "paid_without_capture"
+)
+
+// reconcilerActor is recorded in the status history for reconciled orders
+var reconcilerActor = orders.Actor{Type: orders.ActorSystem, ID: "payment_reconciler"}
+
+// OrderStore is the subset of the order repository used by the reconciler
+type OrderStore interface {
+	ListUnsettledOrders(ctx context.Context, olderThan, maxAge time.Duration, limit int) ([]orders.Order, error)
+	UpdateOrderStatus(ctx context.Context, orderID uuid.UUID, input orders.UpdateOrderStatusInput) (*orders.Order, error)
+}
+
+// ReconcilerConfig controls which orders are reconciled
+type ReconcilerConfig struct {
+	MinAge    time.Duration // Leave recent orders to the checkout flow and webhooks
+	MaxAge    time.Duration // Stop checking orders older than this
+	BatchSize int
+}
+
+// ReconcileResult summarises a reconciliation run
+type ReconcileResult struct {
+	Checked       int
+	Paid          int
+	Failed        int
+	Review        int
+	Discrepancies int
+	Errors        int
+}
+
+// Reconciler settles orders whose payment outcome never reached us, e.g.
+// because the browser closed before verification and the webhook was lost
+type Reconciler struct {
+	store    OrderStore
+	gateways *Registry
+	logger   *zap.Logger
+	config   ReconcilerConfig
+}
+
+// NewReconciler creates a new payment reconciler
+func NewReconciler(store OrderStore, gateways *Registry, logger *zap.Logger, config ReconcilerConfig) *Reconciler {
+	if config.MinAge <= 0 {
+		config.MinAge = 15 * time.Minute
+	}
+	if config.MaxAge <= 0 {
+		config.MaxAge = 7 * 24 * time.Hour
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+
+	return &Reconciler{
+		store:    store,
+		gateways: gateways,
+		logger:   logger,
+		config:   config,
+	}
+}
+
+// Run checks a batch of unsettled orders against their gateway and applies the
+// same status changes the webhook handlers would. A failure on one order is
+// logged and does not stop the batch.
+func (r *Reconciler) Run(ctx context.Context) (*ReconcileResult, error) {
+	unsettled, err := r.store.ListUnsettledOrders(ctx, r.config.MinAge, r.config.MaxAge, r.config.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReconcileResult{}
+	for i := range unsettled {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+
+		order := &unsettled[i]
+		result.Checked++
+
+		if err := r.reconcileOrder(ctx, order, result); err != nil {
+			result.Errors++
+			r.logger.Error("Failed to reconcile order",
+				zap.String("order_id", order.ID.String()),
+				zap.Error(err),
+			)
+		}
+	}
+
+	return result, nil
+}
+
+func (r *Reconciler) reconcileOrder(ctx context.Context, order *orders.Order, result *ReconcileResult) error {
+	gateway, err := r.gateways.Get(order.PaymentGateway)
+	if err != nil {
+		return err
+	}
+
+	gatewayOrderID := *order.RazorpayOrderID
+
+	gatewayOrder, err := gateway.FetchOrder(gatewayOrderID)
+	if err != nil {
+		return err
+	}
+
+	attempts, err := gateway.FetchOrderPayments(gatewayOrderID)
+	if err != nil {
+		return err
+	}
+
+	var captured, lastFailed *Payment
+	allFailed := len(attempts) > 0
+	for i := range attempts {
+		payment := &attempts[i]
+		switch payment.Status {
+		case PaymentStatusCaptured:
+			if captured == nil {
+				captured = payment
+			}
+		case PaymentStatusFailed:
+			if lastFailed == nil || payment.CreatedAt > lastFailed.CreatedAt {
+				lastFailed = payment
+			}
+		}
+		if payment.Status != PaymentStatusFailed {
+			allFailed = false
+		}
+	}
+
+	switch {
+	case captured != nil:
+		if reason := order.PaymentMismatch(captured.Amount, captured.Currency); reason != "" {
+			r.discrepancy(order, DiscrepancyAmountMismatch, result,
+				zap.String("payment_id", captured.ID),
+				zap.Int("gateway_amount", captured.Amount),
+				zap.String("gateway_currency", captured.Currency),
+			)
+			applied, err := r.apply(ctx, order, orders.UpdateOrderStatusInput{
+				Status:              orders.OrderStatusPaymentReview,
+				RazorpayPaymentID:   &captured.ID,
+				ReviewReason:        &reason,
+				CapturedAmountCents: &captured.Amount,
+				CapturedCurrency:    &captured.Currency,
+				Actor:               reconcilerActor,
+				Note:                "Reconciled: " + reason,
+			})
+			if applied {
+				result.Review++
+			}
+			return err
+		}
+		if order.Status == orders.OrderStatusFailed {
+			r.discrepancy(order, DiscrepancyCapturedButFailed, result, zap.String("payment_id", captured.ID))
+		}
+		applied, err := r.apply(ctx, order, orders.UpdateOrderStatusInput{
+			Status:            orders.OrderStatusPaid,
+			RazorpayPaymentID: &captured.ID,
+			Actor:             reconcilerActor,
+			Note:              "Reconciled: payment captured",
+		})
+		if applied {
+			result.Paid++
+		}
+		return err
+
+	case order.PaymentMismatch(gatewayOrder.Amount, gatewayOrder.Currency) != "":
+		// Nothing captured yet, but the gateway order was created for a different amount
+		r.discrepancy(order, DiscrepancyAmountMismatch, result,
+			zap.Int("gateway_amount", gatewayOrder.Amount),
+			zap.String("gateway_currency", gatewayOrder.Currency),
+		)
+		return nil
+
+	case gatewayOrder.Status == GatewayOrderPaid:
+		// The gateway considers the order paid but reported no captured payment
+		r.discrepancy(order, DiscrepancyPaidWithoutCapture, result)
+		return nil
+
+	case allFailed && order.Status != orders.OrderStatusFailed:
+		applied, err := r.apply(ctx, order, orders.UpdateOrderStatusInput{
+			Status:            orders.OrderStatusFailed,
+			RazorpayPaymentID: &lastFailed.ID,
+			Actor:             reconcilerActor,
+			Note:              "Reconciled: " + lastFailed.ErrorDescription,
+		})
+		if applied {
+			result.Failed++
+		}
+		return err
+	}
+
+	// No attempt yet, or a payment is still authorized/in flight
+	return nil
+}
+
+// apply moves the order to a new status, skipping changes the state machine
+// rejects because the order moved on since it was listed
+func (r *Reconciler) apply(ctx context.Context, order *orders.Order, input orders.UpdateOrderStatusInput) (bool, error) {
+	if _, err := r.store.UpdateOrderStatus(ctx, order.ID, input); err != nil {
+		var invalid *orders.InvalidTransitionError
+		if errors.As(err, &invalid) {
+			r.logger.Info("Reconciliation skipped, order already moved on",
+				zap.String("order_id", order.ID.String()),
+				zap.String("from", string(invalid.From)),
+				zap.String("to", string(invalid.To)),
+			)
+			return false, nil
+		}
+		return false, err
+	}
+
+	r.logger.Info("Order reconciled with payment gateway",
+		zap.String("order_id", order.ID.String()),
+		zap.String("from", string(order.Status)),
+		zap.String("to", string(input.Status)),
+	)
+	return true, nil
+}
+
+func (r *Reconciler) discrepancy(order *orders.Order, kind string, result *ReconcileResult, fields ...zap.Field) {
+	result.Discrepancies++
+	r.logger.Warn("Payment discrepancy found during reconciliation", append([]zap.Field{
+		zap.String("discrepancy", kind),
+		zap.String("order_id", order.ID.String()),
+		zap.String("gateway", order.PaymentGateway),
+		zap.String("gateway_order_id", *order.RazorpayOrderID),
+		zap.String("status", string(order.Status)),
+		zap.Int("amount_cents", order.AmountCents),
+		zap.String("currency", order.Currency),
+	}, fields...)...)
+}
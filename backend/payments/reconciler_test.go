@@ -0,0 +1,202 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ramniya/ramniya-backend/orders"
+	"github.com/ramniya/ramniya-backend/razorpay"
+	"go.uber.org/zap"
+)
+
+// fakeStore is an in-memory OrderStore that enforces the order state machine
+type fakeStore struct {
+	orders  []orders.Order
+	updates map[uuid.UUID]orders.UpdateOrderStatusInput
+}
+
+func (s *fakeStore) ListUnsettledOrders(ctx context.Context, olderThan, maxAge time.Duration, limit int) ([]orders.Order, error) {
+	return s.orders, nil
+}
+
+func (s *fakeStore) UpdateOrderStatus(ctx context.Context, orderID uuid.UUID, input orders.UpdateOrderStatusInput) (*orders.Order, error) {
+	for i := range s.orders {
+		if s.orders[i].ID != orderID {
+			continue
+		}
+		if !orders.CanTransition(s.orders[i].Status, input.Status) {
+			return nil, &orders.InvalidTransitionError{From: s.orders[i].Status, To: input.Status}
+		}
+		s.updates[orderID] = input
+		return &s.orders[i], nil
+	}
+	return nil, nil
+}
+
+// razorpayStandIn returns gateways with Razorpay backed by standInServer
+func razorpayStandIn(t *testing.T, rzpOrders map[string]razorpay.OrderEntity, payments map[string][]razorpay.PaymentEntity) *Registry {
+	return NewRegistry(NewRazorpayGateway(razorpay.NewRazorpayService(razorpay.RazorpayConfig{
+		KeyID:     "rzp_test_key",
+		KeySecret: "secret",
+		BaseURL:   standInServer(t, rzpOrders, payments),
+	}, zap.NewNop()), "rzp_test_key"))
+}
+
+// standInServer serves canned orders and payments keyed by Razorpay order ID
+// to clients using the rzp_test_key key, and returns its URL
+func standInServer(t *testing.T, rzpOrders map[string]razorpay.OrderEntity, payments map[string][]razorpay.PaymentEntity) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, _, ok := r.BasicAuth(); !ok || user != "rzp_test_key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		path := strings.TrimPrefix(r.URL.Path, "/orders/")
+		if id, ok := strings.CutSuffix(path, "/payments"); ok {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"entity": "collection",
+				"count":  len(payments[id]),
+				"items":  payments[id],
+			})
+			return
+		}
+
+		order, ok := rzpOrders[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(order)
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL
+}
+
+func newOrder(status orders.OrderStatus, rzpOrderID string) orders.Order {
+	return orders.Order{
+		ID:              uuid.New(),
+		Status:          status,
+		PaymentGateway:  GatewayRazorpay,
+		AmountCents:     49900,
+		Currency:        "INR",
+		RazorpayOrderID: &rzpOrderID,
+	}
+}
+
+func TestReconcilerRun(t *testing.T) {
+	captured := newOrder(orders.OrderStatusPending, "order_captured")
+	failed := newOrder(orders.OrderStatusCreated, "order_failed")
+	lateCapture := newOrder(orders.OrderStatusFailed, "order_late")
+	mismatch := newOrder(orders.OrderStatusPending, "order_mismatch")
+	waiting := newOrder(orders.OrderStatusPending, "order_waiting")
+	underpaid := newOrder(orders.OrderStatusPending, "order_underpaid")
+
+	rzpOrders := map[string]razorpay.OrderEntity{
+		"order_captured":  {ID: "order_captured", Amount: 49900, Currency: "INR", Status: "paid"},
+		"order_failed":    {ID: "order_failed", Amount: 49900, Currency: "INR", Status: "attempted"},
+		"order_late":      {ID: "order_late", Amount: 49900, Currency: "INR", Status: "paid"},
+		"order_mismatch":  {ID: "order_mismatch", Amount: 100, Currency: "INR", Status: "paid"},
+		"order_waiting":   {ID: "order_waiting", Amount: 49900, Currency: "INR", Status: "created"},
+		"order_underpaid": {ID: "order_underpaid", Amount: 49900, Currency: "INR", Status: "paid"},
+	}
+	payments := map[string][]razorpay.PaymentEntity{
+		"order_captured": {
+			{ID: "pay_ok", Amount: 49900, Currency: "INR", Status: "captured"},
+			{ID: "pay_declined", Amount: 49900, Currency: "INR", Status: "failed"},
+		},
+		"order_failed": {
+			{ID: "pay_f1", Amount: 49900, Currency: "INR", Status: "failed", CreatedAt: 1},
+			{ID: "pay_f2", Amount: 49900, Currency: "INR", Status: "failed", CreatedAt: 2},
+		},
+		"order_late": {
+			{ID: "pay_late", Amount: 49900, Currency: "INR", Status: "captured"},
+		},
+		"order_underpaid": {
+			{ID: "pay_short", Amount: 100, Currency: "INR", Status: "captured"},
+		},
+	}
+
+	store := &fakeStore{
+		orders:  []orders.Order{captured, failed, lateCapture, mismatch, waiting, underpaid},
+		updates: map[uuid.UUID]orders.UpdateOrderStatusInput{},
+	}
+	reconciler := NewReconciler(store, razorpayStandIn(t, rzpOrders, payments), zap.NewNop(), ReconcilerConfig{})
+
+	result, err := reconciler.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if result.Checked != 6 || result.Paid != 2 || result.Failed != 1 || result.Review != 1 ||
+		result.Discrepancies != 3 || result.Errors != 0 {
+		t.Errorf("Unexpected result: %+v", *result)
+	}
+
+	expectUpdate := func(order orders.Order, status orders.OrderStatus, paymentID string) {
+		t.Helper()
+		update, ok := store.updates[order.ID]
+		if !ok {
+			t.Fatalf("Expected order %s to be updated", *order.RazorpayOrderID)
+		}
+		if update.Status != status || update.RazorpayPaymentID == nil || *update.RazorpayPaymentID != paymentID {
+			t.Errorf("Order %s: got %s/%v, want %s/%s", *order.RazorpayOrderID, update.Status, update.RazorpayPaymentID, status, paymentID)
+		}
+		if update.Actor.Type != orders.ActorSystem {
+			t.Errorf("Expected system actor, got %s", update.Actor.Type)
+		}
+	}
+
+	expectUpdate(captured, orders.OrderStatusPaid, "pay_ok")
+	expectUpdate(failed, orders.OrderStatusFailed, "pay_f2")
+	expectUpdate(lateCapture, orders.OrderStatusPaid, "pay_late")
+	expectUpdate(underpaid, orders.OrderStatusPaymentReview, "pay_short")
+	if reason := store.updates[underpaid.ID].ReviewReason; reason == nil || *reason == "" {
+		t.Error("Expected a review reason for the underpaid order")
+	}
+	// Refunds of the underpaid order are capped by what was captured
+	if update := store.updates[underpaid.ID]; update.CapturedAmountCents == nil || *update.CapturedAmountCents != 100 ||
+		update.CapturedCurrency == nil || *update.CapturedCurrency != "INR" {
+		t.Errorf("Expected the captured 100 INR to be recorded, got %+v", update)
+	}
+
+	for _, order := range []orders.Order{mismatch, waiting} {
+		if _, ok := store.updates[order.ID]; ok {
+			t.Errorf("Order %s should not have been updated", *order.RazorpayOrderID)
+		}
+	}
+}
+
+func TestReconcilerContinuesAfterAPIError(t *testing.T) {
+	missing := newOrder(orders.OrderStatusPending, "order_missing")
+	captured := newOrder(orders.OrderStatusPending, "order_captured")
+
+	rzp := razorpayStandIn(t,
+		map[string]razorpay.OrderEntity{
+			"order_captured": {ID: "order_captured", Amount: 49900, Currency: "INR", Status: "paid"},
+		},
+		map[string][]razorpay.PaymentEntity{
+			"order_captured": {{ID: "pay_ok", Amount: 49900, Currency: "INR", Status: "captured"}},
+		},
+	)
+
+	store := &fakeStore{
+		orders:  []orders.Order{missing, captured},
+		updates: map[uuid.UUID]orders.UpdateOrderStatusInput{},
+	}
+
+	result, err := NewReconciler(store, rzp, zap.NewNop(), ReconcilerConfig{}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if result.Errors != 1 || result.Paid != 1 {
+		t.Errorf("Unexpected result: %+v", *result)
+	}
+}
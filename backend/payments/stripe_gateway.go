@@ -0,0 +1,225 @@
+package payments
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ramniya/ramniya-backend/orders"
+	"github.com/ramniya/ramniya-backend/stripe"
+)
+
+// StripeGateway adapts the Stripe service to Gateway. A Stripe PaymentIntent
+// is both the gateway order and its payment, so the two IDs are the same.
+type StripeGateway struct {
+	service *stripe.StripeService
+}
+
+// NewStripeGateway creates a Stripe gateway
+func NewStripeGateway(service *stripe.StripeService) *StripeGateway {
+	return &StripeGateway{service: service}
+}
+
+// Name implements Gateway
+func (g *StripeGateway) Name() string {
+	return GatewayStripe
+}
+
+// CreateOrder implements Gateway
+func (g *StripeGateway) CreateOrder(input CreateOrderInput) (*GatewayOrder, error) {
+	metadata := map[string]string{"receipt": input.Receipt}
+	for k, v := range input.Notes {
+		metadata[k] = v
+	}
+
+	intent, err := g.service.CreatePaymentIntent(stripe.CreatePaymentIntentRequest{
+		Amount:         input.Amount,
+		Currency:       input.Currency,
+		Metadata:       metadata,
+		IdempotencyKey: "order_" + input.Receipt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	order := stripeOrder(intent)
+	order.ClientData = map[string]string{
+		"client_secret":   intent.ClientSecret,
+		"publishable_key": g.service.PublishableKey(),
+	}
+	return order, nil
+}
+
+// FetchOrder implements Gateway
+func (g *StripeGateway) FetchOrder(orderID string) (*GatewayOrder, error) {
+	intent, err := g.service.FetchPaymentIntent(orderID)
+	if err != nil {
+		return nil, err
+	}
+	return stripeOrder(intent), nil
+}
+
+// VerifyPaymentSignature implements Gateway. Stripe does not sign what
+// Stripe.js reports to the page, so this only checks that the payment is the
+// order's PaymentIntent; FetchPayment confirms it with Stripe.
+func (g *StripeGateway) VerifyPaymentSignature(orderID, paymentID, signature string) bool {
+	return orderID != "" && paymentID == orderID
+}
+
+// FetchPayment implements Gateway
+func (g *StripeGateway) FetchPayment(paymentID string) (*Payment, error) {
+	intent, err := g.service.FetchPaymentIntent(paymentID)
+	if err != nil {
+		return nil, err
+	}
+	return stripePayment(intent), nil
+}
+
+// FetchOrderPayments implements Gateway. Stripe retries payment methods on
+// the same PaymentIntent, so it reports a single attempt with the latest outcome.
+func (g *StripeGateway) FetchOrderPayments(orderID string) ([]Payment, error) {
+	payment, err := g.FetchPayment(orderID)
+	if err != nil {
+		return nil, err
+	}
+	return []Payment{*payment}, nil
+}
+
+// Refund implements Gateway
+func (g *StripeGateway) Refund(input RefundInput) (*Refund, error) {
+	metadata := map[string]string{"refund_id": input.Receipt}
+	for k, v := range input.Notes {
+		metadata[k] = v
+	}
+
+	refund, err := g.service.CreateRefund(stripe.CreateRefundRequest{
+		PaymentIntent:  input.PaymentID,
+		Amount:         input.Amount,
+		Metadata:       metadata,
+		IdempotencyKey: "refund_" + input.Receipt,
+	})
+	if err != nil {
+		var apiErr *stripe.APIError
+		if errors.As(err, &apiErr) {
+			return nil, refundError(err, apiErr.StatusCode)
+		}
+		return nil, err
+	}
+	return stripeRefund(refund), nil
+}
+
+// VerifyWebhook implements Gateway
+func (g *StripeGateway) VerifyWebhook(payload []byte, header http.Header) bool {
+	signature := header.Get("Stripe-Signature")
+	if signature == "" {
+		return false
+	}
+	return g.service.VerifyWebhookSignature(payload, signature)
+}
+
+// ParseWebhook implements Gateway
+func (g *StripeGateway) ParseWebhook(payload []byte, header http.Header) (*WebhookEvent, error) {
+	parsed, err := stripe.ParseEvent(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	event := &WebhookEvent{ID: parsed.ID, Type: parsed.Type}
+
+	switch parsed.Type {
+	case "payment_intent.succeeded", "payment_intent.payment_failed":
+		var intent stripe.PaymentIntent
+		if err := json.Unmarshal(parsed.Data.Object, &intent); err != nil {
+			return nil, fmt.Errorf("failed to parse payment intent: %w", err)
+		}
+		event.Payment = stripePayment(&intent)
+		event.Kind = WebhookPaymentCaptured
+		if parsed.Type == "payment_intent.payment_failed" {
+			event.Kind = WebhookPaymentFailed
+		}
+
+	case "refund.updated", "refund.failed", "charge.refund.updated":
+		var refund stripe.Refund
+		if err := json.Unmarshal(parsed.Data.Object, &refund); err != nil {
+			return nil, fmt.Errorf("failed to parse refund: %w", err)
+		}
+		event.Refund = stripeRefund(&refund)
+		switch event.Refund.Status {
+		case orders.RefundStatusProcessed:
+			event.Kind = WebhookRefundProcessed
+		case orders.RefundStatusFailed:
+			event.Kind = WebhookRefundFailed
+		}
+	}
+
+	return event, nil
+}
+
+func stripeOrder(intent *stripe.PaymentIntent) *GatewayOrder {
+	status := GatewayOrderCreated
+	switch {
+	case intent.Status == "succeeded":
+		status = GatewayOrderPaid
+	case intent.LastPaymentError != nil || intent.Status == "canceled":
+		status = GatewayOrderAttempted
+	}
+
+	return &GatewayOrder{
+		ID:         intent.ID,
+		Amount:     intent.Amount,
+		AmountPaid: intent.AmountReceived,
+		Currency:   strings.ToUpper(intent.Currency),
+		Status:     status,
+	}
+}
+
+func stripePayment(intent *stripe.PaymentIntent) *Payment {
+	status := PaymentStatusCreated
+	switch {
+	case intent.Status == "succeeded":
+		status = PaymentStatusCaptured
+	case intent.Status == "requires_capture":
+		status = PaymentStatusAuthorized
+	case intent.Status == "canceled":
+		status = PaymentStatusFailed
+	case intent.Status == "requires_payment_method" && intent.LastPaymentError != nil:
+		// The last attempt was declined; the customer may still retry
+		status = PaymentStatusFailed
+	}
+
+	payment := &Payment{
+		ID:        intent.ID,
+		OrderID:   intent.ID,
+		Amount:    intent.Amount,
+		Currency:  strings.ToUpper(intent.Currency),
+		Status:    status,
+		CreatedAt: intent.Created,
+	}
+	if len(intent.PaymentMethodTypes) > 0 {
+		payment.Method = intent.PaymentMethodTypes[0]
+	}
+	if intent.LastPaymentError != nil {
+		payment.ErrorDescription = intent.LastPaymentError.Message
+	}
+	return payment
+}
+
+func stripeRefund(refund *stripe.Refund) *Refund {
+	status := orders.RefundStatusPending
+	switch refund.Status {
+	case "succeeded":
+		status = orders.RefundStatusProcessed
+	case "failed", "canceled":
+		status = orders.RefundStatusFailed
+	}
+
+	return &Refund{
+		ID:        refund.ID,
+		PaymentID: refund.PaymentIntent,
+		Amount:    refund.Amount,
+		Receipt:   refund.Metadata["refund_id"],
+		Status:    status,
+	}
+}
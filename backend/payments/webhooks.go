@@ -0,0 +1,378 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ramniya/ramniya-backend/orders"
+	"go.uber.org/zap"
+)
+
+// Outcomes of applying a webhook event
+const (
+	OutcomeSuccess           = "success"
+	OutcomeUnderReview       = "under_review"
+	OutcomeTransitionIgnored = "transition_ignored"
+	OutcomeEventIgnored      = "event_ignored"
+	OutcomeAlreadyHandled    = "already_processed"
+)
+
+// MaxWebhookAttempts is how many times an event is tried before retries stop
+const MaxWebhookAttempts = 8
+
+// WebhookStore is the subset of the order repository used by the webhook processor
+type WebhookStore interface {
+	ClaimWebhookEvent(ctx context.Context, id uuid.UUID, force bool) (bool, error)
+	CompleteWebhookEvent(ctx context.Context, id uuid.UUID, status orders.WebhookEventStatus) error
+	FailWebhookEvent(ctx context.Context, id uuid.UUID, errText string, nextAttempt *time.Time) error
+	ListRetryableWebhookEvents(ctx context.Context, limit int) ([]orders.WebhookEvent, error)
+	GetOrderByGatewayOrderID(ctx context.Context, gateway, gatewayOrderID string) (*orders.Order, error)
+	UpdateOrderStatus(ctx context.Context, orderID uuid.UUID, input orders.UpdateOrderStatusInput) (*orders.Order, error)
+	UpdateRefundStatus(ctx context.Context, update orders.RefundUpdate) (*orders.Refund, error)
+}
+
+// WebhookProcessor applies stored gateway webhook events to orders and
+// refunds. Events are processed when delivered, retried by RetryFailed and
+// replayed on demand by admins.
+type WebhookProcessor struct {
+	store    WebhookStore
+	gateways *Registry
+	logger   *zap.Logger
+}
+
+// NewWebhookProcessor creates a new webhook processor
+func NewWebhookProcessor(store WebhookStore, gateways *Registry, logger *zap.Logger) *WebhookProcessor {
+	return &WebhookProcessor{
+		store:    store,
+		gateways: gateways,
+		logger:   logger,
+	}
+}
+
+// Process claims a stored event, applies it and records the outcome. force
+// re-applies events that already finished (admin replay). Processing errors
+// are recorded on the event and scheduled for retry before being returned.
+func (p *WebhookProcessor) Process(ctx context.Context, event *orders.WebhookEvent, force bool) (string, error) {
+	attempt := event.Attempts + 1
+
+	claimed, err := p.store.ClaimWebhookEvent(ctx, event.ID, force)
+	if err != nil {
+		return "", err
+	}
+	if !claimed {
+		return OutcomeAlreadyHandled, nil
+	}
+
+	outcome, procErr := p.apply(ctx, event)
+	if procErr != nil {
+		var nextAttempt *time.Time
+		if attempt < MaxWebhookAttempts {
+			next := time.Now().Add(retryDelay(attempt))
+			nextAttempt = &next
+		}
+
+		p.logger.Error("Webhook event failed",
+			zap.String("event_id", event.EventID),
+			zap.String("event", event.EventType),
+			zap.Int("attempt", attempt),
+			zap.Bool("will_retry", nextAttempt != nil),
+			zap.Error(procErr),
+		)
+
+		if err := p.store.FailWebhookEvent(ctx, event.ID, procErr.Error(), nextAttempt); err != nil {
+			return "", err
+		}
+		return "", procErr
+	}
+
+	status := orders.WebhookEventProcessed
+	if outcome == OutcomeEventIgnored || outcome == OutcomeTransitionIgnored {
+		status = orders.WebhookEventIgnored
+	}
+	if err := p.store.CompleteWebhookEvent(ctx, event.ID, status); err != nil {
+		return "", err
+	}
+
+	return outcome, nil
+}
+
+// RetryResult summarises a retry run
+type RetryResult struct {
+	Retried   int
+	Succeeded int
+	Failed    int
+}
+
+// RetryFailed re-processes failed events that are due and events abandoned
+// mid-processing
+func (p *WebhookProcessor) RetryFailed(ctx context.Context, limit int) (*RetryResult, error) {
+	events, err := p.store.ListRetryableWebhookEvents(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RetryResult{}
+	for i := range events {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+
+		outcome, err := p.Process(ctx, &events[i], false)
+		if outcome == OutcomeAlreadyHandled {
+			continue
+		}
+		result.Retried++
+		if err != nil {
+			result.Failed++
+			continue
+		}
+		result.Succeeded++
+	}
+
+	return result, nil
+}
+
+// retryDelay backs off exponentially from one minute, capped at six hours
+func retryDelay(attempt int) time.Duration {
+	delay := time.Minute << (attempt - 1)
+	if delay <= 0 || delay > 6*time.Hour {
+		delay = 6 * time.Hour
+	}
+	return delay
+}
+
+func (p *WebhookProcessor) apply(ctx context.Context, event *orders.WebhookEvent) (string, error) {
+	gateway, err := p.gateways.Get(event.Gateway)
+	if err != nil {
+		return "", err
+	}
+
+	parsed, err := gateway.ParseWebhook(event.Payload, nil)
+	if err != nil {
+		return "", err
+	}
+
+	actor := orders.Actor{Type: orders.ActorWebhook, ID: event.EventID}
+
+	switch parsed.Kind {
+	case WebhookPaymentCaptured:
+		return p.handlePaymentCaptured(ctx, event.Gateway, parsed.Payment, actor)
+	case WebhookPaymentFailed:
+		return p.handlePaymentFailed(ctx, event.Gateway, parsed.Payment, actor)
+	case WebhookOrderPaid:
+		return p.handleOrderPaid(ctx, event.Gateway, parsed.Order, actor)
+	case WebhookRefundProcessed, WebhookRefundFailed:
+		return p.handleRefundUpdate(ctx, parsed, actor)
+	default:
+		p.logger.Info("Unhandled webhook event",
+			zap.String("gateway", event.Gateway),
+			zap.String("event", parsed.Type),
+		)
+		return OutcomeEventIgnored, nil
+	}
+}
+
+func (p *WebhookProcessor) handlePaymentCaptured(ctx context.Context, gateway string, payment *Payment, actor orders.Actor) (string, error) {
+	p.logger.Info("Processing payment captured event",
+		zap.String("payment_id", payment.ID),
+		zap.String("order_id", payment.OrderID),
+		zap.Int("amount", payment.Amount),
+	)
+
+	order, err := p.store.GetOrderByGatewayOrderID(ctx, gateway, payment.OrderID)
+	if err != nil {
+		return "", fmt.Errorf("order for gateway order %s: %w", payment.OrderID, err)
+	}
+
+	if order.Status == orders.OrderStatusPaymentReview {
+		return p.alreadyUnderReview(order)
+	}
+
+	if reason := order.PaymentMismatch(payment.Amount, payment.Currency); reason != "" {
+		return p.holdForReview(ctx, order, &payment.ID, payment.Amount, payment.Currency, reason, actor)
+	}
+
+	_, err = p.store.UpdateOrderStatus(ctx, order.ID, orders.UpdateOrderStatusInput{
+		Status:            orders.OrderStatusPaid,
+		RazorpayPaymentID: &payment.ID,
+		Actor:             actor,
+		Note:              "Payment captured",
+	})
+	if err != nil {
+		return p.ignoreInvalidTransition(order, err)
+	}
+
+	p.logger.Info("Order marked as paid via webhook",
+		zap.String("order_id", order.ID.String()),
+		zap.String("payment_id", payment.ID),
+	)
+
+	return OutcomeSuccess, nil
+}
+
+func (p *WebhookProcessor) handlePaymentFailed(ctx context.Context, gateway string, payment *Payment, actor orders.Actor) (string, error) {
+	p.logger.Info("Processing payment failed event",
+		zap.String("payment_id", payment.ID),
+		zap.String("order_id", payment.OrderID),
+		zap.String("error", payment.ErrorDescription),
+	)
+
+	order, err := p.store.GetOrderByGatewayOrderID(ctx, gateway, payment.OrderID)
+	if err != nil {
+		return "", fmt.Errorf("order for gateway order %s: %w", payment.OrderID, err)
+	}
+
+	_, err = p.store.UpdateOrderStatus(ctx, order.ID, orders.UpdateOrderStatusInput{
+		Status:            orders.OrderStatusFailed,
+		RazorpayPaymentID: &payment.ID,
+		Actor:             actor,
+		Note:              payment.ErrorDescription,
+	})
+	if err != nil {
+		return p.ignoreInvalidTransition(order, err)
+	}
+
+	p.logger.Info("Order marked as failed via webhook",
+		zap.String("order_id", order.ID.String()),
+		zap.String("payment_id", payment.ID),
+	)
+
+	return OutcomeSuccess, nil
+}
+
+func (p *WebhookProcessor) handleOrderPaid(ctx context.Context, gateway string, gatewayOrder *GatewayOrder, actor orders.Actor) (string, error) {
+	p.logger.Info("Processing order paid event",
+		zap.String("gateway_order_id", gatewayOrder.ID),
+		zap.Int("amount_paid", gatewayOrder.AmountPaid),
+	)
+
+	order, err := p.store.GetOrderByGatewayOrderID(ctx, gateway, gatewayOrder.ID)
+	if err != nil {
+		return "", fmt.Errorf("order for gateway order %s: %w", gatewayOrder.ID, err)
+	}
+
+	if order.Status == orders.OrderStatusPaymentReview {
+		return p.alreadyUnderReview(order)
+	}
+
+	if reason := order.PaymentMismatch(gatewayOrder.AmountPaid, gatewayOrder.Currency); reason != "" {
+		return p.holdForReview(ctx, order, nil, gatewayOrder.AmountPaid, gatewayOrder.Currency, reason, actor)
+	}
+
+	// Only update if not already paid
+	if order.Status == orders.OrderStatusPaid {
+		return OutcomeSuccess, nil
+	}
+
+	_, err = p.store.UpdateOrderStatus(ctx, order.ID, orders.UpdateOrderStatusInput{
+		Status: orders.OrderStatusPaid,
+		Actor:  actor,
+		Note:   "Gateway order paid",
+	})
+	if err != nil {
+		return p.ignoreInvalidTransition(order, err)
+	}
+
+	p.logger.Info("Order marked as paid",
+		zap.String("order_id", order.ID.String()),
+	)
+
+	return OutcomeSuccess, nil
+}
+
+// holdForReview moves an order whose captured payment does not match it to
+// payment_review instead of marking it paid, so an admin can approve or refund
+// it. The captured amount is recorded to cap refunds.
+func (p *WebhookProcessor) holdForReview(ctx context.Context, order *orders.Order, paymentID *string, capturedCents int, capturedCurrency string, reason string, actor orders.Actor) (string, error) {
+	p.logger.Warn("Payment does not match order, holding for review",
+		zap.String("order_id", order.ID.String()),
+		zap.Int("amount_cents", order.AmountCents),
+		zap.String("currency", order.Currency),
+		zap.String("reason", reason),
+	)
+
+	_, err := p.store.UpdateOrderStatus(ctx, order.ID, orders.UpdateOrderStatusInput{
+		Status:              orders.OrderStatusPaymentReview,
+		RazorpayPaymentID:   paymentID,
+		ReviewReason:        &reason,
+		CapturedAmountCents: &capturedCents,
+		CapturedCurrency:    &capturedCurrency,
+		Actor:               actor,
+		Note:                reason,
+	})
+	if err != nil {
+		return p.ignoreInvalidTransition(order, err)
+	}
+
+	return OutcomeUnderReview, nil
+}
+
+// alreadyUnderReview acknowledges payment events for orders an admin has yet
+// to review; they must not mark the order paid on their own
+func (p *WebhookProcessor) alreadyUnderReview(order *orders.Order) (string, error) {
+	p.logger.Info("Payment event ignored, order under review",
+		zap.String("order_id", order.ID.String()),
+	)
+	return OutcomeUnderReview, nil
+}
+
+// ignoreInvalidTransition treats status changes that are no longer allowed
+// (e.g. a failed attempt reported after the order was paid) as handled, so
+// they are not retried. Other errors are returned.
+func (p *WebhookProcessor) ignoreInvalidTransition(order *orders.Order, err error) (string, error) {
+	var invalid *orders.InvalidTransitionError
+	if !errors.As(err, &invalid) {
+		return "", err
+	}
+
+	p.logger.Warn("Webhook status change ignored",
+		zap.String("order_id", order.ID.String()),
+		zap.String("from", string(invalid.From)),
+		zap.String("to", string(invalid.To)),
+	)
+
+	return OutcomeTransitionIgnored, nil
+}
+
+func (p *WebhookProcessor) handleRefundUpdate(ctx context.Context, event *WebhookEvent, actor orders.Actor) (string, error) {
+	gatewayRefund := event.Refund
+
+	p.logger.Info("Processing refund event",
+		zap.String("event", event.Type),
+		zap.String("gateway_refund_id", gatewayRefund.ID),
+		zap.String("payment_id", gatewayRefund.PaymentID),
+		zap.Int("amount", gatewayRefund.Amount),
+	)
+
+	// Refunds created by us carry our refund ID as the receipt
+	refundID, _ := uuid.Parse(gatewayRefund.Receipt)
+
+	status := orders.RefundStatusProcessed
+	failureReason := ""
+	if event.Kind == WebhookRefundFailed {
+		status = orders.RefundStatusFailed
+		failureReason = "Refund failed at the payment gateway"
+	}
+
+	refund, err := p.store.UpdateRefundStatus(ctx, orders.RefundUpdate{
+		RefundID:         refundID,
+		RazorpayRefundID: gatewayRefund.ID,
+		Status:           status,
+		FailureReason:    failureReason,
+		Actor:            actor,
+	})
+	if err != nil {
+		return "", fmt.Errorf("refund %s: %w", gatewayRefund.ID, err)
+	}
+
+	p.logger.Info("Refund updated via webhook",
+		zap.String("refund_id", refund.ID.String()),
+		zap.String("order_id", refund.OrderID.String()),
+		zap.String("status", string(refund.Status)),
+	)
+
+	return OutcomeSuccess, nil
+}
@@ -0,0 +1,211 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ramniya/ramniya-backend/orders"
+	"go.uber.org/zap"
+)
+
+// fakeWebhookStore keeps webhook events in memory on top of fakeStore's orders
+type fakeWebhookStore struct {
+	*fakeStore
+	events map[uuid.UUID]*orders.WebhookEvent
+}
+
+func newFakeWebhookStore(ordersList ...orders.Order) *fakeWebhookStore {
+	return &fakeWebhookStore{
+		fakeStore: &fakeStore{
+			orders:  ordersList,
+			updates: map[uuid.UUID]orders.UpdateOrderStatusInput{},
+		},
+		events: map[uuid.UUID]*orders.WebhookEvent{},
+	}
+}
+
+func (s *fakeWebhookStore) add(eventType string, payload map[string]interface{}) *orders.WebhookEvent {
+	payload["event"] = eventType
+	body, _ := json.Marshal(payload)
+	event := &orders.WebhookEvent{
+		ID:        uuid.New(),
+		Gateway:   GatewayRazorpay,
+		EventID:   "evt_" + eventType,
+		EventType: eventType,
+		Payload:   body,
+		Status:    orders.WebhookEventReceived,
+	}
+	s.events[event.ID] = event
+	return event
+}
+
+func (s *fakeWebhookStore) ClaimWebhookEvent(ctx context.Context, id uuid.UUID, force bool) (bool, error) {
+	event := s.events[id]
+	switch event.Status {
+	case orders.WebhookEventReceived, orders.WebhookEventFailed:
+	case orders.WebhookEventProcessed, orders.WebhookEventIgnored:
+		if !force {
+			return false, nil
+		}
+	default:
+		return false, nil
+	}
+	event.Status = orders.WebhookEventProcessing
+	event.Attempts++
+	return true, nil
+}
+
+func (s *fakeWebhookStore) CompleteWebhookEvent(ctx context.Context, id uuid.UUID, status orders.WebhookEventStatus) error {
+	s.events[id].Status = status
+	s.events[id].LastError = nil
+	return nil
+}
+
+func (s *fakeWebhookStore) FailWebhookEvent(ctx context.Context, id uuid.UUID, errText string, nextAttempt *time.Time) error {
+	s.events[id].Status = orders.WebhookEventFailed
+	s.events[id].LastError = &errText
+	s.events[id].NextAttemptAt = nextAttempt
+	return nil
+}
+
+func (s *fakeWebhookStore) ListRetryableWebhookEvents(ctx context.Context, limit int) ([]orders.WebhookEvent, error) {
+	var due []orders.WebhookEvent
+	for _, event := range s.events {
+		if event.Status == orders.WebhookEventFailed && event.NextAttemptAt != nil {
+			due = append(due, *event)
+		}
+	}
+	return due, nil
+}
+
+func (s *fakeWebhookStore) GetOrderByGatewayOrderID(ctx context.Context, gateway, gatewayOrderID string) (*orders.Order, error) {
+	for i := range s.orders {
+		if s.orders[i].PaymentGateway == gateway && *s.orders[i].RazorpayOrderID == gatewayOrderID {
+			return &s.orders[i], nil
+		}
+	}
+	return nil, errors.New("order not found")
+}
+
+func (s *fakeWebhookStore) UpdateRefundStatus(ctx context.Context, update orders.RefundUpdate) (*orders.Refund, error) {
+	return nil, errors.New("refund not found")
+}
+
+func capturedPayload(rzpOrderID string, amount int) map[string]interface{} {
+	return map[string]interface{}{
+		"payload": map[string]interface{}{
+			"payment": map[string]interface{}{
+				"entity": map[string]interface{}{
+					"id":       "pay_" + rzpOrderID,
+					"order_id": rzpOrderID,
+					"amount":   amount,
+					"currency": "INR",
+					"status":   "captured",
+				},
+			},
+		},
+	}
+}
+
+func TestWebhookProcessorProcess(t *testing.T) {
+	order := newOrder(orders.OrderStatusPending, "order_1")
+	store := newFakeWebhookStore(order)
+	processor := NewWebhookProcessor(store, razorpayStandIn(t, nil, nil), zap.NewNop())
+
+	event := store.add("payment.captured", capturedPayload("order_1", 49900))
+	outcome, err := processor.Process(context.Background(), event, false)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if outcome != OutcomeSuccess || event.Status != orders.WebhookEventProcessed {
+		t.Errorf("Got outcome %q and status %s", outcome, event.Status)
+	}
+	update := store.updates[order.ID]
+	if update.Status != orders.OrderStatusPaid || update.Actor.Type != orders.ActorWebhook || update.Actor.ID != event.EventID {
+		t.Errorf("Unexpected order update: %+v", update)
+	}
+
+	// A processed event is not applied twice unless replayed
+	outcome, err = processor.Process(context.Background(), event, false)
+	if err != nil || outcome != OutcomeAlreadyHandled {
+		t.Errorf("Expected already processed, got %q, %v", outcome, err)
+	}
+	if _, err := processor.Process(context.Background(), event, true); err != nil {
+		t.Errorf("Replay failed: %v", err)
+	}
+	if event.Attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", event.Attempts)
+	}
+
+	// Events only settle orders placed with the gateway that sent them
+	stripeOrder := newOrder(orders.OrderStatusPending, "order_stripe")
+	stripeOrder.PaymentGateway = GatewayStripe
+	store.orders = append(store.orders, stripeOrder)
+	forged := store.add("payment.captured", capturedPayload("order_stripe", 49900))
+	if _, err := processor.Process(context.Background(), forged, false); err == nil {
+		t.Error("Expected a Razorpay event for a Stripe order to fail")
+	}
+	if _, updated := store.updates[stripeOrder.ID]; updated {
+		t.Error("Stripe order was updated by a Razorpay event")
+	}
+
+	unknown := store.add("payment.dispute.created", map[string]interface{}{})
+	outcome, err = processor.Process(context.Background(), unknown, false)
+	if err != nil || outcome != OutcomeEventIgnored || unknown.Status != orders.WebhookEventIgnored {
+		t.Errorf("Expected unknown event to be ignored, got %q, %s, %v", outcome, unknown.Status, err)
+	}
+}
+
+func TestWebhookProcessorRetries(t *testing.T) {
+	store := newFakeWebhookStore()
+	processor := NewWebhookProcessor(store, razorpayStandIn(t, nil, nil), zap.NewNop())
+
+	// The order is not known yet, e.g. the webhook raced the checkout commit
+	event := store.add("payment.captured", capturedPayload("order_late", 49900))
+	if _, err := processor.Process(context.Background(), event, false); err == nil {
+		t.Fatal("Expected an error for an unknown order")
+	}
+	if event.Status != orders.WebhookEventFailed || event.LastError == nil || event.NextAttemptAt == nil {
+		t.Fatalf("Expected a scheduled retry, got %+v", *event)
+	}
+
+	order := newOrder(orders.OrderStatusPending, "order_late")
+	store.orders = append(store.orders, order)
+
+	result, err := processor.RetryFailed(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("RetryFailed failed: %v", err)
+	}
+	if result.Retried != 1 || result.Succeeded != 1 {
+		t.Errorf("Unexpected result: %+v", *result)
+	}
+	if event.Status != orders.WebhookEventProcessed || event.Attempts != 2 {
+		t.Errorf("Expected event processed on second attempt, got %s after %d", event.Status, event.Attempts)
+	}
+
+	// Retries stop after the last attempt
+	exhausted := store.add("payment.captured", capturedPayload("order_missing", 49900))
+	exhausted.Attempts = MaxWebhookAttempts - 1
+	if _, err := processor.Process(context.Background(), exhausted, false); err == nil {
+		t.Fatal("Expected an error for an unknown order")
+	}
+	if exhausted.NextAttemptAt != nil {
+		t.Error("Expected no further retries after the last attempt")
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	if d := retryDelay(1); d != time.Minute {
+		t.Errorf("Expected 1m for the first retry, got %s", d)
+	}
+	if d := retryDelay(3); d != 4*time.Minute {
+		t.Errorf("Expected 4m for the third retry, got %s", d)
+	}
+	if d := retryDelay(20); d != 6*time.Hour {
+		t.Errorf("Expected the delay to be capped at 6h, got %s", d)
+	}
+}
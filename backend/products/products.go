@@ -4,18 +4,33 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+var (
+	ErrProductNotFound = errors.New("product not found")
+	ErrVariantNotFound = errors.New("variant not found")
+	ErrVariantMismatch = errors.New("variant does not belong to product")
+	ErrVariantRequired = errors.New("variant is required for this product")
+)
+
 // Product represents a product in the catalog
 type Product struct {
 	ID          uuid.UUID        `json:"id"`
 	Title       string           `json:"title"`
 	Description *string          `json:"description,omitempty"`
 	Price       float64          `json:"price"`
+	HSNCode     *string          `json:"hsn_code,omitempty"`
+	GSTRateBps  *int             `json:"gst_rate_bps,omitempty"`
+	WeightGrams *int             `json:"weight_grams,omitempty"`
+	LengthCm    *int             `json:"length_cm,omitempty"`
+	BreadthCm   *int             `json:"breadth_cm,omitempty"`
+	HeightCm    *int             `json:"height_cm,omitempty"`
 	Metadata    json.RawMessage  `json:"metadata,omitempty"`
 	CreatedAt   time.Time        `json:"created_at"`
 	UpdatedAt   time.Time        `json:"updated_at"`
@@ -51,6 +66,12 @@ type CreateProductInput struct {
 	Title       string               `json:"title"`
 	Description *string              `json:"description,omitempty"`
 	Price       float64              `json:"price"`
+	HSNCode     *string              `json:"hsn_code,omitempty"`
+	GSTRateBps  *int                 `json:"gst_rate_bps,omitempty"` // Basis points, e.g. 500 for 5%
+	WeightGrams *int                 `json:"weight_grams,omitempty"` // Packed weight
+	LengthCm    *int                 `json:"length_cm,omitempty"`    // Packed dimensions
+	BreadthCm   *int                 `json:"breadth_cm,omitempty"`
+	HeightCm    *int                 `json:"height_cm,omitempty"`
 	Metadata    json.RawMessage      `json:"metadata,omitempty"`
 	Variants    []CreateVariantInput `json:"variants,omitempty"`
 }
@@ -68,6 +89,12 @@ type UpdateProductInput struct {
 	Title       *string         `json:"title,omitempty"`
 	Description *string         `json:"description,omitempty"`
 	Price       *float64        `json:"price,omitempty"`
+	HSNCode     *string         `json:"hsn_code,omitempty"`
+	GSTRateBps  *int            `json:"gst_rate_bps,omitempty"`
+	WeightGrams *int            `json:"weight_grams,omitempty"`
+	LengthCm    *int            `json:"length_cm,omitempty"`
+	BreadthCm   *int            `json:"breadth_cm,omitempty"`
+	HeightCm    *int            `json:"height_cm,omitempty"`
 	Metadata    json.RawMessage `json:"metadata,omitempty"`
 }
 
@@ -111,13 +138,17 @@ func (r *ProductRepository) CreateProduct(ctx context.Context, input CreateProdu
 	}
 
 	query := `
-		INSERT INTO products (title, description, price, metadata)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id, title, description, price, metadata, created_at, updated_at
+		INSERT INTO products (title, description, price, hsn_code, gst_rate_bps,
+		                      weight_grams, length_cm, breadth_cm, height_cm, metadata)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, title, description, price, hsn_code, gst_rate_bps,
+		          weight_grams, length_cm, breadth_cm, height_cm, metadata, created_at, updated_at
 	`
 
-	err = tx.QueryRowContext(ctx, query, input.Title, input.Description, input.Price, metadata).
-		Scan(&product.ID, &product.Title, &product.Description, &product.Price, &product.Metadata, &product.CreatedAt, &product.UpdatedAt)
+	err = tx.QueryRowContext(ctx, query, input.Title, input.Description, input.Price, input.HSNCode, input.GSTRateBps,
+		input.WeightGrams, input.LengthCm, input.BreadthCm, input.HeightCm, metadata).
+		Scan(&product.ID, &product.Title, &product.Description, &product.Price, &product.HSNCode, &product.GSTRateBps,
+			&product.WeightGrams, &product.LengthCm, &product.BreadthCm, &product.HeightCm, &product.Metadata, &product.CreatedAt, &product.UpdatedAt)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create product: %w", err)
 	}
@@ -159,15 +190,17 @@ func (r *ProductRepository) GetProduct(ctx context.Context, productID uuid.UUID,
 	var product Product
 
 	query := `
-		SELECT id, title, description, price, metadata, created_at, updated_at
+		SELECT id, title, description, price, hsn_code, gst_rate_bps,
+		       weight_grams, length_cm, breadth_cm, height_cm, metadata, created_at, updated_at
 		FROM products
 		WHERE id = $1
 	`
 
 	err := r.db.QueryRowContext(ctx, query, productID).
-		Scan(&product.ID, &product.Title, &product.Description, &product.Price, &product.Metadata, &product.CreatedAt, &product.UpdatedAt)
+		Scan(&product.ID, &product.Title, &product.Description, &product.Price, &product.HSNCode, &product.GSTRateBps,
+			&product.WeightGrams, &product.LengthCm, &product.BreadthCm, &product.HeightCm, &product.Metadata, &product.CreatedAt, &product.UpdatedAt)
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("product not found")
+		return nil, ErrProductNotFound
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get product: %w", err)
@@ -194,7 +227,8 @@ func (r *ProductRepository) GetProduct(ctx context.Context, productID uuid.UUID,
 func (r *ProductRepository) ListProducts(ctx context.Context, filter ListProductsFilter, baseURL string) ([]Product, int, error) {
 	// Build query with filters
 	query := `
-		SELECT DISTINCT p.id, p.title, p.description, p.price, p.metadata, p.created_at, p.updated_at
+		SELECT DISTINCT p.id, p.title, p.description, p.price, p.hsn_code, p.gst_rate_bps,
+		       p.weight_grams, p.length_cm, p.breadth_cm, p.height_cm, p.metadata, p.created_at, p.updated_at
 		FROM products p
 		LEFT JOIN product_variants pv ON p.id = pv.product_id
 		WHERE 1=1
@@ -274,7 +308,8 @@ func (r *ProductRepository) ListProducts(ctx context.Context, filter ListProduct
 	products := []Product{}
 	for rows.Next() {
 		var p Product
-		err := rows.Scan(&p.ID, &p.Title, &p.Description, &p.Price, &p.Metadata, &p.CreatedAt, &p.UpdatedAt)
+		err := rows.Scan(&p.ID, &p.Title, &p.Description, &p.Price, &p.HSNCode, &p.GSTRateBps,
+			&p.WeightGrams, &p.LengthCm, &p.BreadthCm, &p.HeightCm, &p.Metadata, &p.CreatedAt, &p.UpdatedAt)
 		if err != nil {
 			return nil, 0, fmt.Errorf("failed to scan product: %w", err)
 		}
@@ -301,14 +336,23 @@ func (r *ProductRepository) UpdateProduct(ctx context.Context, productID uuid.UU
 			description = COALESCE($2, description),
 			price = COALESCE($3, price),
 			metadata = COALESCE($4, metadata),
+			hsn_code = COALESCE($6, hsn_code),
+			gst_rate_bps = COALESCE($7, gst_rate_bps),
+			weight_grams = COALESCE($8, weight_grams),
+			length_cm = COALESCE($9, length_cm),
+			breadth_cm = COALESCE($10, breadth_cm),
+			height_cm = COALESCE($11, height_cm),
 			updated_at = NOW()
 		WHERE id = $5
-		RETURNING id, title, description, price, metadata, created_at, updated_at
+		RETURNING id, title, description, price, hsn_code, gst_rate_bps,
+		          weight_grams, length_cm, breadth_cm, height_cm, metadata, created_at, updated_at
 	`
 
 	var product Product
-	err := r.db.QueryRowContext(ctx, query, input.Title, input.Description, input.Price, input.Metadata, productID).
-		Scan(&product.ID, &product.Title, &product.Description, &product.Price, &product.Metadata, &product.CreatedAt, &product.UpdatedAt)
+	err := r.db.QueryRowContext(ctx, query, input.Title, input.Description, input.Price, input.Metadata, productID, input.HSNCode, input.GSTRateBps,
+		input.WeightGrams, input.LengthCm, input.BreadthCm, input.HeightCm).
+		Scan(&product.ID, &product.Title, &product.Description, &product.Price, &product.HSNCode, &product.GSTRateBps,
+			&product.WeightGrams, &product.LengthCm, &product.BreadthCm, &product.HeightCm, &product.Metadata, &product.CreatedAt, &product.UpdatedAt)
 
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("product not found")
@@ -457,3 +501,106 @@ func (r *ProductRepository) DeleteProductImage(ctx context.Context, imageID uuid
 
 	return nil
 }
+
+// PricedItem is an authoritative snapshot of a product/variant pair for checkout
+type PricedItem struct {
+	ProductID      uuid.UUID
+	VariantID      uuid.UUID
+	Title          string
+	SKU            string
+	ImageURL       string
+	UnitPriceCents int
+	Category       string
+	HSNCode        string
+	GSTRateBps     *int // nil uses the default rate
+	WeightGrams    *int // nil uses the shipping default
+	LengthCm       *int
+	BreadthCm      *int
+	HeightCm       *int
+	Stock          int
+	HasVariant     bool
+}
+
+// GetPricedItem resolves a product and optional variant to its current price.
+// Products that have variants require a variant ID that belongs to them.
+func (r *ProductRepository) GetPricedItem(ctx context.Context, productID, variantID uuid.UUID, baseURL string) (*PricedItem, error) {
+	product, err := r.GetProduct(ctx, productID, baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	item := &PricedItem{
+		ProductID:   product.ID,
+		Title:       product.Title,
+		Category:    product.Category(),
+		GSTRateBps:  product.GSTRateBps,
+		WeightGrams: product.WeightGrams,
+		LengthCm:    product.LengthCm,
+		BreadthCm:   product.BreadthCm,
+		HeightCm:    product.HeightCm,
+	}
+	if product.HSNCode != nil {
+		item.HSNCode = *product.HSNCode
+	}
+
+	price := product.Price
+
+	if variantID != uuid.Nil {
+		var variant *ProductVariant
+		for i := range product.Variants {
+			if product.Variants[i].ID == variantID {
+				variant = &product.Variants[i]
+				break
+			}
+		}
+		if variant == nil {
+			var exists bool
+			err := r.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM product_variants WHERE id = $1)", variantID).Scan(&exists)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check variant: %w", err)
+			}
+			if exists {
+				return nil, ErrVariantMismatch
+			}
+			return nil, ErrVariantNotFound
+		}
+
+		item.VariantID = variant.ID
+		item.SKU = variant.SKU
+		item.Stock = variant.Stock
+		item.HasVariant = true
+		price += variant.PriceModifier
+	} else if len(product.Variants) > 0 {
+		return nil, ErrVariantRequired
+	}
+
+	item.UnitPriceCents = PriceToCents(price)
+
+	for _, img := range product.Images {
+		if img.IsPrimary {
+			item.ImageURL = img.URL
+			break
+		}
+	}
+	if item.ImageURL == "" && len(product.Images) > 0 {
+		item.ImageURL = product.Images[0].URL
+	}
+
+	return item, nil
+}
+
+// Category returns the product's category from its metadata, or "" if unset
+func (p *Product) Category() string {
+	var metadata struct {
+		Category string `json:"category"`
+	}
+	if len(p.Metadata) == 0 || json.Unmarshal(p.Metadata, &metadata) != nil {
+		return ""
+	}
+	return metadata.Category
+}
+
+// PriceToCents converts a catalog price in rupees to paise
+func PriceToCents(price float64) int {
+	return int(math.Round(price * 100))
+}
@@ -0,0 +1,339 @@
+package promotions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// DiscountType says how a coupon's value is applied
+type DiscountType string
+
+const (
+	DiscountPercentage DiscountType = "percentage" // Value is percent off
+	DiscountFlat       DiscountType = "flat"       // Value is paise off
+)
+
+var (
+	ErrCouponNotFound  = errors.New("coupon not found")
+	ErrCouponCodeTaken = errors.New("coupon code already exists")
+	ErrCouponRedeemed  = errors.New("coupon has been redeemed")
+)
+
+// codePattern limits codes to what customers can type reliably
+var codePattern = regexp.MustCompile(`^[A-Z0-9_-]{3,32}$`)
+
+// Coupon is a discount code. A coupon with no ProductIDs and no Categories
+// applies to the whole cart; otherwise only matching lines are discounted.
+type Coupon struct {
+	ID               uuid.UUID    `json:"id"`
+	Code             string       `json:"code"`
+	Description      *string      `json:"description,omitempty"`
+	DiscountType     DiscountType `json:"discount_type"`
+	Value            int          `json:"value"`
+	MinCartCents     int          `json:"min_cart_cents"`
+	MaxDiscountCents *int         `json:"max_discount_cents,omitempty"`
+	StartsAt         *time.Time   `json:"starts_at,omitempty"`
+	EndsAt           *time.Time   `json:"ends_at,omitempty"`
+	UsageLimit       *int         `json:"usage_limit,omitempty"`
+	PerUserLimit     *int         `json:"per_user_limit,omitempty"`
+	UsedCount        int          `json:"used_count"`
+	ProductIDs       []uuid.UUID  `json:"product_ids"`
+	Categories       []string     `json:"categories"`
+	Active           bool         `json:"active"`
+	CreatedAt        time.Time    `json:"created_at"`
+	UpdatedAt        time.Time    `json:"updated_at"`
+}
+
+// CouponInput represents input for creating or replacing a coupon
+type CouponInput struct {
+	Code             string       `json:"code"`
+	Description      *string      `json:"description,omitempty"`
+	DiscountType     DiscountType `json:"discount_type"`
+	Value            int          `json:"value"`
+	MinCartCents     int          `json:"min_cart_cents"`
+	MaxDiscountCents *int         `json:"max_discount_cents,omitempty"`
+	StartsAt         *time.Time   `json:"starts_at,omitempty"`
+	EndsAt           *time.Time   `json:"ends_at,omitempty"`
+	UsageLimit       *int         `json:"usage_limit,omitempty"`
+	PerUserLimit     *int         `json:"per_user_limit,omitempty"`
+	ProductIDs       []uuid.UUID  `json:"product_ids,omitempty"`
+	Categories       []string     `json:"categories,omitempty"`
+	Active           *bool        `json:"active,omitempty"` // Defaults to true
+}
+
+// NormalizeCode returns a coupon code in its stored form
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Validate normalizes the input and checks it describes a usable coupon
+func (in *CouponInput) Validate() error {
+	in.Code = NormalizeCode(in.Code)
+	if !codePattern.MatchString(in.Code) {
+		return fmt.Errorf("code must be 3-32 letters, digits, '-' or '_'")
+	}
+
+	switch in.DiscountType {
+	case DiscountPercentage:
+		if in.Value < 1 || in.Value > 100 {
+			return fmt.Errorf("percentage value must be between 1 and 100")
+		}
+	case DiscountFlat:
+		if in.Value <= 0 {
+			return fmt.Errorf("flat value must be greater than 0")
+		}
+	default:
+		return fmt.Errorf("discount_type must be percentage or flat")
+	}
+
+	if in.MinCartCents < 0 {
+		return fmt.Errorf("min_cart_cents must not be negative")
+	}
+	if in.MaxDiscountCents != nil && *in.MaxDiscountCents <= 0 {
+		return fmt.Errorf("max_discount_cents must be greater than 0")
+	}
+	if in.UsageLimit != nil && *in.UsageLimit <= 0 {
+		return fmt.Errorf("usage_limit must be greater than 0")
+	}
+	if in.PerUserLimit != nil && *in.PerUserLimit <= 0 {
+		return fmt.Errorf("per_user_limit must be greater than 0")
+	}
+	if in.StartsAt != nil && in.EndsAt != nil && !in.EndsAt.After(*in.StartsAt) {
+		return fmt.Errorf("ends_at must be after starts_at")
+	}
+
+	categories := make([]string, 0, len(in.Categories))
+	for _, category := range in.Categories {
+		if category = strings.TrimSpace(category); category != "" {
+			categories = append(categories, category)
+		}
+	}
+	in.Categories = categories
+
+	return nil
+}
+
+// ListCouponsFilter represents filters for listing coupons
+type ListCouponsFilter struct {
+	Active *bool
+	Limit  int
+	Offset int
+}
+
+// couponColumns lists the coupon columns in the order scanCoupon expects them
+const couponColumns = `id, code, description, discount_type, value, min_cart_cents, max_discount_cents,
+		       starts_at, ends_at, usage_limit, per_user_limit, used_count, product_ids, categories,
+		       active, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanCoupon scans a row selected with couponColumns. Scan errors such as
+// sql.ErrNoRows are returned unwrapped.
+func scanCoupon(row rowScanner) (*Coupon, error) {
+	var coupon Coupon
+	var productIDs, categories pq.StringArray
+
+	err := row.Scan(
+		&coupon.ID, &coupon.Code, &coupon.Description, &coupon.DiscountType, &coupon.Value,
+		&coupon.MinCartCents, &coupon.MaxDiscountCents, &coupon.StartsAt, &coupon.EndsAt,
+		&coupon.UsageLimit, &coupon.PerUserLimit, &coupon.UsedCount, &productIDs, &categories,
+		&coupon.Active, &coupon.CreatedAt, &coupon.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	coupon.ProductIDs = make([]uuid.UUID, 0, len(productIDs))
+	for _, id := range productIDs {
+		parsed, err := uuid.Parse(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse product ID: %w", err)
+		}
+		coupon.ProductIDs = append(coupon.ProductIDs, parsed)
+	}
+	coupon.Categories = append([]string{}, categories...)
+
+	return &coupon, nil
+}
+
+// couponArgs returns the column values written for a coupon input
+func couponArgs(in CouponInput) []interface{} {
+	productIDs := make(pq.StringArray, len(in.ProductIDs))
+	for i, id := range in.ProductIDs {
+		productIDs[i] = id.String()
+	}
+
+	active := true
+	if in.Active != nil {
+		active = *in.Active
+	}
+
+	return []interface{}{
+		in.Code, in.Description, in.DiscountType, in.Value, in.MinCartCents, in.MaxDiscountCents,
+		in.StartsAt, in.EndsAt, in.UsageLimit, in.PerUserLimit, productIDs, pq.StringArray(in.Categories),
+		active,
+	}
+}
+
+// CouponRepository handles coupon database operations
+type CouponRepository struct {
+	db *sql.DB
+}
+
+// NewCouponRepository creates a new coupon repository
+func NewCouponRepository(db *sql.DB) *CouponRepository {
+	return &CouponRepository{db: db}
+}
+
+// CreateCoupon creates a coupon from a validated input
+func (r *CouponRepository) CreateCoupon(ctx context.Context, input CouponInput) (*Coupon, error) {
+	query := `
+		INSERT INTO coupons (code, description, discount_type, value, min_cart_cents, max_discount_cents,
+		                     starts_at, ends_at, usage_limit, per_user_limit, product_ids, categories, active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11::uuid[], $12, $13)
+		RETURNING ` + couponColumns + `
+	`
+
+	coupon, err := scanCoupon(r.db.QueryRowContext(ctx, query, couponArgs(input)...))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrCouponCodeTaken
+		}
+		return nil, fmt.Errorf("failed to create coupon: %w", err)
+	}
+
+	return coupon, nil
+}
+
+// GetCoupon retrieves a coupon by ID
+func (r *CouponRepository) GetCoupon(ctx context.Context, couponID uuid.UUID) (*Coupon, error) {
+	query := `SELECT ` + couponColumns + ` FROM coupons WHERE id = $1`
+
+	coupon, err := scanCoupon(r.db.QueryRowContext(ctx, query, couponID))
+	if err == sql.ErrNoRows {
+		return nil, ErrCouponNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get coupon: %w", err)
+	}
+
+	return coupon, nil
+}
+
+// GetCouponByCode retrieves a coupon by code, ignoring case
+func (r *CouponRepository) GetCouponByCode(ctx context.Context, code string) (*Coupon, error) {
+	query := `SELECT ` + couponColumns + ` FROM coupons WHERE code = $1`
+
+	coupon, err := scanCoupon(r.db.QueryRowContext(ctx, query, NormalizeCode(code)))
+	if err == sql.ErrNoRows {
+		return nil, ErrCouponNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get coupon: %w", err)
+	}
+
+	return coupon, nil
+}
+
+// ListCoupons retrieves coupons, newest first
+func (r *CouponRepository) ListCoupons(ctx context.Context, filter ListCouponsFilter) ([]Coupon, int, error) {
+	where := " WHERE 1=1"
+	args := []interface{}{}
+	if filter.Active != nil {
+		where += " AND active = $1"
+		args = append(args, *filter.Active)
+	}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM coupons"+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count coupons: %w", err)
+	}
+
+	limit := 20
+	if filter.Limit > 0 && filter.Limit <= 100 {
+		limit = filter.Limit
+	}
+	query := `SELECT ` + couponColumns + ` FROM coupons` + where +
+		fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	args = append(args, limit, filter.Offset)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list coupons: %w", err)
+	}
+	defer rows.Close()
+
+	coupons := []Coupon{}
+	for rows.Next() {
+		coupon, err := scanCoupon(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan coupon: %w", err)
+		}
+		coupons = append(coupons, *coupon)
+	}
+
+	return coupons, total, rows.Err()
+}
+
+// UpdateCoupon replaces a coupon's settings with a validated input. The
+// usage count is kept.
+func (r *CouponRepository) UpdateCoupon(ctx context.Context, couponID uuid.UUID, input CouponInput) (*Coupon, error) {
+	query := `
+		UPDATE coupons
+		SET code = $1, description = $2, discount_type = $3, value = $4, min_cart_cents = $5,
+		    max_discount_cents = $6, starts_at = $7, ends_at = $8, usage_limit = $9,
+		    per_user_limit = $10, product_ids = $11::uuid[], categories = $12, active = $13
+		WHERE id = $14
+		RETURNING ` + couponColumns + `
+	`
+
+	coupon, err := scanCoupon(r.db.QueryRowContext(ctx, query, append(couponArgs(input), couponID)...))
+	if err == sql.ErrNoRows {
+		return nil, ErrCouponNotFound
+	}
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrCouponCodeTaken
+		}
+		return nil, fmt.Errorf("failed to update coupon: %w", err)
+	}
+
+	return coupon, nil
+}
+
+// DeleteCoupon deletes a coupon that was never redeemed. Redeemed coupons
+// return ErrCouponRedeemed and should be deactivated instead.
+func (r *CouponRepository) DeleteCoupon(ctx context.Context, couponID uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM coupons
+		WHERE id = $1
+		  AND NOT EXISTS (SELECT 1 FROM coupon_redemptions WHERE coupon_id = $1)
+	`, couponID)
+	if err != nil {
+		return fmt.Errorf("failed to delete coupon: %w", err)
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		if _, err := r.GetCoupon(ctx, couponID); err != nil {
+			return err
+		}
+		return ErrCouponRedeemed
+	}
+
+	return nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -0,0 +1,319 @@
+package promotions
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CouponError is returned when a coupon cannot be applied. Message is meant
+// for customers.
+type CouponError struct {
+	Reason  string
+	Message string
+}
+
+func (e *CouponError) Error() string {
+	return "coupon rejected: " + e.Reason
+}
+
+// Is matches coupon errors by reason, so errors.Is works for errors that
+// carry a more specific message
+func (e *CouponError) Is(target error) bool {
+	t, ok := target.(*CouponError)
+	return ok && t.Reason == e.Reason
+}
+
+var (
+	ErrCouponInvalid       = &CouponError{Reason: "invalid", Message: "Invalid coupon code"}
+	ErrCouponNotStarted    = &CouponError{Reason: "not_started", Message: "This coupon is not active yet"}
+	ErrCouponExpired       = &CouponError{Reason: "expired", Message: "This coupon has expired"}
+	ErrCouponMinCart       = &CouponError{Reason: "min_cart_value", Message: "Your cart does not meet this coupon's minimum value"}
+	ErrCouponNotApplicable = &CouponError{Reason: "not_applicable", Message: "This coupon does not apply to any item in your cart"}
+	ErrCouponUsageLimit    = &CouponError{Reason: "usage_limit", Message: "This coupon has been fully redeemed"}
+	ErrCouponUserLimit     = &CouponError{Reason: "user_limit", Message: "You have already used this coupon"}
+)
+
+// Line is a priced cart line a coupon may discount
+type Line struct {
+	ProductID  uuid.UUID
+	VariantID  uuid.UUID
+	Category   string
+	TotalCents int
+}
+
+// LineDiscount is the share of a discount taken off one cart line. Line is
+// the line's index in the cart.
+type LineDiscount struct {
+	Line          int       `json:"line"`
+	ProductID     uuid.UUID `json:"product_id"`
+	VariantID     uuid.UUID `json:"variant_id,omitempty"`
+	DiscountCents int       `json:"discount_cents"`
+}
+
+// Discount is a coupon applied to a cart, as stored on the order
+type Discount struct {
+	CouponID      uuid.UUID      `json:"coupon_id"`
+	Code          string         `json:"code"`
+	DiscountType  DiscountType   `json:"discount_type"`
+	Value         int            `json:"value"`
+	SubtotalCents int            `json:"subtotal_cents"`
+	EligibleCents int            `json:"eligible_cents"`
+	DiscountCents int            `json:"discount_cents"`
+	Lines         []LineDiscount `json:"lines"`
+}
+
+// appliesTo reports whether a line is eligible for the coupon
+func (c *Coupon) appliesTo(line Line) bool {
+	if len(c.ProductIDs) == 0 && len(c.Categories) == 0 {
+		return true
+	}
+	for _, id := range c.ProductIDs {
+		if id == line.ProductID {
+			return true
+		}
+	}
+	for _, category := range c.Categories {
+		if line.Category != "" && strings.EqualFold(category, line.Category) {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply works out the discount the coupon gives a cart at the given time and
+// splits it across the eligible lines in proportion to their totals. Usage
+// limits are checked again when the coupon is redeemed.
+func (c *Coupon) Apply(lines []Line, now time.Time) (*Discount, error) {
+	if !c.Active {
+		return nil, ErrCouponInvalid
+	}
+	if c.StartsAt != nil && now.Before(*c.StartsAt) {
+		return nil, ErrCouponNotStarted
+	}
+	if c.EndsAt != nil && !now.Before(*c.EndsAt) {
+		return nil, ErrCouponExpired
+	}
+	if c.UsageLimit != nil && c.UsedCount >= *c.UsageLimit {
+		return nil, ErrCouponUsageLimit
+	}
+
+	discount := &Discount{
+		CouponID:     c.ID,
+		Code:         c.Code,
+		DiscountType: c.DiscountType,
+		Value:        c.Value,
+		Lines:        []LineDiscount{},
+	}
+
+	var eligible []int
+	for i, line := range lines {
+		discount.SubtotalCents += line.TotalCents
+		if line.TotalCents > 0 && c.appliesTo(line) {
+			discount.EligibleCents += line.TotalCents
+			eligible = append(eligible, i)
+		}
+	}
+
+	if discount.SubtotalCents < c.MinCartCents {
+		return nil, &CouponError{
+			Reason:  ErrCouponMinCart.Reason,
+			Message: fmt.Sprintf("Add items worth ₹%.2f more to use this coupon", float64(c.MinCartCents-discount.SubtotalCents)/100),
+		}
+	}
+	if discount.EligibleCents == 0 {
+		return nil, ErrCouponNotApplicable
+	}
+
+	amount := c.Value
+	if c.DiscountType == DiscountPercentage {
+		amount = discount.EligibleCents * c.Value / 100
+	}
+	if c.MaxDiscountCents != nil && amount > *c.MaxDiscountCents {
+		amount = *c.MaxDiscountCents
+	}
+	if amount > discount.EligibleCents {
+		amount = discount.EligibleCents
+	}
+	discount.DiscountCents = amount
+
+	// Split in proportion to line totals, then hand out the rounding remainder
+	// a paisa at a time to lines that still have room
+	shares := make([]int, len(eligible))
+	remaining := amount
+	for j, i := range eligible {
+		shares[j] = amount * lines[i].TotalCents / discount.EligibleCents
+		remaining -= shares[j]
+	}
+	for j := 0; remaining > 0; j = (j + 1) % len(eligible) {
+		if shares[j] < lines[eligible[j]].TotalCents {
+			shares[j]++
+			remaining--
+		}
+	}
+
+	for j, i := range eligible {
+		if shares[j] == 0 {
+			continue
+		}
+		discount.Lines = append(discount.Lines, LineDiscount{
+			Line:          i,
+			ProductID:     lines[i].ProductID,
+			VariantID:     lines[i].VariantID,
+			DiscountCents: shares[j],
+		})
+	}
+
+	return discount, nil
+}
+
+// Redemption records a coupon used by an order
+type Redemption struct {
+	CouponID      uuid.UUID
+	UserID        uuid.UUID
+	OrderID       uuid.UUID
+	DiscountCents int
+}
+
+// Redeem counts a coupon use inside the transaction that creates the order.
+// The coupon row is locked so concurrent checkouts cannot exceed the global
+// or per-user limits.
+func Redeem(ctx context.Context, tx *sql.Tx, redemption Redemption) error {
+	if err := lockCoupon(ctx, tx, redemption.CouponID, redemption.UserID, true); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO coupon_redemptions (coupon_id, user_id, order_id, discount_cents)
+		VALUES ($1, $2, $3, $4)
+	`, redemption.CouponID, redemption.UserID, redemption.OrderID, redemption.DiscountCents); err != nil {
+		return fmt.Errorf("failed to record coupon redemption: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE coupons SET used_count = used_count + 1 WHERE id = $1",
+		redemption.CouponID,
+	); err != nil {
+		return fmt.Errorf("failed to count coupon redemption: %w", err)
+	}
+
+	return nil
+}
+
+// Release gives back the coupon use of a failed or cancelled order. Orders
+// without a coupon, or already released, are left alone.
+func Release(ctx context.Context, tx *sql.Tx, orderID uuid.UUID) error {
+	var couponID uuid.UUID
+	err := tx.QueryRowContext(ctx, `
+		UPDATE coupon_redemptions
+		SET released_at = NOW()
+		WHERE order_id = $1 AND released_at IS NULL
+		RETURNING coupon_id
+	`, orderID).Scan(&couponID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to release coupon redemption: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE coupons SET used_count = GREATEST(used_count - 1, 0) WHERE id = $1",
+		couponID,
+	); err != nil {
+		return fmt.Errorf("failed to release coupon use: %w", err)
+	}
+
+	return nil
+}
+
+// Reinstate takes back the coupon use a failed or cancelled order gave up
+// when the order is revived. With enforceLimits the coupon must still be
+// active and within its global and per-user limits. Orders without a
+// released redemption are left alone.
+func Reinstate(ctx context.Context, tx *sql.Tx, orderID uuid.UUID, enforceLimits bool) error {
+	var couponID, userID uuid.UUID
+	err := tx.QueryRowContext(ctx, `
+		SELECT coupon_id, user_id
+		FROM coupon_redemptions
+		WHERE order_id = $1 AND released_at IS NOT NULL
+	`, orderID).Scan(&couponID, &userID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get coupon redemption: %w", err)
+	}
+
+	if err := lockCoupon(ctx, tx, couponID, userID, enforceLimits); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE coupon_redemptions SET released_at = NULL WHERE order_id = $1",
+		orderID,
+	); err != nil {
+		return fmt.Errorf("failed to reinstate coupon redemption: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE coupons SET used_count = used_count + 1 WHERE id = $1",
+		couponID,
+	); err != nil {
+		return fmt.Errorf("failed to count coupon redemption: %w", err)
+	}
+
+	return nil
+}
+
+// lockCoupon locks the coupon row for a new use by userID and, with
+// enforceLimits, checks it is active and within its limits
+func lockCoupon(ctx context.Context, tx *sql.Tx, couponID, userID uuid.UUID, enforceLimits bool) error {
+	var active bool
+	var usageLimit, perUserLimit *int
+	var usedCount int
+	err := tx.QueryRowContext(ctx, `
+		SELECT active, usage_limit, per_user_limit, used_count
+		FROM coupons
+		WHERE id = $1
+		FOR UPDATE
+	`, couponID).Scan(&active, &usageLimit, &perUserLimit, &usedCount)
+	if err == sql.ErrNoRows {
+		return ErrCouponInvalid
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock coupon: %w", err)
+	}
+
+	if !enforceLimits {
+		return nil
+	}
+
+	if !active {
+		return ErrCouponInvalid
+	}
+	if usageLimit != nil && usedCount >= *usageLimit {
+		return ErrCouponUsageLimit
+	}
+
+	if perUserLimit != nil {
+		var used int
+		err := tx.QueryRowContext(ctx, `
+			SELECT COUNT(*)
+			FROM coupon_redemptions
+			WHERE coupon_id = $1 AND user_id = $2 AND released_at IS NULL
+		`, couponID, userID).Scan(&used)
+		if err != nil {
+			return fmt.Errorf("failed to count coupon redemptions: %w", err)
+		}
+		if used >= *perUserLimit {
+			return ErrCouponUserLimit
+		}
+	}
+
+	return nil
+}
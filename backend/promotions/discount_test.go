@@ -0,0 +1,146 @@
+package promotions
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func intPtr(n int) *int { return &n }
+
+func TestCouponApply(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	saree, dupatta := uuid.New(), uuid.New()
+	lines := []Line{
+		{ProductID: saree, Category: "Sarees", TotalCents: 300000},
+		{ProductID: dupatta, Category: "Dupattas", TotalCents: 100000},
+	}
+
+	tests := []struct {
+		name     string
+		coupon   Coupon
+		discount int
+		perLine  []int
+		err      error
+	}{
+		{
+			name:     "percentage of the whole cart",
+			coupon:   Coupon{DiscountType: DiscountPercentage, Value: 10},
+			discount: 40000,
+			perLine:  []int{30000, 10000},
+		},
+		{
+			name:     "percentage capped",
+			coupon:   Coupon{DiscountType: DiscountPercentage, Value: 50, MaxDiscountCents: intPtr(50000)},
+			discount: 50000,
+			perLine:  []int{37500, 12500},
+		},
+		{
+			name:     "flat restricted to a category",
+			coupon:   Coupon{DiscountType: DiscountFlat, Value: 20000, Categories: []string{"dupattas"}},
+			discount: 20000,
+			perLine:  []int{20000},
+		},
+		{
+			name:     "flat larger than the eligible lines",
+			coupon:   Coupon{DiscountType: DiscountFlat, Value: 500000, ProductIDs: []uuid.UUID{dupatta}},
+			discount: 100000,
+			perLine:  []int{100000},
+		},
+		{
+			name:     "remainder spread across lines",
+			coupon:   Coupon{DiscountType: DiscountFlat, Value: 1001},
+			discount: 1001,
+			perLine:  []int{751, 250},
+		},
+		{
+			name:   "minimum cart value",
+			coupon: Coupon{DiscountType: DiscountFlat, Value: 100, MinCartCents: 500000},
+			err:    ErrCouponMinCart,
+		},
+		{
+			name:   "no eligible lines",
+			coupon: Coupon{DiscountType: DiscountFlat, Value: 100, Categories: []string{"Jewellery"}},
+			err:    ErrCouponNotApplicable,
+		},
+		{
+			name:   "not started",
+			coupon: Coupon{DiscountType: DiscountFlat, Value: 100, StartsAt: timePtr(now.Add(time.Hour))},
+			err:    ErrCouponNotStarted,
+		},
+		{
+			name:   "expired",
+			coupon: Coupon{DiscountType: DiscountFlat, Value: 100, EndsAt: timePtr(now)},
+			err:    ErrCouponExpired,
+		},
+		{
+			name:   "fully redeemed",
+			coupon: Coupon{DiscountType: DiscountFlat, Value: 100, UsageLimit: intPtr(5), UsedCount: 5},
+			err:    ErrCouponUsageLimit,
+		},
+	}
+
+	for _, tt := range tests {
+		tt.coupon.Active = true
+		discount, err := tt.coupon.Apply(lines, now)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("%s: expected %v, got %v", tt.name, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: Apply failed: %v", tt.name, err)
+		}
+
+		if discount.DiscountCents != tt.discount || discount.SubtotalCents != 400000 {
+			t.Errorf("%s: got discount %d of %d, want %d", tt.name, discount.DiscountCents, discount.SubtotalCents, tt.discount)
+		}
+		if len(discount.Lines) != len(tt.perLine) {
+			t.Fatalf("%s: got %d discounted lines, want %d", tt.name, len(discount.Lines), len(tt.perLine))
+		}
+		sum := 0
+		for i, line := range discount.Lines {
+			if line.DiscountCents != tt.perLine[i] {
+				t.Errorf("%s: line %d discounted %d, want %d", tt.name, line.Line, line.DiscountCents, tt.perLine[i])
+			}
+			sum += line.DiscountCents
+		}
+		if sum != discount.DiscountCents {
+			t.Errorf("%s: line discounts add up to %d, not %d", tt.name, sum, discount.DiscountCents)
+		}
+	}
+
+	inactive := Coupon{DiscountType: DiscountFlat, Value: 100}
+	if _, err := inactive.Apply(lines, now); !errors.Is(err, ErrCouponInvalid) {
+		t.Errorf("Expected inactive coupon to be invalid, got %v", err)
+	}
+}
+
+func TestCouponInputValidate(t *testing.T) {
+	input := CouponInput{Code: " diwali25 ", DiscountType: DiscountPercentage, Value: 25, Categories: []string{" Sarees ", ""}}
+	if err := input.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if input.Code != "DIWALI25" || len(input.Categories) != 1 || input.Categories[0] != "Sarees" {
+		t.Errorf("Input not normalized: %+v", input)
+	}
+
+	invalid := []CouponInput{
+		{Code: "X", DiscountType: DiscountFlat, Value: 100},
+		{Code: "BAD CODE", DiscountType: DiscountFlat, Value: 100},
+		{Code: "SALE", DiscountType: DiscountPercentage, Value: 101},
+		{Code: "SALE", DiscountType: DiscountFlat, Value: 0},
+		{Code: "SALE", DiscountType: "bogo", Value: 1},
+		{Code: "SALE", DiscountType: DiscountFlat, Value: 100, UsageLimit: intPtr(0)},
+	}
+	for _, in := range invalid {
+		if err := in.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", in)
+		}
+	}
+}
+
+func timePtr(t time.Time) *time.Time { return &t }
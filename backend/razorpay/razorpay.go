@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
@@ -22,6 +23,7 @@ const (
 type RazorpayConfig struct {
 	KeyID     string
 	KeySecret string
+	BaseURL   string // Defaults to RazorpayAPIURL; overridden in tests
 }
 
 // RazorpayService handles Razorpay API interactions
@@ -33,6 +35,11 @@ type RazorpayService struct {
 
 // NewRazorpayService creates a new Razorpay service
 func NewRazorpayService(config RazorpayConfig, logger *zap.Logger) *RazorpayService {
+	if config.BaseURL == "" {
+		config.BaseURL = RazorpayAPIURL
+	}
+	config.BaseURL = strings.TrimRight(config.BaseURL, "/")
+
 	return &RazorpayService{
 		config: config,
 		httpClient: &http.Client{
@@ -68,57 +75,163 @@ type CreateOrderResponse struct {
 
 // CreateOrder creates a new Razorpay order
 func (s *RazorpayService) CreateOrder(req CreateOrderRequest) (*CreateOrderResponse, error) {
-	// Prepare request body
-	body, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	var orderResp CreateOrderResponse
+	if err := s.doRequest("POST", "/orders", req, "", &orderResp); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Razorpay order created",
+		zap.String("order_id", orderResp.ID),
+		zap.Int("amount", orderResp.Amount),
+		zap.String("currency", orderResp.Currency),
+	)
+
+	return &orderResp, nil
+}
+
+// CreateRefundRequest represents the request to refund a payment
+type CreateRefundRequest struct {
+	Amount  int               `json:"amount,omitempty"`  // Amount in paise; omitted for a full refund
+	Speed   string            `json:"speed,omitempty"`   // normal or optimum
+	Receipt string            `json:"receipt,omitempty"` // Our refund ID, echoed back in webhooks
+	Notes   map[string]string `json:"notes,omitempty"`
+	// IdempotencyKey makes retries return the refund already created
+	IdempotencyKey string `json:"-"`
+}
+
+// RefundEntity represents a Razorpay refund
+type RefundEntity struct {
+	ID             string            `json:"id"`
+	Entity         string            `json:"entity"`
+	Amount         int               `json:"amount"`
+	Currency       string            `json:"currency"`
+	PaymentID      string            `json:"payment_id"`
+	Receipt        string            `json:"receipt,omitempty"`
+	Notes          map[string]string `json:"notes"`
+	Status         string            `json:"status"` // pending, processed, failed
+	SpeedRequested string            `json:"speed_requested,omitempty"`
+	SpeedProcessed string            `json:"speed_processed,omitempty"`
+	CreatedAt      int64             `json:"created_at"`
+}
+
+// CreateRefund refunds a captured payment, fully or partially
+func (s *RazorpayService) CreateRefund(paymentID string, req CreateRefundRequest) (*RefundEntity, error) {
+	var refund RefundEntity
+	if err := s.doRequest("POST", fmt.Sprintf("/payments/%s/refund", paymentID), req, req.IdempotencyKey, &refund); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Razorpay refund created",
+		zap.String("refund_id", refund.ID),
+		zap.String("payment_id", paymentID),
+		zap.Int("amount", refund.Amount),
+		zap.String("status", refund.Status),
+	)
+
+	return &refund, nil
+}
+
+// FetchOrder retrieves a Razorpay order
+func (s *RazorpayService) FetchOrder(orderID string) (*OrderEntity, error) {
+	var order OrderEntity
+	if err := s.doRequest("GET", "/orders/"+orderID, nil, "", &order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// FetchPayment retrieves a Razorpay payment
+func (s *RazorpayService) FetchPayment(paymentID string) (*PaymentEntity, error) {
+	var payment PaymentEntity
+	if err := s.doRequest("GET", "/payments/"+paymentID, nil, "", &payment); err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// paymentCollection is the list envelope returned by Razorpay
+type paymentCollection struct {
+	Entity string          `json:"entity"`
+	Count  int             `json:"count"`
+	Items  []PaymentEntity `json:"items"`
+}
+
+// FetchOrderPayments retrieves every payment attempt made against a Razorpay order
+func (s *RazorpayService) FetchOrderPayments(orderID string) ([]PaymentEntity, error) {
+	var collection paymentCollection
+	if err := s.doRequest("GET", "/orders/"+orderID+"/payments", nil, "", &collection); err != nil {
+		return nil, err
+	}
+	return collection.Items, nil
+}
+
+// APIError is a non-success response from the Razorpay API
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("razorpay API error: status %d, response: %s", e.StatusCode, e.Body)
+}
+
+// doRequest sends an authenticated request to the Razorpay API and decodes
+// the JSON response into out. reqBody may be nil.
+func (s *RazorpayService) doRequest(method, path string, reqBody interface{}, idempotencyKey string, out interface{}) error {
+	var bodyReader io.Reader
+	if reqBody != nil {
+		body, err := json.Marshal(reqBody)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		bodyReader = bytes.NewBuffer(body)
 	}
 
 	// Create HTTP request
-	httpReq, err := http.NewRequest("POST", fmt.Sprintf("%s/orders", RazorpayAPIURL), bytes.NewBuffer(body))
+	httpReq, err := http.NewRequest(method, s.config.BaseURL+path, bodyReader)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// Set headers
-	httpReq.Header.Set("Content-Type", "application/json")
+	if reqBody != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	if idempotencyKey != "" {
+		// Honoured by the refunds API
+		httpReq.Header.Set("X-Refund-Idempotency", idempotencyKey)
+	}
 	httpReq.SetBasicAuth(s.config.KeyID, s.config.KeySecret)
 
 	// Send request
 	resp, err := s.httpClient.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Read response body
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return fmt.Errorf("failed to read response: %w", err)
 	}
 
 	// Check status code
 	if resp.StatusCode != http.StatusOK {
 		s.logger.Error("Razorpay API error",
+			zap.String("path", path),
 			zap.Int("status_code", resp.StatusCode),
 			zap.String("response", string(respBody)),
 		)
-		return nil, fmt.Errorf("razorpay API error: status %d, response: %s", resp.StatusCode, string(respBody))
+		return &APIError{StatusCode: resp.StatusCode, Body: string(respBody)}
 	}
 
 	// Parse response
-	var orderResp CreateOrderResponse
-	if err := json.Unmarshal(respBody, &orderResp); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
-	s.logger.Info("Razorpay order created",
-		zap.String("order_id", orderResp.ID),
-		zap.Int("amount", orderResp.Amount),
-		zap.String("currency", orderResp.Currency),
-	)
-
-	return &orderResp, nil
+	return nil
 }
 
 // VerifyPaymentSignature verifies the Razorpay payment signature
@@ -187,6 +300,7 @@ type WebhookPayload struct {
 type WebhookPayloadData struct {
 	Payment WebhookPaymentData `json:"payment"`
 	Order   WebhookOrderData   `json:"order"`
+	Refund  WebhookRefundData  `json:"refund"`
 }
 
 // WebhookPaymentData represents payment information in webhook
@@ -199,6 +313,11 @@ type WebhookOrderData struct {
 	Entity OrderEntity `json:"entity"`
 }
 
+// WebhookRefundData represents refund information in webhook
+type WebhookRefundData struct {
+	Entity RefundEntity `json:"entity"`
+}
+
 // PaymentEntity represents a Razorpay payment
 type PaymentEntity struct {
 	ID               string            `json:"id"`
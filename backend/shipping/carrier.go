@@ -0,0 +1,172 @@
+package shipping
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Carrier names, recorded on shipments
+const (
+	CarrierShiprocket = "shiprocket"
+	CarrierFake       = "fake"
+)
+
+// ErrCarrierUnavailable is returned for carriers that are not configured
+var ErrCarrierUnavailable = errors.New("shipping carrier not configured")
+
+// Default parcel dimensions in centimetres, used when a shipment is booked
+// without them
+const (
+	DefaultParcelLengthCm  = 30
+	DefaultParcelBreadthCm = 25
+	DefaultParcelHeightCm  = 5
+)
+
+// Carrier books shipments with a courier, or a courier aggregator that picks
+// one, and reports their progress through tracking webhooks
+type Carrier interface {
+	// Name returns the carrier name recorded on shipments
+	Name() string
+
+	// CreateShipment books a parcel and assigns its AWB
+	CreateShipment(req ShipmentRequest) (*Booking, error)
+
+	// Label returns the shipping label PDF of a booked shipment
+	Label(shipmentID string) ([]byte, error)
+
+	// SchedulePickup asks the courier to collect a booked shipment. A zero
+	// date leaves the day to the courier.
+	SchedulePickup(shipmentID string, date time.Time) (*Pickup, error)
+
+	// VerifyWebhook checks that a tracking webhook came from the carrier
+	VerifyWebhook(payload []byte, header http.Header) bool
+
+	// ParseWebhook translates a tracking webhook payload
+	ParseWebhook(payload []byte) (*TrackingEvent, error)
+}
+
+// ShipmentRequest describes a parcel to book
+type ShipmentRequest struct {
+	OrderID       string // Our order ID
+	OrderDate     time.Time
+	Address       Address
+	Items         []ShipmentItem
+	COD           bool
+	SubtotalCents int // What the customer paid, collected by the courier for COD
+	WeightGrams   int
+	LengthCm      int
+	BreadthCm     int
+	HeightCm      int
+}
+
+// Address is the delivery address of a shipment
+type Address struct {
+	Name    string
+	Phone   string
+	Email   string
+	Line1   string
+	Line2   string
+	City    string
+	State   string
+	Pincode string
+	Country string
+}
+
+// ShipmentItem is a line of the parcel's contents
+type ShipmentItem struct {
+	Name       string
+	SKU        string
+	Quantity   int
+	PriceCents int // Unit price
+	HSNCode    string
+}
+
+// Booking is a shipment booked with a carrier
+type Booking struct {
+	CarrierOrderID    string
+	CarrierShipmentID string
+	AWB               string
+	CourierName       string
+	TrackingURL       string
+}
+
+// Pickup is a scheduled collection of a shipment
+type Pickup struct {
+	ScheduledFor time.Time
+	Reference    string // Carrier pickup token, if any
+}
+
+// ShipmentStatus is a shipment's progress, normalised across carriers
+type ShipmentStatus string
+
+const (
+	ShipmentBooked          ShipmentStatus = "booked"
+	ShipmentPickupScheduled ShipmentStatus = "pickup_scheduled"
+	ShipmentInTransit       ShipmentStatus = "in_transit"
+	ShipmentOutForDelivery  ShipmentStatus = "out_for_delivery"
+	ShipmentDelivered       ShipmentStatus = "delivered"
+	ShipmentReturning       ShipmentStatus = "returning" // RTO initiated
+	ShipmentReturned        ShipmentStatus = "returned"  // RTO delivered back to us
+	ShipmentCancelled       ShipmentStatus = "cancelled"
+)
+
+// TrackingEvent is a tracking webhook translated from the carrier's format.
+// Status is empty for scans that do not change the shipment's progress.
+type TrackingEvent struct {
+	AWB           string         `json:"awb"`
+	Status        ShipmentStatus `json:"status"`
+	CarrierStatus string         `json:"carrier_status"` // As the carrier reported it
+	OccurredAt    time.Time      `json:"occurred_at"`
+}
+
+// Carriers holds the configured carriers. New shipments are booked with the
+// default carrier; existing shipments use the carrier recorded on them.
+type Carriers struct {
+	carriers       map[string]Carrier
+	order          []Carrier
+	defaultCarrier string
+}
+
+// NewCarriers creates a carrier registry
+func NewCarriers(defaultCarrier Carrier, others ...Carrier) *Carriers {
+	c := &Carriers{
+		carriers:       map[string]Carrier{defaultCarrier.Name(): defaultCarrier},
+		order:          []Carrier{defaultCarrier},
+		defaultCarrier: defaultCarrier.Name(),
+	}
+	for _, carrier := range others {
+		if _, ok := c.carriers[carrier.Name()]; !ok {
+			c.order = append(c.order, carrier)
+		}
+		c.carriers[carrier.Name()] = carrier
+	}
+	return c
+}
+
+// Default returns the carrier used for new shipments
+func (c *Carriers) Default() Carrier {
+	return c.carriers[c.defaultCarrier]
+}
+
+// Get returns a carrier by name
+func (c *Carriers) Get(name string) (Carrier, error) {
+	carrier, ok := c.carriers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrCarrierUnavailable, name)
+	}
+	return carrier, nil
+}
+
+// ForWebhook returns the carrier that signed a tracking webhook, or nil if
+// none did. Carriers authenticate webhooks with different headers, so a
+// single endpoint serves them all.
+func (c *Carriers) ForWebhook(payload []byte, header http.Header) Carrier {
+	for _, carrier := range c.order {
+		if carrier.VerifyWebhook(payload, header) {
+			return carrier
+		}
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package shipping
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// shiprocketStandIn returns a Shiprocket carrier backed by a server that
+// issues token-1 on the first login and token-2 after, and accepts only the
+// latest token
+func shiprocketStandIn(t *testing.T) (*ShiprocketCarrier, *int32) {
+	var logins int32
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+
+		if r.URL.Path == "/auth/login" {
+			if body["email"] != "ops@example.com" || body["password"] != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			n := atomic.AddInt32(&logins, 1)
+			json.NewEncoder(w).Encode(map[string]string{"token": "token-" + string(rune('0'+n))})
+			return
+		}
+		if r.URL.Path == "/labels/1001.pdf" {
+			w.Write([]byte("%PDF-label"))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer token-"+string(rune('0'+atomic.LoadInt32(&logins))) {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"message": "Token has expired"})
+			return
+		}
+
+		switch r.URL.Path {
+		case "/orders/create/adhoc":
+			if body["payment_method"] != "COD" || body["weight"] != 1.2 || body["pickup_location"] != "Warehouse" {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				json.NewEncoder(w).Encode(map[string]string{"message": "unexpected order"})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"order_id": 501, "shipment_id": 1001, "status": "NEW"})
+		case "/courier/assign/awb":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"awb_assign_status": 1,
+				"response": map[string]interface{}{
+					"data": map[string]string{"awb_code": "19041234567890", "courier_name": "Delhivery Surface"},
+				},
+			})
+		case "/courier/generate/label":
+			json.NewEncoder(w).Encode(map[string]interface{}{"label_created": 1, "label_url": server.URL + "/labels/1001.pdf"})
+		case "/courier/generate/pickup":
+			dates, _ := body["pickup_date"].([]interface{})
+			if len(dates) != 1 || dates[0] != "2026-10-17" {
+				json.NewEncoder(w).Encode(map[string]interface{}{"pickup_status": 0, "message": "invalid date"})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"pickup_status": 1,
+				"response": map[string]string{
+					"pickup_scheduled_date": "2026-10-17 11:00:00",
+					"pickup_token_number":   "Reference No: 4455",
+				},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return NewShiprocketCarrier(ShiprocketConfig{
+		Email:          "ops@example.com",
+		Password:       "secret",
+		PickupLocation: "Warehouse",
+		WebhookToken:   "hook-token",
+		BaseURL:        server.URL,
+	}, zap.NewNop()), &logins
+}
+
+func TestShiprocketCarrier(t *testing.T) {
+	carrier, logins := shiprocketStandIn(t)
+
+	booking, err := carrier.CreateShipment(ShipmentRequest{
+		OrderID:       "order-1",
+		OrderDate:     time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC),
+		Address:       Address{Name: "Asha Rao", Line1: "12 MG Road", City: "Chennai", State: "Tamil Nadu", Pincode: "600001", Country: "India"},
+		Items:         []ShipmentItem{{Name: "Silk saree", SKU: "SAR-1", Quantity: 1, PriceCents: 105000}},
+		COD:           true,
+		SubtotalCents: 109900,
+		WeightGrams:   1200,
+		LengthCm:      30,
+		BreadthCm:     25,
+		HeightCm:      5,
+	})
+	if err != nil {
+		t.Fatalf("CreateShipment failed: %v", err)
+	}
+	if booking.CarrierShipmentID != "1001" || booking.AWB != "19041234567890" || booking.CourierName != "Delhivery Surface" {
+		t.Errorf("Unexpected booking: %+v", *booking)
+	}
+	if booking.TrackingURL != "https://shiprocket.co/tracking/19041234567890" {
+		t.Errorf("Unexpected tracking URL %q", booking.TrackingURL)
+	}
+
+	label, err := carrier.Label(booking.CarrierShipmentID)
+	if err != nil || string(label) != "%PDF-label" {
+		t.Errorf("Label = %q, %v", label, err)
+	}
+
+	// A revoked token is renewed once
+	carrier.mu.Lock()
+	carrier.token = "stale"
+	carrier.mu.Unlock()
+
+	ist := time.FixedZone("IST", 5*60*60+30*60)
+	pickup, err := carrier.SchedulePickup(booking.CarrierShipmentID, time.Date(2026, 10, 17, 0, 0, 0, 0, ist))
+	if err != nil {
+		t.Fatalf("SchedulePickup failed: %v", err)
+	}
+	if !pickup.ScheduledFor.Equal(time.Date(2026, 10, 17, 11, 0, 0, 0, ist)) || pickup.Reference != "Reference No: 4455" {
+		t.Errorf("Unexpected pickup: %+v", *pickup)
+	}
+	if n := atomic.LoadInt32(logins); n != 2 {
+		t.Errorf("Expected 2 logins, got %d", n)
+	}
+}
+
+func TestShiprocketWebhook(t *testing.T) {
+	carrier, _ := shiprocketStandIn(t)
+
+	header := http.Header{}
+	if carrier.VerifyWebhook([]byte("{}"), header) {
+		t.Error("Webhook without a token verified")
+	}
+	header.Set("x-api-key", "wrong")
+	if carrier.VerifyWebhook([]byte("{}"), header) {
+		t.Error("Webhook with the wrong token verified")
+	}
+	header.Set("x-api-key", "hook-token")
+	if !carrier.VerifyWebhook([]byte("{}"), header) {
+		t.Error("Webhook with the right token rejected")
+	}
+
+	tests := []struct {
+		payload string
+		status  ShipmentStatus
+	}{
+		{`{"awb": 19041234567890, "current_status": "Delivered", "current_timestamp": "2026-10-19 14:05:00"}`, ShipmentDelivered},
+		{`{"awb": "19041234567890", "current_status": "IN TRANSIT"}`, ShipmentInTransit},
+		{`{"awb": "19041234567890", "current_status": "OUT FOR DELIVERY"}`, ShipmentOutForDelivery},
+		{`{"awb": "19041234567890", "current_status": "RTO DELIVERED"}`, ShipmentReturned},
+		{`{"awb": "19041234567890", "current_status": "UNDELIVERED"}`, ""},
+	}
+	for _, tt := range tests {
+		event, err := carrier.ParseWebhook([]byte(tt.payload))
+		if err != nil {
+			t.Errorf("ParseWebhook(%s) failed: %v", tt.payload, err)
+			continue
+		}
+		if event.AWB != "19041234567890" || event.Status != tt.status {
+			t.Errorf("ParseWebhook(%s) = %+v, want status %q", tt.payload, *event, tt.status)
+		}
+	}
+
+	event, _ := carrier.ParseWebhook([]byte(tests[0].payload))
+	if want := time.Date(2026, 10, 19, 8, 35, 0, 0, time.UTC); !event.OccurredAt.Equal(want) {
+		t.Errorf("OccurredAt = %v, want %v", event.OccurredAt, want)
+	}
+
+	if _, err := carrier.ParseWebhook([]byte(`{"current_status": "DELIVERED"}`)); err == nil {
+		t.Error("Expected an error for a payload without an AWB")
+	}
+}
+
+func TestFakeCarrier(t *testing.T) {
+	carrier := NewFakeCarrier()
+
+	booking, err := carrier.CreateShipment(ShipmentRequest{OrderID: "order-1", Address: Address{Name: "Asha (Home)"}})
+	if err != nil {
+		t.Fatalf("CreateShipment failed: %v", err)
+	}
+
+	label, err := carrier.Label(booking.CarrierShipmentID)
+	if err != nil {
+		t.Fatalf("Label failed: %v", err)
+	}
+	if !bytes.HasPrefix(label, []byte("%PDF-1.4")) || !bytes.Contains(label, []byte(`(Asha \(Home\)) '`)) {
+		t.Errorf("Unexpected label:\n%s", label)
+	}
+
+	if _, err := carrier.SchedulePickup(booking.CarrierShipmentID, time.Time{}); err != nil {
+		t.Errorf("SchedulePickup failed: %v", err)
+	}
+	if _, err := carrier.Label("missing"); err == nil {
+		t.Error("Expected an error for an unknown shipment")
+	}
+}
+
+func TestCarriersForWebhook(t *testing.T) {
+	shiprocket, _ := shiprocketStandIn(t)
+	fake := NewFakeCarrier()
+	carriers := NewCarriers(shiprocket, fake)
+
+	payload := []byte(`{"awb": "FAKE00000001", "status": "delivered"}`)
+
+	header := http.Header{}
+	header.Set("X-Fake-Signature", FakeSign(string(payload)))
+	if got := carriers.ForWebhook(payload, header); got != Carrier(fake) {
+		t.Errorf("ForWebhook picked %v, want the fake carrier", got)
+	}
+
+	header = http.Header{}
+	header.Set("X-Api-Key", "hook-token")
+	if got := carriers.ForWebhook(payload, header); got != Carrier(shiprocket) {
+		t.Errorf("ForWebhook picked %v, want Shiprocket", got)
+	}
+
+	if got := carriers.ForWebhook(payload, http.Header{}); got != nil {
+		t.Errorf("ForWebhook picked %v for an unsigned webhook", got)
+	}
+
+	if carriers.Default().Name() != CarrierShiprocket {
+		t.Errorf("Default = %s", carriers.Default().Name())
+	}
+	if _, err := carriers.Get("bluedart"); err == nil || !strings.Contains(err.Error(), "bluedart") {
+		t.Errorf("Get(bluedart) error = %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package shipping
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeSecret signs fake tracking webhooks
+const fakeSecret = "fake_carrier_secret"
+
+// FakeCarrier is an in-memory carrier for local development and tests. It
+// books every shipment, renders a placeholder label and schedules pickups
+// for the next day. Tracking webhooks are JSON-encoded TrackingEvents signed
+// with FakeSign in the X-Fake-Signature header.
+type FakeCarrier struct {
+	mu        sync.Mutex
+	nextID    int
+	shipments map[string]ShipmentRequest
+	awbs      map[string]string
+}
+
+// NewFakeCarrier creates a fake carrier
+func NewFakeCarrier() *FakeCarrier {
+	return &FakeCarrier{
+		shipments: map[string]ShipmentRequest{},
+		awbs:      map[string]string{},
+	}
+}
+
+// Name implements Carrier
+func (f *FakeCarrier) Name() string {
+	return CarrierFake
+}
+
+// CreateShipment implements Carrier
+func (f *FakeCarrier) CreateShipment(req ShipmentRequest) (*Booking, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.nextID++
+	shipmentID := fmt.Sprintf("fake_shipment_%d", f.nextID)
+	awb := fmt.Sprintf("FAKE%08d", f.nextID)
+	f.shipments[shipmentID] = req
+	f.awbs[shipmentID] = awb
+
+	return &Booking{
+		CarrierOrderID:    fmt.Sprintf("fake_order_%d", f.nextID),
+		CarrierShipmentID: shipmentID,
+		AWB:               awb,
+		CourierName:       "Fake Express",
+		TrackingURL:       "https://tracking.example.com/" + awb,
+	}, nil
+}
+
+// Label implements Carrier
+func (f *FakeCarrier) Label(shipmentID string) ([]byte, error) {
+	f.mu.Lock()
+	req, ok := f.shipments[shipmentID]
+	awb := f.awbs[shipmentID]
+	f.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("fake carrier: shipment %s not found", shipmentID)
+	}
+
+	return fakeLabel([]string{
+		"FAKE EXPRESS - AWB " + awb,
+		"Order " + req.OrderID,
+		req.Address.Name,
+		req.Address.Line1,
+		req.Address.City + " - " + req.Address.Pincode,
+	}), nil
+}
+
+// SchedulePickup implements Carrier
+func (f *FakeCarrier) SchedulePickup(shipmentID string, date time.Time) (*Pickup, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.shipments[shipmentID]; !ok {
+		return nil, fmt.Errorf("fake carrier: shipment %s not found", shipmentID)
+	}
+	if date.IsZero() {
+		date = time.Now().Add(24 * time.Hour)
+	}
+	return &Pickup{ScheduledFor: date, Reference: "PICKUP-" + f.awbs[shipmentID]}, nil
+}
+
+// VerifyWebhook implements Carrier
+func (f *FakeCarrier) VerifyWebhook(payload []byte, header http.Header) bool {
+	return hmac.Equal([]byte(FakeSign(string(payload))), []byte(header.Get("X-Fake-Signature")))
+}
+
+// ParseWebhook implements Carrier
+func (f *FakeCarrier) ParseWebhook(payload []byte) (*TrackingEvent, error) {
+	var event TrackingEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("failed to parse webhook payload: %w", err)
+	}
+	if event.AWB == "" {
+		return nil, fmt.Errorf("webhook payload has no AWB")
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+	return &event, nil
+}
+
+// FakeSign signs a message the way the fake carrier expects
+func FakeSign(message string) string {
+	h := hmac.New(sha256.New, []byte(fakeSecret))
+	h.Write([]byte(message))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// fakeLabel renders a single-page 4x6 inch PDF with a line of text per entry
+func fakeLabel(lines []string) []byte {
+	var content strings.Builder
+	content.WriteString("BT /F1 12 Tf 20 400 Td 16 TL\n")
+	for _, line := range lines {
+		line = strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`).Replace(line)
+		fmt.Fprintf(&content, "(%s) '\n", line)
+	}
+	content.WriteString("ET")
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 288 432] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
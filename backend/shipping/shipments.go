@@ -0,0 +1,206 @@
+package shipping
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrShipmentNotFound = errors.New("shipment not found")
+	ErrShipmentExists   = errors.New("order already has a shipment")
+)
+
+// Shipment is a parcel booked with a carrier for an order
+type Shipment struct {
+	ID                 uuid.UUID      `json:"id"`
+	OrderID            uuid.UUID      `json:"order_id"`
+	Carrier            string         `json:"carrier"`
+	CarrierOrderID     *string        `json:"carrier_order_id,omitempty"`
+	CarrierShipmentID  string         `json:"carrier_shipment_id"`
+	AWB                string         `json:"awb"`
+	CourierName        *string        `json:"courier_name,omitempty"`
+	TrackingURL        *string        `json:"tracking_url,omitempty"`
+	Status             ShipmentStatus `json:"status"`
+	CarrierStatus      *string        `json:"carrier_status,omitempty"`
+	WeightGrams        int            `json:"weight_grams"`
+	LengthCm           int            `json:"length_cm"`
+	BreadthCm          int            `json:"breadth_cm"`
+	HeightCm           int            `json:"height_cm"`
+	PickupScheduledFor *time.Time     `json:"pickup_scheduled_for,omitempty"`
+	PickupReference    *string        `json:"pickup_reference,omitempty"`
+	LastEventAt        *time.Time     `json:"last_event_at,omitempty"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+}
+
+const shipmentColumns = `id, order_id, carrier, carrier_order_id, carrier_shipment_id, awb, courier_name,
+		       tracking_url, status, carrier_status, weight_grams, length_cm, breadth_cm, height_cm,
+		       pickup_scheduled_for, pickup_reference, last_event_at, created_at, updated_at`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanShipment(row rowScanner) (*Shipment, error) {
+	var s Shipment
+	err := row.Scan(
+		&s.ID, &s.OrderID, &s.Carrier, &s.CarrierOrderID, &s.CarrierShipmentID, &s.AWB, &s.CourierName,
+		&s.TrackingURL, &s.Status, &s.CarrierStatus, &s.WeightGrams, &s.LengthCm, &s.BreadthCm, &s.HeightCm,
+		&s.PickupScheduledFor, &s.PickupReference, &s.LastEventAt, &s.CreatedAt, &s.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ShipmentRepository handles shipment database operations
+type ShipmentRepository struct {
+	db *sql.DB
+}
+
+// NewShipmentRepository creates a new shipment repository
+func NewShipmentRepository(db *sql.DB) *ShipmentRepository {
+	return &ShipmentRepository{db: db}
+}
+
+// CreateShipmentInput represents a booked shipment to record
+type CreateShipmentInput struct {
+	OrderID     uuid.UUID
+	Carrier     string
+	Booking     *Booking
+	WeightGrams int
+	LengthCm    int
+	BreadthCm   int
+	HeightCm    int
+}
+
+// CreateShipment records a booked shipment. Returns ErrShipmentExists if the
+// order already has one that is not cancelled.
+func (r *ShipmentRepository) CreateShipment(ctx context.Context, input CreateShipmentInput) (*Shipment, error) {
+	shipment, err := scanShipment(r.db.QueryRowContext(ctx, `
+		INSERT INTO shipments (order_id, carrier, carrier_order_id, carrier_shipment_id, awb, courier_name,
+		                       tracking_url, status, weight_grams, length_cm, breadth_cm, height_cm)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, NULLIF($6, ''), NULLIF($7, ''), $8, $9, $10, $11, $12)
+		RETURNING `+shipmentColumns,
+		input.OrderID, input.Carrier, input.Booking.CarrierOrderID, input.Booking.CarrierShipmentID,
+		input.Booking.AWB, input.Booking.CourierName, input.Booking.TrackingURL, ShipmentBooked,
+		input.WeightGrams, input.LengthCm, input.BreadthCm, input.HeightCm,
+	))
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "idx_shipments_order_active" {
+			return nil, ErrShipmentExists
+		}
+		return nil, fmt.Errorf("failed to create shipment: %w", err)
+	}
+	return shipment, nil
+}
+
+// GetOrderShipment retrieves an order's live shipment, or its latest
+// cancelled one if it has no other
+func (r *ShipmentRepository) GetOrderShipment(ctx context.Context, orderID uuid.UUID) (*Shipment, error) {
+	shipment, err := scanShipment(r.db.QueryRowContext(ctx, `
+		SELECT `+shipmentColumns+`
+		FROM shipments
+		WHERE order_id = $1
+		ORDER BY (status = $2), created_at DESC
+		LIMIT 1
+	`, orderID, ShipmentCancelled))
+	if err == sql.ErrNoRows {
+		return nil, ErrShipmentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shipment: %w", err)
+	}
+	return shipment, nil
+}
+
+// GetShipmentByAWB retrieves a shipment by its carrier and AWB
+func (r *ShipmentRepository) GetShipmentByAWB(ctx context.Context, carrier, awb string) (*Shipment, error) {
+	shipment, err := scanShipment(r.db.QueryRowContext(ctx, `
+		SELECT `+shipmentColumns+`
+		FROM shipments
+		WHERE carrier = $1 AND awb = $2
+	`, carrier, awb))
+	if err == sql.ErrNoRows {
+		return nil, ErrShipmentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shipment: %w", err)
+	}
+	return shipment, nil
+}
+
+// RecordPickup stores a scheduled pickup. Shipments already on their way
+// keep their status.
+func (r *ShipmentRepository) RecordPickup(ctx context.Context, id uuid.UUID, pickup *Pickup) (*Shipment, error) {
+	var scheduledFor *time.Time
+	if !pickup.ScheduledFor.IsZero() {
+		scheduledFor = &pickup.ScheduledFor
+	}
+
+	shipment, err := scanShipment(r.db.QueryRowContext(ctx, `
+		UPDATE shipments
+		SET pickup_scheduled_for = $1,
+		    pickup_reference = NULLIF($2, ''),
+		    status = CASE WHEN status = $3 THEN $4 ELSE status END
+		WHERE id = $5
+		RETURNING `+shipmentColumns,
+		scheduledFor, pickup.Reference, ShipmentBooked, ShipmentPickupScheduled, id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrShipmentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to record pickup: %w", err)
+	}
+	return shipment, nil
+}
+
+// RecordTrackingEvent stores a tracking update on a shipment. Events older
+// than the latest one recorded are ignored, as carriers do not deliver
+// webhooks in order; applied reports whether the event was newer.
+func (r *ShipmentRepository) RecordTrackingEvent(ctx context.Context, id uuid.UUID, event *TrackingEvent) (shipment *Shipment, applied bool, err error) {
+	shipment, err = scanShipment(r.db.QueryRowContext(ctx, `
+		UPDATE shipments
+		SET status = COALESCE(NULLIF($1, ''), status),
+		    carrier_status = $2,
+		    last_event_at = $3
+		WHERE id = $4
+		  AND (last_event_at IS NULL OR last_event_at <= $3)
+		RETURNING `+shipmentColumns,
+		event.Status, event.CarrierStatus, event.OccurredAt, id,
+	))
+	if err == sql.ErrNoRows {
+		// Either the shipment is gone or the event is stale
+		shipment, err = r.getShipment(ctx, id)
+		return shipment, false, err
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to record tracking event: %w", err)
+	}
+	return shipment, true, nil
+}
+
+func (r *ShipmentRepository) getShipment(ctx context.Context, id uuid.UUID) (*Shipment, error) {
+	shipment, err := scanShipment(r.db.QueryRowContext(ctx, `
+		SELECT `+shipmentColumns+`
+		FROM shipments
+		WHERE id = $1
+	`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrShipmentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shipment: %w", err)
+	}
+	return shipment, nil
+}
@@ -0,0 +1,283 @@
+package shipping
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Basis is what a zone's rate table is keyed on
+type Basis string
+
+const (
+	BasisWeight Basis = "weight"
+	BasisValue  Basis = "value"
+)
+
+// DefaultItemWeightGrams is used for products without a weight
+const DefaultItemWeightGrams = 500
+
+var (
+	ErrInvalidPincode = errors.New("invalid pincode")
+	ErrNotServiceable = errors.New("pincode not serviceable")
+)
+
+// Rate is one slab of a rate table. A slab applies up to and including its
+// maximum; the last slab may leave it at zero to have no upper bound.
+type Rate struct {
+	MaxWeightGrams int `json:"max_weight_grams,omitempty"`
+	MaxValueCents  int `json:"max_value_cents,omitempty"`
+	RateCents      int `json:"rate_cents"`
+}
+
+// Zone is a delivery area and what shipping to it costs. Pincodes holds
+// pincode prefixes, from a single digit (a postal region) to a full pincode.
+type Zone struct {
+	Name     string   `json:"name"`
+	Pincodes []string `json:"pincodes"`
+	Basis    Basis    `json:"basis"`
+	Rates    []Rate   `json:"rates"`
+	// For weight-based zones, charged per started kilogram above the last
+	// slab when that slab has a maximum
+	ExtraPerKgCents int `json:"extra_per_kg_cents,omitempty"`
+	// Orders worth at least this much ship free; zero disables
+	FreeAboveCents int `json:"free_above_cents,omitempty"`
+	MinDays        int `json:"min_days,omitempty"`
+	MaxDays        int `json:"max_days,omitempty"`
+}
+
+// Config is the shipping rate configuration. Zones are matched in order, so
+// list specific areas before broad ones. Pincodes in Blocked are never
+// served.
+type Config struct {
+	Zones   []Zone   `json:"zones"`
+	Blocked []string `json:"blocked_pincodes,omitempty"`
+}
+
+// DefaultConfig ships free to every pincode
+func DefaultConfig() Config {
+	return Config{
+		Zones: []Zone{{
+			Name:     "India",
+			Pincodes: []string{"1", "2", "3", "4", "5", "6", "7", "8", "9"},
+			Basis:    BasisValue,
+			Rates:    []Rate{{RateCents: 0}},
+		}},
+	}
+}
+
+// LoadConfig reads a JSON shipping configuration file
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read shipping config: %w", err)
+	}
+
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return Config{}, fmt.Errorf("failed to parse shipping config: %w", err)
+	}
+
+	if err := config.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	return config, nil
+}
+
+// Validate checks the zones and their rate tables
+func (c Config) Validate() error {
+	if len(c.Zones) == 0 {
+		return fmt.Errorf("shipping config has no zones")
+	}
+
+	for _, zone := range c.Zones {
+		if zone.Name == "" {
+			return fmt.Errorf("shipping zone name is required")
+		}
+		if len(zone.Pincodes) == 0 {
+			return fmt.Errorf("zone %s: pincodes are required", zone.Name)
+		}
+		for _, prefix := range zone.Pincodes {
+			if len(prefix) > 6 || !digits(prefix) {
+				return fmt.Errorf("zone %s: invalid pincode prefix %q", zone.Name, prefix)
+			}
+		}
+		if zone.Basis != BasisWeight && zone.Basis != BasisValue {
+			return fmt.Errorf("zone %s: basis must be weight or value", zone.Name)
+		}
+		if len(zone.Rates) == 0 {
+			return fmt.Errorf("zone %s: rates are required", zone.Name)
+		}
+		if zone.ExtraPerKgCents < 0 || zone.FreeAboveCents < 0 || zone.MinDays < 0 || zone.MaxDays < zone.MinDays {
+			return fmt.Errorf("zone %s: invalid charges or delivery days", zone.Name)
+		}
+
+		previous := 0
+		for i, rate := range zone.Rates {
+			if rate.RateCents < 0 {
+				return fmt.Errorf("zone %s: rates must not be negative", zone.Name)
+			}
+			limit := rate.MaxWeightGrams
+			if zone.Basis == BasisValue {
+				limit = rate.MaxValueCents
+			}
+			last := i == len(zone.Rates)-1
+			if (limit == 0 && !last) || (limit != 0 && limit <= previous) {
+				return fmt.Errorf("zone %s: slabs must increase and only the last may be open-ended", zone.Name)
+			}
+			previous = limit
+		}
+	}
+
+	return nil
+}
+
+// Item is a cart line to ship. Dimensions are in centimetres; missing
+// weights fall back to DefaultItemWeightGrams.
+type Item struct {
+	WeightGrams *int
+	LengthCm    *int
+	BreadthCm   *int
+	HeightCm    *int
+	Quantity    int
+}
+
+// Quote is the shipping charge for a cart
+type Quote struct {
+	Zone            string `json:"zone"`
+	WeightGrams     int    `json:"weight_grams"`     // Chargeable weight
+	RateCents       int    `json:"rate_cents"`       // Before free shipping
+	ShippingCents   int    `json:"shipping_cents"`   // What the customer pays
+	FreeShipping    bool   `json:"free_shipping"`    // The free threshold was met
+	FreeAboveCents  int    `json:"free_above_cents"` // Zero when the zone has none
+	MinDeliveryDays int    `json:"min_delivery_days,omitempty"`
+	MaxDeliveryDays int    `json:"max_delivery_days,omitempty"`
+}
+
+// Calculator prices shipping from a rate configuration
+type Calculator struct {
+	config Config
+}
+
+// NewCalculator creates a shipping calculator. The config should have been
+// validated.
+func NewCalculator(config Config) *Calculator {
+	return &Calculator{config: config}
+}
+
+// Zone returns the zone serving a pincode. Returns ErrInvalidPincode for
+// malformed pincodes and ErrNotServiceable when no zone covers it.
+func (c *Calculator) Zone(pincode string) (*Zone, error) {
+	pincode = strings.TrimSpace(pincode)
+	if len(pincode) != 6 || !digits(pincode) || pincode[0] == '0' {
+		return nil, ErrInvalidPincode
+	}
+
+	for _, blocked := range c.config.Blocked {
+		if strings.HasPrefix(pincode, blocked) {
+			return nil, ErrNotServiceable
+		}
+	}
+
+	for i := range c.config.Zones {
+		for _, prefix := range c.config.Zones[i].Pincodes {
+			if strings.HasPrefix(pincode, prefix) {
+				return &c.config.Zones[i], nil
+			}
+		}
+	}
+
+	return nil, ErrNotServiceable
+}
+
+// Quote prices shipping the items to a pincode. valueCents is what the
+// customer pays for the goods and decides value slabs and free shipping.
+func (c *Calculator) Quote(items []Item, valueCents int, pincode string) (*Quote, error) {
+	zone, err := c.Zone(pincode)
+	if err != nil {
+		return nil, err
+	}
+
+	weight := 0
+	for _, item := range items {
+		weight += ChargeableWeight(item) * item.Quantity
+	}
+
+	quote := &Quote{
+		Zone:            zone.Name,
+		WeightGrams:     weight,
+		FreeAboveCents:  zone.FreeAboveCents,
+		MinDeliveryDays: zone.MinDays,
+		MaxDeliveryDays: zone.MaxDays,
+	}
+
+	if zone.Basis == BasisValue {
+		quote.RateCents = valueRate(zone, valueCents)
+	} else {
+		quote.RateCents = weightRate(zone, weight)
+	}
+
+	quote.ShippingCents = quote.RateCents
+	if zone.FreeAboveCents > 0 && valueCents >= zone.FreeAboveCents {
+		quote.ShippingCents = 0
+		quote.FreeShipping = true
+	}
+
+	return quote, nil
+}
+
+// ChargeableWeight is the greater of an item's actual and volumetric weight
+// in grams. Volumetric weight uses the courier divisor of 5000 cm³ per kg.
+func ChargeableWeight(item Item) int {
+	weight := DefaultItemWeightGrams
+	if item.WeightGrams != nil {
+		weight = *item.WeightGrams
+	}
+
+	if item.LengthCm != nil && item.BreadthCm != nil && item.HeightCm != nil {
+		volumeCm3 := *item.LengthCm * *item.BreadthCm * *item.HeightCm
+		volumetric := (volumeCm3 + 4) / 5
+		if volumetric > weight {
+			weight = volumetric
+		}
+	}
+
+	return weight
+}
+
+func weightRate(zone *Zone, weight int) int {
+	for _, rate := range zone.Rates {
+		if rate.MaxWeightGrams == 0 || weight <= rate.MaxWeightGrams {
+			return rate.RateCents
+		}
+	}
+
+	// Heavier than the last slab: charge each started kilogram over it
+	last := zone.Rates[len(zone.Rates)-1]
+	extraKg := (weight - last.MaxWeightGrams + 999) / 1000
+	return last.RateCents + extraKg*zone.ExtraPerKgCents
+}
+
+func valueRate(zone *Zone, valueCents int) int {
+	for _, rate := range zone.Rates {
+		if rate.MaxValueCents == 0 || valueCents <= rate.MaxValueCents {
+			return rate.RateCents
+		}
+	}
+	return zone.Rates[len(zone.Rates)-1].RateCents
+}
+
+func digits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,109 @@
+package shipping
+
+import (
+	"errors"
+	"testing"
+)
+
+func intPtr(n int) *int { return &n }
+
+func testConfig() Config {
+	return Config{
+		Zones: []Zone{
+			{
+				Name:     "Bengaluru",
+				Pincodes: []string{"560"},
+				Basis:    BasisValue,
+				Rates:    []Rate{{MaxValueCents: 99900, RateCents: 4900}, {RateCents: 2900}},
+				MinDays:  1,
+				MaxDays:  2,
+			},
+			{
+				Name:            "South",
+				Pincodes:        []string{"5", "6"},
+				Basis:           BasisWeight,
+				Rates:           []Rate{{MaxWeightGrams: 500, RateCents: 6000}, {MaxWeightGrams: 2000, RateCents: 9000}},
+				ExtraPerKgCents: 3000,
+				FreeAboveCents:  300000,
+			},
+		},
+		Blocked: []string{"682555"},
+	}
+}
+
+func TestQuote(t *testing.T) {
+	calc := NewCalculator(testConfig())
+	saree := Item{WeightGrams: intPtr(400), Quantity: 1}
+
+	tests := []struct {
+		name     string
+		items    []Item
+		value    int
+		pincode  string
+		zone     string
+		weight   int
+		shipping int
+		free     bool
+	}{
+		{"value slab", []Item{saree}, 50000, "560001", "Bengaluru", 400, 4900, false},
+		{"open-ended value slab", []Item{saree}, 150000, "560001", "Bengaluru", 400, 2900, false},
+		{"first weight slab", []Item{saree}, 50000, "600001", "South", 400, 6000, false},
+		{"second weight slab", []Item{{WeightGrams: intPtr(400), Quantity: 3}}, 50000, "600001", "South", 1200, 9000, false},
+		{"per kg above the last slab", []Item{{WeightGrams: intPtr(2100), Quantity: 1}, {Quantity: 1}}, 50000, "600001", "South", 2600, 9000 + 3000, false},
+		{"volumetric weight", []Item{{WeightGrams: intPtr(300), LengthCm: intPtr(30), BreadthCm: intPtr(25), HeightCm: intPtr(4), Quantity: 1}}, 50000, "600001", "South", 600, 9000, false},
+		{"free above threshold", []Item{saree}, 300000, "600001", "South", 400, 0, true},
+	}
+
+	for _, tt := range tests {
+		quote, err := calc.Quote(tt.items, tt.value, tt.pincode)
+		if err != nil {
+			t.Fatalf("%s: Quote failed: %v", tt.name, err)
+		}
+		if quote.Zone != tt.zone || quote.WeightGrams != tt.weight || quote.ShippingCents != tt.shipping || quote.FreeShipping != tt.free {
+			t.Errorf("%s: got %+v", tt.name, *quote)
+		}
+	}
+}
+
+func TestZoneErrors(t *testing.T) {
+	calc := NewCalculator(testConfig())
+
+	for _, pincode := range []string{"", "56001", "5600011", "56000a", "060001"} {
+		if _, err := calc.Zone(pincode); !errors.Is(err, ErrInvalidPincode) {
+			t.Errorf("Zone(%q): expected invalid pincode, got %v", pincode, err)
+		}
+	}
+	for _, pincode := range []string{"110001", "682555"} {
+		if _, err := calc.Zone(pincode); !errors.Is(err, ErrNotServiceable) {
+			t.Errorf("Zone(%q): expected not serviceable, got %v", pincode, err)
+		}
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	if err := testConfig().Validate(); err != nil {
+		t.Fatalf("Valid config rejected: %v", err)
+	}
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Fatalf("Default config rejected: %v", err)
+	}
+
+	invalid := map[string]Zone{
+		"bad basis":        {Name: "X", Pincodes: []string{"1"}, Basis: "distance", Rates: []Rate{{}}},
+		"bad prefix":       {Name: "X", Pincodes: []string{"11-0"}, Basis: BasisValue, Rates: []Rate{{}}},
+		"no rates":         {Name: "X", Pincodes: []string{"1"}, Basis: BasisValue},
+		"open middle slab": {Name: "X", Pincodes: []string{"1"}, Basis: BasisWeight, Rates: []Rate{{RateCents: 1}, {MaxWeightGrams: 500}}},
+		"decreasing slabs": {Name: "X", Pincodes: []string{"1"}, Basis: BasisValue, Rates: []Rate{{MaxValueCents: 500}, {MaxValueCents: 400}}},
+	}
+	for name, zone := range invalid {
+		if err := (Config{Zones: []Zone{zone}}).Validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestExampleConfig(t *testing.T) {
+	if _, err := LoadConfig("../shipping.example.json"); err != nil {
+		t.Fatalf("shipping.example.json: %v", err)
+	}
+}
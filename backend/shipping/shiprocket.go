@@ -0,0 +1,435 @@
+package shipping
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	ShiprocketAPIURL = "https://apiv2.shiprocket.in/v1/external"
+
+	// Shiprocket tokens last ten days; renew a day early
+	shiprocketTokenLifetime = 9 * 24 * time.Hour
+)
+
+// istLocation is India Standard Time, which Shiprocket uses for dates
+var istLocation = time.FixedZone("IST", 5*60*60+30*60)
+
+// ShiprocketConfig holds Shiprocket API user credentials
+type ShiprocketConfig struct {
+	Email          string
+	Password       string
+	PickupLocation string // Pickup address nickname set up in the Shiprocket panel
+	WebhookToken   string // Sent by Shiprocket in the x-api-key header
+	BaseURL        string // Defaults to ShiprocketAPIURL; overridden in tests
+}
+
+// ShiprocketCarrier books shipments through the Shiprocket aggregator, which
+// assigns the courier
+type ShiprocketCarrier struct {
+	config     ShiprocketConfig
+	httpClient *http.Client
+	logger     *zap.Logger
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+// NewShiprocketCarrier creates a Shiprocket carrier
+func NewShiprocketCarrier(config ShiprocketConfig, logger *zap.Logger) *ShiprocketCarrier {
+	if config.BaseURL == "" {
+		config.BaseURL = ShiprocketAPIURL
+	}
+	config.BaseURL = strings.TrimRight(config.BaseURL, "/")
+
+	return &ShiprocketCarrier{
+		config: config,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		logger: logger,
+	}
+}
+
+// Name implements Carrier
+func (s *ShiprocketCarrier) Name() string {
+	return CarrierShiprocket
+}
+
+type shiprocketOrderItem struct {
+	Name         string  `json:"name"`
+	SKU          string  `json:"sku"`
+	Units        int     `json:"units"`
+	SellingPrice float64 `json:"selling_price"`
+	HSN          string  `json:"hsn,omitempty"`
+}
+
+type shiprocketOrder struct {
+	OrderID           string                `json:"order_id"`
+	OrderDate         string                `json:"order_date"`
+	PickupLocation    string                `json:"pickup_location"`
+	BillingName       string                `json:"billing_customer_name"`
+	BillingLastName   string                `json:"billing_last_name"`
+	BillingAddress    string                `json:"billing_address"`
+	BillingAddress2   string                `json:"billing_address_2"`
+	BillingCity       string                `json:"billing_city"`
+	BillingPincode    string                `json:"billing_pincode"`
+	BillingState      string                `json:"billing_state"`
+	BillingCountry    string                `json:"billing_country"`
+	BillingEmail      string                `json:"billing_email"`
+	BillingPhone      string                `json:"billing_phone"`
+	ShippingIsBilling bool                  `json:"shipping_is_billing"`
+	OrderItems        []shiprocketOrderItem `json:"order_items"`
+	PaymentMethod     string                `json:"payment_method"`
+	SubTotal          float64               `json:"sub_total"`
+	Length            int                   `json:"length"`
+	Breadth           int                   `json:"breadth"`
+	Height            int                   `json:"height"`
+	Weight            float64               `json:"weight"` // Kilograms
+}
+
+// CreateShipment implements Carrier. The order is created in Shiprocket and
+// an AWB assigned from the recommended courier.
+func (s *ShiprocketCarrier) CreateShipment(req ShipmentRequest) (*Booking, error) {
+	paymentMethod := "Prepaid"
+	if req.COD {
+		paymentMethod = "COD"
+	}
+
+	order := shiprocketOrder{
+		OrderID:           req.OrderID,
+		OrderDate:         req.OrderDate.In(istLocation).Format("2006-01-02 15:04"),
+		PickupLocation:    s.config.PickupLocation,
+		BillingName:       req.Address.Name,
+		BillingAddress:    req.Address.Line1,
+		BillingAddress2:   req.Address.Line2,
+		BillingCity:       req.Address.City,
+		BillingPincode:    req.Address.Pincode,
+		BillingState:      req.Address.State,
+		BillingCountry:    req.Address.Country,
+		BillingEmail:      req.Address.Email,
+		BillingPhone:      req.Address.Phone,
+		ShippingIsBilling: true,
+		PaymentMethod:     paymentMethod,
+		SubTotal:          rupees(req.SubtotalCents),
+		Length:            req.LengthCm,
+		Breadth:           req.BreadthCm,
+		Height:            req.HeightCm,
+		Weight:            float64(req.WeightGrams) / 1000,
+	}
+	for _, item := range req.Items {
+		order.OrderItems = append(order.OrderItems, shiprocketOrderItem{
+			Name:         item.Name,
+			SKU:          item.SKU,
+			Units:        item.Quantity,
+			SellingPrice: rupees(item.PriceCents),
+			HSN:          item.HSNCode,
+		})
+	}
+
+	var created struct {
+		OrderID    int64  `json:"order_id"`
+		ShipmentID int64  `json:"shipment_id"`
+		Status     string `json:"status"`
+	}
+	if err := s.doRequest("POST", "/orders/create/adhoc", order, &created); err != nil {
+		return nil, err
+	}
+	if created.ShipmentID == 0 {
+		return nil, fmt.Errorf("shiprocket: order %s was not given a shipment", req.OrderID)
+	}
+	shipmentID := strconv.FormatInt(created.ShipmentID, 10)
+
+	var assigned struct {
+		AWBAssignStatus int `json:"awb_assign_status"`
+		Response        struct {
+			Data struct {
+				AWBCode     string `json:"awb_code"`
+				CourierName string `json:"courier_name"`
+			} `json:"data"`
+		} `json:"response"`
+		Message string `json:"message"`
+	}
+	if err := s.doRequest("POST", "/courier/assign/awb", map[string]interface{}{
+		"shipment_id": created.ShipmentID,
+	}, &assigned); err != nil {
+		return nil, err
+	}
+	if assigned.AWBAssignStatus != 1 || assigned.Response.Data.AWBCode == "" {
+		return nil, fmt.Errorf("shiprocket: AWB not assigned to shipment %s: %s", shipmentID, assigned.Message)
+	}
+
+	awb := assigned.Response.Data.AWBCode
+	s.logger.Info("Shiprocket shipment booked",
+		zap.String("order_id", req.OrderID),
+		zap.String("shipment_id", shipmentID),
+		zap.String("awb", awb),
+		zap.String("courier", assigned.Response.Data.CourierName),
+	)
+
+	return &Booking{
+		CarrierOrderID:    strconv.FormatInt(created.OrderID, 10),
+		CarrierShipmentID: shipmentID,
+		AWB:               awb,
+		CourierName:       assigned.Response.Data.CourierName,
+		TrackingURL:       "https://shiprocket.co/tracking/" + awb,
+	}, nil
+}
+
+// Label implements Carrier. Shiprocket renders the label and returns a link
+// to it, which is downloaded here.
+func (s *ShiprocketCarrier) Label(shipmentID string) ([]byte, error) {
+	id, err := strconv.ParseInt(shipmentID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("shiprocket: invalid shipment ID %q", shipmentID)
+	}
+
+	var generated struct {
+		LabelCreated int    `json:"label_created"`
+		LabelURL     string `json:"label_url"`
+		Response     string `json:"response"`
+	}
+	if err := s.doRequest("POST", "/courier/generate/label", map[string]interface{}{
+		"shipment_id": []int64{id},
+	}, &generated); err != nil {
+		return nil, err
+	}
+	if generated.LabelCreated != 1 || generated.LabelURL == "" {
+		return nil, fmt.Errorf("shiprocket: label not created for shipment %s: %s", shipmentID, generated.Response)
+	}
+
+	resp, err := s.httpClient.Get(generated.LabelURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download label: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download label: status %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// SchedulePickup implements Carrier
+func (s *ShiprocketCarrier) SchedulePickup(shipmentID string, date time.Time) (*Pickup, error) {
+	id, err := strconv.ParseInt(shipmentID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("shiprocket: invalid shipment ID %q", shipmentID)
+	}
+
+	body := map[string]interface{}{"shipment_id": []int64{id}}
+	if !date.IsZero() {
+		body["pickup_date"] = []string{date.In(istLocation).Format("2006-01-02")}
+	}
+
+	var scheduled struct {
+		PickupStatus int `json:"pickup_status"`
+		Response     struct {
+			PickupScheduledDate string `json:"pickup_scheduled_date"`
+			PickupTokenNumber   string `json:"pickup_token_number"`
+		} `json:"response"`
+		Message string `json:"message"`
+	}
+	if err := s.doRequest("POST", "/courier/generate/pickup", body, &scheduled); err != nil {
+		return nil, err
+	}
+	if scheduled.PickupStatus != 1 {
+		return nil, fmt.Errorf("shiprocket: pickup not scheduled for shipment %s: %s", shipmentID, scheduled.Message)
+	}
+
+	pickup := &Pickup{Reference: scheduled.Response.PickupTokenNumber, ScheduledFor: date}
+	if at, err := time.ParseInLocation("2006-01-02 15:04:05", scheduled.Response.PickupScheduledDate, istLocation); err == nil {
+		pickup.ScheduledFor = at
+	}
+	return pickup, nil
+}
+
+// VerifyWebhook implements Carrier. Shiprocket sends the token configured
+// with the webhook in the x-api-key header.
+func (s *ShiprocketCarrier) VerifyWebhook(payload []byte, header http.Header) bool {
+	token := header.Get("X-Api-Key")
+	if s.config.WebhookToken == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.config.WebhookToken)) == 1
+}
+
+// shiprocketStatuses maps Shiprocket's current_status to shipment progress
+var shiprocketStatuses = map[string]ShipmentStatus{
+	"PICKUP SCHEDULED":           ShipmentPickupScheduled,
+	"OUT FOR PICKUP":             ShipmentPickupScheduled,
+	"PICKED UP":                  ShipmentInTransit,
+	"SHIPPED":                    ShipmentInTransit,
+	"IN TRANSIT":                 ShipmentInTransit,
+	"REACHED AT DESTINATION HUB": ShipmentInTransit,
+	"OUT FOR DELIVERY":           ShipmentOutForDelivery,
+	"DELIVERED":                  ShipmentDelivered,
+	"RTO INITIATED":              ShipmentReturning,
+	"RTO IN TRANSIT":             ShipmentReturning,
+	"RTO OUT FOR DELIVERY":       ShipmentReturning,
+	"RTO DELIVERED":              ShipmentReturned,
+	"CANCELED":                   ShipmentCancelled,
+	"CANCELLED":                  ShipmentCancelled,
+}
+
+// ParseWebhook implements Carrier
+func (s *ShiprocketCarrier) ParseWebhook(payload []byte) (*TrackingEvent, error) {
+	var hook struct {
+		AWB              json.RawMessage `json:"awb"` // Sent as a number or a string
+		CurrentStatus    string          `json:"current_status"`
+		CurrentTimestamp string          `json:"current_timestamp"`
+	}
+	if err := json.Unmarshal(payload, &hook); err != nil {
+		return nil, fmt.Errorf("failed to parse webhook payload: %w", err)
+	}
+
+	awb := strings.Trim(string(hook.AWB), `"`)
+	if awb == "" || awb == "null" {
+		return nil, fmt.Errorf("webhook payload has no AWB")
+	}
+
+	carrierStatus := strings.ToUpper(strings.TrimSpace(hook.CurrentStatus))
+	event := &TrackingEvent{
+		AWB:           awb,
+		Status:        shiprocketStatuses[carrierStatus],
+		CarrierStatus: carrierStatus,
+		OccurredAt:    time.Now(),
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "02 01 2006 15:04:05"} {
+		if at, err := time.ParseInLocation(layout, hook.CurrentTimestamp, istLocation); err == nil {
+			event.OccurredAt = at
+			break
+		}
+	}
+
+	return event, nil
+}
+
+// authToken returns a cached API token, logging in when it has expired
+func (s *ShiprocketCarrier) authToken(renew bool) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !renew && s.token != "" && time.Now().Before(s.tokenExpiry) {
+		return s.token, nil
+	}
+
+	var login struct {
+		Token string `json:"token"`
+	}
+	if err := s.send("POST", "/auth/login", map[string]string{
+		"email":    s.config.Email,
+		"password": s.config.Password,
+	}, "", &login); err != nil {
+		return "", fmt.Errorf("shiprocket login failed: %w", err)
+	}
+	if login.Token == "" {
+		return "", fmt.Errorf("shiprocket login returned no token")
+	}
+
+	s.token = login.Token
+	s.tokenExpiry = time.Now().Add(shiprocketTokenLifetime)
+	return s.token, nil
+}
+
+// doRequest sends an authenticated JSON request, logging in again once if the
+// token has been revoked
+func (s *ShiprocketCarrier) doRequest(method, path string, body, out interface{}) error {
+	token, err := s.authToken(false)
+	if err != nil {
+		return err
+	}
+
+	err = s.send(method, path, body, token, out)
+	if apiErr, ok := err.(*shiprocketError); ok && apiErr.status == http.StatusUnauthorized {
+		if token, err = s.authToken(true); err != nil {
+			return err
+		}
+		err = s.send(method, path, body, token, out)
+	}
+	return err
+}
+
+// shiprocketError is a non-2xx response from the API
+type shiprocketError struct {
+	status  int
+	message string
+}
+
+func (e *shiprocketError) Error() string {
+	return fmt.Sprintf("shiprocket API error (status %d): %s", e.status, e.message)
+}
+
+// send makes one request to the API and decodes the JSON response into out
+func (s *ShiprocketCarrier) send(method, path string, body interface{}, token string, out interface{}) error {
+	var bodyReader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		bodyReader = bytes.NewReader(data)
+	}
+
+	// Create HTTP request
+	httpReq, err := http.NewRequest(method, s.config.BaseURL+path, bodyReader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Set headers
+	httpReq.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	// Send request
+	resp, err := s.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		json.Unmarshal(respBody, &apiErr)
+		if apiErr.Message == "" {
+			apiErr.Message = string(respBody)
+		}
+		s.logger.Error("Shiprocket API error",
+			zap.String("path", path),
+			zap.Int("status_code", resp.StatusCode),
+			zap.String("message", apiErr.Message),
+		)
+		return &shiprocketError{status: resp.StatusCode, message: apiErr.Message}
+	}
+
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return nil
+}
+
+// rupees converts paise to the rupee amounts the API expects
+func rupees(cents int) float64 {
+	return float64(cents) / 100
+}
@@ -0,0 +1,310 @@
+package stripe
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	StripeAPIURL = "https://api.stripe.com/v1"
+
+	// WebhookTolerance is how old a signed webhook may be before it is rejected
+	WebhookTolerance = 5 * time.Minute
+)
+
+// StripeConfig holds Stripe credentials
+type StripeConfig struct {
+	SecretKey      string
+	PublishableKey string
+	WebhookSecret  string
+	BaseURL        string // Defaults to StripeAPIURL; overridden in tests
+}
+
+// StripeService handles Stripe API interactions
+type StripeService struct {
+	config     StripeConfig
+	httpClient *http.Client
+	logger     *zap.Logger
+}
+
+// NewStripeService creates a new Stripe service
+func NewStripeService(config StripeConfig, logger *zap.Logger) *StripeService {
+	if config.BaseURL == "" {
+		config.BaseURL = StripeAPIURL
+	}
+	config.BaseURL = strings.TrimRight(config.BaseURL, "/")
+
+	return &StripeService{
+		config: config,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		logger: logger,
+	}
+}
+
+// PublishableKey returns the key the frontend uses to confirm payments
+func (s *StripeService) PublishableKey() string {
+	return s.config.PublishableKey
+}
+
+// CreatePaymentIntentRequest represents the request to create a PaymentIntent
+type CreatePaymentIntentRequest struct {
+	Amount         int               // Amount in the smallest currency unit
+	Currency       string            // INR, USD, etc.
+	Metadata       map[string]string // Additional notes
+	IdempotencyKey string            // Retries with the same key return the same intent
+}
+
+// PaymentError describes why the last payment attempt failed
+type PaymentError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Type    string `json:"type"`
+}
+
+// PaymentIntent represents a Stripe PaymentIntent
+type PaymentIntent struct {
+	ID                 string            `json:"id"`
+	Object             string            `json:"object"`
+	Amount             int               `json:"amount"`
+	AmountReceived     int               `json:"amount_received"`
+	Currency           string            `json:"currency"`
+	Status             string            `json:"status"` // requires_payment_method, requires_action, processing, requires_capture, succeeded, canceled
+	ClientSecret       string            `json:"client_secret"`
+	LatestCharge       string            `json:"latest_charge"`
+	LastPaymentError   *PaymentError     `json:"last_payment_error"`
+	PaymentMethodTypes []string          `json:"payment_method_types"`
+	Metadata           map[string]string `json:"metadata"`
+	Created            int64             `json:"created"`
+}
+
+// CreatePaymentIntent creates a PaymentIntent the customer confirms with Stripe.js
+func (s *StripeService) CreatePaymentIntent(req CreatePaymentIntentRequest) (*PaymentIntent, error) {
+	form := url.Values{}
+	form.Set("amount", strconv.Itoa(req.Amount))
+	form.Set("currency", strings.ToLower(req.Currency))
+	form.Set("automatic_payment_methods[enabled]", "true")
+	for k, v := range req.Metadata {
+		form.Set("metadata["+k+"]", v)
+	}
+
+	var intent PaymentIntent
+	if err := s.doRequest("POST", "/payment_intents", form, req.IdempotencyKey, &intent); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Stripe payment intent created",
+		zap.String("payment_intent_id", intent.ID),
+		zap.Int("amount", intent.Amount),
+		zap.String("currency", intent.Currency),
+	)
+
+	return &intent, nil
+}
+
+// FetchPaymentIntent retrieves a PaymentIntent
+func (s *StripeService) FetchPaymentIntent(id string) (*PaymentIntent, error) {
+	var intent PaymentIntent
+	if err := s.doRequest("GET", "/payment_intents/"+url.PathEscape(id), nil, "", &intent); err != nil {
+		return nil, err
+	}
+	return &intent, nil
+}
+
+// CreateRefundRequest represents the request to refund a PaymentIntent
+type CreateRefundRequest struct {
+	PaymentIntent  string
+	Amount         int // Omitted for a full refund
+	Metadata       map[string]string
+	IdempotencyKey string
+}
+
+// Refund represents a Stripe refund
+type Refund struct {
+	ID            string            `json:"id"`
+	Object        string            `json:"object"`
+	Amount        int               `json:"amount"`
+	Currency      string            `json:"currency"`
+	PaymentIntent string            `json:"payment_intent"`
+	Status        string            `json:"status"` // pending, requires_action, succeeded, failed, canceled
+	FailureReason string            `json:"failure_reason,omitempty"`
+	Metadata      map[string]string `json:"metadata"`
+	Created       int64             `json:"created"`
+}
+
+// CreateRefund refunds a PaymentIntent, fully or partially
+func (s *StripeService) CreateRefund(req CreateRefundRequest) (*Refund, error) {
+	form := url.Values{}
+	form.Set("payment_intent", req.PaymentIntent)
+	if req.Amount > 0 {
+		form.Set("amount", strconv.Itoa(req.Amount))
+	}
+	for k, v := range req.Metadata {
+		form.Set("metadata["+k+"]", v)
+	}
+
+	var refund Refund
+	if err := s.doRequest("POST", "/refunds", form, req.IdempotencyKey, &refund); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Stripe refund created",
+		zap.String("refund_id", refund.ID),
+		zap.String("payment_intent_id", req.PaymentIntent),
+		zap.Int("amount", refund.Amount),
+		zap.String("status", refund.Status),
+	)
+
+	return &refund, nil
+}
+
+// APIError is a non-success response from the Stripe API
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("stripe API error: status %d, response: %s", e.StatusCode, e.Body)
+}
+
+// doRequest sends an authenticated, form-encoded request to the Stripe API and
+// decodes the JSON response into out. form may be nil.
+func (s *StripeService) doRequest(method, path string, form url.Values, idempotencyKey string, out interface{}) error {
+	var bodyReader io.Reader
+	if form != nil {
+		bodyReader = strings.NewReader(form.Encode())
+	}
+
+	// Create HTTP request
+	httpReq, err := http.NewRequest(method, s.config.BaseURL+path, bodyReader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Set headers
+	if form != nil {
+		httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if idempotencyKey != "" {
+		httpReq.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+s.config.SecretKey)
+
+	// Send request
+	resp, err := s.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Read response body
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	// Check status code
+	if resp.StatusCode != http.StatusOK {
+		s.logger.Error("Stripe API error",
+			zap.String("path", path),
+			zap.Int("status_code", resp.StatusCode),
+			zap.String("response", string(respBody)),
+		)
+		return &APIError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+
+	// Parse response
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	return nil
+}
+
+// VerifyWebhookSignature verifies the Stripe-Signature header of a webhook.
+// The header carries a timestamp and one or more v1 signatures of
+// "timestamp.payload"; deliveries older than WebhookTolerance are rejected.
+func (s *StripeService) VerifyWebhookSignature(payload []byte, header string) bool {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		s.logger.Warn("Malformed Stripe signature header")
+		return false
+	}
+
+	if age := time.Since(time.Unix(ts, 0)); age > WebhookTolerance || age < -WebhookTolerance {
+		s.logger.Warn("Stripe webhook timestamp outside tolerance",
+			zap.Int64("timestamp", ts),
+		)
+		return false
+	}
+
+	expectedSignature := SignWebhookPayload(payload, ts, s.config.WebhookSecret)
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(expectedSignature), []byte(signature)) {
+			return true
+		}
+	}
+
+	s.logger.Warn("Invalid Stripe webhook signature")
+	return false
+}
+
+// SignWebhookPayload computes the v1 signature Stripe sends for a payload
+func SignWebhookPayload(payload []byte, timestamp int64, secret string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	h.Write([]byte("."))
+	h.Write(payload)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Event represents a Stripe webhook event
+type Event struct {
+	ID      string    `json:"id"`
+	Object  string    `json:"object"`
+	Type    string    `json:"type"`
+	Created int64     `json:"created"`
+	Data    EventData `json:"data"`
+}
+
+// EventData holds the object the event is about, e.g. a PaymentIntent
+type EventData struct {
+	Object json.RawMessage `json:"object"`
+}
+
+// ParseEvent parses a webhook payload
+func ParseEvent(body []byte) (*Event, error) {
+	var event Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, fmt.Errorf("failed to parse webhook payload: %w", err)
+	}
+	return &event, nil
+}
@@ -0,0 +1,292 @@
+package tax
+
+import (
+	"strings"
+)
+
+// GST slabs in basis points (1% = 100)
+var validRates = map[int]bool{0: true, 25: true, 300: true, 500: true, 1200: true, 1800: true, 2800: true}
+
+// ValidRate reports whether rateBps is a GST slab
+func ValidRate(rateBps int) bool {
+	return validRates[rateBps]
+}
+
+// Config describes how prices are taxed
+type Config struct {
+	SellerState      string // State the goods are supplied from
+	PricesIncludeTax bool   // Catalog prices already include GST
+	DefaultRateBps   int    // Used for products without a GST rate
+	DefaultHSN       string // Used for products without an HSN code
+}
+
+// Line is an order line to tax. AmountCents is the line total after any
+// discount, at catalog prices.
+type Line struct {
+	HSNCode     string
+	RateBps     *int
+	AmountCents int
+	Charge      string // Names a fee such as shipping; empty for goods
+}
+
+// LineTax is the tax on one order line. Line is the line's index in the order.
+type LineTax struct {
+	Line         int    `json:"line"`
+	HSNCode      string `json:"hsn_code,omitempty"`
+	RateBps      int    `json:"rate_bps"`
+	TaxableCents int    `json:"taxable_cents"`
+	CGSTCents    int    `json:"cgst_cents"`
+	SGSTCents    int    `json:"sgst_cents"`
+	IGSTCents    int    `json:"igst_cents"`
+	TaxCents     int    `json:"tax_cents"`
+	TotalCents   int    `json:"total_cents"`
+	Charge       string `json:"charge,omitempty"`
+}
+
+// Breakdown is the GST on an order, as stored on the order
+type Breakdown struct {
+	SellerState      string    `json:"seller_state"`
+	PlaceOfSupply    string    `json:"place_of_supply"`
+	Interstate       bool      `json:"interstate"`
+	PricesIncludeTax bool      `json:"prices_include_tax"`
+	TaxableCents     int       `json:"taxable_cents"`
+	CGSTCents        int       `json:"cgst_cents"`
+	SGSTCents        int       `json:"sgst_cents"`
+	IGSTCents        int       `json:"igst_cents"`
+	TaxCents         int       `json:"tax_cents"`
+	TotalCents       int       `json:"total_cents"`
+	Lines            []LineTax `json:"lines"`
+}
+
+// Calculator works out GST for orders
+type Calculator struct {
+	config Config
+}
+
+// NewCalculator creates a GST calculator
+func NewCalculator(config Config) *Calculator {
+	return &Calculator{config: config}
+}
+
+// PricesIncludeTax reports whether catalog prices already include GST
+func (c *Calculator) PricesIncludeTax() bool {
+	return c.config.PricesIncludeTax
+}
+
+// Calculate taxes order lines shipped to placeOfSupply. Supply within the
+// seller's state is split evenly into CGST and SGST, with the odd paisa going
+// to SGST; supply to another state is charged IGST. When prices include tax
+// the line amount is split into taxable value and tax, otherwise tax is added
+// on top. TotalCents is what the customer pays for the lines.
+func (c *Calculator) Calculate(lines []Line, placeOfSupply string) *Breakdown {
+	seller := StateCode(c.config.SellerState)
+	buyer := StateCode(placeOfSupply)
+
+	breakdown := &Breakdown{
+		SellerState:      c.config.SellerState,
+		PlaceOfSupply:    placeOfSupply,
+		Interstate:       seller == "" || buyer == "" || seller != buyer,
+		PricesIncludeTax: c.config.PricesIncludeTax,
+		Lines:            make([]LineTax, 0, len(lines)),
+	}
+
+	for i, line := range lines {
+		lineTax := LineTax{
+			Line:    i,
+			HSNCode: line.HSNCode,
+			RateBps: c.config.DefaultRateBps,
+			Charge:  line.Charge,
+		}
+		if lineTax.HSNCode == "" {
+			lineTax.HSNCode = c.config.DefaultHSN
+		}
+		if line.RateBps != nil {
+			lineTax.RateBps = *line.RateBps
+		}
+
+		if c.config.PricesIncludeTax {
+			lineTax.TaxableCents = divRound(line.AmountCents*10000, 10000+lineTax.RateBps)
+			lineTax.TaxCents = line.AmountCents - lineTax.TaxableCents
+		} else {
+			lineTax.TaxableCents = line.AmountCents
+			lineTax.TaxCents = divRound(line.AmountCents*lineTax.RateBps, 10000)
+		}
+		lineTax.TotalCents = lineTax.TaxableCents + lineTax.TaxCents
+
+		if breakdown.Interstate {
+			lineTax.IGSTCents = lineTax.TaxCents
+		} else {
+			lineTax.CGSTCents = lineTax.TaxCents / 2
+			lineTax.SGSTCents = lineTax.TaxCents - lineTax.CGSTCents
+		}
+
+		breakdown.TaxableCents += lineTax.TaxableCents
+		breakdown.CGSTCents += lineTax.CGSTCents
+		breakdown.SGSTCents += lineTax.SGSTCents
+		breakdown.IGSTCents += lineTax.IGSTCents
+		breakdown.TaxCents += lineTax.TaxCents
+		breakdown.TotalCents += lineTax.TotalCents
+		breakdown.Lines = append(breakdown.Lines, lineTax)
+	}
+
+	return breakdown
+}
+
+// ChargeLine returns a line taxing a fee billed with the goods, such as
+// shipping. A fee is part of the same supply as the goods, so it takes the
+// highest rate among them and that line's HSN code.
+func (c *Calculator) ChargeLine(goods []Line, charge string, amountCents int) Line {
+	rateBps := c.config.DefaultRateBps
+	line := Line{HSNCode: c.config.DefaultHSN, AmountCents: amountCents, Charge: charge}
+	for i, good := range goods {
+		rate := c.config.DefaultRateBps
+		if good.RateBps != nil {
+			rate = *good.RateBps
+		}
+		if i == 0 || rate > rateBps {
+			rateBps = rate
+			line.HSNCode = good.HSNCode
+		}
+	}
+	line.RateBps = &rateBps
+	return line
+}
+
+// HasCharge reports whether the breakdown taxes the named fee
+func (b *Breakdown) HasCharge(charge string) bool {
+	for _, line := range b.Lines {
+		if line.Charge == charge {
+			return true
+		}
+	}
+	return false
+}
+
+// Scale returns the part of the breakdown covering amountCents of its total,
+// as used for credit notes on partial refunds. The amount is split across
+// lines in proportion to their totals and each line keeps its own ratio of
+// taxable value to tax. Amounts above TotalCents are capped.
+func (b *Breakdown) Scale(amountCents int) *Breakdown {
+	if amountCents > b.TotalCents {
+		amountCents = b.TotalCents
+	}
+
+	scaled := &Breakdown{
+		SellerState:      b.SellerState,
+		PlaceOfSupply:    b.PlaceOfSupply,
+		Interstate:       b.Interstate,
+		PricesIncludeTax: b.PricesIncludeTax,
+		Lines:            make([]LineTax, 0, len(b.Lines)),
+	}
+	if amountCents <= 0 || b.TotalCents == 0 {
+		return scaled
+	}
+
+	// Split in proportion to line totals, then hand out the rounding
+	// remainder a paisa at a time to lines that still have room
+	shares := make([]int, len(b.Lines))
+	remaining := amountCents
+	for i, line := range b.Lines {
+		shares[i] = amountCents * line.TotalCents / b.TotalCents
+		remaining -= shares[i]
+	}
+	for i := 0; remaining > 0; i = (i + 1) % len(b.Lines) {
+		if shares[i] < b.Lines[i].TotalCents {
+			shares[i]++
+			remaining--
+		}
+	}
+
+	for i, line := range b.Lines {
+		if shares[i] == 0 {
+			continue
+		}
+		lineTax := LineTax{
+			Line:         line.Line,
+			HSNCode:      line.HSNCode,
+			RateBps:      line.RateBps,
+			TaxableCents: divRound(shares[i]*line.TaxableCents, line.TotalCents),
+			TotalCents:   shares[i],
+			Charge:       line.Charge,
+		}
+		lineTax.TaxCents = lineTax.TotalCents - lineTax.TaxableCents
+		if scaled.Interstate {
+			lineTax.IGSTCents = lineTax.TaxCents
+		} else {
+			lineTax.CGSTCents = lineTax.TaxCents / 2
+			lineTax.SGSTCents = lineTax.TaxCents - lineTax.CGSTCents
+		}
+
+		scaled.TaxableCents += lineTax.TaxableCents
+		scaled.CGSTCents += lineTax.CGSTCents
+		scaled.SGSTCents += lineTax.SGSTCents
+		scaled.IGSTCents += lineTax.IGSTCents
+		scaled.TaxCents += lineTax.TaxCents
+		scaled.TotalCents += lineTax.TotalCents
+		scaled.Lines = append(scaled.Lines, lineTax)
+	}
+
+	return scaled
+}
+
+// divRound divides non-negative integers, rounding half up
+func divRound(n, d int) int {
+	return (2*n + d) / (2 * d)
+}
+
+// stateCodes maps state and union territory names and their usual
+// abbreviations to GST state codes
+var stateCodes = map[string]string{
+	"jammu and kashmir": "01", "jk": "01",
+	"himachal pradesh": "02", "hp": "02",
+	"punjab": "03", "pb": "03",
+	"chandigarh": "04", "ch": "04",
+	"uttarakhand": "05", "uk": "05", "uttaranchal": "05",
+	"haryana": "06", "hr": "06",
+	"delhi": "07", "dl": "07", "new delhi": "07",
+	"rajasthan": "08", "rj": "08",
+	"uttar pradesh": "09", "up": "09",
+	"bihar": "10", "br": "10",
+	"sikkim": "11", "sk": "11",
+	"arunachal pradesh": "12", "ar": "12",
+	"nagaland": "13", "nl": "13",
+	"manipur": "14", "mn": "14",
+	"mizoram": "15", "mz": "15",
+	"tripura": "16", "tr": "16",
+	"meghalaya": "17", "ml": "17",
+	"assam": "18", "as": "18",
+	"west bengal": "19", "wb": "19",
+	"jharkhand": "20", "jh": "20",
+	"odisha": "21", "od": "21", "orissa": "21",
+	"chhattisgarh": "22", "cg": "22",
+	"madhya pradesh": "23", "mp": "23",
+	"gujarat": "24", "gj": "24",
+	"dadra and nagar haveli and daman and diu": "26", "dn": "26", "dd": "26",
+	"maharashtra": "27", "mh": "27",
+	"karnataka": "29", "ka": "29",
+	"goa": "30", "ga": "30",
+	"lakshadweep": "31", "ld": "31",
+	"kerala": "32", "kl": "32",
+	"tamil nadu": "33", "tn": "33",
+	"puducherry": "34", "py": "34", "pondicherry": "34",
+	"andaman and nicobar islands": "35", "an": "35",
+	"telangana": "36", "ts": "36", "tg": "36",
+	"andhra pradesh": "37", "ap": "37",
+	"ladakh": "38", "la": "38",
+}
+
+// StateCode returns the GST state code for a state name, abbreviation or
+// code, or "" if the state is not recognised
+func StateCode(state string) string {
+	key := strings.ToLower(strings.Join(strings.Fields(state), " "))
+	key = strings.ReplaceAll(key, "&", "and")
+	if code, ok := stateCodes[key]; ok {
+		return code
+	}
+	for _, code := range stateCodes {
+		if code == key {
+			return code
+		}
+	}
+	return ""
+}
@@ -0,0 +1,128 @@
+package tax
+
+import "testing"
+
+func ratePtr(bps int) *int { return &bps }
+
+func TestCalculateInclusive(t *testing.T) {
+	calc := NewCalculator(Config{SellerState: "Karnataka", PricesIncludeTax: true, DefaultRateBps: 500, DefaultHSN: "5208"})
+	lines := []Line{
+		{HSNCode: "5007", RateBps: ratePtr(500), AmountCents: 105000},
+		{RateBps: ratePtr(1200), AmountCents: 11200},
+		{AmountCents: 999},
+	}
+
+	// Shipping within Karnataka splits the tax into CGST and SGST
+	local := calc.Calculate(lines, "KA")
+	if local.Interstate {
+		t.Fatal("Expected intrastate supply")
+	}
+
+	want := []LineTax{
+		{Line: 0, HSNCode: "5007", RateBps: 500, TaxableCents: 100000, CGSTCents: 2500, SGSTCents: 2500, TaxCents: 5000, TotalCents: 105000},
+		{Line: 1, HSNCode: "5208", RateBps: 1200, TaxableCents: 10000, CGSTCents: 600, SGSTCents: 600, TaxCents: 1200, TotalCents: 11200},
+		{Line: 2, HSNCode: "5208", RateBps: 500, TaxableCents: 951, CGSTCents: 24, SGSTCents: 24, TaxCents: 48, TotalCents: 999},
+	}
+	for i, line := range local.Lines {
+		if line != want[i] {
+			t.Errorf("Line %d = %+v, want %+v", i, line, want[i])
+		}
+	}
+	if local.TotalCents != 117199 || local.TaxCents != 6248 || local.TaxableCents != 110951 {
+		t.Errorf("Unexpected totals: %+v", *local)
+	}
+
+	// Shipping to another state charges IGST on the same amounts
+	interstate := calc.Calculate(lines, "Tamil Nadu")
+	if !interstate.Interstate || interstate.IGSTCents != 6248 || interstate.CGSTCents != 0 || interstate.SGSTCents != 0 {
+		t.Errorf("Unexpected interstate breakdown: %+v", *interstate)
+	}
+}
+
+func TestCalculateExclusive(t *testing.T) {
+	calc := NewCalculator(Config{SellerState: "29", DefaultRateBps: 500})
+
+	breakdown := calc.Calculate([]Line{{RateBps: ratePtr(500), AmountCents: 12345}}, " karnataka ")
+	line := breakdown.Lines[0]
+	if line.TaxableCents != 12345 || line.TaxCents != 617 || line.TotalCents != 12962 {
+		t.Errorf("Unexpected line: %+v", line)
+	}
+	// The odd paisa goes to SGST
+	if line.CGSTCents != 308 || line.SGSTCents != 309 {
+		t.Errorf("Unexpected split: CGST %d, SGST %d", line.CGSTCents, line.SGSTCents)
+	}
+}
+
+func TestChargeLine(t *testing.T) {
+	calc := NewCalculator(Config{SellerState: "KA", DefaultRateBps: 500, DefaultHSN: "5208"})
+	goods := []Line{
+		{HSNCode: "5007", RateBps: ratePtr(500), AmountCents: 105000},
+		{HSNCode: "7117", RateBps: ratePtr(1200), AmountCents: 11200},
+		{AmountCents: 999},
+	}
+
+	// Shipping follows the goods line with the highest rate
+	shipping := calc.ChargeLine(goods, "shipping", 6900)
+	if shipping.HSNCode != "7117" || *shipping.RateBps != 1200 || shipping.AmountCents != 6900 {
+		t.Errorf("Unexpected charge line: %+v", shipping)
+	}
+
+	breakdown := calc.Calculate(append(goods, shipping), "KA")
+	line := breakdown.Lines[3]
+	if line.Charge != "shipping" || line.TaxCents != 828 || line.TotalCents != 7728 {
+		t.Errorf("Unexpected shipping tax: %+v", line)
+	}
+	if !breakdown.HasCharge("shipping") || breakdown.HasCharge("cod_fee") {
+		t.Error("Expected only shipping to be charged")
+	}
+
+	// Products without a rate fall back to the default
+	if fee := calc.ChargeLine([]Line{{AmountCents: 100}}, "cod_fee", 4900); *fee.RateBps != 500 || fee.HSNCode != "" {
+		t.Errorf("Unexpected default charge line: %+v", fee)
+	}
+}
+
+func TestBreakdownScale(t *testing.T) {
+	calc := NewCalculator(Config{SellerState: "KA", PricesIncludeTax: true, DefaultRateBps: 500})
+	full := calc.Calculate([]Line{{AmountCents: 105000}, {RateBps: ratePtr(1200), AmountCents: 11200}}, "KA")
+
+	// Refunding the whole order reverses every line exactly
+	all := full.Scale(200000)
+	if all.TotalCents != full.TotalCents || all.TaxCents != full.TaxCents || all.TaxableCents != full.TaxableCents {
+		t.Errorf("Full scale changed totals: %+v", *all)
+	}
+
+	half := full.Scale(58100)
+	if half.TotalCents != 58100 {
+		t.Fatalf("Scaled total = %d, want 58100", half.TotalCents)
+	}
+	if half.Lines[0].TotalCents != 52500 || half.Lines[0].TaxableCents != 50000 || half.Lines[0].TaxCents != 2500 {
+		t.Errorf("Unexpected first line: %+v", half.Lines[0])
+	}
+	if half.Lines[1].TotalCents != 5600 || half.Lines[1].TaxableCents != 5000 || half.Lines[1].TaxCents != 600 {
+		t.Errorf("Unexpected second line: %+v", half.Lines[1])
+	}
+	if half.CGSTCents+half.SGSTCents != half.TaxCents || half.IGSTCents != 0 {
+		t.Errorf("Unexpected split: %+v", *half)
+	}
+
+	if none := full.Scale(0); len(none.Lines) != 0 || none.TotalCents != 0 {
+		t.Errorf("Expected empty breakdown, got %+v", *none)
+	}
+}
+
+func TestStateCode(t *testing.T) {
+	tests := map[string]string{
+		"Karnataka":        "29",
+		"KA":               "29",
+		"29":               "29",
+		"Jammu & Kashmir":  "01",
+		"  tamil   nadu  ": "33",
+		"Atlantis":         "",
+	}
+	for state, want := range tests {
+		if got := StateCode(state); got != want {
+			t.Errorf("StateCode(%q) = %q, want %q", state, got, want)
+		}
+	}
+}
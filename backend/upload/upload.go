@@ -228,6 +228,80 @@ func sanitizeExtension(ext string) string {
 	return ext
 }
 
+// SaveBytes writes generated content, such as an invoice PDF, to a relative
+// path. An existing file at the path is replaced.
+func (s *UploadService) SaveBytes(relativePath string, data []byte) (*UploadResult, error) {
+	fullPath, err := s.resolvePath(relativePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// Write to a temporary file first so readers never see a partial file
+	tmpPath := fullPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("failed to save file: %w", err)
+	}
+	if err := os.Rename(tmpPath, fullPath); err != nil {
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("failed to save file: %w", err)
+	}
+
+	s.logger.Info("File saved successfully",
+		zap.String("path", relativePath),
+		zap.Int("size", len(data)),
+	)
+
+	return &UploadResult{
+		Path:     relativePath,
+		FullPath: fullPath,
+		Filename: filepath.Base(relativePath),
+		Size:     int64(len(data)),
+	}, nil
+}
+
+// ReadFile reads a stored file
+func (s *UploadService) ReadFile(relativePath string) ([]byte, error) {
+	fullPath, err := s.resolvePath(relativePath)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("file not found")
+		}
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	return data, nil
+}
+
+// resolvePath returns the absolute path of a relative path, rejecting paths
+// that escape the upload directory
+func (s *UploadService) resolvePath(relativePath string) (string, error) {
+	absPath, err := filepath.Abs(filepath.Join(s.uploadDir, relativePath))
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	absUploadDir, err := filepath.Abs(s.uploadDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to get upload directory path: %w", err)
+	}
+
+	if !strings.HasPrefix(absPath, absUploadDir+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid path: outside upload directory")
+	}
+
+	return absPath, nil
+}
+
 // DeleteFile deletes an uploaded file
 func (s *UploadService) DeleteFile(relativePath string) error {
 	fullPath := filepath.Join(s.uploadDir, relativePath)
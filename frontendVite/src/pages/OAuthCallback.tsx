@@ -19,12 +19,29 @@ const OAuthCallback: React.FC = () => {
         // eslint-disable-next-line react-hooks/exhaustive-deps
     }, []);
 
+    // Tokens arrive in the URL fragment so they never reach server logs.
+    // Read it once, then drop it from the address bar and history.
+    const [fragment] = useState(
+        () => new URLSearchParams(window.location.hash.slice(1))
+    );
+
+    useEffect(() => {
+        if (window.location.hash) {
+            window.history.replaceState(
+                null,
+                "",
+                window.location.pathname + window.location.search
+            );
+        }
+    }, []);
+
     const handleCallback = async () => {
-        const accessToken = searchParams.get("access_token");
-        const userId = searchParams.get("user_id");
-        const userName = searchParams.get("user_name");
-        const userEmail = searchParams.get("user_email");
-        const userRole = searchParams.get("user_role");
+        const accessToken = fragment.get("access_token");
+        const refreshToken = fragment.get("refresh_token");
+        const userId = fragment.get("user_id");
+        const userName = fragment.get("user_name");
+        const userEmail = fragment.get("user_email");
+        const userRole = fragment.get("user_role");
         const error = searchParams.get("error");
 
         if (error) {
@@ -51,6 +68,9 @@ const OAuthCallback: React.FC = () => {
             };
 
             localStorage.setItem("access_token", accessToken);
+            if (refreshToken) {
+                localStorage.setItem("refresh_token", refreshToken);
+            }
             localStorage.setItem("user", JSON.stringify(user));
 
             setStatus("success");